@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sso/internal/lib/identity"
+	"strings"
+)
+
+// normalizeEmails converts the domains of existing emails to the IDNA ASCII
+// form that identity.NormalizeEmail gives new ones, which migration 6 could
+// not do in SQL. Emails that would then equal the one of another active user
+// are reported and nothing is changed, such accounts have to be merged by
+// hand first.
+func normalizeEmails(ctx context.Context, log *slog.Logger, db *sql.DB) error {
+	const op = "Migrator.NormalizeEmails"
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	defer tx.Rollback()
+
+	// only emails with non-ASCII characters can change, the others were
+	// lowercased by migration 6
+	rows, err := tx.QueryContext(ctx, "SELECT id, email, deleted_at IS NOT NULL FROM users WHERE email !~ '^[[:ascii:]]*$' ORDER BY id FOR UPDATE")
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	normalized := map[int64]string{}
+	deleted := map[int64]bool{}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var email string
+		var isDeleted bool
+		if err := rows.Scan(&id, &email, &isDeleted); err != nil {
+			rows.Close()
+			return fmt.Errorf("%s:%w", op, err)
+		}
+		converted, err := identity.NormalizeEmail(email)
+		if err != nil {
+			log.Warn("email left as is, it is not a valid address", slog.Int64("userId", id), slog.String("email", email))
+			continue
+		}
+		if converted != email {
+			normalized[id] = converted
+			deleted[id] = isDeleted
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	// soft deleted users may share an email, the unique index skips them
+	owners := map[string][]int64{}
+	for _, id := range ids {
+		if !deleted[id] {
+			owners[normalized[id]] = append(owners[normalized[id]], id)
+		}
+	}
+	for email := range owners {
+		var existing []int64
+		rows, err := tx.QueryContext(ctx, "SELECT id FROM users WHERE lower(email) = $1 AND deleted_at IS NULL", email)
+		if err != nil {
+			return fmt.Errorf("%s:%w", op, err)
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return fmt.Errorf("%s:%w", op, err)
+			}
+			existing = append(existing, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("%s:%w", op, err)
+		}
+		owners[email] = append(owners[email], existing...)
+	}
+
+	var collisions []string
+	for email, userIds := range owners {
+		if len(userIds) < 2 {
+			continue
+		}
+		sort.Slice(userIds, func(i, j int) bool { return userIds[i] < userIds[j] })
+		list := make([]string, 0, len(userIds))
+		for _, id := range userIds {
+			list = append(list, fmt.Sprint(id))
+		}
+		collisions = append(collisions, fmt.Sprintf("%s (user ids %s)", email, strings.Join(list, ", ")))
+	}
+	if collisions != nil {
+		sort.Strings(collisions)
+		return fmt.Errorf("%s:users with equal IDNA emails must be merged first: %s", op, strings.Join(collisions, "; "))
+	}
+
+	for _, id := range ids {
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET email = $1 WHERE id = $2", normalized[id], id); err != nil {
+			return fmt.Errorf("%s:%w", op, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	log.Info("emails converted to IDNA ASCII", slog.Int("count", len(ids)))
+	return nil
+}
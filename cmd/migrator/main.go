@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"flag"
 	"fmt"
@@ -68,11 +70,22 @@ func main() {
 	if mode == UpOnly || mode == UpAndDown {
 		if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
 			log.Error("failed to run up migration", slog.String("op", op), sl.Err(err))
+		} else if err := runNormalizeEmails(log, cfg.StoragePath); err != nil {
+			log.Error("failed to normalize emails", slog.String("op", op), sl.Err(err))
 		}
 	}
 
 }
 
+func runNormalizeEmails(log *slog.Logger, storagePath string) error {
+	db, err := sql.Open("pgx", storagePath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return normalizeEmails(context.Background(), log, db)
+}
+
 func setupLogger(env string) *slog.Logger {
 	var log *slog.Logger
 
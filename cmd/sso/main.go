@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"log/slog"
 	"os"
 	"os/signal"
 	"sso/internal/app"
 	"sso/internal/config"
+	"sso/internal/lib/lifecycle"
 	"sso/internal/lib/logger/handlers/slogpretty"
+	"sso/internal/lib/logger/sl"
 	"syscall"
 )
 
@@ -17,6 +21,13 @@ const (
 	envProd  = "prod"
 )
 
+// exit codes, so supervisors can tell a broken setup from a failure at run
+// time
+const (
+	exitFailed      = 1
+	exitStartFailed = 2
+)
+
 func main() {
 	//Переводим флаги в переменные окружения
 	MustSetupEnvVars()
@@ -29,20 +40,31 @@ func main() {
 	log = log.With(slog.String("env", cfg.Env))
 
 	//TODO: инициализация приложения (app)
-	application := app.NewApp(log, cfg)
-	go application.GRPCServer.MustRun()
-	log.Info("application started")
-	//TODO: запустить gRPC-сервер приложения
+	application, err := app.NewApp(log, cfg)
+	if err != nil {
+		log.Error("failed to initialize application", sl.Err(err))
+		os.Exit(exitStartFailed)
+	}
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sign := <-stop
+		log.Info("stopping application", slog.String("signal", sign.String()))
+		cancel()
+	}()
 
-	sign := <-stop
-
-	log.Info("stopping application", slog.String("signal", sign.String()))
-	application.GRPCServer.Stop()
+	//TODO: запустить gRPC-сервер приложения
+	log.Info("application starting")
+	if err := application.Run(ctx); err != nil {
+		log.Error("application stopped with an error", sl.Err(err))
+		if errors.Is(err, lifecycle.ErrStart) {
+			os.Exit(exitStartFailed)
+		}
+		os.Exit(exitFailed)
+	}
 	log.Info("application stopped")
-
 }
 
 func setupLogger(env string) *slog.Logger {
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sso/internal/domain/models"
+	appsservice "sso/internal/services/apps"
+	"strings"
+)
+
+func init() {
+	register("create-app", "create an app and print its secret (shown only once)", createApp)
+	register("get-app", "show app metadata", getApp)
+	register("list-apps", "list apps page by page", listApps)
+	register("update-app", "change app metadata", updateApp)
+	register("rotate-app-secret", "generate a new app secret", rotateAppSecret)
+	register("delete-app", "delete an app", deleteApp)
+}
+
+func appsService(env *environment) *appsservice.Apps {
+	return appsservice.NewAppsService(env.log, env.storage, env.storage, env.storage, env.storage)
+}
+
+func createApp(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("create-app", flag.ExitOnError)
+	name := fs.String("name", "", "app name")
+	redirectURIs := fs.String("redirect-uris", "", "comma separated allowed redirect URIs")
+	tokenTTL := fs.Duration("token-ttl", 0, "token TTL override, 0 uses the global token_ttl")
+	disabled := fs.Bool("disabled", false, "create the app disabled")
+	claimRules := fs.String("claim-rules", "", claimRulesUsage)
+	idleTimeout := fs.Duration("idle-timeout", 0, idleTimeoutUsage)
+	sessionLifetime := fs.Duration("session-lifetime", 0, sessionLifetimeUsage)
+	maxSessions := fs.Int("max-sessions", 0, maxSessionsUsage)
+	sessionLimitAction := fs.String("session-limit-action", models.SessionLimitEvictOldest, sessionLimitActionUsage)
+	stepUpAfter := fs.Duration("step-up-after", 0, stepUpAfterUsage)
+	_ = fs.Parse(args)
+
+	rules, err := parseClaimRules(*claimRules)
+	if err != nil {
+		return nil, err
+	}
+
+	return appsService(env).CreateApp(env.ctx, models.App{
+		Name:         *name,
+		RedirectURIs: splitList(*redirectURIs),
+		TokenTTL:     *tokenTTL,
+		Enabled:      !*disabled,
+		ClaimRules:   rules,
+		SessionPolicy: models.SessionPolicy{
+			IdleTimeout: *idleTimeout,
+			Lifetime:    *sessionLifetime,
+			MaxSessions: *maxSessions,
+			LimitAction: *sessionLimitAction,
+			StepUpAfter: *stepUpAfter,
+		},
+	})
+}
+
+func getApp(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("get-app", flag.ExitOnError)
+	appId := fs.Int64("id", 0, "app id")
+	_ = fs.Parse(args)
+
+	return appsService(env).GetApp(env.ctx, *appId)
+}
+
+func listApps(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("list-apps", flag.ExitOnError)
+	pageToken := fs.String("page-token", "", "next_page_token of the previous page")
+	pageSize := fs.Int("page-size", 0, "number of apps per page")
+	_ = fs.Parse(args)
+
+	apps, next, err := appsService(env).ListApps(env.ctx, *pageToken, *pageSize)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"apps": apps, "next_page_token": next}, nil
+}
+
+func updateApp(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("update-app", flag.ExitOnError)
+	appId := fs.Int64("id", 0, "app id")
+	name := fs.String("name", "", "new app name")
+	redirectURIs := fs.String("redirect-uris", "", "comma separated allowed redirect URIs")
+	tokenTTL := fs.Duration("token-ttl", 0, "token TTL override, 0 uses the global token_ttl")
+	enabled := fs.Bool("enabled", true, "whether users can log in to the app")
+	claimRules := fs.String("claim-rules", "", claimRulesUsage)
+	idleTimeout := fs.Duration("idle-timeout", 0, idleTimeoutUsage)
+	sessionLifetime := fs.Duration("session-lifetime", 0, sessionLifetimeUsage)
+	maxSessions := fs.Int("max-sessions", 0, maxSessionsUsage)
+	sessionLimitAction := fs.String("session-limit-action", models.SessionLimitEvictOldest, sessionLimitActionUsage)
+	stepUpAfter := fs.Duration("step-up-after", 0, stepUpAfterUsage)
+	_ = fs.Parse(args)
+
+	rules, err := parseClaimRules(*claimRules)
+	if err != nil {
+		return nil, err
+	}
+
+	// only flags given on the command line are applied
+	var update models.AppUpdate
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			update.Name = name
+		case "redirect-uris":
+			uris := splitList(*redirectURIs)
+			update.RedirectURIs = &uris
+		case "token-ttl":
+			update.TokenTTL = tokenTTL
+		case "enabled":
+			update.Enabled = enabled
+		case "claim-rules":
+			update.ClaimRules = &rules
+		case "idle-timeout":
+			update.IdleTimeout = idleTimeout
+		case "session-lifetime":
+			update.SessionLifetime = sessionLifetime
+		case "max-sessions":
+			update.MaxSessions = maxSessions
+		case "session-limit-action":
+			update.SessionLimitAction = sessionLimitAction
+		case "step-up-after":
+			update.StepUpAfter = stepUpAfter
+		}
+	})
+
+	return appsService(env).UpdateApp(env.ctx, *appId, update)
+}
+
+func rotateAppSecret(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("rotate-app-secret", flag.ExitOnError)
+	appId := fs.Int64("id", 0, "app id")
+	_ = fs.Parse(args)
+
+	secret, err := appsService(env).RotateAppSecret(env.ctx, *appId)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"id": *appId, "secret": secret}, nil
+}
+
+func deleteApp(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("delete-app", flag.ExitOnError)
+	appId := fs.Int64("id", 0, "app id")
+	_ = fs.Parse(args)
+
+	if err := appsService(env).DeleteApp(env.ctx, *appId); err != nil {
+		return nil, err
+	}
+	return map[string]any{"id": *appId, "deleted": true}, nil
+}
+
+const claimRulesUsage = "comma separated claim=attribute pairs, e.g. name=display_name,plan=metadata.plan"
+
+const (
+	idleTimeoutUsage        = "revoke sessions unused for this long, 0 disables"
+	sessionLifetimeUsage    = "end sessions this long after login regardless of refreshes, 0 disables"
+	maxSessionsUsage        = "maximum active sessions per user, 0 is unlimited"
+	sessionLimitActionUsage = "what login does at the session limit: evict_oldest or deny"
+	stepUpAfterUsage        = "require the password again this long after it was last entered, 0 disables"
+)
+
+func parseClaimRules(value string) ([]models.ClaimRule, error) {
+	var rules []models.ClaimRule
+	for _, pair := range splitList(value) {
+		claim, attribute, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid claim rule %q, expected claim=attribute", pair)
+		}
+		rules = append(rules, models.ClaimRule{Claim: strings.TrimSpace(claim), Attribute: strings.TrimSpace(attribute)})
+	}
+	return rules, nil
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"sso/internal/domain/models"
+	auditservice "sso/internal/services/audit"
+)
+
+func init() {
+	register("list-audit-events", "list and filter audit events page by page", listAuditEvents)
+	register("verify-audit", "check the audit log hash chain for tampering", verifyAudit)
+}
+
+func auditService(env *environment) *auditservice.Audit {
+	return auditservice.NewAuditService(env.log, env.storage, env.storage)
+}
+
+func listAuditEvents(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("list-audit-events", flag.ExitOnError)
+	action := fs.String("action", "", "only events of the action, e.g. login")
+	actorId := fs.Int64("actor-id", 0, "only events performed by the user")
+	subjectId := fs.Int64("subject-id", 0, "only events concerning the user")
+	appId := fs.Int64("app-id", 0, "only events concerning the app")
+	outcome := fs.String("outcome", "", "only success or failure events")
+	after := fs.String("after", "", "only events at or after the RFC 3339 time")
+	before := fs.String("before", "", "only events before the RFC 3339 time")
+	pageToken := fs.String("page-token", "", "next_page_token of the previous page")
+	pageSize := fs.Int("page-size", 0, "number of events per page")
+	_ = fs.Parse(args)
+
+	filter := models.AuditFilter{
+		Action:    *action,
+		ActorId:   *actorId,
+		SubjectId: *subjectId,
+		AppId:     *appId,
+		Outcome:   *outcome,
+	}
+	var err error
+	if filter.After, err = parseTime(*after); err != nil {
+		return nil, err
+	}
+	if filter.Before, err = parseTime(*before); err != nil {
+		return nil, err
+	}
+
+	events, next, err := auditService(env).ListAuditEvents(env.ctx, filter, *pageToken, *pageSize)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"events": events, "next_page_token": next}, nil
+}
+
+func verifyAudit(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("verify-audit", flag.ExitOnError)
+	anchorId := fs.Int64("anchor-id", 0, "id of an event whose hash was recorded outside the database")
+	anchorHash := fs.String("anchor-hash", "", "hex hash recorded for the anchor event, e.g. a logged audit chain head")
+	_ = fs.Parse(args)
+
+	var anchor *models.AuditAnchor
+	if *anchorId != 0 || *anchorHash != "" {
+		hash, err := hex.DecodeString(*anchorHash)
+		if *anchorId == 0 || len(hash) == 0 || err != nil {
+			return nil, errors.New("-anchor-id and a hex -anchor-hash must be given together")
+		}
+		anchor = &models.AuditAnchor{Id: *anchorId, Hash: hash}
+	}
+
+	return auditService(env).VerifyChain(env.ctx, anchor)
+}
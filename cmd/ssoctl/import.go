@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sso/internal/domain/models"
+	"sso/internal/lib/userimport"
+	"strings"
+)
+
+func init() {
+	register("import-users", "import users with legacy password hashes from a csv or jsonl file", importUsers)
+}
+
+func importUsers(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("import-users", flag.ExitOnError)
+	path := fs.String("file", "", "csv or jsonl file, - reads stdin")
+	format := fs.String("format", "", "csv or jsonl, detected from the file extension by default")
+	dryRun := fs.Bool("dry-run", false, "only validate the records")
+	batchSize := fs.Int("batch-size", 1000, "number of records imported per batch")
+	_ = fs.Parse(args)
+
+	if *path == "" {
+		return nil, errors.New("file must be provided")
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(*path), ".")
+	}
+
+	var input io.Reader = os.Stdin
+	if *path != "-" {
+		file, err := os.Open(*path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	reader, err := userimport.NewReader(input, *format)
+	if err != nil {
+		return nil, err
+	}
+
+	users := usersService(env)
+	var result models.ImportResult
+	batch := make([]models.ImportedUser, 0, *batchSize)
+	flush := func() error {
+		batchResult, err := users.ImportUsers(env.ctx, batch, *dryRun)
+		result.Add(batchResult)
+		batch = batch[:0]
+		return err
+	}
+
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to read %s: %w", *path, err)
+		}
+
+		batch = append(batch, record)
+		if len(batch) == *batchSize {
+			if err := flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sso/internal/config"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/requestinfo"
+	psql "sso/internal/storage/postgreSQL"
+)
+
+// command is an administrative ssoctl subcommand. run parses its own flags
+// from args and returns the value printed to stdout as JSON.
+type command struct {
+	usage string
+	run   func(env *environment, args []string) (any, error)
+}
+
+type environment struct {
+	ctx     context.Context
+	log     *slog.Logger
+	cfg     *config.Config
+	storage *psql.Storage
+}
+
+var commands = map[string]command{}
+
+func register(name string, usage string, run func(env *environment, args []string) (any, error)) {
+	commands[name] = command{usage: usage, run: run}
+}
+
+func main() {
+	//Переводим флаги в переменные окружения
+	MustSetupEnvVars()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		usage()
+		os.Exit(2)
+	}
+
+	cfg := config.MustLoad()
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	log = log.With(slog.String("env", cfg.Env), slog.String("command", args[0]))
+
+	storage, err := psql.New(cfg)
+	if err != nil {
+		log.Error("failed to init storage", sl.Err(err))
+		os.Exit(1)
+	}
+
+	// audit events recorded by commands are attributed to ssoctl
+	ctx := requestinfo.NewContext(context.Background(), requestinfo.Info{UserAgent: "ssoctl"})
+
+	result, err := cmd.run(&environment{ctx: ctx, log: log, cfg: cfg, storage: storage}, args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+
+	if result != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			log.Error("failed to write result", sl.Err(err))
+			os.Exit(1)
+		}
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: ssoctl [-config path] <command> [flags]\n\ncommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", name, commands[name].usage)
+	}
+}
+
+func MustSetupEnvVars() {
+	var configPath string
+	flag.StringVar(&configPath, "config", "", "Path to config file")
+	flag.Usage = usage
+	flag.Parse()
+	if configPath != "" {
+		err := os.Setenv("CONFIG_PATH", configPath)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
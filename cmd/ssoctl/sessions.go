@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	sessionsservice "sso/internal/services/sessions"
+)
+
+func init() {
+	register("list-sessions", "list active sessions of a user", listSessions)
+	register("get-session", "show a session, including revoked and expired ones", getSession)
+	register("revoke-session", "revoke a session, its tokens are rejected from now on", revokeSession)
+	register("revoke-all-sessions", "revoke every active session of a user", revokeAllSessions)
+}
+
+func sessionsService(env *environment) *sessionsservice.Sessions {
+	return sessionsservice.NewSessionsService(env.log, env.storage, env.storage, auditService(env))
+}
+
+func listSessions(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("list-sessions", flag.ExitOnError)
+	userId := fs.Int64("user-id", 0, "user id")
+	_ = fs.Parse(args)
+
+	return sessionsService(env).ListSessions(env.ctx, *userId)
+}
+
+func getSession(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("get-session", flag.ExitOnError)
+	sessionId := fs.String("id", "", "session id")
+	_ = fs.Parse(args)
+
+	return sessionsService(env).GetSession(env.ctx, *sessionId)
+}
+
+func revokeSession(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("revoke-session", flag.ExitOnError)
+	sessionId := fs.String("id", "", "session id")
+	_ = fs.Parse(args)
+
+	if err := sessionsService(env).RevokeSession(env.ctx, *sessionId); err != nil {
+		return nil, err
+	}
+	return map[string]any{"id": *sessionId, "ok": true}, nil
+}
+
+func revokeAllSessions(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("revoke-all-sessions", flag.ExitOnError)
+	userId := fs.Int64("user-id", 0, "user id")
+	_ = fs.Parse(args)
+
+	revoked, err := sessionsService(env).RevokeAllSessions(env.ctx, *userId)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"user_id": *userId, "revoked": revoked}, nil
+}
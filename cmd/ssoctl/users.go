@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"sso/internal/domain/models"
+	usersservice "sso/internal/services/users"
+	"strconv"
+	"time"
+)
+
+func init() {
+	register("get-user", "show a user", getUser)
+	register("list-users", "list and search users page by page", listUsers)
+	register("update-user", "change user email, username, phone or admin flag", updateUser)
+	register("disable-user", "block a user and invalidate their tokens", disableUser)
+	register("enable-user", "unblock a disabled user", enableUser)
+	register("delete-user", "soft delete a user, purged after users.purge_after", deleteUser)
+	register("export-user", "export everything stored about a user as JSON", exportUser)
+	register("erase-user", "anonymize a user and delete their personal data, cannot be undone", eraseUser)
+	register("login-history", "show login attempts of a user, newest first", loginHistory)
+	register("list-devices", "list devices a user has logged in from", listDevices)
+	register("get-profile", "show user profile attributes", getProfile)
+	register("update-profile", "change user profile attributes", updateProfile)
+	register("get-app-metadata", "show user metadata kept for an app", getAppMetadata)
+	register("set-app-metadata", "replace user metadata kept for an app", setAppMetadata)
+}
+
+func usersService(env *environment) *usersservice.Users {
+	return usersservice.NewUsersService(env.log, env.storage, env.storage, env.storage, env.storage, env.storage, env.storage, env.storage, auditService(env), env.cfg.Users.PurgeAfter)
+}
+
+func getUser(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("get-user", flag.ExitOnError)
+	userId := fs.Int64("id", 0, "user id")
+	_ = fs.Parse(args)
+
+	return usersService(env).GetUser(env.ctx, *userId)
+}
+
+func listUsers(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("list-users", flag.ExitOnError)
+	emailPrefix := fs.String("email-prefix", "", "only users whose email starts with the prefix")
+	createdAfter := fs.String("created-after", "", "only users created at or after the RFC 3339 time")
+	createdBefore := fs.String("created-before", "", "only users created before the RFC 3339 time")
+	isAdmin := fs.String("admin", "", "only admins (true) or non-admins (false)")
+	isDisabled := fs.String("disabled", "", "only disabled (true) or active (false) users")
+	pageToken := fs.String("page-token", "", "next_page_token of the previous page")
+	pageSize := fs.Int("page-size", 0, "number of users per page")
+	_ = fs.Parse(args)
+
+	filter := models.UserFilter{EmailPrefix: *emailPrefix}
+	var err error
+	if filter.CreatedAfter, err = parseTime(*createdAfter); err != nil {
+		return nil, err
+	}
+	if filter.CreatedBefore, err = parseTime(*createdBefore); err != nil {
+		return nil, err
+	}
+	if filter.IsAdmin, err = parseOptionalBool(*isAdmin); err != nil {
+		return nil, err
+	}
+	if filter.IsDisabled, err = parseOptionalBool(*isDisabled); err != nil {
+		return nil, err
+	}
+
+	users, next, err := usersService(env).ListUsers(env.ctx, filter, *pageToken, *pageSize)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"users": users, "next_page_token": next}, nil
+}
+
+func updateUser(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("update-user", flag.ExitOnError)
+	userId := fs.Int64("id", 0, "user id")
+	email := fs.String("email", "", "new email")
+	username := fs.String("username", "", "login username, empty removes it")
+	phone := fs.String("phone", "", "login phone number in E.164 format, empty removes it")
+	isAdmin := fs.Bool("admin", false, "whether the user is an admin")
+	_ = fs.Parse(args)
+
+	// only flags given on the command line are applied
+	var update models.UserUpdate
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "email":
+			update.Email = email
+		case "username":
+			update.Username = username
+		case "phone":
+			update.Phone = phone
+		case "admin":
+			update.IsAdmin = isAdmin
+		}
+	})
+
+	return usersService(env).UpdateUser(env.ctx, *userId, update)
+}
+
+func disableUser(env *environment, args []string) (any, error) {
+	return userAction(env, "disable-user", args, usersService(env).DisableUser)
+}
+
+func enableUser(env *environment, args []string) (any, error) {
+	return userAction(env, "enable-user", args, usersService(env).EnableUser)
+}
+
+func deleteUser(env *environment, args []string) (any, error) {
+	return userAction(env, "delete-user", args, usersService(env).DeleteUser)
+}
+
+func exportUser(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("export-user", flag.ExitOnError)
+	userId := fs.Int64("id", 0, "user id")
+	_ = fs.Parse(args)
+
+	return usersService(env).ExportUser(env.ctx, *userId)
+}
+
+func eraseUser(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("erase-user", flag.ExitOnError)
+	userId := fs.Int64("id", 0, "user id")
+	confirm := fs.Bool("confirm", false, "confirm the erasure, it cannot be undone")
+	_ = fs.Parse(args)
+
+	if !*confirm {
+		return nil, errors.New("erasure cannot be undone, pass -confirm to proceed")
+	}
+	if err := usersService(env).EraseUser(env.ctx, *userId); err != nil {
+		return nil, err
+	}
+	return map[string]any{"id": *userId, "ok": true}, nil
+}
+
+func loginHistory(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("login-history", flag.ExitOnError)
+	userId := fs.Int64("id", 0, "user id")
+	pageToken := fs.String("page-token", "", "next_page_token of the previous page")
+	pageSize := fs.Int("page-size", 0, "number of login attempts per page")
+	_ = fs.Parse(args)
+
+	logins, next, err := usersService(env).GetLoginHistory(env.ctx, *userId, *pageToken, *pageSize)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"logins": logins, "next_page_token": next}, nil
+}
+
+func listDevices(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("list-devices", flag.ExitOnError)
+	userId := fs.Int64("id", 0, "user id")
+	_ = fs.Parse(args)
+
+	return usersService(env).ListDevices(env.ctx, *userId)
+}
+
+func userAction(env *environment, name string, args []string, action func(ctx context.Context, userId int64) error) (any, error) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	userId := fs.Int64("id", 0, "user id")
+	_ = fs.Parse(args)
+
+	if err := action(env.ctx, *userId); err != nil {
+		return nil, err
+	}
+	return map[string]any{"id": *userId, "ok": true}, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: %w", value, err)
+	}
+	return t, nil
+}
+
+func parseOptionalBool(value string) (*bool, error) {
+	if value == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid boolean %q: %w", value, err)
+	}
+	return &b, nil
+}
+
+func getProfile(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("get-profile", flag.ExitOnError)
+	userId := fs.Int64("id", 0, "user id")
+	_ = fs.Parse(args)
+
+	return usersService(env).GetProfile(env.ctx, *userId)
+}
+
+func updateProfile(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("update-profile", flag.ExitOnError)
+	userId := fs.Int64("id", 0, "user id")
+	displayName := fs.String("display-name", "", "display name")
+	locale := fs.String("locale", "", "BCP 47 language tag, e.g. en-US")
+	timezone := fs.String("timezone", "", "IANA time zone, e.g. Europe/Berlin")
+	avatarURL := fs.String("avatar-url", "", "avatar image url")
+	_ = fs.Parse(args)
+
+	// only flags given on the command line are applied
+	var update models.ProfileUpdate
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "display-name":
+			update.DisplayName = displayName
+		case "locale":
+			update.Locale = locale
+		case "timezone":
+			update.Timezone = timezone
+		case "avatar-url":
+			update.AvatarURL = avatarURL
+		}
+	})
+
+	return usersService(env).UpdateProfile(env.ctx, *userId, update)
+}
+
+func getAppMetadata(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("get-app-metadata", flag.ExitOnError)
+	userId := fs.Int64("id", 0, "user id")
+	appId := fs.Int64("app-id", 0, "app id")
+	_ = fs.Parse(args)
+
+	return usersService(env).GetAppMetadata(env.ctx, *userId, *appId)
+}
+
+func setAppMetadata(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("set-app-metadata", flag.ExitOnError)
+	userId := fs.Int64("id", 0, "user id")
+	appId := fs.Int64("app-id", 0, "app id")
+	metadata := fs.String("metadata", "{}", "JSON object replacing the current metadata")
+	_ = fs.Parse(args)
+
+	if err := usersService(env).SetAppMetadata(env.ctx, *userId, *appId, json.RawMessage(*metadata)); err != nil {
+		return nil, err
+	}
+	return json.RawMessage(*metadata), nil
+}
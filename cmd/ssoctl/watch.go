@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"os/signal"
+	"sso/internal/domain/models"
+	"sso/internal/lib/events"
+	"sso/internal/lib/logger/sl"
+	watchservice "sso/internal/services/watch"
+	psql "sso/internal/storage/postgreSQL"
+	"syscall"
+)
+
+func init() {
+	register("watch-events", "print events as JSON lines until interrupted, resumable with -cursor", watchEvents)
+}
+
+func watchEvents(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("watch-events", flag.ExitOnError)
+	cursor := fs.String("cursor", "", "cursor of the last seen event, empty starts from now")
+	_ = fs.Parse(args)
+
+	ctx, stop := signal.NotifyContext(env.ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	hub := events.NewHub()
+	go func() {
+		if err := env.storage.Listen(ctx, psql.EventsChannel, func(string) { hub.Notify() }); err != nil && ctx.Err() == nil {
+			// the watch falls back to polling
+			env.log.Warn("event listener disconnected", sl.Err(err))
+		}
+	}()
+
+	// ssoctl has direct database access, so no token is needed
+	watch := watchservice.NewWatchService(env.log, env.storage, hub, env.cfg.Events.Retention, env.cfg.Events.PollInterval)
+	enc := json.NewEncoder(os.Stdout)
+	err := watch.Watch(ctx, *cursor, func(event models.Event, cursor string) error {
+		return enc.Encode(map[string]any{"cursor": cursor, "event": event})
+	})
+	return nil, err
+}
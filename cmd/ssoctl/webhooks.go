@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"sso/internal/domain/models"
+	webhooksservice "sso/internal/services/webhooks"
+)
+
+func init() {
+	register("create-webhook", "subscribe an app to events and print the signing secret (shown only once)", createWebhook)
+	register("get-webhook", "show a webhook subscription", getWebhook)
+	register("list-webhooks", "list webhook subscriptions of an app", listWebhooks)
+	register("update-webhook", "change a webhook subscription", updateWebhook)
+	register("rotate-webhook-secret", "generate a new webhook signing secret", rotateWebhookSecret)
+	register("delete-webhook", "delete a webhook subscription and its delivery logs", deleteWebhook)
+	register("list-webhook-deliveries", "list deliveries of a webhook subscription, newest first", listWebhookDeliveries)
+	register("get-webhook-delivery", "show a webhook delivery and its attempts", getWebhookDelivery)
+	register("redeliver-webhook", "queue a webhook delivery again, e.g. a dead one", redeliverWebhook)
+}
+
+func webhooksService(env *environment) *webhooksservice.Webhooks {
+	return webhooksservice.NewWebhooksService(env.log, env.storage, env.storage, env.storage, env.storage, env.storage, env.storage)
+}
+
+const eventTypesUsage = "comma separated event types to deliver, e.g. user.registered,user.deleted; empty delivers all"
+
+func createWebhook(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("create-webhook", flag.ExitOnError)
+	appId := fs.Int64("app-id", 0, "app id")
+	url := fs.String("url", "", "https url receiving the events")
+	eventTypes := fs.String("events", "", eventTypesUsage)
+	disabled := fs.Bool("disabled", false, "create the subscription disabled")
+	_ = fs.Parse(args)
+
+	return webhooksService(env).CreateSubscription(env.ctx, models.WebhookSubscription{
+		AppId:      *appId,
+		URL:        *url,
+		EventTypes: splitList(*eventTypes),
+		Enabled:    !*disabled,
+	})
+}
+
+func getWebhook(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("get-webhook", flag.ExitOnError)
+	id := fs.Int64("id", 0, "subscription id")
+	_ = fs.Parse(args)
+
+	return webhooksService(env).GetSubscription(env.ctx, *id)
+}
+
+func listWebhooks(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("list-webhooks", flag.ExitOnError)
+	appId := fs.Int64("app-id", 0, "app id")
+	_ = fs.Parse(args)
+
+	return webhooksService(env).ListSubscriptions(env.ctx, *appId)
+}
+
+func updateWebhook(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("update-webhook", flag.ExitOnError)
+	id := fs.Int64("id", 0, "subscription id")
+	url := fs.String("url", "", "https url receiving the events")
+	eventTypes := fs.String("events", "", eventTypesUsage)
+	enabled := fs.Bool("enabled", true, "whether events are delivered")
+	_ = fs.Parse(args)
+
+	// only flags given on the command line are applied
+	var update models.WebhookSubscriptionUpdate
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "url":
+			update.URL = url
+		case "events":
+			types := splitList(*eventTypes)
+			update.EventTypes = &types
+		case "enabled":
+			update.Enabled = enabled
+		}
+	})
+
+	return webhooksService(env).UpdateSubscription(env.ctx, *id, update)
+}
+
+func rotateWebhookSecret(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("rotate-webhook-secret", flag.ExitOnError)
+	id := fs.Int64("id", 0, "subscription id")
+	_ = fs.Parse(args)
+
+	secret, err := webhooksService(env).RotateSubscriptionSecret(env.ctx, *id)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"id": *id, "secret": secret}, nil
+}
+
+func deleteWebhook(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("delete-webhook", flag.ExitOnError)
+	id := fs.Int64("id", 0, "subscription id")
+	_ = fs.Parse(args)
+
+	if err := webhooksService(env).DeleteSubscription(env.ctx, *id); err != nil {
+		return nil, err
+	}
+	return map[string]any{"id": *id, "deleted": true}, nil
+}
+
+func listWebhookDeliveries(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("list-webhook-deliveries", flag.ExitOnError)
+	id := fs.Int64("id", 0, "subscription id")
+	status := fs.String("status", "", "only deliveries in the status: pending, delivered or dead")
+	pageToken := fs.String("page-token", "", "next_page_token of the previous page")
+	pageSize := fs.Int("page-size", 0, "number of deliveries per page")
+	_ = fs.Parse(args)
+
+	deliveries, next, err := webhooksService(env).ListDeliveries(env.ctx, *id, *status, *pageToken, *pageSize)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"deliveries": deliveries, "next_page_token": next}, nil
+}
+
+func getWebhookDelivery(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("get-webhook-delivery", flag.ExitOnError)
+	id := fs.Int64("id", 0, "delivery id")
+	_ = fs.Parse(args)
+
+	delivery, attempts, err := webhooksService(env).GetDelivery(env.ctx, *id)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"delivery": delivery, "attempts": attempts}, nil
+}
+
+func redeliverWebhook(env *environment, args []string) (any, error) {
+	fs := flag.NewFlagSet("redeliver-webhook", flag.ExitOnError)
+	id := fs.Int64("id", 0, "delivery id")
+	_ = fs.Parse(args)
+
+	if err := webhooksService(env).Redeliver(env.ctx, *id); err != nil {
+		return nil, err
+	}
+	return map[string]any{"id": *id, "queued": true}, nil
+}
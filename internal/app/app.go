@@ -1,38 +1,427 @@
 package app
 
 import (
+	"context"
+	"encoding/hex"
+	"fmt"
 	"log/slog"
+	gatewayApplication "sso/internal/app/gateway"
 	grpcApplication "sso/internal/app/grpc"
+	metricsApplication "sso/internal/app/metrics"
 	"sso/internal/config"
+	"sso/internal/grpc/admin"
+	authgrpc "sso/internal/grpc/auth"
+	"sso/internal/grpc/gateway"
+	"sso/internal/grpc/interceptors"
+	"sso/internal/lib/events"
+	"sso/internal/lib/lifecycle"
 	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/metrics"
+	"sso/internal/lib/notify"
+	"sso/internal/lib/password"
+	"sso/internal/lib/systemd"
+	"sso/internal/lib/tlsreload"
+	"sso/internal/lib/tracing"
+	"sso/internal/lib/webhook"
+	appsservice "sso/internal/services/apps"
+	auditservice "sso/internal/services/audit"
 	authservice "sso/internal/services/auth"
+	sessionsservice "sso/internal/services/sessions"
+	usersservice "sso/internal/services/users"
+	watchservice "sso/internal/services/watch"
+	webhooksservice "sso/internal/services/webhooks"
+	"sso/internal/storage/cached"
 	psql "sso/internal/storage/postgreSQL"
+	"sync"
+	"time"
 )
 
+const listenRetryDelay = time.Second
+
 type App struct {
-	GRPCServer *grpcApplication.App
+	lifecycle *lifecycle.Manager
 }
 
+// NewApp wires the application, nothing runs until Run. On error whatever
+// was set up so far is released again.
 func NewApp(
 	log *slog.Logger,
-	cfg *config.Config) *App {
-	const op = "app.Application.Run"
+	cfg *config.Config) (_ *App, err error) {
+	const op = "app.Application.New"
 	log = log.With(
 		slog.String("operation", op),
 	)
 
+	m := lifecycle.New(log, cfg.ShutdownTimeout)
+	defer func() {
+		if err != nil {
+			_ = m.Shutdown()
+		}
+	}()
+
+	// with socket activation systemd holds the ports, so connections wait
+	// in its backlog while the service restarts
+	sockets := []string{systemd.SocketGRPC}
+	if cfg.Metrics.Address != "" {
+		sockets = append(sockets, systemd.SocketMetrics)
+	}
+	if cfg.Gateway.Address != "" {
+		sockets = append(sockets, systemd.SocketGateway)
+	}
+	listeners, err := systemd.Listeners(sockets...)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	if len(listeners) > 0 {
+		// servers close the ones they took over, this covers the others
+		m.Add(lifecycle.Component{Name: "sockets", Stop: func(context.Context) error {
+			for _, lis := range listeners {
+				_ = lis.Close()
+			}
+			return nil
+		}})
+		log.Info("listening on sockets passed by systemd", slog.Int("count", len(listeners)))
+	}
+
+	sdNotifier, err := systemd.NewNotifier(log)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+
+	tracer, err := tracing.New(context.Background(), cfg.Tracing)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	if tracer != nil {
+		m.Add(lifecycle.Component{Name: "tracing", Stop: tracer.Shutdown})
+		log.Info("tracing initialized", slog.String("exporter", cfg.Tracing.Exporter))
+	}
+
 	storage, err := psql.New(cfg)
 	if err != nil {
-		log.Error("failed to init storage : %s", sl.Err(err))
-		return nil
+		return nil, fmt.Errorf("%s:%w", op, err)
 	}
-	log.Info("storage initialized", slog.Any("db", cfg))
-	auth := authservice.NewAuthService(log, storage, storage, storage, storage, cfg.TokenTTL)
+	m.Add(lifecycle.Component{Name: "storage", Stop: func(context.Context) error {
+		return storage.Close()
+	}})
+	log.Info("storage initialized", slog.String("host", cfg.Storage.DBHost), slog.String("db", cfg.Storage.DBName))
+
+	encrypted, err := storage.EncryptAppSecrets(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	if encrypted > 0 {
+		log.Info("plaintext app secrets encrypted", slog.Int("count", encrypted))
+	}
+
+	appMetrics := metrics.New()
+	appMetrics.RegisterDB(storage)
+
+	hasher, err := password.New(cfg.Password)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+
+	notifier, err := notify.New(log, cfg.Notify)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+
+	var publisher events.Publisher
+	if cfg.Events.Publisher != "" {
+		if publisher, err = events.New(cfg.Events); err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+		if closer, ok := publisher.(interface{ Close() }); ok {
+			m.Add(lifecycle.Component{Name: "event publisher", Stop: func(context.Context) error {
+				closer.Close()
+				return nil
+			}})
+		}
+	}
+
+	var tlsReloader *tlsreload.Reloader
+	if cfg.GRPC.TLS.CertFile != "" {
+		if tlsReloader, err = tlsreload.New(log, cfg.GRPC.TLS); err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+		log.Info("gRPC TLS enabled", slog.Bool("clientCerts", cfg.GRPC.TLS.ClientCAFile != ""), slog.Bool("mutual", cfg.GRPC.TLS.RequireClientCert))
+	} else {
+		log.Warn("gRPC TLS is not configured, serving plaintext")
+	}
+
+	// background jobs start after the storage and stop before it
+	jobs := &workers{}
+	m.Add(lifecycle.Component{Name: "workers", Start: jobs.start, Stop: jobs.stop})
+
+	apps := cached.NewApps(log, storage, cfg.Cache)
+	jobs.add(func(ctx context.Context) {
+		listenAppChanges(ctx, log, storage, apps)
+	})
+	log.Info("apps cache initialized", slog.Duration("ttl", cfg.Cache.AppTTL), slog.Int("maxSize", cfg.Cache.MaxSize))
+
+	// app changes reach the cache through listenAppChanges
+	appsService := appsservice.NewAppsService(log, storage, storage, storage, storage)
+
+	audit := auditservice.NewAuditService(log, storage, storage)
+	jobs.add(func(ctx context.Context) {
+		runPeriodically(ctx, cfg.Audit.AnchorInterval, func(ctx context.Context) {
+			logAuditHead(ctx, log, audit)
+		})
+	})
+
+	auth := authservice.NewAuthService(log, storage, storage, apps, storage, storage, storage, hasher, audit, storage, notifier, storage, appMetrics, cfg.TokenTTL)
 	log.Info("auth service initialized")
-	grpcApp := grpcApplication.NewApp(log, cfg.GRPC.Port, auth)
+
+	users := usersservice.NewUsersService(log, storage, storage, storage, storage, storage, storage, storage, audit, cfg.Users.PurgeAfter)
+	jobs.add(func(ctx context.Context) {
+		runPeriodically(ctx, cfg.Users.PurgeInterval, func(ctx context.Context) {
+			_, _ = users.PurgeDeletedUsers(ctx)
+		})
+	})
+	log.Info("users service initialized", slog.Duration("purgeAfter", cfg.Users.PurgeAfter))
+
+	sessions := sessionsservice.NewSessionsService(log, storage, storage, audit)
+	jobs.add(func(ctx context.Context) {
+		runPeriodically(ctx, cfg.Users.PurgeInterval, func(ctx context.Context) {
+			_, _ = sessions.PurgeExpiredSessions(ctx)
+		})
+	})
+	log.Info("sessions service initialized")
+
+	if publisher != nil {
+		jobs.add(events.NewRelay(log, storage, publisher, cfg.Events).Run)
+		jobs.add(func(ctx context.Context) {
+			runPeriodically(ctx, cfg.Users.PurgeInterval, func(ctx context.Context) {
+				purgeOutboxEvents(ctx, log, storage, cfg.Events.Retention)
+			})
+		})
+		log.Info("event relay initialized", slog.String("publisher", cfg.Events.Publisher))
+	} else {
+		jobs.add(func(ctx context.Context) {
+			runPeriodically(ctx, cfg.Users.PurgeInterval, func(ctx context.Context) {
+				purgeUnpublishedOutboxEvents(ctx, log, storage, cfg.Events.Retention)
+			})
+		})
+		log.Warn("no event publisher configured, events are kept in the outbox for the retention period")
+	}
+
+	hub := events.NewHub()
+	jobs.add(func(ctx context.Context) {
+		listenEvents(ctx, log, storage, hub)
+	})
+	watch := watchservice.NewWatchService(log, storage, hub, cfg.Events.Retention, cfg.Events.PollInterval)
+
+	webhooks := webhooksservice.NewWebhooksService(log, storage, storage, storage, storage, storage, storage)
+	jobs.add(webhook.NewDispatcher(log, storage, cfg.Webhooks).Run)
+	jobs.add(func(ctx context.Context) {
+		runPeriodically(ctx, cfg.Users.PurgeInterval, func(ctx context.Context) {
+			_, _ = webhooks.PurgeDeliveries(ctx, cfg.Webhooks.Retention)
+		})
+	})
+	log.Info("webhook dispatcher initialized", slog.Int("maxAttempts", cfg.Webhooks.MaxAttempts))
+
+	// servers stop first, so calls in flight still reach the storage
+	if cfg.Metrics.Address != "" {
+		metricsApp := metricsApplication.NewApp(log, cfg.Metrics.Address, cfg.Metrics.Path, appMetrics.Handler())
+		m.Add(lifecycle.Component{
+			Name:  "metrics server",
+			Start: func() error { return metricsApp.Listen(listeners[systemd.SocketMetrics]) },
+			Run:   metricsApp.Run,
+			Stop:  metricsApp.Stop,
+		})
+		log.Info("metrics server initialized", slog.String("address", cfg.Metrics.Address))
+	}
+
+	// the RPCs that are not in the published protos, the routes describe
+	// them for the gRPC server too
+	var routes []gateway.Route
+	routes = append(routes, gateway.TokenRoutes(authgrpc.NewTokenAPI(auth))...)
+	routes = append(routes, gateway.AppRoutes(admin.NewAppsAPI(appsService))...)
+	routes = append(routes, gateway.UserRoutes(admin.NewUsersAPI(users))...)
+	routes = append(routes, gateway.ProfileRoutes(admin.NewProfilesAPI(users))...)
+	routes = append(routes, gateway.ImportRoutes(admin.NewImportAPI(users))...)
+	routes = append(routes, gateway.PrivacyRoutes(admin.NewPrivacyAPI(users))...)
+	routes = append(routes, gateway.LoginRoutes(admin.NewLoginsAPI(users))...)
+	routes = append(routes, gateway.SessionRoutes(admin.NewSessionsAPI(sessions))...)
+	routes = append(routes, gateway.WebhookRoutes(admin.NewWebhooksAPI(webhooks))...)
+	routes = append(routes, gateway.AuditRoutes(admin.NewAuditAPI(audit))...)
+	routes = append(routes, gateway.WatchRoutes(admin.NewWatchAPI(watch))...)
+	adminMethods := append([]string{admin.Service}, authgrpc.AdminMethods...)
+
+	grpcApp := grpcApplication.NewApp(log, cfg.GRPC, auth, appMetrics, storage, tlsReloader, cfg.Admin.AppId, adminMethods, gateway.Services(routes...)...)
+	m.Add(lifecycle.Component{
+		Name:  "gRPC server",
+		Start: func() error { return grpcApp.Listen(listeners[systemd.SocketGRPC]) },
+		Run:   grpcApp.Run,
+		Stop:  grpcApp.Stop,
+	})
 	log.Info("gRPC server initialized", slog.Int("port", cfg.GRPC.Port))
 
-	return &App{
-		GRPCServer: grpcApp,
+	if cfg.Gateway.Address != "" {
+		var gatewayTLS *tlsreload.Reloader
+		if cfg.Gateway.TLS.CertFile != "" {
+			if gatewayTLS, err = tlsreload.New(log, cfg.Gateway.TLS); err != nil {
+				return nil, fmt.Errorf("%s:%w", op, err)
+			}
+		} else {
+			log.Warn("gateway TLS is not configured, serving plaintext HTTP")
+		}
+
+		gatewayInterceptors := append(interceptors.Unary(log, appMetrics, cfg.GRPC.Timeout), interceptors.UnaryAdmin(auth, auth, cfg.Admin.AppId, adminMethods...))
+		gatewayStreamInterceptors := append(interceptors.Stream(log, appMetrics), interceptors.StreamAdmin(auth, auth, cfg.Admin.AppId, adminMethods...))
+		gatewayRoutes := append(gateway.AuthRoutes(authgrpc.NewServerAPI(auth)), routes...)
+		gatewayApp := gatewayApplication.NewApp(log, cfg.Gateway, gatewayInterceptors, gatewayStreamInterceptors, gatewayTLS, gatewayRoutes...)
+		m.Add(lifecycle.Component{
+			Name:  "gateway server",
+			Start: func() error { return gatewayApp.Listen(listeners[systemd.SocketGateway]) },
+			Run:   gatewayApp.Run,
+			Stop:  gatewayApp.Stop,
+		})
+		log.Info("gateway server initialized", slog.String("address", cfg.Gateway.Address), slog.Bool("tls", gatewayTLS != nil), slog.Any("allowedOrigins", cfg.Gateway.AllowedOrigins))
+	}
+
+	// added last, systemd hears of the start once everything serves and of
+	// the shutdown before anything stops
+	m.Add(lifecycle.Component{Name: "systemd notifier", Start: sdNotifier.Ready, Run: sdNotifier.Watchdog, Stop: sdNotifier.Stopping})
+
+	return &App{lifecycle: m}, nil
+}
+
+// Run starts the servers and background workers and blocks until ctx is
+// done or one of them fails, then stops everything within
+// cfg.ShutdownTimeout. Start failures wrap lifecycle.ErrStart.
+func (a *App) Run(ctx context.Context) error {
+	return a.lifecycle.Run(ctx)
+}
+
+// listenAppChanges keeps the apps cache in sync with the apps table,
+// resubscribing whenever the notification connection is lost.
+func listenAppChanges(ctx context.Context, log *slog.Logger, storage *psql.Storage, apps *cached.Apps) {
+	for {
+		err := storage.Listen(ctx, psql.AppsChannel, apps.Invalidate)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn("apps change listener disconnected", sl.Err(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+// listenEvents wakes the event watchers of hub when events are added to the
+// outbox. While it is disconnected the watchers poll.
+func listenEvents(ctx context.Context, log *slog.Logger, storage *psql.Storage, hub *events.Hub) {
+	for {
+		err := storage.Listen(ctx, psql.EventsChannel, func(string) { hub.Notify() })
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn("event listener disconnected", sl.Err(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+// logAuditHead logs the newest audit event, the log keeps it as an anchor
+// of the chain outside the database.
+func logAuditHead(ctx context.Context, log *slog.Logger, audit *auditservice.Audit) {
+	head, err := audit.Head(ctx)
+	if err != nil || head.Id == 0 {
+		return
+	}
+	log.Info("audit chain head", slog.Int64("eventId", head.Id), slog.String("hash", hex.EncodeToString(head.Hash)))
+}
+
+// purgeOutboxEvents removes events published longer than retention ago.
+func purgeOutboxEvents(ctx context.Context, log *slog.Logger, storage *psql.Storage, retention time.Duration) {
+	purged, err := storage.PurgeOutboxEvents(ctx, time.Now().UTC().Add(-retention))
+	if err != nil {
+		log.Error("failed to purge outbox events", sl.Err(err))
+		return
+	}
+	if purged > 0 {
+		log.Info("published outbox events purged", slog.Int64("count", purged))
+	}
+}
+
+// purgeUnpublishedOutboxEvents removes events older than retention when no
+// publisher relays them, they are only read by watchers until then.
+func purgeUnpublishedOutboxEvents(ctx context.Context, log *slog.Logger, storage *psql.Storage, retention time.Duration) {
+	purged, err := storage.PurgeUnpublishedOutboxEvents(ctx, time.Now().UTC().Add(-retention))
+	if err != nil {
+		log.Error("failed to purge unpublished outbox events", sl.Err(err))
+		return
+	}
+	if purged > 0 {
+		log.Info("unpublished outbox events purged", slog.Int64("count", purged))
+	}
+}
+
+// runPeriodically runs a background cleanup job until ctx is canceled. Jobs
+// log their own errors, the next tick retries.
+func runPeriodically(ctx context.Context, interval time.Duration, job func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job(ctx)
+		}
+	}
+}
+
+// workers runs the background jobs of the application until stopped.
+type workers struct {
+	jobs    []func(ctx context.Context)
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+}
+
+func (w *workers) add(job func(ctx context.Context)) {
+	w.jobs = append(w.jobs, job)
+}
+
+func (w *workers) start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	for _, job := range w.jobs {
+		w.running.Add(1)
+		go func() {
+			defer w.running.Done()
+			job(ctx)
+		}()
+	}
+	return nil
+}
+
+// stop cancels the jobs and waits for them until ctx is done.
+func (w *workers) stop(ctx context.Context) error {
+	if w.cancel == nil {
+		return nil
+	}
+	w.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		w.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
@@ -0,0 +1,140 @@
+package gatewayApplication
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sso/internal/config"
+	"sso/internal/grpc/gateway"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/tlsreload"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+const (
+	readHeaderTimeout = 5 * time.Second
+)
+
+type App struct {
+	log    *slog.Logger
+	server *http.Server
+	tls    *tlsreload.Reloader
+	lis    net.Listener
+}
+
+// NewApp creates the HTTP server exposing routes as REST endpoints, calls go
+// through interceptors and streams through streamInterceptors like the ones
+// of the gRPC server. The server speaks
+// TLS with the certificates of tlsReloader, plaintext HTTP if it is nil.
+func NewApp(
+	log *slog.Logger,
+	cfg config.Gateway,
+	interceptors []grpc.UnaryServerInterceptor,
+	streamInterceptors []grpc.StreamServerInterceptor,
+	tlsReloader *tlsreload.Reloader,
+	routes ...gateway.Route) *App {
+	gw := gateway.New(interceptors, streamInterceptors, routes...)
+	server := &http.Server{
+		Addr:              cfg.Address,
+		Handler:           gateway.CORS(cfg.AllowedOrigins).Handler(gw),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	server.RegisterOnShutdown(gw.StopStreams)
+	if tlsReloader != nil {
+		server.TLSConfig = tlsReloader.HTTPConfig()
+	}
+
+	return &App{
+		log:    log,
+		server: server,
+		tls:    tlsReloader,
+	}
+}
+
+func (a *App) MustRun() {
+	if err := a.Run(); err != nil {
+		panic("failed to run gateway server")
+	}
+}
+
+// Listen binds the address, so a taken one is reported before anything is
+// served, or takes over inherited, e.g. a socket passed by systemd. Run
+// listens itself if it was not called.
+func (a *App) Listen(inherited net.Listener) error {
+	const op = "app.Gateway.Application.Listen"
+
+	if inherited != nil {
+		a.lis = inherited
+		return nil
+	}
+	lis, err := net.Listen("tcp", a.server.Addr)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	a.lis = lis
+	return nil
+}
+
+func (a *App) Run() error {
+	const op = "app.Gateway.Application.Run"
+	address := a.server.Addr
+	if a.lis != nil {
+		address = a.lis.Addr().String()
+	}
+	log := a.log.With(
+		slog.String("operation", op),
+		slog.String("address", address),
+	)
+
+	lis := a.lis
+	if lis == nil {
+		var err error
+		if lis, err = net.Listen("tcp", a.server.Addr); err != nil {
+			log.Error("failed to listen", sl.Err(err))
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if a.tls != nil {
+		go a.tls.Run(ctx)
+		lis = tls.NewListener(lis, a.server.TLSConfig)
+	}
+
+	log.Info("gateway server is running", slog.Bool("tls", a.tls != nil))
+
+	if err := a.server.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error("failed to serve gateway", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// Stop lets the requests in flight finish until ctx is done, then closes the
+// remaining connections. Streams are ended right away.
+func (a *App) Stop(ctx context.Context) error {
+	const op = "app.Gateway.Application.Stop"
+	log := a.log.With(
+		slog.String("operation", op),
+	)
+
+	log.Info("stopping gateway server")
+	if err := a.server.Shutdown(ctx); err != nil {
+		_ = a.server.Close()
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	// a listener that was never served is not closed by Shutdown
+	if a.lis != nil {
+		_ = a.lis.Close()
+	}
+	log.Info("gateway server stopped")
+	return nil
+}
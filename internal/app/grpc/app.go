@@ -1,30 +1,142 @@
 package grpcApplication
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 	"log/slog"
 	"net"
+	"net/http"
+	"sso/internal/config"
 	authgrpc "sso/internal/grpc/auth"
+	"sso/internal/grpc/interceptors"
+	"sso/internal/grpc/mux"
+	"sso/internal/grpc/readiness"
+	"sso/internal/grpc/web"
 	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/tlsreload"
 	authservice "sso/internal/services/auth"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// matchTimeout bounds how long a new connection may take to show its
+	// protocol.
+	matchTimeout      = 10 * time.Second
+	readHeaderTimeout = 5 * time.Second
 )
 
 type App struct {
 	log        *slog.Logger
 	gRPCServer *grpc.Server
-	port       int
+	health     *health.Server
+	readiness  *readiness.Checker
+	tls        *tlsreload.Reloader
+	// web serves gRPC-Web and Connect next to native gRPC, nil if disabled.
+	web     *http.Server
+	lis     net.Listener
+	stopped atomic.Bool
+	port    int
 }
 
-func NewApp(log *slog.Logger, port int, auth *authservice.Auth) *App {
-	gRPCServer := grpc.NewServer()
+// NewApp creates the gRPC server with the auth API, descs, the
+// grpc.health.v1 service tracking db and, if enabled, server reflection. The server speaks
+// TLS with the certificates of tlsReloader, plaintext if it is nil. With
+// cfg.Web enabled the port also serves gRPC-Web and Connect requests. Calls
+// are cut off after cfg.Timeout and adminMethods need the token of an admin
+// issued for adminAppId, connections are kept alive and recycled as
+// configured.
+func NewApp(
+	log *slog.Logger,
+	cfg config.GRPC,
+	auth *authservice.Auth,
+	observer interceptors.RPCObserver,
+	db readiness.Pinger,
+	tlsReloader *tlsreload.Reloader,
+	adminAppId int64,
+	adminMethods []string,
+	descs ...*grpc.ServiceDesc) *App {
+	opts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(append(interceptors.Unary(log, observer, cfg.Timeout), interceptors.UnaryAdmin(auth, auth, adminAppId, adminMethods...))...),
+		grpc.ChainStreamInterceptor(append(interceptors.Stream(log, observer), interceptors.StreamAdmin(auth, auth, adminAppId, adminMethods...))...),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionIdle:     cfg.IdleTimeout,
+			MaxConnectionAge:      cfg.MaxConnectionAge,
+			MaxConnectionAgeGrace: cfg.MaxConnectionAgeGrace,
+			Time:                  cfg.Keepalive.PingInterval,
+			Timeout:               cfg.Keepalive.PingTimeout,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             cfg.Keepalive.MinPingInterval,
+			PermitWithoutStream: cfg.Keepalive.PermitWithoutStream,
+		}),
+	}
+	// zero limits would refuse every call, they keep the gRPC defaults
+	if cfg.MaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(cfg.MaxRecvMsgSize))
+	}
+	if cfg.MaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(cfg.MaxSendMsgSize))
+	}
+	if cfg.MaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(cfg.MaxConcurrentStreams))
+	}
+	switch {
+	case tlsReloader != nil && cfg.Web.Enabled:
+		// the listener does the handshake, see serveMux
+		opts = append(opts, grpc.Creds(terminatedTLS{TransportCredentials: credentials.NewTLS(nil)}))
+	case tlsReloader != nil:
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsReloader.Config())))
+	}
+	gRPCServer := grpc.NewServer(opts...)
 
 	authgrpc.RegisterServerAPI(gRPCServer, auth)
+	for _, desc := range descs {
+		gRPCServer.RegisterService(desc, nil)
+	}
+
+	services := make([]string, 0, len(gRPCServer.GetServiceInfo()))
+	for name := range gRPCServer.GetServiceInfo() {
+		services = append(services, name)
+	}
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(gRPCServer, healthServer)
+
+	if cfg.Reflection {
+		reflection.Register(gRPCServer)
+	}
+
+	var webServer *http.Server
+	if cfg.Web.Enabled {
+		webServer = &http.Server{
+			// browsers use h2 over TLS only, h2c is for other Connect clients
+			Handler:           h2c.NewHandler(withTLSState(web.CORS(cfg.Web.AllowedOrigins).Handler(web.New(gRPCServer))), &http2.Server{}),
+			ReadHeaderTimeout: readHeaderTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+			ConnContext:       withTLSConn,
+		}
+	}
 
 	return &App{
 		log:        log,
 		gRPCServer: gRPCServer,
-		port:       port,
+		health:     healthServer,
+		readiness:  readiness.New(log, healthServer, db, cfg.HealthInterval, services...),
+		tls:        tlsReloader,
+		web:        webServer,
+		port:       cfg.Port,
 	}
 }
 
@@ -34,6 +146,32 @@ func (a *App) MustRun() {
 	}
 }
 
+// Listen binds the port, so a taken one is reported before anything is
+// served, or takes over inherited, e.g. a socket passed by systemd. Run
+// listens itself if it was not called.
+func (a *App) Listen(inherited net.Listener) error {
+	if inherited != nil {
+		a.lis = inherited
+		return nil
+	}
+	lis, err := a.listen()
+	if err != nil {
+		return err
+	}
+	a.lis = lis
+	return nil
+}
+
+func (a *App) listen() (net.Listener, error) {
+	const op = "app.gRPC.Application.Listen"
+
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", a.port))
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return lis, nil
+}
+
 func (a *App) Run() error {
 	const op = "app.gRPC.Application.Run"
 	log := a.log.With(
@@ -41,14 +179,29 @@ func (a *App) Run() error {
 		slog.Int("port", a.port),
 	)
 
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", a.port))
-	if err != nil {
-		log.Error("failed to listen", sl.Err(err))
+	lis := a.lis
+	if lis == nil {
+		var err error
+		if lis, err = a.listen(); err != nil {
+			log.Error("failed to listen", sl.Err(err))
+			return err
+		}
+	}
+
+	// the health status stays NOT_SERVING until the first check passes
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.readiness.Run(ctx)
+	if a.tls != nil {
+		go a.tls.Run(ctx)
 	}
 
-	log.Info("gRPC server is running", slog.String("address", lis.Addr().String()))
+	log.Info("gRPC server is running", slog.String("address", lis.Addr().String()), slog.Bool("tls", a.tls != nil), slog.Bool("web", a.web != nil))
 
-	if err := a.gRPCServer.Serve(lis); err != nil {
+	if a.web != nil {
+		return a.serveMux(log, lis)
+	}
+	if err := a.gRPCServer.Serve(lis); err != nil && !a.stopped.Load() {
 		log.Error("failed to serve gRPC server", sl.Err(err))
 		return err
 	}
@@ -56,13 +209,76 @@ func (a *App) Run() error {
 	return nil
 }
 
-func (a *App) Stop() {
+// serveMux shares lis between native gRPC and the gRPC-Web and Connect
+// server, which also passes on gRPC calls that reach it.
+func (a *App) serveMux(log *slog.Logger, lis net.Listener) error {
+	if a.tls != nil {
+		// browsers may only speak HTTP/1.1
+		lis = tls.NewListener(lis, a.tls.HTTPConfig())
+	}
+
+	m := mux.New(lis, matchTimeout)
+
+	go func() {
+		if err := a.gRPCServer.Serve(m.GRPC); err != nil && !a.stopped.Load() {
+			log.Error("failed to serve gRPC server", sl.Err(err))
+		}
+	}()
+	go func() {
+		if err := a.web.Serve(m.HTTP); err != nil && !a.stopped.Load() {
+			log.Error("failed to serve gRPC-Web and Connect", sl.Err(err))
+		}
+	}()
+
+	// stopping the gRPC server closes lis and so ends Serve
+	if err := m.Serve(); err != nil && !a.stopped.Load() {
+		log.Error("failed to accept connections", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// Stop lets the calls in flight finish until ctx is done, then closes the
+// remaining connections.
+func (a *App) Stop(ctx context.Context) error {
 	const op = "app.gRPC.Application.Stop"
 	log := a.log.With(
 		slog.String("operation", op),
 	)
 
+	a.stopped.Store(true)
+	// health checks fail from now on, so balancers stop sending new calls
+	// while the ones in flight finish
+	a.health.Shutdown()
 	log.Info("stopping gRPC server")
-	a.gRPCServer.GracefulStop()
+
+	stopped := make(chan struct{})
+	go func() {
+		a.gRPCServer.GracefulStop()
+		close(stopped)
+	}()
+	var err error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Warn("calls still in flight, closing connections")
+		a.gRPCServer.Stop()
+		<-stopped
+		err = fmt.Errorf("%s:%w", op, ctx.Err())
+	}
+
+	if a.web != nil {
+		// the listener is already closed along with the gRPC one
+		if shutdownErr := a.web.Shutdown(ctx); shutdownErr != nil && !errors.Is(shutdownErr, net.ErrClosed) {
+			_ = a.web.Close()
+			err = errors.Join(err, fmt.Errorf("%s:%w", op, shutdownErr))
+		}
+	}
+	// a listener that was never served is not closed by the server
+	if a.lis != nil {
+		_ = a.lis.Close()
+	}
 	log.Info("gRPC server stopped")
+	return err
 }
@@ -0,0 +1,74 @@
+package grpcApplication
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/soheilhy/cmux"
+	"google.golang.org/grpc/credentials"
+)
+
+// terminatedTLS are the credentials of a gRPC server behind a TLS listener.
+// The handshake is done by then, it only reports the connection state so
+// client certificates still identify callers.
+type terminatedTLS struct {
+	credentials.TransportCredentials
+}
+
+func (c terminatedTLS) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	tlsConn, ok := tlsConnOf(conn)
+	if !ok {
+		return nil, nil, errors.New("connection is not TLS")
+	}
+	return conn, credentials.TLSInfo{
+		State:          tlsConn.ConnectionState(),
+		CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity},
+	}, nil
+}
+
+func (c terminatedTLS) Clone() credentials.TransportCredentials {
+	return terminatedTLS{TransportCredentials: c.TransportCredentials.Clone()}
+}
+
+// tlsConnOf unwraps the connections handed out by the mux.
+func tlsConnOf(conn net.Conn) (*tls.Conn, bool) {
+	for {
+		switch c := conn.(type) {
+		case *tls.Conn:
+			return c, true
+		case *cmux.MuxConn:
+			conn = c.Conn
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil, false
+		}
+	}
+}
+
+type tlsConnKey struct{}
+
+// withTLSConn keeps the TLS connection of an HTTP connection, the server
+// sees the decrypted cmux connection only.
+func withTLSConn(ctx context.Context, conn net.Conn) context.Context {
+	if tlsConn, ok := tlsConnOf(conn); ok {
+		return context.WithValue(ctx, tlsConnKey{}, tlsConn)
+	}
+	return ctx
+}
+
+// withTLSState fills in Request.TLS from the connection kept by
+// withTLSConn, the gRPC server takes the client certificate from there.
+func withTLSState(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tlsConn, ok := r.Context().Value(tlsConnKey{}).(*tls.Conn); ok && r.TLS == nil {
+			state := tlsConn.ConnectionState()
+			r = r.WithContext(r.Context())
+			r.TLS = &state
+		}
+		next.ServeHTTP(w, r)
+	})
+}
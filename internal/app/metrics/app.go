@@ -0,0 +1,109 @@
+package metricsApplication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+const (
+	readHeaderTimeout = 5 * time.Second
+)
+
+type App struct {
+	log    *slog.Logger
+	server *http.Server
+	lis    net.Listener
+}
+
+// NewApp creates the HTTP server that serves handler on path.
+func NewApp(log *slog.Logger, address string, path string, handler http.Handler) *App {
+	mux := http.NewServeMux()
+	mux.Handle(path, handler)
+
+	return &App{
+		log: log,
+		server: &http.Server{
+			Addr:              address,
+			Handler:           mux,
+			ReadHeaderTimeout: readHeaderTimeout,
+		},
+	}
+}
+
+func (a *App) MustRun() {
+	if err := a.Run(); err != nil {
+		panic("failed to run metrics server")
+	}
+}
+
+// Listen binds the address, so a taken one is reported before anything is
+// served, or takes over inherited, e.g. a socket passed by systemd. Run
+// listens itself if it was not called.
+func (a *App) Listen(inherited net.Listener) error {
+	const op = "app.Metrics.Application.Listen"
+
+	if inherited != nil {
+		a.lis = inherited
+		return nil
+	}
+	lis, err := net.Listen("tcp", a.server.Addr)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	a.lis = lis
+	return nil
+}
+
+func (a *App) Run() error {
+	const op = "app.Metrics.Application.Run"
+	address := a.server.Addr
+	if a.lis != nil {
+		address = a.lis.Addr().String()
+	}
+	log := a.log.With(
+		slog.String("operation", op),
+		slog.String("address", address),
+	)
+
+	log.Info("metrics server is running")
+
+	var err error
+	if a.lis != nil {
+		err = a.server.Serve(a.lis)
+	} else {
+		err = a.server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error("failed to serve metrics", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// Stop lets the requests in flight finish until ctx is done, then closes the
+// remaining connections.
+func (a *App) Stop(ctx context.Context) error {
+	const op = "app.Metrics.Application.Stop"
+	log := a.log.With(
+		slog.String("operation", op),
+	)
+
+	log.Info("stopping metrics server")
+	if err := a.server.Shutdown(ctx); err != nil {
+		_ = a.server.Close()
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	// a listener that was never served is not closed by Shutdown
+	if a.lis != nil {
+		_ = a.lis.Close()
+	}
+	log.Info("metrics server stopped")
+	return nil
+}
@@ -11,15 +11,82 @@ import (
 type Config struct {
 	Env                     string        `yaml:"env" env-required:"true"`
 	TokenTTL                time.Duration `yaml:"token_ttl" env-required:"true"`
+	SecretsKey              string        `yaml:"secrets_key" env:"SECRETS_KEY" env-required:"true"`
 	GRPC                    `yaml:"grpc" env-required:"true"`
 	Storage                 `yaml:"storage" env-required:"true"`
+	Cache                   `yaml:"cache"`
+	Users                   `yaml:"users"`
+	Audit                   `yaml:"audit"`
+	Admin                   `yaml:"admin" env-required:"true"`
+	Password                `yaml:"password"`
+	Notify                  `yaml:"notify"`
+	Events                  `yaml:"events"`
+	Webhooks                `yaml:"webhooks"`
+	Gateway                 `yaml:"gateway"`
+	Metrics                 `yaml:"metrics"`
+	Tracing                 `yaml:"tracing"`
 	MigrationSourceFilePath string `yaml:"migration_source_file_path" env-required:"true"`
+	// ShutdownTimeout is how long the servers and workers get to finish their
+	// work on shutdown before they are stopped forcibly.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env-default:"15s"`
 }
 
 type GRPC struct {
-	Port        int           `yaml:"port" env-required:"true"`
-	Timeout     time.Duration `yaml:"timeout" env-required:"true"`
-	IdleTimeout time.Duration `yaml:"idle_timeout" env-required:"true"`
+	Port int `yaml:"port" env-required:"true"`
+	// Timeout caps the deadline of unary calls, clients may ask for less.
+	Timeout time.Duration `yaml:"timeout" env-default:"10s"`
+	// IdleTimeout closes connections without calls for that long.
+	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"5m"`
+	// MaxConnectionAge closes connections after that long, with
+	// MaxConnectionAgeGrace for calls in flight to finish, so clients
+	// reconnect and spread over new instances. 0 keeps them open.
+	MaxConnectionAge      time.Duration `yaml:"max_connection_age"`
+	MaxConnectionAgeGrace time.Duration `yaml:"max_connection_age_grace" env-default:"30s"`
+	// MaxRecvMsgSize and MaxSendMsgSize bound messages in bytes.
+	MaxRecvMsgSize int `yaml:"max_recv_msg_size" env-default:"4194304"`
+	MaxSendMsgSize int `yaml:"max_send_msg_size" env-default:"4194304"`
+	// MaxConcurrentStreams bounds the calls in flight on one connection.
+	MaxConcurrentStreams uint32 `yaml:"max_concurrent_streams" env-default:"100"`
+	Keepalive            `yaml:"keepalive"`
+	// HealthInterval is how often the database is checked for the
+	// grpc.health.v1 status.
+	HealthInterval time.Duration `yaml:"health_interval" env-default:"5s"`
+	// Reflection exposes the server reflection service, e.g. for grpcurl.
+	Reflection bool `yaml:"reflection"`
+	TLS        `yaml:"tls"`
+	Web        `yaml:"web"`
+}
+
+// Keepalive configures the pings on gRPC connections. The server pings
+// clients idle for ping_interval and drops them without an answer within
+// ping_timeout. Clients pinging more often than min_ping_interval, or
+// without calls in flight unless permit_without_stream, are disconnected.
+type Keepalive struct {
+	PingInterval        time.Duration `yaml:"ping_interval" env-default:"2h"`
+	PingTimeout         time.Duration `yaml:"ping_timeout" env-default:"20s"`
+	MinPingInterval     time.Duration `yaml:"min_ping_interval" env-default:"1m"`
+	PermitWithoutStream bool          `yaml:"permit_without_stream"`
+}
+
+// Web lets browsers call the gRPC API with gRPC-Web or the Connect protocol
+// on the gRPC port, over HTTP/1.1 or HTTP/2. Pages on allowed_origins may
+// make cross-origin calls, "*" allows any origin.
+type Web struct {
+	Enabled        bool     `yaml:"enabled"`
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+// TLS configures the certificates of a listener, without cert_file it
+// accepts plaintext connections. With client_ca_file client certificates
+// signed by those CAs are verified, require_client_cert turns on mutual TLS.
+// Changed files are picked up every reload_interval.
+type TLS struct {
+	CertFile          string        `yaml:"cert_file"`
+	KeyFile           string        `yaml:"key_file"`
+	ClientCAFile      string        `yaml:"client_ca_file"`
+	RequireClientCert bool          `yaml:"require_client_cert"`
+	MinVersion        string        `yaml:"min_version" env-default:"1.2"`
+	ReloadInterval    time.Duration `yaml:"reload_interval" env-default:"1m"`
 }
 
 type Storage struct {
@@ -33,6 +100,108 @@ type Storage struct {
 	StoragePath string
 }
 
+type Cache struct {
+	AppTTL      time.Duration `yaml:"app_ttl" env-default:"5m"`
+	NegativeTTL time.Duration `yaml:"negative_ttl" env-default:"30s"`
+	MaxSize     int           `yaml:"max_size" env-default:"1024"`
+}
+
+type Users struct {
+	PurgeAfter    time.Duration `yaml:"purge_after" env-default:"720h"`
+	PurgeInterval time.Duration `yaml:"purge_interval" env-default:"1h"`
+}
+
+// Audit configures the audit log. Every anchor_interval the id and hash of
+// the newest event are logged as "audit chain head". Kept with the logs,
+// outside the database, they are anchors for verify-audit.
+type Audit struct {
+	AnchorInterval time.Duration `yaml:"anchor_interval" env-default:"1h"`
+}
+
+// Admin configures the admin API. Only tokens issued for the app app_id,
+// e.g. an admin console, are accepted there, whatever other app the admin
+// logged in to.
+type Admin struct {
+	AppId int64 `yaml:"app_id" env:"ADMIN_APP_ID" env-required:"true"`
+}
+
+// Password configures hashing of new passwords. Hashes made with other
+// settings are upgraded on the next successful login.
+type Password struct {
+	Algorithm         string `yaml:"algorithm" env-default:"argon2id"`
+	Argon2Memory      uint32 `yaml:"argon2_memory" env-default:"65536"`
+	Argon2Iterations  uint32 `yaml:"argon2_iterations" env-default:"3"`
+	Argon2Parallelism uint8  `yaml:"argon2_parallelism" env-default:"2"`
+	ScryptLogN        int    `yaml:"scrypt_log_n" env-default:"15"`
+	ScryptR           int    `yaml:"scrypt_r" env-default:"8"`
+	ScryptP           int    `yaml:"scrypt_p" env-default:"1"`
+	BcryptCost        int    `yaml:"bcrypt_cost" env-default:"12"`
+}
+
+// Notify configures how users are notified, e.g. about logins from new devices.
+type Notify struct {
+	Sender       string `yaml:"sender" env-default:"log"`
+	From         string `yaml:"from"`
+	SMTPHost     string `yaml:"smtp_host"`
+	SMTPPort     int    `yaml:"smtp_port" env-default:"587"`
+	SMTPUsername string `yaml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password" env:"SMTP_PASSWORD"`
+}
+
+// Events configures publishing of domain events from the outbox. With an
+// empty publisher events stay in the outbox until one is configured.
+type Events struct {
+	Publisher         string        `yaml:"publisher"`
+	NATSURL           string        `yaml:"nats_url" env-default:"nats://127.0.0.1:4222"`
+	NATSSubjectPrefix string        `yaml:"nats_subject_prefix" env-default:"sso"`
+	WebhookURL        string        `yaml:"webhook_url"`
+	WebhookTimeout    time.Duration `yaml:"webhook_timeout" env-default:"10s"`
+	PollInterval      time.Duration `yaml:"poll_interval" env-default:"1s"`
+	BatchSize         int           `yaml:"batch_size" env-default:"100"`
+	MaxBackoff        time.Duration `yaml:"max_backoff" env-default:"5m"`
+	Retention         time.Duration `yaml:"retention" env-default:"168h"`
+}
+
+// Webhooks configures delivery of events to app webhook subscriptions.
+type Webhooks struct {
+	Timeout      time.Duration `yaml:"timeout" env-default:"10s"`
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"1s"`
+	BatchSize    int           `yaml:"batch_size" env-default:"50"`
+	MaxAttempts  int           `yaml:"max_attempts" env-default:"12"`
+	MaxBackoff   time.Duration `yaml:"max_backoff" env-default:"1h"`
+	Retention    time.Duration `yaml:"retention" env-default:"720h"`
+	// AllowPrivateHosts lets deliveries reach loopback and private
+	// addresses, e.g. receivers on a development machine.
+	AllowPrivateHosts bool `yaml:"allow_private_hosts"`
+}
+
+// Gateway configures the HTTP/JSON gateway to the gRPC API. With an empty
+// address it is not served. Browser pages on allowed_origins may call it,
+// "*" allows any origin. Without tls.cert_file it serves plaintext HTTP.
+type Gateway struct {
+	Address        string   `yaml:"address"`
+	AllowedOrigins []string `yaml:"allowed_origins"`
+	TLS            TLS      `yaml:"tls"`
+}
+
+// Metrics configures the HTTP listener that serves Prometheus metrics. With
+// an empty address metrics are not served.
+type Metrics struct {
+	Address string `yaml:"address"`
+	Path    string `yaml:"path" env-default:"/metrics"`
+}
+
+// Tracing configures exporting of OpenTelemetry traces. With an empty
+// exporter trace context is still propagated but no spans are recorded.
+type Tracing struct {
+	Exporter     string  `yaml:"exporter"`
+	OTLPEndpoint string  `yaml:"otlp_endpoint" env-default:"localhost:4317"`
+	OTLPInsecure bool    `yaml:"otlp_insecure"`
+	File         string  `yaml:"file" env-default:"traces.json"`
+	ServiceName  string  `yaml:"service_name" env-default:"sso"`
+	SampleRatio  float64 `yaml:"sample_ratio" env-default:"1"`
+}
+
 func MustLoad() *Config {
 	configPath := os.Getenv("CONFIG_PATH")
 	if configPath == "" {
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFiles(t *testing.T) {
+	// secrets of prod.yaml come from the environment
+	t.Setenv("SECRETS_KEY", "secret")
+	t.Setenv("DB_PASS", "secret")
+	t.Setenv("ADMIN_APP_ID", "1")
+
+	paths, err := filepath.Glob("../../config/*.yaml")
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			var cfg Config
+			require.NoError(t, cleanenv.ReadConfig(path, &cfg))
+		})
+	}
+}
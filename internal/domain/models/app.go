@@ -1,7 +1,51 @@
 package models
 
+import "time"
+
 type App struct {
-	Id     int64  `json:"id"`
-	Name   string `json:"name"`
-	Secret string `json:"secret"`
+	Id            int64         `json:"id"`
+	Name          string        `json:"name"`
+	Secret        string        `json:"secret,omitempty"`
+	RedirectURIs  []string      `json:"redirect_uris"`
+	TokenTTL      time.Duration `json:"token_ttl"`
+	Enabled       bool          `json:"enabled"`
+	ClaimRules    []ClaimRule   `json:"claim_rules"`
+	SessionPolicy SessionPolicy `json:"session_policy"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+// AppUpdate describes a partial app update, nil fields are left unchanged.
+type AppUpdate struct {
+	Name         *string
+	RedirectURIs *[]string
+	TokenTTL     *time.Duration
+	Enabled      *bool
+	ClaimRules   *[]ClaimRule
+
+	IdleTimeout        *time.Duration
+	SessionLifetime    *time.Duration
+	MaxSessions        *int
+	SessionLimitAction *string
+	StepUpAfter        *time.Duration
+}
+
+// Session limit actions, applied at Login when the user already has
+// MaxSessions active sessions in the app.
+const (
+	SessionLimitEvictOldest = "evict_oldest"
+	SessionLimitDeny        = "deny"
+)
+
+// SessionPolicy restricts sessions of an app, zero fields mean no limit.
+type SessionPolicy struct {
+	// IdleTimeout revokes a session that has not been used for that long.
+	IdleTimeout time.Duration `json:"idle_timeout"`
+	// Lifetime ends a session that long after Login regardless of refreshes.
+	Lifetime    time.Duration `json:"lifetime"`
+	MaxSessions int           `json:"max_sessions"`
+	LimitAction string        `json:"limit_action"`
+	// StepUpAfter requires the user to enter the password again once that
+	// much time has passed since the session was last authenticated.
+	StepUpAfter time.Duration `json:"step_up_after"`
 }
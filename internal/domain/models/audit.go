@@ -0,0 +1,82 @@
+package models
+
+import "time"
+
+// Audited actions.
+const (
+	AuditLogin       = "login"
+	AuditRegister    = "register"
+	AuditLogout      = "logout"
+	AuditStepUp      = "step_up"
+	AuditSetAdmin    = "set_admin"
+	AuditUpdateUser  = "update_user"
+	AuditDisableUser = "disable_user"
+	AuditEnableUser  = "enable_user"
+	AuditDeleteUser  = "delete_user"
+	AuditExportUser  = "export_user"
+	AuditEraseUser   = "erase_user"
+	AuditImportUsers = "import_users"
+
+	AuditRevokeSession     = "revoke_session"
+	AuditRevokeAllSessions = "revoke_all_sessions"
+)
+
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvent is a security relevant action. Events form a hash chain: Hash
+// covers the event fields and the Hash of the previous event, kept in PrevHash.
+// Zero ActorId, SubjectId and AppId mean the event has none. PersonalDigest,
+// made with PersonalSalt, stands in for IP and UserAgent in Hash, so they
+// can be erased without breaking the chain. Events saved before it existed
+// have neither.
+type AuditEvent struct {
+	Id         int64     `json:"id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Action     string    `json:"action"`
+	ActorId    int64     `json:"actor_id,omitempty"`
+	SubjectId  int64     `json:"subject_id,omitempty"`
+	AppId      int64     `json:"app_id,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	Outcome    string    `json:"outcome"`
+	Reason     string    `json:"reason,omitempty"`
+	PrevHash   []byte    `json:"prev_hash"`
+	Hash       []byte    `json:"hash"`
+
+	PersonalSalt   []byte `json:"-"`
+	PersonalDigest []byte `json:"personal_digest,omitempty"`
+}
+
+// AuditFilter narrows down ListAuditEvents, zero fields match everything.
+type AuditFilter struct {
+	Action    string
+	ActorId   int64
+	SubjectId int64
+	AppId     int64
+	Outcome   string
+	After     time.Time
+	Before    time.Time
+}
+
+// AuditVerification is the result of checking the audit hash chain. BrokenAt
+// is the id of the first event that does not match the chain, Head the last
+// event checked.
+type AuditVerification struct {
+	Checked  int64        `json:"checked"`
+	Valid    bool         `json:"valid"`
+	BrokenAt int64        `json:"broken_at,omitempty"`
+	Reason   string       `json:"reason,omitempty"`
+	Head     *AuditAnchor `json:"head,omitempty"`
+}
+
+// AuditAnchor pins the hash of an event. The chain only proves that events
+// were not changed relative to each other, whoever can write the database
+// can rehash it from scratch. An anchor kept outside the database, e.g. the
+// head logged by the service, catches that.
+type AuditAnchor struct {
+	Id   int64  `json:"id"`
+	Hash []byte `json:"hash"`
+}
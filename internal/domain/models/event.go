@@ -0,0 +1,65 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Domain event types published to downstream consumers.
+const (
+	EventUserRegistered = "user.registered"
+	EventUserUpdated    = "user.updated"
+	EventUserDisabled   = "user.disabled"
+	EventUserEnabled    = "user.enabled"
+	EventUserDeleted    = "user.deleted"
+	EventUserErased     = "user.erased"
+	EventAdminGranted   = "admin.granted"
+	EventAdminRevoked   = "admin.revoked"
+
+	EventAppCreated       = "app.created"
+	EventAppUpdated       = "app.updated"
+	EventAppSecretRotated = "app.secret_rotated"
+	EventAppDeleted       = "app.deleted"
+)
+
+// IsEventType reports whether eventType is a known domain event type.
+func IsEventType(eventType string) bool {
+	switch eventType {
+	case EventUserRegistered, EventUserUpdated, EventUserDisabled, EventUserEnabled, EventUserDeleted, EventUserErased,
+		EventAdminGranted, EventAdminRevoked,
+		EventAppCreated, EventAppUpdated, EventAppSecretRotated, EventAppDeleted:
+		return true
+	}
+	return false
+}
+
+// Event is a domain event written to the outbox together with the state change
+// it describes. Events are delivered at least once, consumers deduplicate them
+// by Id.
+type Event struct {
+	Id         string          `json:"id"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload"`
+	OccurredAt time.Time       `json:"occurred_at"`
+
+	// Seq is the outbox position and Attempts the number of delivery
+	// attempts so far, neither is published.
+	Seq      int64 `json:"-"`
+	Attempts int   `json:"-"`
+}
+
+// UserEvent is the payload of user.* and admin.* events. Email is omitted
+// from user.erased.
+type UserEvent struct {
+	UserId int64  `json:"user_id"`
+	Email  string `json:"email,omitempty"`
+	// Source is "import" for users created by ImportUsers.
+	Source string `json:"source,omitempty"`
+}
+
+// AppEvent is the payload of app.* events, Name is omitted from
+// app.secret_rotated and app.deleted.
+type AppEvent struct {
+	AppId int64  `json:"app_id"`
+	Name  string `json:"name,omitempty"`
+}
@@ -0,0 +1,34 @@
+package models
+
+// ImportedUser is a user exported from another auth system together with its
+// password hash in that system's format.
+type ImportedUser struct {
+	Line         int    `json:"-"`
+	Email        string `json:"email"`
+	Username     string `json:"username,omitempty"`
+	Phone        string `json:"phone,omitempty"`
+	PasswordHash string `json:"password_hash"`
+	HashFormat   string `json:"hash_format"`
+	Salt         string `json:"salt,omitempty"`
+}
+
+type ImportError struct {
+	Line  int    `json:"line"`
+	Email string `json:"email"`
+	Error string `json:"error"`
+}
+
+type ImportResult struct {
+	Imported int           `json:"imported"`
+	Skipped  int           `json:"skipped"`
+	Failed   int           `json:"failed"`
+	Errors   []ImportError `json:"errors,omitempty"`
+}
+
+// Add accumulates the result of another import batch.
+func (r *ImportResult) Add(other ImportResult) {
+	r.Imported += other.Imported
+	r.Skipped += other.Skipped
+	r.Failed += other.Failed
+	r.Errors = append(r.Errors, other.Errors...)
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// LoginAttempt is a successful or failed Login. UserId is zero when the login
+// did not match any user, Login keeps the identifier as it was entered.
+type LoginAttempt struct {
+	Id         int64     `json:"id"`
+	UserId     int64     `json:"user_id,omitempty"`
+	AppId      int64     `json:"app_id,omitempty"`
+	Login      string    `json:"login"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	DeviceId   string    `json:"device_id,omitempty"`
+	Success    bool      `json:"success"`
+	Reason     string    `json:"reason,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Device is a device the user has successfully logged in from.
+type Device struct {
+	DeviceId    string    `json:"device_id"`
+	IP          string    `json:"ip,omitempty"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RoleAdmin is the only role a user can currently hold.
+const RoleAdmin = "admin"
+
+// UserExport bundles everything stored about a user, it answers data-subject
+// access requests.
+type UserExport struct {
+	ExportedAt  time.Time      `json:"exported_at"`
+	User        User           `json:"user"`
+	Roles       []string       `json:"roles"`
+	Profile     Profile        `json:"profile"`
+	AppMetadata []AppMetadata  `json:"app_metadata"`
+	AuditEvents []AuditEvent   `json:"audit_events"`
+	Logins      []LoginAttempt `json:"logins"`
+	Devices     []Device       `json:"devices"`
+	Sessions    []Session      `json:"sessions"`
+}
+
+type AppMetadata struct {
+	AppId     int64           `json:"app_id"`
+	Metadata  json.RawMessage `json:"metadata"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Profile attributes that can be mapped to token claims.
+const (
+	AttributeDisplayName = "display_name"
+	AttributeLocale      = "locale"
+	AttributeTimezone    = "timezone"
+	AttributeAvatarURL   = "avatar_url"
+	// AttributeMetadata maps the whole per-app metadata object, while
+	// "metadata.<key>" maps a single top-level key of it.
+	AttributeMetadata = "metadata"
+)
+
+type Profile struct {
+	UserId      int64     `json:"user_id"`
+	DisplayName string    `json:"display_name"`
+	Locale      string    `json:"locale"`
+	Timezone    string    `json:"timezone"`
+	AvatarURL   string    `json:"avatar_url"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ProfileUpdate describes a partial profile update, nil fields are left unchanged.
+type ProfileUpdate struct {
+	DisplayName *string
+	Locale      *string
+	Timezone    *string
+	AvatarURL   *string
+}
+
+// ClaimRule puts a profile attribute into tokens issued for an app.
+type ClaimRule struct {
+	Claim     string `json:"claim"`
+	Attribute string `json:"attribute"`
+}
+
+// IsKnownAttribute reports whether attribute can be used in a ClaimRule.
+func IsKnownAttribute(attribute string) bool {
+	switch attribute {
+	case AttributeDisplayName, AttributeLocale, AttributeTimezone, AttributeAvatarURL, AttributeMetadata:
+		return true
+	}
+	key, ok := strings.CutPrefix(attribute, AttributeMetadata+".")
+	return ok && key != ""
+}
+
+// Attribute resolves a claim rule attribute against the profile and the
+// per-app metadata. Empty values are reported as missing.
+func (p *Profile) Attribute(attribute string, metadata json.RawMessage) (any, bool) {
+	var value string
+	switch attribute {
+	case AttributeDisplayName:
+		value = p.DisplayName
+	case AttributeLocale:
+		value = p.Locale
+	case AttributeTimezone:
+		value = p.Timezone
+	case AttributeAvatarURL:
+		value = p.AvatarURL
+	default:
+		return metadataAttribute(attribute, metadata)
+	}
+	return value, value != ""
+}
+
+func metadataAttribute(attribute string, metadata json.RawMessage) (any, bool) {
+	if len(metadata) == 0 {
+		return nil, false
+	}
+
+	var object map[string]any
+	if err := json.Unmarshal(metadata, &object); err != nil || len(object) == 0 {
+		return nil, false
+	}
+	if attribute == AttributeMetadata {
+		return object, true
+	}
+
+	key, ok := strings.CutPrefix(attribute, AttributeMetadata+".")
+	if !ok {
+		return nil, false
+	}
+	value, ok := object[key]
+	return value, ok
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Session is created by a successful Login, the tokens issued for it carry
+// its id and stop being valid once it is revoked. AuthenticatedAt is when the
+// user last entered the password for the session, at Login or at a step-up.
+type Session struct {
+	Id              string     `json:"id"`
+	UserId          int64      `json:"user_id"`
+	AppId           int64      `json:"app_id"`
+	DeviceId        string     `json:"device_id,omitempty"`
+	IP              string     `json:"ip,omitempty"`
+	UserAgent       string     `json:"user_agent,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	AuthenticatedAt time.Time  `json:"authenticated_at"`
+	LastSeenAt      time.Time  `json:"last_seen_at"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty"`
+}
+
+// IsActive reports whether tokens of the session are still accepted.
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
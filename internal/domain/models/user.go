@@ -1,7 +1,37 @@
 package models
 
+import "time"
+
 type User struct {
-	Id       int64  `json:"id" db:"id"`
-	Email    string `json:"email" db:"user_email"`
-	PassHash []byte `json:"password_hash" db:"pass_hash"`
+	Id         int64      `json:"id" db:"id"`
+	Email      string     `json:"email" db:"user_email"`
+	Username   string     `json:"username,omitempty" db:"username"`
+	Phone      string     `json:"phone,omitempty" db:"phone"`
+	PassHash   []byte     `json:"-" db:"pass_hash"`
+	IsAdmin    bool       `json:"is_admin" db:"is_admin"`
+	CreatedAt  time.Time  `json:"created_at" db:"timestamp"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty" db:"disabled_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
+}
+
+// UserFilter narrows down ListUsers, zero fields match everything.
+type UserFilter struct {
+	EmailPrefix   string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	IsAdmin       *bool
+	IsDisabled    *bool
+}
+
+// UserUpdate describes a partial user update, nil fields are left unchanged.
+// An empty Username or Phone removes the identifier.
+type UserUpdate struct {
+	Email    *string
+	Username *string
+	Phone    *string
+	IsAdmin  *bool
 }
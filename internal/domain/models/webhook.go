@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Webhook delivery statuses. A delivery is dead once it failed the maximum
+// number of attempts, it is only retried by a manual redelivery.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// WebhookSubscription delivers domain events to an app over HTTPS, signed
+// with Secret.
+type WebhookSubscription struct {
+	Id     int64  `json:"id"`
+	AppId  int64  `json:"app_id"`
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+	// EventTypes limits the delivered events, empty means all of them.
+	EventTypes []string  `json:"event_types"`
+	Enabled    bool      `json:"enabled"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// WebhookSubscriptionUpdate describes a partial subscription update, nil
+// fields are left unchanged.
+type WebhookSubscriptionUpdate struct {
+	URL        *string
+	EventTypes *[]string
+	Enabled    *bool
+}
+
+// WebhookDelivery is one event queued for one subscription.
+type WebhookDelivery struct {
+	Id             int64      `json:"id"`
+	SubscriptionId int64      `json:"subscription_id"`
+	Event          Event      `json:"event"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// WebhookAttempt is the log entry of one delivery attempt.
+type WebhookAttempt struct {
+	Id          int64         `json:"id"`
+	DeliveryId  int64         `json:"delivery_id"`
+	AttemptedAt time.Time     `json:"attempted_at"`
+	StatusCode  int           `json:"status_code,omitempty"`
+	Error       string        `json:"error,omitempty"`
+	Duration    time.Duration `json:"duration"`
+}
@@ -0,0 +1,78 @@
+// Package admin is the API for administrators: apps, users and what belongs
+// to them. Its RPCs are not in the published protos, so the messages are
+// plain structs carrying their JSON names and, for the HTTP gateway, the
+// path and query parameters they are read from. The gRPC server serves them
+// with JSON messages, see gateway.Services. Every method needs an admin
+// token, see interceptors.UnaryAdmin with Service.
+package admin
+
+import (
+	"errors"
+	appsservice "sso/internal/services/apps"
+	auditservice "sso/internal/services/audit"
+	sessionsservice "sso/internal/services/sessions"
+	usersservice "sso/internal/services/users"
+	watchservice "sso/internal/services/watch"
+	webhooksservice "sso/internal/services/webhooks"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const emptyValue = 0
+
+// Service prefixes the full names of the admin methods.
+const Service = "/admin.Admin/"
+
+// Method returns the full name of the admin method name.
+func Method(name string) string {
+	return Service + name
+}
+
+type errorCode struct {
+	err  error
+	code codes.Code
+}
+
+// errorCodes maps the errors of the services to status codes, anything else
+// is Internal.
+var errorCodes = []errorCode{
+	{appsservice.ErrAppNotFound, codes.NotFound},
+	{appsservice.ErrAppAlreadyExists, codes.AlreadyExists},
+	{appsservice.ErrInvalidApp, codes.InvalidArgument},
+	{appsservice.ErrInvalidPageToken, codes.InvalidArgument},
+
+	{usersservice.ErrUserNotFound, codes.NotFound},
+	{usersservice.ErrUserAlreadyExists, codes.AlreadyExists},
+	{usersservice.ErrInvalidEmail, codes.InvalidArgument},
+	{usersservice.ErrInvalidIdentifier, codes.InvalidArgument},
+	{usersservice.ErrInvalidPageToken, codes.InvalidArgument},
+	{usersservice.ErrInvalidProfile, codes.InvalidArgument},
+	{usersservice.ErrInvalidMetadata, codes.InvalidArgument},
+	{usersservice.ErrAppNotFound, codes.NotFound},
+
+	{auditservice.ErrInvalidPageToken, codes.InvalidArgument},
+
+	{sessionsservice.ErrSessionNotFound, codes.NotFound},
+
+	{webhooksservice.ErrSubscriptionNotFound, codes.NotFound},
+	{webhooksservice.ErrDeliveryNotFound, codes.NotFound},
+	{webhooksservice.ErrAppNotFound, codes.NotFound},
+	{webhooksservice.ErrInvalidSubscription, codes.InvalidArgument},
+	{webhooksservice.ErrInvalidStatus, codes.InvalidArgument},
+	{webhooksservice.ErrInvalidPageToken, codes.InvalidArgument},
+
+	{watchservice.ErrInvalidCursor, codes.InvalidArgument},
+	{watchservice.ErrCursorExpired, codes.FailedPrecondition},
+}
+
+func statusError(msg string, err error) error {
+	code := codes.Internal
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			code = c.code
+			break
+		}
+	}
+	return status.Errorf(code, "%s: %v", msg, err)
+}
@@ -0,0 +1,304 @@
+package admin
+
+import (
+	"context"
+	"sso/internal/domain/models"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Durations are whole seconds, like the app settings they describe.
+
+type ClaimRule struct {
+	Claim     string `json:"claim"`
+	Attribute string `json:"attribute"`
+}
+
+type SessionPolicy struct {
+	IdleTimeoutSeconds int64  `json:"idleTimeoutSeconds"`
+	LifetimeSeconds    int64  `json:"lifetimeSeconds"`
+	MaxSessions        int32  `json:"maxSessions"`
+	LimitAction        string `json:"limitAction"`
+	StepUpAfterSeconds int64  `json:"stepUpAfterSeconds"`
+}
+
+type App struct {
+	Id int64 `json:"id"`
+	// Secret is only set when the app is created.
+	Secret          string        `json:"secret,omitempty"`
+	Name            string        `json:"name"`
+	RedirectUris    []string      `json:"redirectUris"`
+	TokenTtlSeconds int64         `json:"tokenTtlSeconds"`
+	Enabled         bool          `json:"enabled"`
+	ClaimRules      []ClaimRule   `json:"claimRules"`
+	SessionPolicy   SessionPolicy `json:"sessionPolicy"`
+	CreatedAt       time.Time     `json:"createdAt"`
+	UpdatedAt       time.Time     `json:"updatedAt"`
+}
+
+type CreateAppRequest struct {
+	Name            string        `json:"name"`
+	RedirectUris    []string      `json:"redirectUris"`
+	TokenTtlSeconds int64         `json:"tokenTtlSeconds"`
+	Disabled        bool          `json:"disabled"`
+	ClaimRules      []ClaimRule   `json:"claimRules"`
+	SessionPolicy   SessionPolicy `json:"sessionPolicy"`
+}
+
+type CreateAppResponse struct {
+	App *App `json:"app"`
+}
+
+type GetAppRequest struct {
+	AppId int64 `json:"-" path:"appId"`
+}
+
+type GetAppResponse struct {
+	App *App `json:"app"`
+}
+
+type ListAppsRequest struct {
+	PageToken string `json:"-" query:"pageToken"`
+	PageSize  int32  `json:"-" query:"pageSize"`
+}
+
+type ListAppsResponse struct {
+	Apps          []*App `json:"apps"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// UpdateAppRequest changes the fields that are set, the others are left
+// unchanged.
+type UpdateAppRequest struct {
+	AppId                  int64        `json:"-" path:"appId"`
+	Name                   *string      `json:"name"`
+	RedirectUris           *[]string    `json:"redirectUris"`
+	TokenTtlSeconds        *int64       `json:"tokenTtlSeconds"`
+	Enabled                *bool        `json:"enabled"`
+	ClaimRules             *[]ClaimRule `json:"claimRules"`
+	IdleTimeoutSeconds     *int64       `json:"idleTimeoutSeconds"`
+	SessionLifetimeSeconds *int64       `json:"sessionLifetimeSeconds"`
+	MaxSessions            *int32       `json:"maxSessions"`
+	SessionLimitAction     *string      `json:"sessionLimitAction"`
+	StepUpAfterSeconds     *int64       `json:"stepUpAfterSeconds"`
+}
+
+type UpdateAppResponse struct {
+	App *App `json:"app"`
+}
+
+type RotateAppSecretRequest struct {
+	AppId int64 `json:"-" path:"appId"`
+}
+
+type RotateAppSecretResponse struct {
+	Secret string `json:"secret"`
+}
+
+type DeleteAppRequest struct {
+	AppId int64 `json:"-" path:"appId"`
+}
+
+type DeleteAppResponse struct{}
+
+type Apps interface {
+	CreateApp(ctx context.Context, app models.App) (*models.App, error)
+	GetApp(ctx context.Context, appId int64) (*models.App, error)
+	ListApps(ctx context.Context, pageToken string, pageSize int) ([]models.App, string, error)
+	UpdateApp(ctx context.Context, appId int64, update models.AppUpdate) (*models.App, error)
+	RotateAppSecret(ctx context.Context, appId int64) (string, error)
+	DeleteApp(ctx context.Context, appId int64) error
+}
+
+type AppsServer interface {
+	CreateApp(ctx context.Context, req *CreateAppRequest) (*CreateAppResponse, error)
+	GetApp(ctx context.Context, req *GetAppRequest) (*GetAppResponse, error)
+	ListApps(ctx context.Context, req *ListAppsRequest) (*ListAppsResponse, error)
+	UpdateApp(ctx context.Context, req *UpdateAppRequest) (*UpdateAppResponse, error)
+	RotateAppSecret(ctx context.Context, req *RotateAppSecretRequest) (*RotateAppSecretResponse, error)
+	DeleteApp(ctx context.Context, req *DeleteAppRequest) (*DeleteAppResponse, error)
+}
+
+type appsAPI struct {
+	apps Apps
+}
+
+func NewAppsAPI(apps Apps) AppsServer {
+	return &appsAPI{apps: apps}
+}
+
+func (s *appsAPI) CreateApp(ctx context.Context, req *CreateAppRequest) (*CreateAppResponse, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name must be provided")
+	}
+
+	app, err := s.apps.CreateApp(ctx, models.App{
+		Name:          req.Name,
+		RedirectURIs:  req.RedirectUris,
+		TokenTTL:      seconds(req.TokenTtlSeconds),
+		Enabled:       !req.Disabled,
+		ClaimRules:    claimRulesFromMessage(req.ClaimRules),
+		SessionPolicy: sessionPolicyFromMessage(req.SessionPolicy),
+	})
+	if err != nil {
+		return nil, statusError("failed to create app", err)
+	}
+
+	return &CreateAppResponse{
+		App: appMessage(app),
+	}, nil
+}
+
+func (s *appsAPI) GetApp(ctx context.Context, req *GetAppRequest) (*GetAppResponse, error) {
+	if req.AppId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "appId must be provided")
+	}
+
+	app, err := s.apps.GetApp(ctx, req.AppId)
+	if err != nil {
+		return nil, statusError("failed to get app", err)
+	}
+
+	return &GetAppResponse{
+		App: appMessage(app),
+	}, nil
+}
+
+func (s *appsAPI) ListApps(ctx context.Context, req *ListAppsRequest) (*ListAppsResponse, error) {
+	apps, next, err := s.apps.ListApps(ctx, req.PageToken, int(req.PageSize))
+	if err != nil {
+		return nil, statusError("failed to list apps", err)
+	}
+
+	res := &ListAppsResponse{
+		Apps:          make([]*App, 0, len(apps)),
+		NextPageToken: next,
+	}
+	for i := range apps {
+		res.Apps = append(res.Apps, appMessage(&apps[i]))
+	}
+	return res, nil
+}
+
+func (s *appsAPI) UpdateApp(ctx context.Context, req *UpdateAppRequest) (*UpdateAppResponse, error) {
+	if req.AppId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "appId must be provided")
+	}
+
+	update := models.AppUpdate{
+		Name:               req.Name,
+		RedirectURIs:       req.RedirectUris,
+		TokenTTL:           secondsPtr(req.TokenTtlSeconds),
+		Enabled:            req.Enabled,
+		IdleTimeout:        secondsPtr(req.IdleTimeoutSeconds),
+		SessionLifetime:    secondsPtr(req.SessionLifetimeSeconds),
+		SessionLimitAction: req.SessionLimitAction,
+		StepUpAfter:        secondsPtr(req.StepUpAfterSeconds),
+	}
+	if req.ClaimRules != nil {
+		rules := claimRulesFromMessage(*req.ClaimRules)
+		update.ClaimRules = &rules
+	}
+	if req.MaxSessions != nil {
+		maxSessions := int(*req.MaxSessions)
+		update.MaxSessions = &maxSessions
+	}
+
+	app, err := s.apps.UpdateApp(ctx, req.AppId, update)
+	if err != nil {
+		return nil, statusError("failed to update app", err)
+	}
+
+	return &UpdateAppResponse{
+		App: appMessage(app),
+	}, nil
+}
+
+func (s *appsAPI) RotateAppSecret(ctx context.Context, req *RotateAppSecretRequest) (*RotateAppSecretResponse, error) {
+	if req.AppId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "appId must be provided")
+	}
+
+	secret, err := s.apps.RotateAppSecret(ctx, req.AppId)
+	if err != nil {
+		return nil, statusError("failed to rotate app secret", err)
+	}
+
+	return &RotateAppSecretResponse{
+		Secret: secret,
+	}, nil
+}
+
+func (s *appsAPI) DeleteApp(ctx context.Context, req *DeleteAppRequest) (*DeleteAppResponse, error) {
+	if req.AppId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "appId must be provided")
+	}
+
+	if err := s.apps.DeleteApp(ctx, req.AppId); err != nil {
+		return nil, statusError("failed to delete app", err)
+	}
+
+	return &DeleteAppResponse{}, nil
+}
+
+func appMessage(app *models.App) *App {
+	rules := make([]ClaimRule, 0, len(app.ClaimRules))
+	for _, rule := range app.ClaimRules {
+		rules = append(rules, ClaimRule{Claim: rule.Claim, Attribute: rule.Attribute})
+	}
+	policy := app.SessionPolicy
+
+	return &App{
+		Id:              app.Id,
+		Secret:          app.Secret,
+		Name:            app.Name,
+		RedirectUris:    app.RedirectURIs,
+		TokenTtlSeconds: int64(app.TokenTTL / time.Second),
+		Enabled:         app.Enabled,
+		ClaimRules:      rules,
+		SessionPolicy: SessionPolicy{
+			IdleTimeoutSeconds: int64(policy.IdleTimeout / time.Second),
+			LifetimeSeconds:    int64(policy.Lifetime / time.Second),
+			MaxSessions:        int32(policy.MaxSessions),
+			LimitAction:        policy.LimitAction,
+			StepUpAfterSeconds: int64(policy.StepUpAfter / time.Second),
+		},
+		CreatedAt: app.CreatedAt,
+		UpdatedAt: app.UpdatedAt,
+	}
+}
+
+func claimRulesFromMessage(rules []ClaimRule) []models.ClaimRule {
+	if rules == nil {
+		return nil
+	}
+	converted := make([]models.ClaimRule, 0, len(rules))
+	for _, rule := range rules {
+		converted = append(converted, models.ClaimRule{Claim: rule.Claim, Attribute: rule.Attribute})
+	}
+	return converted
+}
+
+func sessionPolicyFromMessage(policy SessionPolicy) models.SessionPolicy {
+	return models.SessionPolicy{
+		IdleTimeout: seconds(policy.IdleTimeoutSeconds),
+		Lifetime:    seconds(policy.LifetimeSeconds),
+		MaxSessions: int(policy.MaxSessions),
+		LimitAction: policy.LimitAction,
+		StepUpAfter: seconds(policy.StepUpAfterSeconds),
+	}
+}
+
+func seconds(s int64) time.Duration {
+	return time.Duration(s) * time.Second
+}
+
+func secondsPtr(s *int64) *time.Duration {
+	if s == nil {
+		return nil
+	}
+	d := seconds(*s)
+	return &d
+}
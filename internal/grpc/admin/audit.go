@@ -0,0 +1,151 @@
+package admin
+
+import (
+	"context"
+	"encoding/hex"
+	"sso/internal/domain/models"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AuditEvent is an entry of the audit hash chain, hashes are hex encoded.
+type AuditEvent struct {
+	Id         int64     `json:"id"`
+	OccurredAt time.Time `json:"occurredAt"`
+	Action     string    `json:"action"`
+	ActorId    int64     `json:"actorId"`
+	SubjectId  int64     `json:"subjectId"`
+	AppId      int64     `json:"appId"`
+	Ip         string    `json:"ip"`
+	UserAgent  string    `json:"userAgent"`
+	Outcome    string    `json:"outcome"`
+	Reason     string    `json:"reason"`
+	PrevHash   string    `json:"prevHash"`
+	Hash       string    `json:"hash"`
+}
+
+func auditEventMessage(event *models.AuditEvent) *AuditEvent {
+	return &AuditEvent{
+		Id:         event.Id,
+		OccurredAt: event.OccurredAt,
+		Action:     event.Action,
+		ActorId:    event.ActorId,
+		SubjectId:  event.SubjectId,
+		AppId:      event.AppId,
+		Ip:         event.IP,
+		UserAgent:  event.UserAgent,
+		Outcome:    event.Outcome,
+		Reason:     event.Reason,
+		PrevHash:   hex.EncodeToString(event.PrevHash),
+		Hash:       hex.EncodeToString(event.Hash),
+	}
+}
+
+// ListAuditEventsRequest filters by the parameters that are set.
+type ListAuditEventsRequest struct {
+	Action    string    `json:"-" query:"action"`
+	ActorId   int64     `json:"-" query:"actorId"`
+	SubjectId int64     `json:"-" query:"subjectId"`
+	AppId     int64     `json:"-" query:"appId"`
+	Outcome   string    `json:"-" query:"outcome"`
+	After     time.Time `json:"-" query:"after"`
+	Before    time.Time `json:"-" query:"before"`
+	PageToken string    `json:"-" query:"pageToken"`
+	PageSize  int32     `json:"-" query:"pageSize"`
+}
+
+type ListAuditEventsResponse struct {
+	Events        []*AuditEvent `json:"events"`
+	NextPageToken string        `json:"nextPageToken"`
+}
+
+// VerifyAuditChainRequest optionally pins the hash of an event, an anchor
+// kept outside the database such as a head reported earlier.
+type VerifyAuditChainRequest struct {
+	AnchorId   int64  `json:"-" query:"anchorId"`
+	AnchorHash string `json:"-" query:"anchorHash"`
+}
+
+// VerifyAuditChainResponse reports the first event that does not match the
+// chain in brokenAt and the last event checked in headId and headHash.
+type VerifyAuditChainResponse struct {
+	Checked  int64  `json:"checked"`
+	Valid    bool   `json:"valid"`
+	BrokenAt int64  `json:"brokenAt"`
+	Reason   string `json:"reason"`
+	HeadId   int64  `json:"headId"`
+	HeadHash string `json:"headHash"`
+}
+
+type Audit interface {
+	ListAuditEvents(ctx context.Context, filter models.AuditFilter, pageToken string, pageSize int) ([]models.AuditEvent, string, error)
+	VerifyChain(ctx context.Context, anchor *models.AuditAnchor) (*models.AuditVerification, error)
+}
+
+type AuditServer interface {
+	ListAuditEvents(ctx context.Context, req *ListAuditEventsRequest) (*ListAuditEventsResponse, error)
+	VerifyAuditChain(ctx context.Context, req *VerifyAuditChainRequest) (*VerifyAuditChainResponse, error)
+}
+
+type auditAPI struct {
+	audit Audit
+}
+
+func NewAuditAPI(audit Audit) AuditServer {
+	return &auditAPI{audit: audit}
+}
+
+func (s *auditAPI) ListAuditEvents(ctx context.Context, req *ListAuditEventsRequest) (*ListAuditEventsResponse, error) {
+	filter := models.AuditFilter{
+		Action:    req.Action,
+		ActorId:   req.ActorId,
+		SubjectId: req.SubjectId,
+		AppId:     req.AppId,
+		Outcome:   req.Outcome,
+		After:     req.After,
+		Before:    req.Before,
+	}
+	events, next, err := s.audit.ListAuditEvents(ctx, filter, req.PageToken, int(req.PageSize))
+	if err != nil {
+		return nil, statusError("failed to list audit events", err)
+	}
+
+	res := &ListAuditEventsResponse{
+		Events:        make([]*AuditEvent, 0, len(events)),
+		NextPageToken: next,
+	}
+	for i := range events {
+		res.Events = append(res.Events, auditEventMessage(&events[i]))
+	}
+	return res, nil
+}
+
+func (s *auditAPI) VerifyAuditChain(ctx context.Context, req *VerifyAuditChainRequest) (*VerifyAuditChainResponse, error) {
+	var anchor *models.AuditAnchor
+	if req.AnchorId != emptyValue || req.AnchorHash != "" {
+		hash, err := hex.DecodeString(req.AnchorHash)
+		if req.AnchorId == emptyValue || len(hash) == 0 || err != nil {
+			return nil, status.Error(codes.InvalidArgument, "anchorId and a hex anchorHash must be provided together")
+		}
+		anchor = &models.AuditAnchor{Id: req.AnchorId, Hash: hash}
+	}
+
+	result, err := s.audit.VerifyChain(ctx, anchor)
+	if err != nil {
+		return nil, statusError("failed to verify audit chain", err)
+	}
+
+	res := &VerifyAuditChainResponse{
+		Checked:  result.Checked,
+		Valid:    result.Valid,
+		BrokenAt: result.BrokenAt,
+		Reason:   result.Reason,
+	}
+	if result.Head != nil {
+		res.HeadId = result.Head.Id
+		res.HeadHash = hex.EncodeToString(result.Head.Hash)
+	}
+	return res, nil
+}
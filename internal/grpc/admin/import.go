@@ -0,0 +1,93 @@
+package admin
+
+import (
+	"context"
+	"sso/internal/domain/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ImportedUser is one user of an ImportUsersRequest, hashFormat names the
+// format of passwordHash, e.g. bcrypt.
+type ImportedUser struct {
+	Email        string `json:"email"`
+	Username     string `json:"username"`
+	Phone        string `json:"phone"`
+	PasswordHash string `json:"passwordHash"`
+	HashFormat   string `json:"hashFormat"`
+	Salt         string `json:"salt"`
+}
+
+// ImportUsersRequest carries one batch of users, the request size limit of
+// the transport bounds it. With dryRun the users are only validated.
+type ImportUsersRequest struct {
+	Users  []ImportedUser `json:"users"`
+	DryRun bool           `json:"dryRun"`
+}
+
+// ImportError reports a user that was not imported, index is its position
+// in the request.
+type ImportError struct {
+	Index int32  `json:"index"`
+	Email string `json:"email"`
+	Error string `json:"error"`
+}
+
+type ImportUsersResponse struct {
+	Imported int32         `json:"imported"`
+	Skipped  int32         `json:"skipped"`
+	Failed   int32         `json:"failed"`
+	Errors   []ImportError `json:"errors"`
+}
+
+type Importer interface {
+	ImportUsers(ctx context.Context, records []models.ImportedUser, dryRun bool) (models.ImportResult, error)
+}
+
+type ImportServer interface {
+	ImportUsers(ctx context.Context, req *ImportUsersRequest) (*ImportUsersResponse, error)
+}
+
+type importAPI struct {
+	importer Importer
+}
+
+func NewImportAPI(importer Importer) ImportServer {
+	return &importAPI{importer: importer}
+}
+
+func (s *importAPI) ImportUsers(ctx context.Context, req *ImportUsersRequest) (*ImportUsersResponse, error) {
+	if len(req.Users) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "users must be provided")
+	}
+
+	records := make([]models.ImportedUser, 0, len(req.Users))
+	for i, user := range req.Users {
+		records = append(records, models.ImportedUser{
+			Line:         i,
+			Email:        user.Email,
+			Username:     user.Username,
+			Phone:        user.Phone,
+			PasswordHash: user.PasswordHash,
+			HashFormat:   user.HashFormat,
+			Salt:         user.Salt,
+		})
+	}
+
+	result, err := s.importer.ImportUsers(ctx, records, req.DryRun)
+	if err != nil {
+		return nil, statusError("failed to import users", err)
+	}
+
+	res := &ImportUsersResponse{
+		Imported: int32(result.Imported),
+		Skipped:  int32(result.Skipped),
+		Failed:   int32(result.Failed),
+		Errors:   make([]ImportError, 0, len(result.Errors)),
+	}
+	for _, e := range result.Errors {
+		res.Errors = append(res.Errors, ImportError{Index: int32(e.Line), Email: e.Email, Error: e.Error})
+	}
+	return res, nil
+}
@@ -0,0 +1,135 @@
+package admin
+
+import (
+	"context"
+	"sso/internal/domain/models"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// LoginAttempt is a successful or failed login, userId is 0 when the login
+// did not match any user.
+type LoginAttempt struct {
+	Id         int64     `json:"id"`
+	UserId     int64     `json:"userId"`
+	AppId      int64     `json:"appId"`
+	Login      string    `json:"login"`
+	Ip         string    `json:"ip"`
+	UserAgent  string    `json:"userAgent"`
+	DeviceId   string    `json:"deviceId"`
+	Success    bool      `json:"success"`
+	Reason     string    `json:"reason"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+type Device struct {
+	DeviceId    string    `json:"deviceId"`
+	Ip          string    `json:"ip"`
+	UserAgent   string    `json:"userAgent"`
+	FirstSeenAt time.Time `json:"firstSeenAt"`
+	LastSeenAt  time.Time `json:"lastSeenAt"`
+}
+
+func loginAttemptMessage(attempt *models.LoginAttempt) *LoginAttempt {
+	return &LoginAttempt{
+		Id:         attempt.Id,
+		UserId:     attempt.UserId,
+		AppId:      attempt.AppId,
+		Login:      attempt.Login,
+		Ip:         attempt.IP,
+		UserAgent:  attempt.UserAgent,
+		DeviceId:   attempt.DeviceId,
+		Success:    attempt.Success,
+		Reason:     attempt.Reason,
+		OccurredAt: attempt.OccurredAt,
+	}
+}
+
+func deviceMessage(device *models.Device) *Device {
+	return &Device{
+		DeviceId:    device.DeviceId,
+		Ip:          device.IP,
+		UserAgent:   device.UserAgent,
+		FirstSeenAt: device.FirstSeenAt,
+		LastSeenAt:  device.LastSeenAt,
+	}
+}
+
+type GetLoginHistoryRequest struct {
+	UserId    int64  `json:"-" path:"userId"`
+	PageToken string `json:"-" query:"pageToken"`
+	PageSize  int32  `json:"-" query:"pageSize"`
+}
+
+// GetLoginHistoryResponse lists the attempts newest first.
+type GetLoginHistoryResponse struct {
+	Attempts      []*LoginAttempt `json:"attempts"`
+	NextPageToken string          `json:"nextPageToken"`
+}
+
+type ListDevicesRequest struct {
+	UserId int64 `json:"-" path:"userId"`
+}
+
+type ListDevicesResponse struct {
+	Devices []*Device `json:"devices"`
+}
+
+type Logins interface {
+	GetLoginHistory(ctx context.Context, userId int64, pageToken string, pageSize int) ([]models.LoginAttempt, string, error)
+	ListDevices(ctx context.Context, userId int64) ([]models.Device, error)
+}
+
+type LoginsServer interface {
+	GetLoginHistory(ctx context.Context, req *GetLoginHistoryRequest) (*GetLoginHistoryResponse, error)
+	ListDevices(ctx context.Context, req *ListDevicesRequest) (*ListDevicesResponse, error)
+}
+
+type loginsAPI struct {
+	logins Logins
+}
+
+func NewLoginsAPI(logins Logins) LoginsServer {
+	return &loginsAPI{logins: logins}
+}
+
+func (s *loginsAPI) GetLoginHistory(ctx context.Context, req *GetLoginHistoryRequest) (*GetLoginHistoryResponse, error) {
+	if req.UserId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "userId must be provided")
+	}
+
+	attempts, next, err := s.logins.GetLoginHistory(ctx, req.UserId, req.PageToken, int(req.PageSize))
+	if err != nil {
+		return nil, statusError("failed to get login history", err)
+	}
+
+	res := &GetLoginHistoryResponse{
+		Attempts:      make([]*LoginAttempt, 0, len(attempts)),
+		NextPageToken: next,
+	}
+	for i := range attempts {
+		res.Attempts = append(res.Attempts, loginAttemptMessage(&attempts[i]))
+	}
+	return res, nil
+}
+
+func (s *loginsAPI) ListDevices(ctx context.Context, req *ListDevicesRequest) (*ListDevicesResponse, error) {
+	if req.UserId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "userId must be provided")
+	}
+
+	devices, err := s.logins.ListDevices(ctx, req.UserId)
+	if err != nil {
+		return nil, statusError("failed to list devices", err)
+	}
+
+	res := &ListDevicesResponse{
+		Devices: make([]*Device, 0, len(devices)),
+	}
+	for i := range devices {
+		res.Devices = append(res.Devices, deviceMessage(&devices[i]))
+	}
+	return res, nil
+}
@@ -0,0 +1,114 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"sso/internal/domain/models"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type AppMetadata struct {
+	AppId     int64           `json:"appId"`
+	Metadata  json.RawMessage `json:"metadata"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+type ExportUserRequest struct {
+	UserId int64 `json:"-" path:"userId"`
+}
+
+// ExportUserResponse is everything stored about the user, it answers
+// data-subject access requests.
+type ExportUserResponse struct {
+	ExportedAt  time.Time       `json:"exportedAt"`
+	User        *User           `json:"user"`
+	Roles       []string        `json:"roles"`
+	Profile     *Profile        `json:"profile"`
+	AppMetadata []*AppMetadata  `json:"appMetadata"`
+	AuditEvents []*AuditEvent   `json:"auditEvents"`
+	Logins      []*LoginAttempt `json:"logins"`
+	Devices     []*Device       `json:"devices"`
+	Sessions    []*Session      `json:"sessions"`
+}
+
+// EraseUserRequest needs confirm set, the erasure cannot be undone.
+type EraseUserRequest struct {
+	UserId  int64 `json:"-" path:"userId"`
+	Confirm bool  `json:"confirm"`
+}
+
+type EraseUserResponse struct{}
+
+type Privacy interface {
+	ExportUser(ctx context.Context, userId int64) (*models.UserExport, error)
+	EraseUser(ctx context.Context, userId int64) error
+}
+
+type PrivacyServer interface {
+	ExportUser(ctx context.Context, req *ExportUserRequest) (*ExportUserResponse, error)
+	EraseUser(ctx context.Context, req *EraseUserRequest) (*EraseUserResponse, error)
+}
+
+type privacyAPI struct {
+	privacy Privacy
+}
+
+func NewPrivacyAPI(privacy Privacy) PrivacyServer {
+	return &privacyAPI{privacy: privacy}
+}
+
+func (s *privacyAPI) ExportUser(ctx context.Context, req *ExportUserRequest) (*ExportUserResponse, error) {
+	if req.UserId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "userId must be provided")
+	}
+
+	export, err := s.privacy.ExportUser(ctx, req.UserId)
+	if err != nil {
+		return nil, statusError("failed to export user", err)
+	}
+
+	res := &ExportUserResponse{
+		ExportedAt:  export.ExportedAt,
+		User:        userMessage(&export.User),
+		Roles:       export.Roles,
+		Profile:     profileMessage(&export.Profile),
+		AppMetadata: make([]*AppMetadata, 0, len(export.AppMetadata)),
+		AuditEvents: make([]*AuditEvent, 0, len(export.AuditEvents)),
+		Logins:      make([]*LoginAttempt, 0, len(export.Logins)),
+		Devices:     make([]*Device, 0, len(export.Devices)),
+		Sessions:    make([]*Session, 0, len(export.Sessions)),
+	}
+	for _, metadata := range export.AppMetadata {
+		res.AppMetadata = append(res.AppMetadata, &AppMetadata{AppId: metadata.AppId, Metadata: metadata.Metadata, UpdatedAt: metadata.UpdatedAt})
+	}
+	for i := range export.AuditEvents {
+		res.AuditEvents = append(res.AuditEvents, auditEventMessage(&export.AuditEvents[i]))
+	}
+	for i := range export.Logins {
+		res.Logins = append(res.Logins, loginAttemptMessage(&export.Logins[i]))
+	}
+	for i := range export.Devices {
+		res.Devices = append(res.Devices, deviceMessage(&export.Devices[i]))
+	}
+	for i := range export.Sessions {
+		res.Sessions = append(res.Sessions, sessionMessage(&export.Sessions[i]))
+	}
+	return res, nil
+}
+
+func (s *privacyAPI) EraseUser(ctx context.Context, req *EraseUserRequest) (*EraseUserResponse, error) {
+	if req.UserId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "userId must be provided")
+	}
+	if !req.Confirm {
+		return nil, status.Error(codes.InvalidArgument, "erasure cannot be undone, confirm must be set")
+	}
+
+	if err := s.privacy.EraseUser(ctx, req.UserId); err != nil {
+		return nil, statusError("failed to erase user", err)
+	}
+	return &EraseUserResponse{}, nil
+}
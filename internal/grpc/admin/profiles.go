@@ -0,0 +1,158 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"sso/internal/domain/models"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Profile struct {
+	UserId      int64     `json:"userId"`
+	DisplayName string    `json:"displayName"`
+	Locale      string    `json:"locale"`
+	Timezone    string    `json:"timezone"`
+	AvatarUrl   string    `json:"avatarUrl"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+type GetProfileRequest struct {
+	UserId int64 `json:"-" path:"userId"`
+}
+
+type GetProfileResponse struct {
+	Profile *Profile `json:"profile"`
+}
+
+// UpdateProfileRequest changes the attributes that are set.
+type UpdateProfileRequest struct {
+	UserId      int64   `json:"-" path:"userId"`
+	DisplayName *string `json:"displayName"`
+	Locale      *string `json:"locale"`
+	Timezone    *string `json:"timezone"`
+	AvatarUrl   *string `json:"avatarUrl"`
+}
+
+type UpdateProfileResponse struct {
+	Profile *Profile `json:"profile"`
+}
+
+type GetAppMetadataRequest struct {
+	UserId int64 `json:"-" path:"userId"`
+	AppId  int64 `json:"-" path:"appId"`
+}
+
+type GetAppMetadataResponse struct {
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+// SetAppMetadataRequest replaces the metadata with a JSON object.
+type SetAppMetadataRequest struct {
+	UserId   int64           `json:"-" path:"userId"`
+	AppId    int64           `json:"-" path:"appId"`
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+type SetAppMetadataResponse struct {
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+type Profiles interface {
+	GetProfile(ctx context.Context, userId int64) (*models.Profile, error)
+	UpdateProfile(ctx context.Context, userId int64, update models.ProfileUpdate) (*models.Profile, error)
+	GetAppMetadata(ctx context.Context, userId int64, appId int64) (json.RawMessage, error)
+	SetAppMetadata(ctx context.Context, userId int64, appId int64, metadata json.RawMessage) error
+}
+
+type ProfilesServer interface {
+	GetProfile(ctx context.Context, req *GetProfileRequest) (*GetProfileResponse, error)
+	UpdateProfile(ctx context.Context, req *UpdateProfileRequest) (*UpdateProfileResponse, error)
+	GetAppMetadata(ctx context.Context, req *GetAppMetadataRequest) (*GetAppMetadataResponse, error)
+	SetAppMetadata(ctx context.Context, req *SetAppMetadataRequest) (*SetAppMetadataResponse, error)
+}
+
+type profilesAPI struct {
+	profiles Profiles
+}
+
+func NewProfilesAPI(profiles Profiles) ProfilesServer {
+	return &profilesAPI{profiles: profiles}
+}
+
+func (s *profilesAPI) GetProfile(ctx context.Context, req *GetProfileRequest) (*GetProfileResponse, error) {
+	if req.UserId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "userId must be provided")
+	}
+
+	profile, err := s.profiles.GetProfile(ctx, req.UserId)
+	if err != nil {
+		return nil, statusError("failed to get profile", err)
+	}
+
+	return &GetProfileResponse{
+		Profile: profileMessage(profile),
+	}, nil
+}
+
+func (s *profilesAPI) UpdateProfile(ctx context.Context, req *UpdateProfileRequest) (*UpdateProfileResponse, error) {
+	if req.UserId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "userId must be provided")
+	}
+
+	profile, err := s.profiles.UpdateProfile(ctx, req.UserId, models.ProfileUpdate{
+		DisplayName: req.DisplayName,
+		Locale:      req.Locale,
+		Timezone:    req.Timezone,
+		AvatarURL:   req.AvatarUrl,
+	})
+	if err != nil {
+		return nil, statusError("failed to update profile", err)
+	}
+
+	return &UpdateProfileResponse{
+		Profile: profileMessage(profile),
+	}, nil
+}
+
+func (s *profilesAPI) GetAppMetadata(ctx context.Context, req *GetAppMetadataRequest) (*GetAppMetadataResponse, error) {
+	if req.UserId == emptyValue || req.AppId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "userId and appId must be provided")
+	}
+
+	metadata, err := s.profiles.GetAppMetadata(ctx, req.UserId, req.AppId)
+	if err != nil {
+		return nil, statusError("failed to get app metadata", err)
+	}
+
+	return &GetAppMetadataResponse{
+		Metadata: metadata,
+	}, nil
+}
+
+func (s *profilesAPI) SetAppMetadata(ctx context.Context, req *SetAppMetadataRequest) (*SetAppMetadataResponse, error) {
+	if req.UserId == emptyValue || req.AppId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "userId and appId must be provided")
+	}
+
+	if err := s.profiles.SetAppMetadata(ctx, req.UserId, req.AppId, req.Metadata); err != nil {
+		return nil, statusError("failed to set app metadata", err)
+	}
+
+	return &SetAppMetadataResponse{
+		Metadata: req.Metadata,
+	}, nil
+}
+
+func profileMessage(profile *models.Profile) *Profile {
+	return &Profile{
+		UserId:      profile.UserId,
+		DisplayName: profile.DisplayName,
+		Locale:      profile.Locale,
+		Timezone:    profile.Timezone,
+		AvatarUrl:   profile.AvatarURL,
+		UpdatedAt:   profile.UpdatedAt,
+	}
+}
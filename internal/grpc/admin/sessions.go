@@ -0,0 +1,153 @@
+package admin
+
+import (
+	"context"
+	"sso/internal/domain/models"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Session struct {
+	Id              string     `json:"id"`
+	UserId          int64      `json:"userId"`
+	AppId           int64      `json:"appId"`
+	DeviceId        string     `json:"deviceId"`
+	Ip              string     `json:"ip"`
+	UserAgent       string     `json:"userAgent"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	AuthenticatedAt time.Time  `json:"authenticatedAt"`
+	LastSeenAt      time.Time  `json:"lastSeenAt"`
+	ExpiresAt       time.Time  `json:"expiresAt"`
+	RevokedAt       *time.Time `json:"revokedAt,omitempty"`
+}
+
+func sessionMessage(session *models.Session) *Session {
+	return &Session{
+		Id:              session.Id,
+		UserId:          session.UserId,
+		AppId:           session.AppId,
+		DeviceId:        session.DeviceId,
+		Ip:              session.IP,
+		UserAgent:       session.UserAgent,
+		CreatedAt:       session.CreatedAt,
+		AuthenticatedAt: session.AuthenticatedAt,
+		LastSeenAt:      session.LastSeenAt,
+		ExpiresAt:       session.ExpiresAt,
+		RevokedAt:       session.RevokedAt,
+	}
+}
+
+type ListSessionsRequest struct {
+	UserId int64 `json:"-" path:"userId"`
+}
+
+// ListSessionsResponse lists the active sessions newest first.
+type ListSessionsResponse struct {
+	Sessions []*Session `json:"sessions"`
+}
+
+type GetSessionRequest struct {
+	SessionId string `json:"-" path:"sessionId"`
+}
+
+type GetSessionResponse struct {
+	Session *Session `json:"session"`
+}
+
+type RevokeSessionRequest struct {
+	SessionId string `json:"-" path:"sessionId"`
+}
+
+type RevokeSessionResponse struct{}
+
+type RevokeAllSessionsRequest struct {
+	UserId int64 `json:"-" path:"userId"`
+}
+
+type RevokeAllSessionsResponse struct {
+	Revoked int64 `json:"revoked"`
+}
+
+type Sessions interface {
+	ListSessions(ctx context.Context, userId int64) ([]models.Session, error)
+	GetSession(ctx context.Context, sessionId string) (*models.Session, error)
+	RevokeSession(ctx context.Context, sessionId string) error
+	RevokeAllSessions(ctx context.Context, userId int64) (int64, error)
+}
+
+type SessionsServer interface {
+	ListSessions(ctx context.Context, req *ListSessionsRequest) (*ListSessionsResponse, error)
+	GetSession(ctx context.Context, req *GetSessionRequest) (*GetSessionResponse, error)
+	RevokeSession(ctx context.Context, req *RevokeSessionRequest) (*RevokeSessionResponse, error)
+	RevokeAllSessions(ctx context.Context, req *RevokeAllSessionsRequest) (*RevokeAllSessionsResponse, error)
+}
+
+type sessionsAPI struct {
+	sessions Sessions
+}
+
+func NewSessionsAPI(sessions Sessions) SessionsServer {
+	return &sessionsAPI{sessions: sessions}
+}
+
+func (s *sessionsAPI) ListSessions(ctx context.Context, req *ListSessionsRequest) (*ListSessionsResponse, error) {
+	if req.UserId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "userId must be provided")
+	}
+
+	sessions, err := s.sessions.ListSessions(ctx, req.UserId)
+	if err != nil {
+		return nil, statusError("failed to list sessions", err)
+	}
+
+	res := &ListSessionsResponse{
+		Sessions: make([]*Session, 0, len(sessions)),
+	}
+	for i := range sessions {
+		res.Sessions = append(res.Sessions, sessionMessage(&sessions[i]))
+	}
+	return res, nil
+}
+
+func (s *sessionsAPI) GetSession(ctx context.Context, req *GetSessionRequest) (*GetSessionResponse, error) {
+	if req.SessionId == "" {
+		return nil, status.Error(codes.InvalidArgument, "sessionId must be provided")
+	}
+
+	session, err := s.sessions.GetSession(ctx, req.SessionId)
+	if err != nil {
+		return nil, statusError("failed to get session", err)
+	}
+
+	return &GetSessionResponse{
+		Session: sessionMessage(session),
+	}, nil
+}
+
+func (s *sessionsAPI) RevokeSession(ctx context.Context, req *RevokeSessionRequest) (*RevokeSessionResponse, error) {
+	if req.SessionId == "" {
+		return nil, status.Error(codes.InvalidArgument, "sessionId must be provided")
+	}
+
+	if err := s.sessions.RevokeSession(ctx, req.SessionId); err != nil {
+		return nil, statusError("failed to revoke session", err)
+	}
+	return &RevokeSessionResponse{}, nil
+}
+
+func (s *sessionsAPI) RevokeAllSessions(ctx context.Context, req *RevokeAllSessionsRequest) (*RevokeAllSessionsResponse, error) {
+	if req.UserId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "userId must be provided")
+	}
+
+	revoked, err := s.sessions.RevokeAllSessions(ctx, req.UserId)
+	if err != nil {
+		return nil, statusError("failed to revoke sessions", err)
+	}
+
+	return &RevokeAllSessionsResponse{
+		Revoked: revoked,
+	}, nil
+}
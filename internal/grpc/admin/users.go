@@ -0,0 +1,207 @@
+package admin
+
+import (
+	"context"
+	"sso/internal/domain/models"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type User struct {
+	Id         int64      `json:"id"`
+	Email      string     `json:"email"`
+	Username   string     `json:"username,omitempty"`
+	Phone      string     `json:"phone,omitempty"`
+	IsAdmin    bool       `json:"isAdmin"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	DisabledAt *time.Time `json:"disabledAt,omitempty"`
+	DeletedAt  *time.Time `json:"deletedAt,omitempty"`
+}
+
+type GetUserRequest struct {
+	UserId int64 `json:"-" path:"userId"`
+}
+
+type GetUserResponse struct {
+	User *User `json:"user"`
+}
+
+// ListUsersRequest filters by the parameters that are set.
+type ListUsersRequest struct {
+	EmailPrefix   string    `json:"-" query:"emailPrefix"`
+	CreatedAfter  time.Time `json:"-" query:"createdAfter"`
+	CreatedBefore time.Time `json:"-" query:"createdBefore"`
+	IsAdmin       *bool     `json:"-" query:"isAdmin"`
+	IsDisabled    *bool     `json:"-" query:"isDisabled"`
+	PageToken     string    `json:"-" query:"pageToken"`
+	PageSize      int32     `json:"-" query:"pageSize"`
+}
+
+type ListUsersResponse struct {
+	Users         []*User `json:"users"`
+	NextPageToken string  `json:"nextPageToken"`
+}
+
+// UpdateUserRequest changes the fields that are set, an empty username or
+// phone removes it.
+type UpdateUserRequest struct {
+	UserId   int64   `json:"-" path:"userId"`
+	Email    *string `json:"email"`
+	Username *string `json:"username"`
+	Phone    *string `json:"phone"`
+	IsAdmin  *bool   `json:"isAdmin"`
+}
+
+type UpdateUserResponse struct {
+	User *User `json:"user"`
+}
+
+type DisableUserRequest struct {
+	UserId int64 `json:"-" path:"userId"`
+}
+
+type DisableUserResponse struct{}
+
+type EnableUserRequest struct {
+	UserId int64 `json:"-" path:"userId"`
+}
+
+type EnableUserResponse struct{}
+
+type DeleteUserRequest struct {
+	UserId int64 `json:"-" path:"userId"`
+}
+
+type DeleteUserResponse struct{}
+
+type Users interface {
+	GetUser(ctx context.Context, userId int64) (*models.User, error)
+	ListUsers(ctx context.Context, filter models.UserFilter, pageToken string, pageSize int) ([]models.User, string, error)
+	UpdateUser(ctx context.Context, userId int64, update models.UserUpdate) (*models.User, error)
+	DisableUser(ctx context.Context, userId int64) error
+	EnableUser(ctx context.Context, userId int64) error
+	DeleteUser(ctx context.Context, userId int64) error
+}
+
+type UsersServer interface {
+	GetUser(ctx context.Context, req *GetUserRequest) (*GetUserResponse, error)
+	ListUsers(ctx context.Context, req *ListUsersRequest) (*ListUsersResponse, error)
+	UpdateUser(ctx context.Context, req *UpdateUserRequest) (*UpdateUserResponse, error)
+	DisableUser(ctx context.Context, req *DisableUserRequest) (*DisableUserResponse, error)
+	EnableUser(ctx context.Context, req *EnableUserRequest) (*EnableUserResponse, error)
+	DeleteUser(ctx context.Context, req *DeleteUserRequest) (*DeleteUserResponse, error)
+}
+
+type usersAPI struct {
+	users Users
+}
+
+func NewUsersAPI(users Users) UsersServer {
+	return &usersAPI{users: users}
+}
+
+func (s *usersAPI) GetUser(ctx context.Context, req *GetUserRequest) (*GetUserResponse, error) {
+	if req.UserId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "userId must be provided")
+	}
+
+	user, err := s.users.GetUser(ctx, req.UserId)
+	if err != nil {
+		return nil, statusError("failed to get user", err)
+	}
+
+	return &GetUserResponse{
+		User: userMessage(user),
+	}, nil
+}
+
+func (s *usersAPI) ListUsers(ctx context.Context, req *ListUsersRequest) (*ListUsersResponse, error) {
+	filter := models.UserFilter{
+		EmailPrefix:   req.EmailPrefix,
+		CreatedAfter:  req.CreatedAfter,
+		CreatedBefore: req.CreatedBefore,
+		IsAdmin:       req.IsAdmin,
+		IsDisabled:    req.IsDisabled,
+	}
+	users, next, err := s.users.ListUsers(ctx, filter, req.PageToken, int(req.PageSize))
+	if err != nil {
+		return nil, statusError("failed to list users", err)
+	}
+
+	res := &ListUsersResponse{
+		Users:         make([]*User, 0, len(users)),
+		NextPageToken: next,
+	}
+	for i := range users {
+		res.Users = append(res.Users, userMessage(&users[i]))
+	}
+	return res, nil
+}
+
+func (s *usersAPI) UpdateUser(ctx context.Context, req *UpdateUserRequest) (*UpdateUserResponse, error) {
+	if req.UserId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "userId must be provided")
+	}
+
+	user, err := s.users.UpdateUser(ctx, req.UserId, models.UserUpdate{
+		Email:    req.Email,
+		Username: req.Username,
+		Phone:    req.Phone,
+		IsAdmin:  req.IsAdmin,
+	})
+	if err != nil {
+		return nil, statusError("failed to update user", err)
+	}
+
+	return &UpdateUserResponse{
+		User: userMessage(user),
+	}, nil
+}
+
+func (s *usersAPI) DisableUser(ctx context.Context, req *DisableUserRequest) (*DisableUserResponse, error) {
+	if req.UserId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "userId must be provided")
+	}
+
+	if err := s.users.DisableUser(ctx, req.UserId); err != nil {
+		return nil, statusError("failed to disable user", err)
+	}
+	return &DisableUserResponse{}, nil
+}
+
+func (s *usersAPI) EnableUser(ctx context.Context, req *EnableUserRequest) (*EnableUserResponse, error) {
+	if req.UserId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "userId must be provided")
+	}
+
+	if err := s.users.EnableUser(ctx, req.UserId); err != nil {
+		return nil, statusError("failed to enable user", err)
+	}
+	return &EnableUserResponse{}, nil
+}
+
+func (s *usersAPI) DeleteUser(ctx context.Context, req *DeleteUserRequest) (*DeleteUserResponse, error) {
+	if req.UserId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "userId must be provided")
+	}
+
+	if err := s.users.DeleteUser(ctx, req.UserId); err != nil {
+		return nil, statusError("failed to delete user", err)
+	}
+	return &DeleteUserResponse{}, nil
+}
+
+func userMessage(user *models.User) *User {
+	return &User{
+		Id:         user.Id,
+		Email:      user.Email,
+		Username:   user.Username,
+		Phone:      user.Phone,
+		IsAdmin:    user.IsAdmin,
+		CreatedAt:  user.CreatedAt,
+		DisabledAt: user.DisabledAt,
+		DeletedAt:  user.DeletedAt,
+	}
+}
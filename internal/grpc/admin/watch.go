@@ -0,0 +1,46 @@
+package admin
+
+import (
+	"context"
+	"sso/internal/domain/models"
+)
+
+// WatchEventsRequest resumes the stream after cursor, the id of the last
+// event seen. Without one the stream starts at the current end of the log.
+type WatchEventsRequest struct {
+	Cursor string `json:"-" query:"cursor" header:"Last-Event-ID"`
+}
+
+// WatchEventsResponse is an event with the cursor to resume after it.
+type WatchEventsResponse struct {
+	Event  *Event `json:"event"`
+	Cursor string `json:"cursor"`
+}
+
+type Watcher interface {
+	Watch(ctx context.Context, cursor string, send func(event models.Event, cursor string) error) error
+}
+
+// WatchServer streams user, app and role change events, every event comes
+// with the cursor to resume after it.
+type WatchServer interface {
+	WatchEvents(ctx context.Context, req *WatchEventsRequest, send func(resp *WatchEventsResponse, cursor string) error) error
+}
+
+type watchAPI struct {
+	watcher Watcher
+}
+
+func NewWatchAPI(watcher Watcher) WatchServer {
+	return &watchAPI{watcher: watcher}
+}
+
+func (s *watchAPI) WatchEvents(ctx context.Context, req *WatchEventsRequest, send func(resp *WatchEventsResponse, cursor string) error) error {
+	err := s.watcher.Watch(ctx, req.Cursor, func(event models.Event, cursor string) error {
+		return send(&WatchEventsResponse{Event: eventMessage(&event), Cursor: cursor}, cursor)
+	})
+	if err != nil {
+		return statusError("failed to watch events", err)
+	}
+	return nil
+}
@@ -0,0 +1,363 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"sso/internal/domain/models"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type WebhookSubscription struct {
+	Id    int64  `json:"id"`
+	AppId int64  `json:"appId"`
+	Url   string `json:"url"`
+	// Secret is only set when the subscription is created.
+	Secret string `json:"secret,omitempty"`
+	// EventTypes limits the delivered events, empty means all of them.
+	EventTypes []string  `json:"eventTypes"`
+	Enabled    bool      `json:"enabled"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type Event struct {
+	Id         string          `json:"id"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload"`
+	OccurredAt time.Time       `json:"occurredAt"`
+}
+
+type WebhookDelivery struct {
+	Id             int64      `json:"id"`
+	SubscriptionId int64      `json:"subscriptionId"`
+	Event          Event      `json:"event"`
+	Status         string     `json:"status"`
+	Attempts       int32      `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"nextAttemptAt"`
+	LastStatusCode int32      `json:"lastStatusCode"`
+	LastError      string     `json:"lastError"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+}
+
+// WebhookAttempt is one delivery attempt, durationMs is how long the
+// receiver took to answer.
+type WebhookAttempt struct {
+	Id          int64     `json:"id"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+	StatusCode  int32     `json:"statusCode"`
+	Error       string    `json:"error"`
+	DurationMs  int64     `json:"durationMs"`
+}
+
+type CreateWebhookSubscriptionRequest struct {
+	AppId      int64    `json:"-" path:"appId"`
+	Url        string   `json:"url"`
+	EventTypes []string `json:"eventTypes"`
+	Disabled   bool     `json:"disabled"`
+}
+
+type CreateWebhookSubscriptionResponse struct {
+	Subscription *WebhookSubscription `json:"subscription"`
+}
+
+type GetWebhookSubscriptionRequest struct {
+	SubscriptionId int64 `json:"-" path:"subscriptionId"`
+}
+
+type GetWebhookSubscriptionResponse struct {
+	Subscription *WebhookSubscription `json:"subscription"`
+}
+
+type ListWebhookSubscriptionsRequest struct {
+	AppId int64 `json:"-" path:"appId"`
+}
+
+type ListWebhookSubscriptionsResponse struct {
+	Subscriptions []*WebhookSubscription `json:"subscriptions"`
+}
+
+// UpdateWebhookSubscriptionRequest changes the fields that are set, the
+// others are left unchanged.
+type UpdateWebhookSubscriptionRequest struct {
+	SubscriptionId int64     `json:"-" path:"subscriptionId"`
+	Url            *string   `json:"url"`
+	EventTypes     *[]string `json:"eventTypes"`
+	Enabled        *bool     `json:"enabled"`
+}
+
+type UpdateWebhookSubscriptionResponse struct {
+	Subscription *WebhookSubscription `json:"subscription"`
+}
+
+type RotateWebhookSecretRequest struct {
+	SubscriptionId int64 `json:"-" path:"subscriptionId"`
+}
+
+type RotateWebhookSecretResponse struct {
+	Secret string `json:"secret"`
+}
+
+type DeleteWebhookSubscriptionRequest struct {
+	SubscriptionId int64 `json:"-" path:"subscriptionId"`
+}
+
+type DeleteWebhookSubscriptionResponse struct{}
+
+// ListWebhookDeliveriesRequest lists the deliveries with status, all of them
+// when it is empty.
+type ListWebhookDeliveriesRequest struct {
+	SubscriptionId int64  `json:"-" path:"subscriptionId"`
+	Status         string `json:"-" query:"status"`
+	PageToken      string `json:"-" query:"pageToken"`
+	PageSize       int32  `json:"-" query:"pageSize"`
+}
+
+// ListWebhookDeliveriesResponse lists the deliveries newest first.
+type ListWebhookDeliveriesResponse struct {
+	Deliveries    []*WebhookDelivery `json:"deliveries"`
+	NextPageToken string             `json:"nextPageToken"`
+}
+
+type GetWebhookDeliveryRequest struct {
+	DeliveryId int64 `json:"-" path:"deliveryId"`
+}
+
+type GetWebhookDeliveryResponse struct {
+	Delivery *WebhookDelivery  `json:"delivery"`
+	Attempts []*WebhookAttempt `json:"attempts"`
+}
+
+type RedeliverWebhookRequest struct {
+	DeliveryId int64 `json:"-" path:"deliveryId"`
+}
+
+type RedeliverWebhookResponse struct{}
+
+type Webhooks interface {
+	CreateSubscription(ctx context.Context, sub models.WebhookSubscription) (*models.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id int64) (*models.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context, appId int64) ([]models.WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, id int64, update models.WebhookSubscriptionUpdate) (*models.WebhookSubscription, error)
+	RotateSubscriptionSecret(ctx context.Context, id int64) (string, error)
+	DeleteSubscription(ctx context.Context, id int64) error
+	ListDeliveries(ctx context.Context, subscriptionId int64, status string, pageToken string, pageSize int) ([]models.WebhookDelivery, string, error)
+	GetDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, []models.WebhookAttempt, error)
+	Redeliver(ctx context.Context, id int64) error
+}
+
+type WebhooksServer interface {
+	CreateWebhookSubscription(ctx context.Context, req *CreateWebhookSubscriptionRequest) (*CreateWebhookSubscriptionResponse, error)
+	GetWebhookSubscription(ctx context.Context, req *GetWebhookSubscriptionRequest) (*GetWebhookSubscriptionResponse, error)
+	ListWebhookSubscriptions(ctx context.Context, req *ListWebhookSubscriptionsRequest) (*ListWebhookSubscriptionsResponse, error)
+	UpdateWebhookSubscription(ctx context.Context, req *UpdateWebhookSubscriptionRequest) (*UpdateWebhookSubscriptionResponse, error)
+	RotateWebhookSecret(ctx context.Context, req *RotateWebhookSecretRequest) (*RotateWebhookSecretResponse, error)
+	DeleteWebhookSubscription(ctx context.Context, req *DeleteWebhookSubscriptionRequest) (*DeleteWebhookSubscriptionResponse, error)
+	ListWebhookDeliveries(ctx context.Context, req *ListWebhookDeliveriesRequest) (*ListWebhookDeliveriesResponse, error)
+	GetWebhookDelivery(ctx context.Context, req *GetWebhookDeliveryRequest) (*GetWebhookDeliveryResponse, error)
+	RedeliverWebhook(ctx context.Context, req *RedeliverWebhookRequest) (*RedeliverWebhookResponse, error)
+}
+
+type webhooksAPI struct {
+	webhooks Webhooks
+}
+
+func NewWebhooksAPI(webhooks Webhooks) WebhooksServer {
+	return &webhooksAPI{webhooks: webhooks}
+}
+
+func (s *webhooksAPI) CreateWebhookSubscription(ctx context.Context, req *CreateWebhookSubscriptionRequest) (*CreateWebhookSubscriptionResponse, error) {
+	if req.AppId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "appId must be provided")
+	}
+
+	sub, err := s.webhooks.CreateSubscription(ctx, models.WebhookSubscription{
+		AppId:      req.AppId,
+		URL:        req.Url,
+		EventTypes: req.EventTypes,
+		Enabled:    !req.Disabled,
+	})
+	if err != nil {
+		return nil, statusError("failed to create webhook subscription", err)
+	}
+
+	return &CreateWebhookSubscriptionResponse{
+		Subscription: webhookSubscriptionMessage(sub),
+	}, nil
+}
+
+func (s *webhooksAPI) GetWebhookSubscription(ctx context.Context, req *GetWebhookSubscriptionRequest) (*GetWebhookSubscriptionResponse, error) {
+	if req.SubscriptionId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "subscriptionId must be provided")
+	}
+
+	sub, err := s.webhooks.GetSubscription(ctx, req.SubscriptionId)
+	if err != nil {
+		return nil, statusError("failed to get webhook subscription", err)
+	}
+
+	return &GetWebhookSubscriptionResponse{
+		Subscription: webhookSubscriptionMessage(sub),
+	}, nil
+}
+
+func (s *webhooksAPI) ListWebhookSubscriptions(ctx context.Context, req *ListWebhookSubscriptionsRequest) (*ListWebhookSubscriptionsResponse, error) {
+	if req.AppId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "appId must be provided")
+	}
+
+	subs, err := s.webhooks.ListSubscriptions(ctx, req.AppId)
+	if err != nil {
+		return nil, statusError("failed to list webhook subscriptions", err)
+	}
+
+	res := &ListWebhookSubscriptionsResponse{
+		Subscriptions: make([]*WebhookSubscription, 0, len(subs)),
+	}
+	for i := range subs {
+		res.Subscriptions = append(res.Subscriptions, webhookSubscriptionMessage(&subs[i]))
+	}
+	return res, nil
+}
+
+func (s *webhooksAPI) UpdateWebhookSubscription(ctx context.Context, req *UpdateWebhookSubscriptionRequest) (*UpdateWebhookSubscriptionResponse, error) {
+	if req.SubscriptionId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "subscriptionId must be provided")
+	}
+
+	sub, err := s.webhooks.UpdateSubscription(ctx, req.SubscriptionId, models.WebhookSubscriptionUpdate{
+		URL:        req.Url,
+		EventTypes: req.EventTypes,
+		Enabled:    req.Enabled,
+	})
+	if err != nil {
+		return nil, statusError("failed to update webhook subscription", err)
+	}
+
+	return &UpdateWebhookSubscriptionResponse{
+		Subscription: webhookSubscriptionMessage(sub),
+	}, nil
+}
+
+func (s *webhooksAPI) RotateWebhookSecret(ctx context.Context, req *RotateWebhookSecretRequest) (*RotateWebhookSecretResponse, error) {
+	if req.SubscriptionId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "subscriptionId must be provided")
+	}
+
+	secret, err := s.webhooks.RotateSubscriptionSecret(ctx, req.SubscriptionId)
+	if err != nil {
+		return nil, statusError("failed to rotate webhook secret", err)
+	}
+
+	return &RotateWebhookSecretResponse{
+		Secret: secret,
+	}, nil
+}
+
+func (s *webhooksAPI) DeleteWebhookSubscription(ctx context.Context, req *DeleteWebhookSubscriptionRequest) (*DeleteWebhookSubscriptionResponse, error) {
+	if req.SubscriptionId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "subscriptionId must be provided")
+	}
+
+	if err := s.webhooks.DeleteSubscription(ctx, req.SubscriptionId); err != nil {
+		return nil, statusError("failed to delete webhook subscription", err)
+	}
+	return &DeleteWebhookSubscriptionResponse{}, nil
+}
+
+func (s *webhooksAPI) ListWebhookDeliveries(ctx context.Context, req *ListWebhookDeliveriesRequest) (*ListWebhookDeliveriesResponse, error) {
+	if req.SubscriptionId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "subscriptionId must be provided")
+	}
+
+	deliveries, next, err := s.webhooks.ListDeliveries(ctx, req.SubscriptionId, req.Status, req.PageToken, int(req.PageSize))
+	if err != nil {
+		return nil, statusError("failed to list webhook deliveries", err)
+	}
+
+	res := &ListWebhookDeliveriesResponse{
+		Deliveries:    make([]*WebhookDelivery, 0, len(deliveries)),
+		NextPageToken: next,
+	}
+	for i := range deliveries {
+		res.Deliveries = append(res.Deliveries, webhookDeliveryMessage(&deliveries[i]))
+	}
+	return res, nil
+}
+
+func (s *webhooksAPI) GetWebhookDelivery(ctx context.Context, req *GetWebhookDeliveryRequest) (*GetWebhookDeliveryResponse, error) {
+	if req.DeliveryId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "deliveryId must be provided")
+	}
+
+	delivery, attempts, err := s.webhooks.GetDelivery(ctx, req.DeliveryId)
+	if err != nil {
+		return nil, statusError("failed to get webhook delivery", err)
+	}
+
+	res := &GetWebhookDeliveryResponse{
+		Delivery: webhookDeliveryMessage(delivery),
+		Attempts: make([]*WebhookAttempt, 0, len(attempts)),
+	}
+	for _, attempt := range attempts {
+		res.Attempts = append(res.Attempts, &WebhookAttempt{
+			Id:          attempt.Id,
+			AttemptedAt: attempt.AttemptedAt,
+			StatusCode:  int32(attempt.StatusCode),
+			Error:       attempt.Error,
+			DurationMs:  attempt.Duration.Milliseconds(),
+		})
+	}
+	return res, nil
+}
+
+func (s *webhooksAPI) RedeliverWebhook(ctx context.Context, req *RedeliverWebhookRequest) (*RedeliverWebhookResponse, error) {
+	if req.DeliveryId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "deliveryId must be provided")
+	}
+
+	if err := s.webhooks.Redeliver(ctx, req.DeliveryId); err != nil {
+		return nil, statusError("failed to redeliver webhook", err)
+	}
+	return &RedeliverWebhookResponse{}, nil
+}
+
+func webhookSubscriptionMessage(sub *models.WebhookSubscription) *WebhookSubscription {
+	return &WebhookSubscription{
+		Id:         sub.Id,
+		AppId:      sub.AppId,
+		Url:        sub.URL,
+		Secret:     sub.Secret,
+		EventTypes: sub.EventTypes,
+		Enabled:    sub.Enabled,
+		CreatedAt:  sub.CreatedAt,
+		UpdatedAt:  sub.UpdatedAt,
+	}
+}
+
+func webhookDeliveryMessage(delivery *models.WebhookDelivery) *WebhookDelivery {
+	return &WebhookDelivery{
+		Id:             delivery.Id,
+		SubscriptionId: delivery.SubscriptionId,
+		Event:          *eventMessage(&delivery.Event),
+		Status:         delivery.Status,
+		Attempts:       int32(delivery.Attempts),
+		NextAttemptAt:  delivery.NextAttemptAt,
+		LastStatusCode: int32(delivery.LastStatusCode),
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt,
+		DeliveredAt:    delivery.DeliveredAt,
+	}
+}
+
+func eventMessage(event *models.Event) *Event {
+	return &Event{
+		Id:         event.Id,
+		Type:       event.Type,
+		Payload:    event.Payload,
+		OccurredAt: event.OccurredAt,
+	}
+}
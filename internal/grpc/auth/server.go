@@ -2,14 +2,20 @@ package auth
 
 import (
 	"context"
+	"errors"
 	ssov1 "github.com/makar182/protos/gen/sso"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"sso/internal/lib/identity"
+	authservice "sso/internal/services/auth"
 )
 
 const emptyValue = 0
 
+// AdminMethods need the token of an admin, see interceptors.UnaryAdmin.
+var AdminMethods = []string{"/auth.Auth/SetAdmin"}
+
 type Auth interface {
 	Login(ctx context.Context, email string, password string, appId int) (string, error)
 	Logout(ctx context.Context, token string) (bool, error)
@@ -24,17 +30,27 @@ type serverAPI struct {
 }
 
 func RegisterServerAPI(srv *grpc.Server, auth Auth) {
-	ssov1.RegisterAuthServer(srv, &serverAPI{auth: auth})
+	ssov1.RegisterAuthServer(srv, NewServerAPI(auth))
+}
+
+// NewServerAPI returns the auth API without a gRPC server, e.g. for the
+// HTTP gateway, so both transports share validation and error mapping.
+func NewServerAPI(auth Auth) ssov1.AuthServer {
+	return &serverAPI{auth: auth}
 }
 
 func (s *serverAPI) Login(ctx context.Context, req *ssov1.LoginRequest) (*ssov1.LoginResponse, error) {
 	if req.GetEmail() == "" || req.GetPassword() == "" || req.GetAppId() == emptyValue {
 		return nil, status.Error(codes.InvalidArgument, "email, password and app_id must be provided")
 	}
+	// the email field also accepts a username or a phone number
+	if _, _, err := identity.Parse(req.GetEmail()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "email must be a valid email, username or phone number")
+	}
 
 	token, err := s.auth.Login(ctx, req.GetEmail(), req.GetPassword(), int(req.GetAppId()))
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to login: %v", err)
+		return nil, statusError("failed to login", err)
 	}
 
 	return &ssov1.LoginResponse{
@@ -48,7 +64,7 @@ func (s *serverAPI) Logout(ctx context.Context, req *ssov1.LogoutRequest) (*ssov
 	}
 	isLoggedOut, err := s.auth.Logout(ctx, req.GetToken())
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to logout: %v", err)
+		return nil, statusError("failed to logout", err)
 	}
 	return &ssov1.LogoutResponse{
 		IsLoggedOut: isLoggedOut,
@@ -59,10 +75,13 @@ func (s *serverAPI) Register(ctx context.Context, req *ssov1.RegisterRequest) (*
 	if req.GetEmail() == "" || req.GetPassword() == "" {
 		return nil, status.Error(codes.InvalidArgument, "email and password must be provided")
 	}
+	if _, err := identity.NormalizeEmail(req.GetEmail()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "email is invalid")
+	}
 
 	userId, err := s.auth.Register(ctx, req.GetEmail(), req.GetPassword())
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to register: %v", err)
+		return nil, statusError("failed to register", err)
 	}
 
 	res := &ssov1.RegisterResponse{
@@ -78,7 +97,7 @@ func (s *serverAPI) IsAdmin(ctx context.Context, req *ssov1.IsAdminRequest) (*ss
 
 	isAdmin, err := s.auth.IsAdmin(ctx, req.GetUserId())
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to check admin status: %v", err)
+		return nil, statusError("failed to check admin status", err)
 	}
 
 	return &ssov1.IsAdminResponse{
@@ -93,10 +112,33 @@ func (s *serverAPI) SetAdmin(ctx context.Context, req *ssov1.SetAdminRequest) (*
 
 	isAdmin, err := s.auth.SetAdmin(ctx, req.GetUserId(), req.GetIsAdmin())
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to set admin status: %v", err)
+		return nil, statusError("failed to set admin status", err)
 	}
 
 	return &ssov1.SetAdminResponse{
 		IsAdmin: isAdmin,
 	}, nil
 }
+
+// statusError maps the errors of the auth service to status codes, anything
+// unexpected is Internal.
+func statusError(msg string, err error) error {
+	code := codes.Internal
+	switch {
+	case errors.Is(err, authservice.ErrInvalidCredentials), errors.Is(err, authservice.ErrInvalidToken):
+		code = codes.Unauthenticated
+	case errors.Is(err, authservice.ErrStepUpRequired):
+		code = codes.FailedPrecondition
+	case errors.Is(err, authservice.ErrTooManySessions):
+		code = codes.ResourceExhausted
+	case errors.Is(err, authservice.ErrUserDisabled), errors.Is(err, authservice.ErrAppDisabled):
+		code = codes.PermissionDenied
+	case errors.Is(err, authservice.ErrUserNotFound):
+		code = codes.NotFound
+	case errors.Is(err, authservice.ErrUserAlreadyExists):
+		code = codes.AlreadyExists
+	case errors.Is(err, authservice.ErrInvalidEmail):
+		code = codes.InvalidArgument
+	}
+	return status.Errorf(code, "%s: %v", msg, err)
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sso/internal/lib/jwt"
+	authservice "sso/internal/services/auth"
+	"testing"
+
+	ssov1 "github.com/makar182/protos/gen/sso"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// failingAuth fails every call with err.
+type failingAuth struct {
+	err error
+}
+
+func (f failingAuth) Login(context.Context, string, string, int) (string, error) { return "", f.err }
+func (f failingAuth) Logout(context.Context, string) (bool, error)               { return false, f.err }
+func (f failingAuth) Register(context.Context, string, string) (int64, error)    { return 0, f.err }
+func (f failingAuth) IsAdmin(context.Context, int64) (bool, error)               { return false, f.err }
+func (f failingAuth) SetAdmin(context.Context, int64, bool) (bool, error)        { return false, f.err }
+func (f failingAuth) ValidateToken(context.Context, string) (*jwt.Claims, error) { return nil, f.err }
+func (f failingAuth) Refresh(context.Context, string) (string, error)            { return "", f.err }
+func (f failingAuth) StepUp(context.Context, string, string) (string, error)     { return "", f.err }
+
+func TestServerAPI_ErrorCodes(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{err: authservice.ErrInvalidCredentials, code: codes.Unauthenticated},
+		{err: authservice.ErrInvalidToken, code: codes.Unauthenticated},
+		{err: authservice.ErrStepUpRequired, code: codes.FailedPrecondition},
+		{err: authservice.ErrTooManySessions, code: codes.ResourceExhausted},
+		{err: authservice.ErrUserDisabled, code: codes.PermissionDenied},
+		{err: authservice.ErrAppDisabled, code: codes.PermissionDenied},
+		{err: authservice.ErrUserNotFound, code: codes.NotFound},
+		{err: authservice.ErrUserAlreadyExists, code: codes.AlreadyExists},
+		{err: fmt.Errorf("wrapped: %w", authservice.ErrTooManySessions), code: codes.ResourceExhausted},
+		{err: authservice.ErrInternalServerError, code: codes.Internal},
+		{err: errors.New("unexpected"), code: codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			api := NewServerAPI(failingAuth{err: tt.err})
+
+			_, err := api.Login(context.Background(), &ssov1.LoginRequest{Email: "a@example.com", Password: "secret", AppId: 1})
+			assert.Equal(t, tt.code, status.Code(err))
+			_, err = api.Logout(context.Background(), &ssov1.LogoutRequest{Token: "t"})
+			assert.Equal(t, tt.code, status.Code(err))
+			_, err = api.SetAdmin(context.Background(), &ssov1.SetAdminRequest{UserId: 1, IsAdmin: true})
+			assert.Equal(t, tt.code, status.Code(err))
+
+			tokens := NewTokenAPI(failingAuth{err: tt.err})
+			_, err = tokens.ValidateToken(context.Background(), &ValidateTokenRequest{Token: "t"})
+			assert.Equal(t, tt.code, status.Code(err))
+			_, err = tokens.Refresh(context.Background(), &RefreshRequest{Token: "t"})
+			assert.Equal(t, tt.code, status.Code(err))
+			_, err = tokens.StepUp(context.Background(), &StepUpRequest{Token: "t", Password: "secret"})
+			assert.Equal(t, tt.code, status.Code(err))
+		})
+	}
+}
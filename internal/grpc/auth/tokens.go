@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"sso/internal/lib/jwt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The token RPCs are not in the published protos, so their messages are
+// plain structs shaped like the generated ones. gateway.TokenRoutes serves
+// them over HTTP and, as the auth.Tokens service with JSON messages, on the
+// gRPC server.
+
+type ValidateTokenRequest struct {
+	Token string
+}
+
+type ValidateTokenResponse struct {
+	UserId    int64
+	Email     string
+	AppId     int64
+	SessionId string
+	AuthTime  time.Time
+	ExpiresAt time.Time
+}
+
+type RefreshRequest struct {
+	Token string
+}
+
+type RefreshResponse struct {
+	Token string
+}
+
+type StepUpRequest struct {
+	Token    string
+	Password string
+}
+
+type StepUpResponse struct {
+	Token string
+}
+
+type Tokens interface {
+	ValidateToken(ctx context.Context, token string) (*jwt.Claims, error)
+	Refresh(ctx context.Context, token string) (string, error)
+	StepUp(ctx context.Context, token string, password string) (string, error)
+}
+
+// TokenServer is the API for apps holding a token: checking it, getting a
+// fresh one for its session and re-authenticating the session.
+type TokenServer interface {
+	ValidateToken(ctx context.Context, req *ValidateTokenRequest) (*ValidateTokenResponse, error)
+	Refresh(ctx context.Context, req *RefreshRequest) (*RefreshResponse, error)
+	StepUp(ctx context.Context, req *StepUpRequest) (*StepUpResponse, error)
+}
+
+type tokenAPI struct {
+	tokens Tokens
+}
+
+func NewTokenAPI(tokens Tokens) TokenServer {
+	return &tokenAPI{tokens: tokens}
+}
+
+func (s *tokenAPI) ValidateToken(ctx context.Context, req *ValidateTokenRequest) (*ValidateTokenResponse, error) {
+	if req.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token must be provided")
+	}
+
+	claims, err := s.tokens.ValidateToken(ctx, req.Token)
+	if err != nil {
+		return nil, statusError("failed to validate token", err)
+	}
+
+	return &ValidateTokenResponse{
+		UserId:    claims.UserId,
+		Email:     claims.Email,
+		AppId:     claims.AppId,
+		SessionId: claims.SessionId,
+		AuthTime:  claims.AuthTime,
+		ExpiresAt: claims.ExpiresAt,
+	}, nil
+}
+
+func (s *tokenAPI) Refresh(ctx context.Context, req *RefreshRequest) (*RefreshResponse, error) {
+	if req.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token must be provided")
+	}
+
+	token, err := s.tokens.Refresh(ctx, req.Token)
+	if err != nil {
+		return nil, statusError("failed to refresh token", err)
+	}
+
+	return &RefreshResponse{
+		Token: token,
+	}, nil
+}
+
+func (s *tokenAPI) StepUp(ctx context.Context, req *StepUpRequest) (*StepUpResponse, error) {
+	if req.Token == "" || req.Password == "" {
+		return nil, status.Error(codes.InvalidArgument, "token and password must be provided")
+	}
+
+	token, err := s.tokens.StepUp(ctx, req.Token, req.Password)
+	if err != nil {
+		return nil, statusError("failed to step up", err)
+	}
+
+	return &StepUpResponse{
+		Token: token,
+	}, nil
+}
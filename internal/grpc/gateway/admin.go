@@ -0,0 +1,108 @@
+package gateway
+
+import (
+	"net/http"
+	"sso/internal/grpc/admin"
+)
+
+// The admin messages carry their JSON and parameter names, so the admin API
+// methods serve as the calls of the routes. All of them need an admin token.
+
+// AppRoutes maps the REST endpoints to the app management API.
+func AppRoutes(api admin.AppsServer) []Route {
+	return []Route{
+		Unary(http.MethodPost, "/v1/admin/apps", admin.Method("CreateApp"), "Create an app, the only response with its secret", api.CreateApp),
+		Unary(http.MethodGet, "/v1/admin/apps", admin.Method("ListApps"), "List apps page by page", api.ListApps),
+		Unary(http.MethodGet, "/v1/admin/apps/{appId}", admin.Method("GetApp"), "Get an app", api.GetApp),
+		Unary(http.MethodPatch, "/v1/admin/apps/{appId}", admin.Method("UpdateApp"), "Change the settings of an app", api.UpdateApp),
+		Unary(http.MethodPost, "/v1/admin/apps/{appId}/secret", admin.Method("RotateAppSecret"), "Generate a new app secret", api.RotateAppSecret),
+		Unary(http.MethodDelete, "/v1/admin/apps/{appId}", admin.Method("DeleteApp"), "Delete an app", api.DeleteApp),
+	}
+}
+
+// UserRoutes maps the REST endpoints to the user management API.
+func UserRoutes(api admin.UsersServer) []Route {
+	return []Route{
+		Unary(http.MethodGet, "/v1/admin/users", admin.Method("ListUsers"), "List and search users page by page", api.ListUsers),
+		Unary(http.MethodGet, "/v1/admin/users/{userId}", admin.Method("GetUser"), "Get a user", api.GetUser),
+		Unary(http.MethodPatch, "/v1/admin/users/{userId}", admin.Method("UpdateUser"), "Change the email, username, phone or admin flag of a user", api.UpdateUser),
+		Unary(http.MethodPost, "/v1/admin/users/{userId}/disable", admin.Method("DisableUser"), "Block a user and invalidate their tokens", api.DisableUser),
+		Unary(http.MethodPost, "/v1/admin/users/{userId}/enable", admin.Method("EnableUser"), "Unblock a disabled user", api.EnableUser),
+		Unary(http.MethodDelete, "/v1/admin/users/{userId}", admin.Method("DeleteUser"), "Soft delete a user, purged after the purge window", api.DeleteUser),
+	}
+}
+
+// ProfileRoutes maps the REST endpoints to the profile and app metadata API.
+func ProfileRoutes(api admin.ProfilesServer) []Route {
+	return []Route{
+		Unary(http.MethodGet, "/v1/admin/users/{userId}/profile", admin.Method("GetProfile"), "Get the profile attributes of a user", api.GetProfile),
+		Unary(http.MethodPatch, "/v1/admin/users/{userId}/profile", admin.Method("UpdateProfile"), "Change the profile attributes of a user", api.UpdateProfile),
+		Unary(http.MethodGet, "/v1/admin/users/{userId}/apps/{appId}/metadata", admin.Method("GetAppMetadata"), "Get the metadata a user has for an app", api.GetAppMetadata),
+		Unary(http.MethodPut, "/v1/admin/users/{userId}/apps/{appId}/metadata", admin.Method("SetAppMetadata"), "Replace the metadata a user has for an app", api.SetAppMetadata),
+	}
+}
+
+// ImportRoutes maps the REST endpoint to the user import API.
+func ImportRoutes(api admin.ImportServer) []Route {
+	return []Route{
+		Unary(http.MethodPost, "/v1/admin/users/import", admin.Method("ImportUsers"), "Import a batch of users with password hashes of another system", api.ImportUsers),
+	}
+}
+
+// PrivacyRoutes maps the REST endpoints to the data-subject request API.
+func PrivacyRoutes(api admin.PrivacyServer) []Route {
+	return []Route{
+		Unary(http.MethodGet, "/v1/admin/users/{userId}/export", admin.Method("ExportUser"), "Export everything stored about a user", api.ExportUser),
+		Unary(http.MethodPost, "/v1/admin/users/{userId}/erase", admin.Method("EraseUser"), "Anonymize a user and delete their personal data, cannot be undone", api.EraseUser),
+	}
+}
+
+// LoginRoutes maps the REST endpoints to the login history API.
+func LoginRoutes(api admin.LoginsServer) []Route {
+	return []Route{
+		Unary(http.MethodGet, "/v1/admin/users/{userId}/logins", admin.Method("GetLoginHistory"), "List the login attempts of a user newest first, page by page", api.GetLoginHistory),
+		Unary(http.MethodGet, "/v1/admin/users/{userId}/devices", admin.Method("ListDevices"), "List the devices a user has logged in from", api.ListDevices),
+	}
+}
+
+// SessionRoutes maps the REST endpoints to the session management API.
+func SessionRoutes(api admin.SessionsServer) []Route {
+	return []Route{
+		Unary(http.MethodGet, "/v1/admin/users/{userId}/sessions", admin.Method("ListSessions"), "List the active sessions of a user", api.ListSessions),
+		Unary(http.MethodPost, "/v1/admin/users/{userId}/sessions/revoke", admin.Method("RevokeAllSessions"), "Revoke every active session of a user", api.RevokeAllSessions),
+		Unary(http.MethodGet, "/v1/admin/sessions/{sessionId}", admin.Method("GetSession"), "Get a session, including revoked and expired ones", api.GetSession),
+		Unary(http.MethodPost, "/v1/admin/sessions/{sessionId}/revoke", admin.Method("RevokeSession"), "Revoke a session, its tokens are rejected from now on", api.RevokeSession),
+	}
+}
+
+// WebhookRoutes maps the REST endpoints to the webhook subscription and
+// delivery API.
+func WebhookRoutes(api admin.WebhooksServer) []Route {
+	return []Route{
+		Unary(http.MethodPost, "/v1/admin/apps/{appId}/webhooks", admin.Method("CreateWebhookSubscription"), "Subscribe an app to events, the only response with the signing secret", api.CreateWebhookSubscription),
+		Unary(http.MethodGet, "/v1/admin/apps/{appId}/webhooks", admin.Method("ListWebhookSubscriptions"), "List the webhook subscriptions of an app", api.ListWebhookSubscriptions),
+		Unary(http.MethodGet, "/v1/admin/webhooks/{subscriptionId}", admin.Method("GetWebhookSubscription"), "Get a webhook subscription", api.GetWebhookSubscription),
+		Unary(http.MethodPatch, "/v1/admin/webhooks/{subscriptionId}", admin.Method("UpdateWebhookSubscription"), "Change the url, event types or state of a webhook subscription", api.UpdateWebhookSubscription),
+		Unary(http.MethodPost, "/v1/admin/webhooks/{subscriptionId}/secret", admin.Method("RotateWebhookSecret"), "Generate a new webhook signing secret", api.RotateWebhookSecret),
+		Unary(http.MethodDelete, "/v1/admin/webhooks/{subscriptionId}", admin.Method("DeleteWebhookSubscription"), "Delete a webhook subscription and its delivery logs", api.DeleteWebhookSubscription),
+		Unary(http.MethodGet, "/v1/admin/webhooks/{subscriptionId}/deliveries", admin.Method("ListWebhookDeliveries"), "List the deliveries of a webhook subscription newest first, page by page", api.ListWebhookDeliveries),
+		Unary(http.MethodGet, "/v1/admin/webhook-deliveries/{deliveryId}", admin.Method("GetWebhookDelivery"), "Get a webhook delivery and its attempts", api.GetWebhookDelivery),
+		Unary(http.MethodPost, "/v1/admin/webhook-deliveries/{deliveryId}/redeliver", admin.Method("RedeliverWebhook"), "Queue a webhook delivery again, e.g. a dead one", api.RedeliverWebhook),
+	}
+}
+
+// WatchRoutes maps the REST endpoint to the event stream, served as
+// server-sent events.
+func WatchRoutes(api admin.WatchServer) []Route {
+	return []Route{
+		ServerStream(http.MethodGet, "/v1/admin/events/watch", admin.Method("WatchEvents"), "Stream user, app and role changes, resumable with the id of the last event", api.WatchEvents),
+	}
+}
+
+// AuditRoutes maps the REST endpoints to the audit log API.
+func AuditRoutes(api admin.AuditServer) []Route {
+	return []Route{
+		Unary(http.MethodGet, "/v1/admin/audit/events", admin.Method("ListAuditEvents"), "List and filter audit events page by page", api.ListAuditEvents),
+		Unary(http.MethodGet, "/v1/admin/audit/verify", admin.Method("VerifyAuditChain"), "Check the audit hash chain for tampering", api.VerifyAuditChain),
+	}
+}
@@ -0,0 +1,883 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sso/internal/domain/models"
+	"sso/internal/grpc/admin"
+	"sso/internal/grpc/interceptors"
+	"sso/internal/lib/jwt"
+	appsservice "sso/internal/services/apps"
+	auditservice "sso/internal/services/audit"
+	sessionsservice "sso/internal/services/sessions"
+	usersservice "sso/internal/services/users"
+	watchservice "sso/internal/services/watch"
+	webhooksservice "sso/internal/services/webhooks"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+const adminAppId = 1
+
+// adminTokens accepts the tokens "admin" and "user", only user 1 of the
+// "admin" token is an admin.
+type adminTokens struct{}
+
+func (adminTokens) ValidateToken(_ context.Context, token string) (*jwt.Claims, error) {
+	switch token {
+	case "admin":
+		return &jwt.Claims{UserId: 1, AppId: adminAppId}, nil
+	case "user":
+		return &jwt.Claims{UserId: 2, AppId: adminAppId}, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+func (adminTokens) IsAdmin(_ context.Context, userId int64) (bool, error) {
+	return userId == 1, nil
+}
+
+// fakeApps keeps the apps in memory.
+type fakeApps struct {
+	apps      map[int64]*models.App
+	pageToken string
+	pageSize  int
+}
+
+func (f *fakeApps) CreateApp(_ context.Context, app models.App) (*models.App, error) {
+	app.Id = int64(len(f.apps) + 1)
+	app.Secret = "secret"
+	f.apps[app.Id] = &app
+	return &app, nil
+}
+
+func (f *fakeApps) GetApp(_ context.Context, appId int64) (*models.App, error) {
+	app, ok := f.apps[appId]
+	if !ok {
+		return nil, appsservice.ErrAppNotFound
+	}
+	withoutSecret := *app
+	withoutSecret.Secret = ""
+	return &withoutSecret, nil
+}
+
+func (f *fakeApps) ListApps(_ context.Context, pageToken string, pageSize int) ([]models.App, string, error) {
+	f.pageToken, f.pageSize = pageToken, pageSize
+	var apps []models.App
+	for _, app := range f.apps {
+		apps = append(apps, *app)
+	}
+	return apps, "next", nil
+}
+
+func (f *fakeApps) UpdateApp(ctx context.Context, appId int64, update models.AppUpdate) (*models.App, error) {
+	app, ok := f.apps[appId]
+	if !ok {
+		return nil, appsservice.ErrAppNotFound
+	}
+	if update.Name != nil {
+		app.Name = *update.Name
+	}
+	if update.StepUpAfter != nil {
+		app.SessionPolicy.StepUpAfter = *update.StepUpAfter
+	}
+	return f.GetApp(ctx, appId)
+}
+
+func (f *fakeApps) RotateAppSecret(_ context.Context, appId int64) (string, error) {
+	if _, ok := f.apps[appId]; !ok {
+		return "", appsservice.ErrAppNotFound
+	}
+	return "rotated", nil
+}
+
+func (f *fakeApps) DeleteApp(_ context.Context, appId int64) error {
+	if _, ok := f.apps[appId]; !ok {
+		return appsservice.ErrAppNotFound
+	}
+	delete(f.apps, appId)
+	return nil
+}
+
+// fakeUsers serves user 1 and records the calls it gets.
+type fakeUsers struct {
+	filter   models.UserFilter
+	update   models.UserUpdate
+	disabled bool
+	deleted  bool
+}
+
+func (f *fakeUsers) GetUser(_ context.Context, userId int64) (*models.User, error) {
+	if userId != 1 {
+		return nil, usersservice.ErrUserNotFound
+	}
+	return &models.User{Id: 1, Email: "a@example.com", CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}, nil
+}
+
+func (f *fakeUsers) ListUsers(ctx context.Context, filter models.UserFilter, _ string, _ int) ([]models.User, string, error) {
+	f.filter = filter
+	user, _ := f.GetUser(ctx, 1)
+	return []models.User{*user}, "", nil
+}
+
+func (f *fakeUsers) UpdateUser(ctx context.Context, userId int64, update models.UserUpdate) (*models.User, error) {
+	f.update = update
+	if update.Email != nil && *update.Email == "taken@example.com" {
+		return nil, usersservice.ErrUserAlreadyExists
+	}
+	return f.GetUser(ctx, userId)
+}
+
+func (f *fakeUsers) DisableUser(ctx context.Context, userId int64) error {
+	f.disabled = true
+	_, err := f.GetUser(ctx, userId)
+	return err
+}
+
+func (f *fakeUsers) EnableUser(ctx context.Context, userId int64) error {
+	f.disabled = false
+	_, err := f.GetUser(ctx, userId)
+	return err
+}
+
+func (f *fakeUsers) DeleteUser(ctx context.Context, userId int64) error {
+	f.deleted = true
+	_, err := f.GetUser(ctx, userId)
+	return err
+}
+
+// fakeProfiles keeps the profile and app metadata of user 1.
+type fakeProfiles struct {
+	profile  models.Profile
+	metadata map[int64]json.RawMessage
+}
+
+func (f *fakeProfiles) GetProfile(_ context.Context, userId int64) (*models.Profile, error) {
+	if userId != 1 {
+		return nil, usersservice.ErrUserNotFound
+	}
+	profile := f.profile
+	profile.UserId = userId
+	return &profile, nil
+}
+
+func (f *fakeProfiles) UpdateProfile(ctx context.Context, userId int64, update models.ProfileUpdate) (*models.Profile, error) {
+	if update.DisplayName != nil {
+		f.profile.DisplayName = *update.DisplayName
+	}
+	if update.Locale != nil {
+		f.profile.Locale = *update.Locale
+	}
+	return f.GetProfile(ctx, userId)
+}
+
+func (f *fakeProfiles) GetAppMetadata(_ context.Context, _ int64, appId int64) (json.RawMessage, error) {
+	if metadata, ok := f.metadata[appId]; ok {
+		return metadata, nil
+	}
+	return json.RawMessage("{}"), nil
+}
+
+func (f *fakeProfiles) SetAppMetadata(_ context.Context, _ int64, appId int64, metadata json.RawMessage) error {
+	var object map[string]any
+	if json.Unmarshal(metadata, &object) != nil || object == nil {
+		return usersservice.ErrInvalidMetadata
+	}
+	f.metadata[appId] = metadata
+	return nil
+}
+
+// fakeImporter skips users of example.org and fails the ones without a hash.
+type fakeImporter struct {
+	dryRun bool
+}
+
+func (f *fakeImporter) ImportUsers(_ context.Context, records []models.ImportedUser, dryRun bool) (models.ImportResult, error) {
+	f.dryRun = dryRun
+	var result models.ImportResult
+	for _, record := range records {
+		switch {
+		case record.PasswordHash == "":
+			result.Failed++
+			result.Errors = append(result.Errors, models.ImportError{Line: record.Line, Email: record.Email, Error: "no password hash"})
+		case strings.HasSuffix(record.Email, "@example.org"):
+			result.Skipped++
+		default:
+			result.Imported++
+		}
+	}
+	return result, nil
+}
+
+// fakePrivacy exports and erases user 1.
+type fakePrivacy struct {
+	erased bool
+}
+
+func (f *fakePrivacy) ExportUser(_ context.Context, userId int64) (*models.UserExport, error) {
+	if userId != 1 {
+		return nil, usersservice.ErrUserNotFound
+	}
+	return &models.UserExport{
+		User:        models.User{Id: 1, Email: "a@example.com"},
+		Roles:       []string{models.RoleAdmin},
+		AppMetadata: []models.AppMetadata{{AppId: 7, Metadata: json.RawMessage(`{"plan":"pro"}`)}},
+		AuditEvents: []models.AuditEvent{{Id: 3, Action: models.AuditLogin, Hash: []byte{0xab, 0xcd}}},
+		Sessions:    []models.Session{{Id: "s1", UserId: 1, AppId: 7}},
+	}, nil
+}
+
+func (f *fakePrivacy) EraseUser(_ context.Context, userId int64) error {
+	if userId != 1 {
+		return usersservice.ErrUserNotFound
+	}
+	f.erased = true
+	return nil
+}
+
+// fakeLogins has the history of user 1 only.
+type fakeLogins struct{}
+
+func (fakeLogins) GetLoginHistory(_ context.Context, userId int64, pageToken string, _ int) ([]models.LoginAttempt, string, error) {
+	if userId != 1 {
+		return nil, "", usersservice.ErrUserNotFound
+	}
+	if pageToken == "bad" {
+		return nil, "", usersservice.ErrInvalidPageToken
+	}
+	return []models.LoginAttempt{{Id: 9, UserId: 1, Login: "ann@example.com", IP: "10.0.0.1", Success: true}}, "9", nil
+}
+
+func (fakeLogins) ListDevices(_ context.Context, userId int64) ([]models.Device, error) {
+	if userId != 1 {
+		return nil, usersservice.ErrUserNotFound
+	}
+	return []models.Device{{DeviceId: "d1", UserAgent: "curl"}}, nil
+}
+
+// fakeSessions has session "s1" of user 1.
+type fakeSessions struct {
+	revoked bool
+}
+
+func (f *fakeSessions) ListSessions(_ context.Context, userId int64) ([]models.Session, error) {
+	if userId != 1 || f.revoked {
+		return nil, nil
+	}
+	return []models.Session{{Id: "s1", UserId: 1, AppId: 7}}, nil
+}
+
+func (f *fakeSessions) GetSession(_ context.Context, sessionId string) (*models.Session, error) {
+	if sessionId != "s1" {
+		return nil, sessionsservice.ErrSessionNotFound
+	}
+	return &models.Session{Id: "s1", UserId: 1, AppId: 7}, nil
+}
+
+func (f *fakeSessions) RevokeSession(_ context.Context, sessionId string) error {
+	if sessionId != "s1" {
+		return sessionsservice.ErrSessionNotFound
+	}
+	f.revoked = true
+	return nil
+}
+
+func (f *fakeSessions) RevokeAllSessions(_ context.Context, userId int64) (int64, error) {
+	if userId != 1 || f.revoked {
+		return 0, nil
+	}
+	f.revoked = true
+	return 1, nil
+}
+
+// fakeWebhooks has subscription 1 of app 7 with delivery 3.
+type fakeWebhooks struct {
+	update      models.WebhookSubscriptionUpdate
+	redelivered bool
+}
+
+var fakeSubscription = models.WebhookSubscription{Id: 1, AppId: 7, URL: "https://example.com/hook", Enabled: true}
+
+func (f *fakeWebhooks) CreateSubscription(_ context.Context, sub models.WebhookSubscription) (*models.WebhookSubscription, error) {
+	if sub.URL == "" {
+		return nil, webhooksservice.ErrInvalidSubscription
+	}
+	sub.Id, sub.Secret = 2, "secret"
+	return &sub, nil
+}
+
+func (f *fakeWebhooks) GetSubscription(_ context.Context, id int64) (*models.WebhookSubscription, error) {
+	if id != 1 {
+		return nil, webhooksservice.ErrSubscriptionNotFound
+	}
+	sub := fakeSubscription
+	return &sub, nil
+}
+
+func (f *fakeWebhooks) ListSubscriptions(_ context.Context, appId int64) ([]models.WebhookSubscription, error) {
+	if appId != 7 {
+		return nil, nil
+	}
+	return []models.WebhookSubscription{fakeSubscription}, nil
+}
+
+func (f *fakeWebhooks) UpdateSubscription(ctx context.Context, id int64, update models.WebhookSubscriptionUpdate) (*models.WebhookSubscription, error) {
+	f.update = update
+	return f.GetSubscription(ctx, id)
+}
+
+func (f *fakeWebhooks) RotateSubscriptionSecret(context.Context, int64) (string, error) {
+	return "rotated", nil
+}
+
+func (f *fakeWebhooks) DeleteSubscription(context.Context, int64) error {
+	return nil
+}
+
+func (f *fakeWebhooks) ListDeliveries(_ context.Context, _ int64, status string, _ string, _ int) ([]models.WebhookDelivery, string, error) {
+	if status == "unknown" {
+		return nil, "", webhooksservice.ErrInvalidStatus
+	}
+	return []models.WebhookDelivery{{Id: 3, SubscriptionId: 1, Status: models.DeliveryDead, Attempts: 3}}, "", nil
+}
+
+func (f *fakeWebhooks) GetDelivery(_ context.Context, id int64) (*models.WebhookDelivery, []models.WebhookAttempt, error) {
+	if id != 3 {
+		return nil, nil, webhooksservice.ErrDeliveryNotFound
+	}
+	delivery := &models.WebhookDelivery{
+		Id:     3,
+		Event:  models.Event{Id: "e1", Type: "user.created", Payload: json.RawMessage(`{"user_id":1}`)},
+		Status: models.DeliveryDead,
+	}
+	return delivery, []models.WebhookAttempt{{Id: 4, StatusCode: 500, Duration: 1500 * time.Millisecond}}, nil
+}
+
+func (f *fakeWebhooks) Redeliver(_ context.Context, id int64) error {
+	if id != 3 {
+		return webhooksservice.ErrDeliveryNotFound
+	}
+	f.redelivered = true
+	return nil
+}
+
+// fakeWatcher sends two events after any cursor but "bad", which is
+// invalid, "fail", which fails after the first event, and "block", which
+// waits for the stream to end.
+type fakeWatcher struct {
+	cursor string
+}
+
+func (f *fakeWatcher) Watch(ctx context.Context, cursor string, send func(event models.Event, cursor string) error) error {
+	f.cursor = cursor
+	switch cursor {
+	case "bad":
+		return watchservice.ErrInvalidCursor
+	case "block":
+		<-ctx.Done()
+		return nil
+	}
+
+	event := models.Event{Id: "e1", Type: models.EventUserRegistered, Payload: json.RawMessage(`{"user_id":1}`)}
+	if err := send(event, "c1"); err != nil {
+		return err
+	}
+	if cursor == "fail" {
+		return watchservice.ErrInternalServerError
+	}
+	event.Id = "e2"
+	return send(event, "c2")
+}
+
+// fakeAudit records the filter it lists with and the anchor it verifies
+// with.
+type fakeAudit struct {
+	filter models.AuditFilter
+	anchor *models.AuditAnchor
+}
+
+func (f *fakeAudit) ListAuditEvents(_ context.Context, filter models.AuditFilter, pageToken string, _ int) ([]models.AuditEvent, string, error) {
+	if pageToken == "bad" {
+		return nil, "", auditservice.ErrInvalidPageToken
+	}
+	f.filter = filter
+	return []models.AuditEvent{{Id: 1, Action: models.AuditLogin, PrevHash: nil, Hash: []byte{1, 2}}}, "1", nil
+}
+
+func (f *fakeAudit) VerifyChain(_ context.Context, anchor *models.AuditAnchor) (*models.AuditVerification, error) {
+	f.anchor = anchor
+	return &models.AuditVerification{
+		Checked:  5,
+		BrokenAt: 4,
+		Reason:   "previous hash does not match the previous event",
+		Head:     &models.AuditAnchor{Id: 3, Hash: []byte{0xab}},
+	}, nil
+}
+
+func newAdminGateway(routes ...Route) http.Handler {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	chain := append(interceptors.Unary(log, &rpcRecorder{}, time.Second), interceptors.UnaryAdmin(adminTokens{}, adminTokens{}, adminAppId, admin.Service))
+	streamChain := append(interceptors.Stream(log, &rpcRecorder{}), interceptors.StreamAdmin(adminTokens{}, adminTokens{}, adminAppId, admin.Service))
+	return New(chain, streamChain, routes...)
+}
+
+// doAs calls the gateway with token in the authorization header.
+func doAs(t *testing.T, h http.Handler, token, method, target, body string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var res map[string]any
+	if rec.Body.Len() > 0 {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	}
+	return rec, res
+}
+
+func TestGateway_AdminOnly(t *testing.T) {
+	h := newAdminGateway(AppRoutes(admin.NewAppsAPI(&fakeApps{apps: map[int64]*models.App{}}))...)
+
+	tests := []struct {
+		token  string
+		status int
+		code   codes.Code
+	}{
+		{token: "", status: http.StatusUnauthorized, code: codes.Unauthenticated},
+		{token: "forged", status: http.StatusUnauthorized, code: codes.Unauthenticated},
+		{token: "user", status: http.StatusForbidden, code: codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		rec, res := doAs(t, h, tt.token, http.MethodGet, "/v1/admin/apps", "")
+		assert.Equal(t, tt.status, rec.Code, tt.token)
+		assert.Equal(t, float64(tt.code), res["code"], tt.token)
+	}
+
+	rec, _ := doAs(t, h, "admin", http.MethodGet, "/v1/admin/apps", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestGateway_AppRoutes(t *testing.T) {
+	apps := &fakeApps{apps: map[int64]*models.App{}}
+	h := newAdminGateway(AppRoutes(admin.NewAppsAPI(apps))...)
+
+	rec, res := doAs(t, h, "admin", http.MethodPost, "/v1/admin/apps",
+		`{"name":"shop","redirectUris":["https://shop.example.com/cb"],"tokenTtlSeconds":600,"sessionPolicy":{"maxSessions":3,"limitAction":"deny"}}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	app := res["app"].(map[string]any)
+	assert.Equal(t, 1.0, app["id"])
+	assert.Equal(t, "secret", app["secret"], "the secret is returned on creation")
+	assert.Equal(t, true, app["enabled"])
+	assert.Equal(t, 600.0, app["tokenTtlSeconds"])
+	assert.Equal(t, 3.0, app["sessionPolicy"].(map[string]any)["maxSessions"])
+	assert.Equal(t, 10*time.Minute, apps.apps[1].TokenTTL)
+
+	rec, res = doAs(t, h, "admin", http.MethodGet, "/v1/admin/apps/1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, res["app"], "secret")
+
+	rec, res = doAs(t, h, "admin", http.MethodGet, "/v1/admin/apps?pageToken=abc&pageSize=20", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, res["apps"], 1)
+	assert.Equal(t, "next", res["nextPageToken"])
+	assert.Equal(t, "abc", apps.pageToken)
+	assert.Equal(t, 20, apps.pageSize)
+
+	rec, res = doAs(t, h, "admin", http.MethodPatch, "/v1/admin/apps/1", `{"name":"store","stepUpAfterSeconds":900}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "store", res["app"].(map[string]any)["name"])
+	assert.Equal(t, 15*time.Minute, apps.apps[1].SessionPolicy.StepUpAfter)
+	assert.Equal(t, 10*time.Minute, apps.apps[1].TokenTTL, "fields that are not set are left unchanged")
+
+	rec, res = doAs(t, h, "admin", http.MethodPost, "/v1/admin/apps/1/secret", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "rotated", res["secret"])
+
+	rec, _ = doAs(t, h, "admin", http.MethodDelete, "/v1/admin/apps/1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec, res = doAs(t, h, "admin", http.MethodGet, "/v1/admin/apps/1", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, float64(codes.NotFound), res["code"])
+
+	rec, _ = doAs(t, h, "admin", http.MethodGet, "/v1/admin/apps?pageSize=many", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGateway_UserRoutes(t *testing.T) {
+	users := &fakeUsers{}
+	h := newAdminGateway(UserRoutes(admin.NewUsersAPI(users))...)
+
+	rec, res := doAs(t, h, "admin", http.MethodGet, "/v1/admin/users/1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]any{"id": 1.0, "email": "a@example.com", "isAdmin": false, "createdAt": "2024-01-02T03:04:05Z"}, res["user"])
+
+	rec, res = doAs(t, h, "admin", http.MethodGet, "/v1/admin/users?emailPrefix=a&createdAfter=2024-01-01T00:00:00Z&isDisabled=false", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, res["users"], 1)
+	assert.Equal(t, "a", users.filter.EmailPrefix)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), users.filter.CreatedAfter)
+	assert.True(t, users.filter.CreatedBefore.IsZero())
+	assert.Nil(t, users.filter.IsAdmin, "filters that are not given match everything")
+	require.NotNil(t, users.filter.IsDisabled)
+	assert.False(t, *users.filter.IsDisabled)
+
+	rec, _ = doAs(t, h, "admin", http.MethodGet, "/v1/admin/users?createdAfter=yesterday", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec, _ = doAs(t, h, "admin", http.MethodGet, "/v1/admin/users?isAdmin=maybe", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec, _ = doAs(t, h, "admin", http.MethodPatch, "/v1/admin/users/1", `{"username":"","isAdmin":true}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Nil(t, users.update.Email)
+	require.NotNil(t, users.update.Username)
+	assert.Empty(t, *users.update.Username, "an empty username removes it")
+	require.NotNil(t, users.update.IsAdmin)
+	assert.True(t, *users.update.IsAdmin)
+
+	rec, res = doAs(t, h, "admin", http.MethodPatch, "/v1/admin/users/1", `{"email":"taken@example.com"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, float64(codes.AlreadyExists), res["code"])
+
+	rec, _ = doAs(t, h, "admin", http.MethodPost, "/v1/admin/users/1/disable", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, users.disabled)
+	rec, _ = doAs(t, h, "admin", http.MethodPost, "/v1/admin/users/1/enable", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.False(t, users.disabled)
+
+	rec, _ = doAs(t, h, "admin", http.MethodDelete, "/v1/admin/users/2", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec, _ = doAs(t, h, "admin", http.MethodDelete, "/v1/admin/users/1", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, users.deleted)
+}
+
+func TestGateway_ProfileRoutes(t *testing.T) {
+	profiles := &fakeProfiles{profile: models.Profile{Locale: "en-US"}, metadata: map[int64]json.RawMessage{}}
+	h := newAdminGateway(ProfileRoutes(admin.NewProfilesAPI(profiles))...)
+
+	rec, res := doAs(t, h, "admin", http.MethodPatch, "/v1/admin/users/1/profile", `{"displayName":"Ann"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	profile := res["profile"].(map[string]any)
+	assert.Equal(t, "Ann", profile["displayName"])
+	assert.Equal(t, "en-US", profile["locale"])
+
+	rec, _ = doAs(t, h, "admin", http.MethodGet, "/v1/admin/users/2/profile", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec, res = doAs(t, h, "admin", http.MethodGet, "/v1/admin/users/1/apps/7/metadata", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]any{}, res["metadata"])
+
+	rec, res = doAs(t, h, "admin", http.MethodPut, "/v1/admin/users/1/apps/7/metadata", `{"metadata":{"plan":"pro"}}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]any{"plan": "pro"}, res["metadata"])
+
+	rec, res = doAs(t, h, "admin", http.MethodGet, "/v1/admin/users/1/apps/7/metadata", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]any{"plan": "pro"}, res["metadata"])
+
+	rec, _ = doAs(t, h, "admin", http.MethodPut, "/v1/admin/users/1/apps/7/metadata", `{"metadata":[1,2]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	_, doc := do(t, New(nil, nil, ProfileRoutes(admin.NewProfilesAPI(profiles))...), http.MethodGet, OpenAPIPath, "")
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]any)
+	metadata := schemas["SetAppMetadataRequest"].(map[string]any)["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "object"}, metadata["metadata"])
+}
+
+func TestGateway_ImportRoutes(t *testing.T) {
+	importer := &fakeImporter{}
+	h := newAdminGateway(ImportRoutes(admin.NewImportAPI(importer))...)
+
+	rec, res := doAs(t, h, "admin", http.MethodPost, "/v1/admin/users/import", `{"dryRun":true,"users":[
+		{"email":"a@example.com","passwordHash":"h","hashFormat":"bcrypt"},
+		{"email":"b@example.org","passwordHash":"h","hashFormat":"bcrypt"},
+		{"email":"c@example.com","hashFormat":"bcrypt"}]}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]any{
+		"imported": 1.0,
+		"skipped":  1.0,
+		"failed":   1.0,
+		"errors":   []any{map[string]any{"index": 2.0, "email": "c@example.com", "error": "no password hash"}},
+	}, res)
+	assert.True(t, importer.dryRun)
+
+	rec, _ = doAs(t, h, "admin", http.MethodPost, "/v1/admin/users/import", `{"users":[]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGateway_PrivacyRoutes(t *testing.T) {
+	privacy := &fakePrivacy{}
+	h := newAdminGateway(PrivacyRoutes(admin.NewPrivacyAPI(privacy))...)
+
+	rec, res := doAs(t, h, "admin", http.MethodGet, "/v1/admin/users/1/export", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "a@example.com", res["user"].(map[string]any)["email"])
+	assert.Equal(t, []any{"admin"}, res["roles"])
+	assert.Equal(t, map[string]any{"plan": "pro"}, res["appMetadata"].([]any)[0].(map[string]any)["metadata"])
+	assert.Equal(t, "abcd", res["auditEvents"].([]any)[0].(map[string]any)["hash"])
+	assert.Equal(t, "s1", res["sessions"].([]any)[0].(map[string]any)["id"])
+	assert.Equal(t, []any{}, res["logins"])
+
+	rec, _ = doAs(t, h, "admin", http.MethodPost, "/v1/admin/users/1/erase", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.False(t, privacy.erased, "erasure needs a confirmation")
+
+	rec, _ = doAs(t, h, "admin", http.MethodPost, "/v1/admin/users/2/erase", `{"confirm":true}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec, _ = doAs(t, h, "admin", http.MethodPost, "/v1/admin/users/1/erase", `{"confirm":true}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, privacy.erased)
+}
+
+func TestGateway_AuditRoutes(t *testing.T) {
+	audit := &fakeAudit{}
+	h := newAdminGateway(AuditRoutes(admin.NewAuditAPI(audit))...)
+
+	rec, res := doAs(t, h, "admin", http.MethodGet, "/v1/admin/audit/events?action=login&subjectId=3&before=2024-01-01T00:00:00Z", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, models.AuditFilter{Action: "login", SubjectId: 3, Before: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, audit.filter)
+	event := res["events"].([]any)[0].(map[string]any)
+	assert.Equal(t, "", event["prevHash"])
+	assert.Equal(t, "0102", event["hash"])
+	assert.Equal(t, "1", res["nextPageToken"])
+
+	rec, _ = doAs(t, h, "admin", http.MethodGet, "/v1/admin/audit/events?pageToken=bad", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec, res = doAs(t, h, "admin", http.MethodGet, "/v1/admin/audit/verify", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, false, res["valid"])
+	assert.Equal(t, 4.0, res["brokenAt"])
+	assert.Equal(t, 3.0, res["headId"])
+	assert.Equal(t, "ab", res["headHash"])
+	assert.Nil(t, audit.anchor)
+
+	rec, _ = doAs(t, h, "admin", http.MethodGet, "/v1/admin/audit/verify?anchorId=3&anchorHash=ab", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, &models.AuditAnchor{Id: 3, Hash: []byte{0xab}}, audit.anchor)
+
+	for _, query := range []string{"anchorId=3", "anchorHash=ab", "anchorId=3&anchorHash=xyz"} {
+		rec, _ = doAs(t, h, "admin", http.MethodGet, "/v1/admin/audit/verify?"+query, "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+func TestGateway_LoginRoutes(t *testing.T) {
+	h := newAdminGateway(LoginRoutes(admin.NewLoginsAPI(fakeLogins{}))...)
+
+	rec, res := doAs(t, h, "admin", http.MethodGet, "/v1/admin/users/1/logins?pageSize=10", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	attempt := res["attempts"].([]any)[0].(map[string]any)
+	assert.Equal(t, "ann@example.com", attempt["login"])
+	assert.Equal(t, "10.0.0.1", attempt["ip"])
+	assert.Equal(t, true, attempt["success"])
+	assert.Equal(t, "9", res["nextPageToken"])
+
+	rec, _ = doAs(t, h, "admin", http.MethodGet, "/v1/admin/users/1/logins?pageToken=bad", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec, _ = doAs(t, h, "admin", http.MethodGet, "/v1/admin/users/2/logins", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec, res = doAs(t, h, "admin", http.MethodGet, "/v1/admin/users/1/devices", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	device := res["devices"].([]any)[0].(map[string]any)
+	assert.Equal(t, "d1", device["deviceId"])
+	assert.Equal(t, "curl", device["userAgent"])
+
+	rec, _ = doAs(t, h, "user", http.MethodGet, "/v1/admin/users/2/devices", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestGateway_SessionRoutes(t *testing.T) {
+	sessions := &fakeSessions{}
+	h := newAdminGateway(SessionRoutes(admin.NewSessionsAPI(sessions))...)
+
+	rec, res := doAs(t, h, "admin", http.MethodGet, "/v1/admin/users/1/sessions", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	session := res["sessions"].([]any)[0].(map[string]any)
+	assert.Equal(t, "s1", session["id"])
+	assert.Equal(t, 7.0, session["appId"])
+
+	rec, res = doAs(t, h, "admin", http.MethodGet, "/v1/admin/sessions/s1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 1.0, res["session"].(map[string]any)["userId"])
+
+	rec, _ = doAs(t, h, "admin", http.MethodGet, "/v1/admin/sessions/s2", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec, _ = doAs(t, h, "user", http.MethodPost, "/v1/admin/sessions/s1/revoke", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.False(t, sessions.revoked)
+
+	rec, res = doAs(t, h, "admin", http.MethodPost, "/v1/admin/users/1/sessions/revoke", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 1.0, res["revoked"])
+
+	rec, res = doAs(t, h, "admin", http.MethodGet, "/v1/admin/users/1/sessions", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, res["sessions"])
+
+	rec, _ = doAs(t, h, "admin", http.MethodPost, "/v1/admin/sessions/s2/revoke", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestGateway_WebhookRoutes(t *testing.T) {
+	webhooks := &fakeWebhooks{}
+	h := newAdminGateway(WebhookRoutes(admin.NewWebhooksAPI(webhooks))...)
+
+	rec, res := doAs(t, h, "admin", http.MethodPost, "/v1/admin/apps/7/webhooks", `{"url":"https://example.com/new","eventTypes":["user.created"]}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	sub := res["subscription"].(map[string]any)
+	assert.Equal(t, 7.0, sub["appId"])
+	assert.Equal(t, "secret", sub["secret"])
+	assert.Equal(t, true, sub["enabled"])
+	assert.Equal(t, []any{"user.created"}, sub["eventTypes"])
+
+	rec, _ = doAs(t, h, "admin", http.MethodPost, "/v1/admin/apps/7/webhooks", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec, res = doAs(t, h, "admin", http.MethodGet, "/v1/admin/apps/7/webhooks", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, res["subscriptions"], 1)
+
+	rec, res = doAs(t, h, "admin", http.MethodPatch, "/v1/admin/webhooks/1", `{"enabled":false}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotNil(t, webhooks.update.Enabled)
+	assert.False(t, *webhooks.update.Enabled)
+	assert.Nil(t, webhooks.update.URL)
+	assert.NotContains(t, res["subscription"], "secret")
+
+	rec, _ = doAs(t, h, "admin", http.MethodGet, "/v1/admin/webhooks/2", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec, res = doAs(t, h, "admin", http.MethodPost, "/v1/admin/webhooks/1/secret", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "rotated", res["secret"])
+
+	rec, res = doAs(t, h, "admin", http.MethodGet, "/v1/admin/webhooks/1/deliveries?status=dead", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "dead", res["deliveries"].([]any)[0].(map[string]any)["status"])
+
+	rec, _ = doAs(t, h, "admin", http.MethodGet, "/v1/admin/webhooks/1/deliveries?status=unknown", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec, res = doAs(t, h, "admin", http.MethodGet, "/v1/admin/webhook-deliveries/3", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	event := res["delivery"].(map[string]any)["event"].(map[string]any)
+	assert.Equal(t, map[string]any{"user_id": 1.0}, event["payload"])
+	attempt := res["attempts"].([]any)[0].(map[string]any)
+	assert.Equal(t, 500.0, attempt["statusCode"])
+	assert.Equal(t, 1500.0, attempt["durationMs"])
+
+	rec, _ = doAs(t, h, "user", http.MethodPost, "/v1/admin/webhook-deliveries/3/redeliver", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.False(t, webhooks.redelivered)
+
+	rec, _ = doAs(t, h, "admin", http.MethodPost, "/v1/admin/webhook-deliveries/3/redeliver", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, webhooks.redelivered)
+
+	rec, _ = doAs(t, h, "admin", http.MethodDelete, "/v1/admin/webhooks/1", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+// watchAs opens the event stream with token and the Last-Event-ID header
+// if lastEventId is set.
+func watchAs(h http.Handler, token, target, lastEventId string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if lastEventId != "" {
+		req.Header.Set("Last-Event-ID", lastEventId)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGateway_WatchRoutes(t *testing.T) {
+	watcher := &fakeWatcher{}
+	h := newAdminGateway(WatchRoutes(admin.NewWatchAPI(watcher))...)
+
+	rec := watchAs(h, "admin", "/v1/admin/events/watch?cursor=c0", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "c0", watcher.cursor)
+	assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+	events := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n\n"), "\n\n")
+	require.Len(t, events, 2)
+	id, data, _ := strings.Cut(events[0], "\n")
+	assert.Equal(t, "id: c1", id)
+	var res map[string]any
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), &res))
+	assert.Equal(t, "c1", res["cursor"])
+	event := res["event"].(map[string]any)
+	assert.Equal(t, "e1", event["id"])
+	assert.Equal(t, map[string]any{"user_id": 1.0}, event["payload"])
+
+	// a reconnecting client resumes after its last event
+	rec = watchAs(h, "admin", "/v1/admin/events/watch?cursor=c0", "c1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "c1", watcher.cursor)
+
+	rec = watchAs(h, "admin", "/v1/admin/events/watch?cursor=bad", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	watcher.cursor = ""
+	rec = watchAs(h, "user", "/v1/admin/events/watch", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Empty(t, watcher.cursor)
+
+	// failing after the first event, the error is an event of the stream
+	rec = watchAs(h, "admin", "/v1/admin/events/watch?cursor=fail", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "id: c1\n")
+	assert.Contains(t, rec.Body.String(), "event: error\ndata: {\"code\":13,")
+}
+
+func TestGateway_StopStreams(t *testing.T) {
+	h := newAdminGateway(WatchRoutes(admin.NewWatchAPI(&fakeWatcher{}))...)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- watchAs(h, "admin", "/v1/admin/events/watch?cursor=block", "")
+	}()
+	h.(*Gateway).StopStreams()
+
+	select {
+	case rec := <-done:
+		assert.Equal(t, http.StatusOK, rec.Code)
+	case <-time.After(time.Second):
+		t.Fatal("stream did not end")
+	}
+
+	// streams started later end right away too
+	rec := watchAs(h, "admin", "/v1/admin/events/watch?cursor=block", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	_, doc := do(t, h, http.MethodGet, OpenAPIPath, "")
+	operation := doc["paths"].(map[string]any)["/v1/admin/events/watch"].(map[string]any)["get"].(map[string]any)
+	content := operation["responses"].(map[string]any)["200"].(map[string]any)["content"].(map[string]any)
+	assert.Contains(t, content, "text/event-stream")
+	assert.Contains(t, operation["parameters"], map[string]any{"name": "Last-Event-ID", "in": "header", "schema": map[string]any{"type": "string"}})
+}
@@ -0,0 +1,17 @@
+package gateway
+
+import (
+	"net/http"
+	"sso/internal/lib/cors"
+)
+
+// CORS is the policy that lets browser pages on allowedOrigins call the
+// routes.
+func CORS(allowedOrigins []string) cors.Policy {
+	return cors.Policy{
+		AllowedOrigins: allowedOrigins,
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+		AllowedHeaders: []string{"Authorization", "Content-Type", "Last-Event-ID", "X-Request-Id", "X-Device-Id", "Traceparent", "Tracestate"},
+		ExposedHeaders: []string{"X-Request-Id"},
+	}
+}
@@ -0,0 +1,371 @@
+// Package gateway serves the gRPC API as HTTP/JSON for clients that can not
+// speak gRPC. Requests go through the same interceptors and handlers as
+// their gRPC counterparts, so validation and error codes are shared.
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// maxBodySize bounds request bodies, none of the RPCs takes more than a
+// few fields.
+const maxBodySize = 1 << 20
+
+// OpenAPIPath serves the OpenAPI document of the routes.
+const OpenAPIPath = "/openapi.json"
+
+var tracer = otel.Tracer("sso/internal/grpc/gateway")
+
+// Route maps an HTTP method and path to an RPC. Path parameters are written
+// as {name} and fill the request fields tagged path:"name", query parameters
+// fill the ones tagged query:"name", headers the ones tagged header:"Name"
+// and the JSON body fills the rest.
+type Route struct {
+	Method string
+	Path   string
+	// RPC is the full gRPC method name, e.g. /auth.Auth/Login.
+	RPC     string
+	Summary string
+
+	request  reflect.Type
+	response reflect.Type
+	handler  grpc.UnaryHandler
+	stream   streamHandler
+}
+
+// streamHandler runs a server stream, every message is sent with the id to
+// resume the stream after it.
+type streamHandler func(ctx context.Context, req any, send func(msg any, id string) error) error
+
+// Unary creates a route calling call with a *Req decoded from the request.
+func Unary[Req, Resp any](method, path, rpc, summary string, call func(ctx context.Context, req *Req) (*Resp, error)) Route {
+	return Route{
+		Method:   method,
+		Path:     path,
+		RPC:      rpc,
+		Summary:  summary,
+		request:  reflect.TypeFor[Req](),
+		response: reflect.TypeFor[Resp](),
+		handler: func(ctx context.Context, req any) (any, error) {
+			return call(ctx, req.(*Req))
+		},
+	}
+}
+
+// ServerStream creates a route calling call with a *Req decoded from the
+// request and sending the messages it sends as server-sent events. The id of
+// an event resumes the stream after it, clients reconnecting send it back in
+// the Last-Event-ID header.
+func ServerStream[Req, Resp any](method, path, rpc, summary string, call func(ctx context.Context, req *Req, send func(resp *Resp, id string) error) error) Route {
+	return Route{
+		Method:   method,
+		Path:     path,
+		RPC:      rpc,
+		Summary:  summary,
+		request:  reflect.TypeFor[Req](),
+		response: reflect.TypeFor[Resp](),
+		stream: func(ctx context.Context, req any, send func(msg any, id string) error) error {
+			return call(ctx, req.(*Req), func(resp *Resp, id string) error {
+				return send(resp, id)
+			})
+		},
+	}
+}
+
+type Gateway struct {
+	interceptors       []grpc.UnaryServerInterceptor
+	streamInterceptors []grpc.StreamServerInterceptor
+	mux                *http.ServeMux
+
+	// streams is canceled by StopStreams
+	streams     context.Context
+	stopStreams context.CancelFunc
+}
+
+// New creates the HTTP handler serving routes and their OpenAPI document.
+// Every call runs through interceptors, or streamInterceptors for streams,
+// like a gRPC call would.
+func New(interceptors []grpc.UnaryServerInterceptor, streamInterceptors []grpc.StreamServerInterceptor, routes ...Route) *Gateway {
+	g := &Gateway{
+		interceptors:       interceptors,
+		streamInterceptors: streamInterceptors,
+		mux:                http.NewServeMux(),
+	}
+	g.streams, g.stopStreams = context.WithCancel(context.Background())
+	for _, route := range routes {
+		if route.stream != nil {
+			g.mux.Handle(route.Method+" "+route.Path, g.handleStream(route))
+			continue
+		}
+		g.mux.Handle(route.Method+" "+route.Path, g.handle(route))
+	}
+
+	doc, err := json.Marshal(OpenAPI(routes))
+	if err != nil {
+		// the document is built from static types only
+		panic("gateway: failed to encode OpenAPI document: " + err.Error())
+	}
+	g.mux.HandleFunc("GET "+OpenAPIPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(doc)
+	})
+
+	return g
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.ServeHTTP(w, r)
+}
+
+// StopStreams ends the streams in flight and the ones started later.
+// Streams do not finish on their own, so a server shutting down has to end
+// them, clients resume elsewhere with the id of their last event.
+func (g *Gateway) StopStreams() {
+	g.stopStreams()
+}
+
+func (g *Gateway) handle(route Route) http.Handler {
+	info := &grpc.UnaryServerInfo{FullMethod: route.RPC}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := startCall(r, route)
+		defer span.End()
+
+		stream := &transportStream{method: route.RPC, header: w.Header()}
+		ctx = grpc.NewContextWithServerTransportStream(ctx, stream)
+
+		// decoding errors are returned from inside the interceptors, so they
+		// are logged and counted like the ones of the API
+		req, decodeErr := decode(r, route)
+		handler := chain(g.interceptors, info, func(ctx context.Context, req any) (any, error) {
+			if decodeErr != nil {
+				return nil, decodeErr
+			}
+			return route.handler(ctx, req)
+		})
+		resp, err := handler(ctx, req)
+
+		code := status.Code(err)
+		span.SetAttributes(attribute.String("rpc.grpc.status_code", code.String()))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+}
+
+func (g *Gateway) handleStream(route Route) http.Handler {
+	info := &grpc.StreamServerInfo{FullMethod: route.RPC, IsServerStream: true}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := startCall(r, route)
+		defer span.End()
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		defer context.AfterFunc(g.streams, cancel)()
+
+		stream := &eventStream{ctx: ctx, w: w}
+		req, decodeErr := decode(r, route)
+		handler := chainStream(g.streamInterceptors, info, func(_ any, ss grpc.ServerStream) error {
+			if decodeErr != nil {
+				return decodeErr
+			}
+			return route.stream(ss.Context(), req, func(msg any, id string) error {
+				return ss.SendMsg(&streamMessage{id: id, msg: msg})
+			})
+		})
+		stop := stream.keepalive(keepaliveInterval)
+		err := handler(nil, stream)
+		stop()
+
+		span.SetAttributes(attribute.String("rpc.grpc.status_code", status.Code(err).String()))
+		if err != nil {
+			stream.fail(err)
+		}
+	})
+}
+
+// startCall starts the span of a call and returns the context the
+// interceptors get, with the request headers as metadata.
+func startCall(r *http.Request, route Route) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracer.Start(ctx, strings.TrimPrefix(route.RPC, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("http.route", route.Path),
+		),
+	)
+
+	ctx = metadata.NewIncomingContext(ctx, incomingMetadata(r))
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: remoteAddr(r.RemoteAddr)})
+	return ctx, span
+}
+
+// chain wraps handler in interceptors, the first one runs outermost.
+func chain(interceptors []grpc.UnaryServerInterceptor, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) grpc.UnaryHandler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, req any) (any, error) {
+			return interceptor(ctx, req, info, next)
+		}
+	}
+	return handler
+}
+
+// chainStream wraps handler in interceptors, the first one runs outermost.
+func chainStream(interceptors []grpc.StreamServerInterceptor, info *grpc.StreamServerInfo, handler grpc.StreamHandler) grpc.StreamHandler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(srv any, ss grpc.ServerStream) error {
+			return interceptor(srv, ss, info, next)
+		}
+	}
+	return handler
+}
+
+// decode builds the request of route from the JSON body and the path, query
+// and header parameters, failures are InvalidArgument errors.
+func decode(r *http.Request, route Route) (any, error) {
+	req := reflect.New(route.request)
+
+	if r.Body != nil && r.Body != http.NoBody {
+		dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodySize))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(req.Interface()); err != nil && !errors.Is(err, io.EOF) {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid request body: %v", err)
+		}
+	}
+
+	fields := req.Elem()
+	query := r.URL.Query()
+	for i := 0; i < route.request.NumField(); i++ {
+		field := route.request.Field(i)
+		if name := field.Tag.Get("path"); name != "" {
+			if err := setParam(fields.Field(i), r.PathValue(name)); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid %s: %v", name, err)
+			}
+		}
+		if name := field.Tag.Get("query"); name != "" && query.Has(name) {
+			if err := setParam(fields.Field(i), query.Get(name)); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid %s: %v", name, err)
+			}
+		}
+		// a header takes precedence over the query, e.g. Last-Event-ID of a
+		// reconnecting stream over the position it first asked for
+		if name := field.Tag.Get("header"); name != "" && r.Header.Get(name) != "" {
+			if err := setParam(fields.Field(i), r.Header.Get(name)); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid %s: %v", name, err)
+			}
+		}
+	}
+
+	return req.Interface(), nil
+}
+
+// setParam sets a field from a path or query parameter. Pointer fields stay
+// nil without the parameter, times are RFC 3339.
+func setParam(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Pointer {
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	}
+	if field.Type() == reflect.TypeFor[time.Time]() {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return errors.New("must be an RFC 3339 time")
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		field.SetBool(b)
+	case reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		field.SetInt(n)
+	default:
+		panic("gateway: unsupported parameter type " + field.Type().String())
+	}
+	return nil
+}
+
+// incomingMetadata passes the request headers on as gRPC metadata, e.g.
+// x-request-id, user-agent and x-device-id.
+func incomingMetadata(r *http.Request) metadata.MD {
+	md := make(metadata.MD, len(r.Header))
+	for name, values := range r.Header {
+		md.Append(strings.ToLower(name), values...)
+	}
+	return md
+}
+
+// remoteAddr is the client address of an HTTP request as a net.Addr.
+type remoteAddr string
+
+func (a remoteAddr) Network() string { return "tcp" }
+func (a remoteAddr) String() string  { return string(a) }
+
+// transportStream lets handlers set response headers with grpc.SetHeader,
+// they become HTTP response headers.
+type transportStream struct {
+	method string
+	header http.Header
+}
+
+func (s *transportStream) Method() string {
+	return s.method
+}
+
+func (s *transportStream) SetHeader(md metadata.MD) error {
+	for name, values := range md {
+		for _, value := range values {
+			s.header.Add(name, value)
+		}
+	}
+	return nil
+}
+
+func (s *transportStream) SendHeader(md metadata.MD) error {
+	return s.SetHeader(md)
+}
+
+func (s *transportStream) SetTrailer(metadata.MD) error {
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
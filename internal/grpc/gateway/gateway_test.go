@@ -0,0 +1,315 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	authgrpc "sso/internal/grpc/auth"
+	"sso/internal/grpc/interceptors"
+	"sso/internal/lib/jwt"
+	authservice "sso/internal/services/auth"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+// fakeAuth records the calls it gets and fails them with err if set.
+type fakeAuth struct {
+	err     error
+	userId  int64
+	isAdmin bool
+	appId   int
+}
+
+func (f *fakeAuth) Login(ctx context.Context, email string, password string, appId int) (string, error) {
+	f.appId = appId
+	return "token-for-" + email, f.err
+}
+
+func (f *fakeAuth) Logout(ctx context.Context, token string) (bool, error) {
+	return true, f.err
+}
+
+func (f *fakeAuth) Register(ctx context.Context, email string, password string) (int64, error) {
+	return 42, f.err
+}
+
+func (f *fakeAuth) IsAdmin(ctx context.Context, userId int64) (bool, error) {
+	f.userId = userId
+	return true, f.err
+}
+
+func (f *fakeAuth) SetAdmin(ctx context.Context, userId int64, isAdmin bool) (bool, error) {
+	f.userId, f.isAdmin = userId, isAdmin
+	return isAdmin, f.err
+}
+
+func (f *fakeAuth) ValidateToken(ctx context.Context, token string) (*jwt.Claims, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &jwt.Claims{
+		UserId:    5,
+		Email:     "a@example.com",
+		AppId:     7,
+		SessionId: "session-of-" + token,
+		AuthTime:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		ExpiresAt: time.Date(2024, 1, 2, 4, 4, 5, 0, time.UTC),
+	}, nil
+}
+
+func (f *fakeAuth) Refresh(ctx context.Context, token string) (string, error) {
+	return "refreshed-" + token, f.err
+}
+
+func (f *fakeAuth) StepUp(ctx context.Context, token string, password string) (string, error) {
+	return "stepped-up-" + token, f.err
+}
+
+type rpcRecorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *rpcRecorder) ObserveRPC(method string, code codes.Code, latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, method+" "+code.String())
+}
+
+func newGateway(auth *fakeAuth, observer interceptors.RPCObserver, origins ...string) http.Handler {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	routes := append(AuthRoutes(authgrpc.NewServerAPI(auth)), TokenRoutes(authgrpc.NewTokenAPI(auth))...)
+	g := New(interceptors.Unary(log, observer, time.Second), interceptors.Stream(log, observer), routes...)
+	return CORS(origins).Handler(g)
+}
+
+func do(t *testing.T, h http.Handler, method, target, body string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, reader))
+
+	var res map[string]any
+	if rec.Body.Len() > 0 {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	}
+	return rec, res
+}
+
+func TestGateway_Routes(t *testing.T) {
+	auth := &fakeAuth{}
+	h := newGateway(auth, &rpcRecorder{})
+
+	rec, res := do(t, h, http.MethodPost, "/v1/auth/register", `{"email":"a@example.com","password":"secret"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]any{"userId": 42.0}, res)
+
+	rec, res = do(t, h, http.MethodPost, "/v1/auth/login", `{"email":"a@example.com","password":"secret","appId":7}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]any{"token": "token-for-a@example.com"}, res)
+	assert.Equal(t, 7, auth.appId)
+
+	rec, res = do(t, h, http.MethodPost, "/v1/auth/logout", `{"token":"t"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]any{"isLoggedOut": true}, res)
+
+	rec, res = do(t, h, http.MethodGet, "/v1/users/17/admin", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]any{"isAdmin": true}, res)
+	assert.Equal(t, int64(17), auth.userId)
+
+	rec, res = do(t, h, http.MethodPut, "/v1/users/18/admin", `{"isAdmin":true}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]any{"isAdmin": true}, res)
+	assert.Equal(t, int64(18), auth.userId)
+	assert.True(t, auth.isAdmin)
+}
+
+func TestGateway_TokenRoutes(t *testing.T) {
+	h := newGateway(&fakeAuth{}, &rpcRecorder{})
+
+	rec, res := do(t, h, http.MethodPost, "/v1/auth/validate", `{"token":"t"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]any{
+		"userId":    5.0,
+		"email":     "a@example.com",
+		"appId":     7.0,
+		"sessionId": "session-of-t",
+		"authTime":  "2024-01-02T03:04:05Z",
+		"expiresAt": "2024-01-02T04:04:05Z",
+	}, res)
+
+	rec, res = do(t, h, http.MethodPost, "/v1/auth/refresh", `{"token":"t"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]any{"token": "refreshed-t"}, res)
+
+	rec, res = do(t, h, http.MethodPost, "/v1/auth/step-up", `{"token":"t","password":"secret"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]any{"token": "stepped-up-t"}, res)
+}
+
+func TestGateway_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		auth   *fakeAuth
+		method string
+		target string
+		body   string
+		status int
+		code   codes.Code
+	}{
+		{
+			name:   "validation",
+			auth:   &fakeAuth{},
+			method: http.MethodPost,
+			target: "/v1/auth/login",
+			body:   `{"email":"a@example.com","password":"secret"}`,
+			status: http.StatusBadRequest,
+			code:   codes.InvalidArgument,
+		},
+		{
+			name:   "service error",
+			auth:   &fakeAuth{err: errors.New("db is down")},
+			method: http.MethodPost,
+			target: "/v1/auth/register",
+			body:   `{"email":"a@example.com","password":"secret"}`,
+			status: http.StatusInternalServerError,
+			code:   codes.Internal,
+		},
+		{
+			name:   "step-up required",
+			auth:   &fakeAuth{err: authservice.ErrStepUpRequired},
+			method: http.MethodPost,
+			target: "/v1/auth/validate",
+			body:   `{"token":"t"}`,
+			status: http.StatusBadRequest,
+			code:   codes.FailedPrecondition,
+		},
+		{
+			name:   "step-up without password",
+			auth:   &fakeAuth{},
+			method: http.MethodPost,
+			target: "/v1/auth/step-up",
+			body:   `{"token":"t"}`,
+			status: http.StatusBadRequest,
+			code:   codes.InvalidArgument,
+		},
+		{
+			name:   "unknown field",
+			auth:   &fakeAuth{},
+			method: http.MethodPost,
+			target: "/v1/auth/logout",
+			body:   `{"token":"t","extra":1}`,
+			status: http.StatusBadRequest,
+			code:   codes.InvalidArgument,
+		},
+		{
+			name:   "malformed body",
+			auth:   &fakeAuth{},
+			method: http.MethodPost,
+			target: "/v1/auth/logout",
+			body:   `{"token":`,
+			status: http.StatusBadRequest,
+			code:   codes.InvalidArgument,
+		},
+		{
+			name:   "invalid path parameter",
+			auth:   &fakeAuth{},
+			method: http.MethodGet,
+			target: "/v1/users/abc/admin",
+			status: http.StatusBadRequest,
+			code:   codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, res := do(t, newGateway(tt.auth, &rpcRecorder{}), tt.method, tt.target, tt.body)
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, float64(tt.code), res["code"])
+			assert.NotEmpty(t, res["message"])
+		})
+	}
+}
+
+func TestGateway_Interceptors(t *testing.T) {
+	observer := &rpcRecorder{}
+	h := newGateway(&fakeAuth{}, observer)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/1/admin", nil)
+	req.Header.Set("X-Request-Id", "req-1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, "req-1", rec.Header().Get("X-Request-Id"))
+
+	rec, _ = do(t, h, http.MethodGet, "/v1/users/x/admin", "")
+	assert.NotEmpty(t, rec.Header().Get("X-Request-Id"))
+
+	assert.Equal(t, []string{"/auth.Auth/IsAdmin OK", "/auth.Auth/IsAdmin InvalidArgument"}, observer.calls)
+}
+
+func TestCORS(t *testing.T) {
+	h := newGateway(&fakeAuth{}, &rpcRecorder{}, "https://app.example.com")
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/v1/auth/login", nil)
+	preflight.Header.Set("Origin", "https://app.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, preflight)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), "Content-Type")
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/1/admin", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "X-Request-Id", rec.Header().Get("Access-Control-Expose-Headers"))
+
+	preflight.Header.Set("Origin", "https://evil.example.com")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, preflight)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestOpenAPI(t *testing.T) {
+	rec, doc := do(t, newGateway(&fakeAuth{}, &rpcRecorder{}), http.MethodGet, OpenAPIPath, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, openAPIVersion, doc["openapi"])
+	paths := doc["paths"].(map[string]any)
+	assert.Len(t, paths, 7)
+
+	admin := paths["/v1/users/{userId}/admin"].(map[string]any)
+	require.Contains(t, admin, "get")
+	require.Contains(t, admin, "put")
+	assert.Equal(t, "auth_Auth_IsAdmin", admin["get"].(map[string]any)["operationId"])
+	assert.NotContains(t, admin["get"], "requestBody")
+	assert.Contains(t, admin["put"], "requestBody")
+
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]any)
+	login := schemas["LoginRequest"].(map[string]any)["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "integer", "format": "int32"}, login["appId"])
+	validate := schemas["ValidateTokenResponse"].(map[string]any)["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "string", "format": "date-time"}, validate["expiresAt"])
+	setAdmin := schemas["SetAdminRequest"].(map[string]any)["properties"].(map[string]any)
+	assert.NotContains(t, setAdmin, "userId")
+	assert.Contains(t, schemas, "Status")
+}
@@ -0,0 +1,170 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	openAPIVersion = "3.0.3"
+	apiTitle       = "sso"
+	apiVersion     = "v1"
+)
+
+// OpenAPI describes routes as an OpenAPI 3 document, ready to be encoded as
+// JSON. Schemas are derived from the request and response types, named
+// after the RPC. Streams respond with text/event-stream, every event is a
+// response.
+func OpenAPI(routes []Route) map[string]any {
+	paths := map[string]any{}
+	schemas := map[string]any{
+		"Status": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"code":    map[string]any{"type": "integer", "format": "int32", "description": "gRPC status code"},
+				"message": map[string]any{"type": "string"},
+			},
+		},
+	}
+
+	for _, route := range routes {
+		rpc := path.Base(route.RPC)
+		service := path.Base(path.Dir(route.RPC))
+		requestName, responseName := rpc+"Request", rpc+"Response"
+		content := jsonContent("#/components/schemas/" + responseName)
+		if route.stream != nil {
+			content = map[string]any{
+				"text/event-stream": map[string]any{
+					"schema": map[string]any{"$ref": "#/components/schemas/" + responseName},
+				},
+			}
+		}
+
+		operation := map[string]any{
+			"operationId": strings.ReplaceAll(service, ".", "_") + "_" + rpc,
+			"summary":     route.Summary,
+			"tags":        []string{service},
+			"responses": map[string]any{
+				"200": map[string]any{
+					"description": "OK",
+					"content":     content,
+				},
+				"default": map[string]any{
+					"description": "Error, the code is the gRPC status code",
+					"content":     jsonContent("#/components/schemas/Status"),
+				},
+			},
+		}
+
+		var parameters []any
+		for i := 0; i < route.request.NumField(); i++ {
+			field := route.request.Field(i)
+			if name := field.Tag.Get("path"); name != "" {
+				parameters = append(parameters, map[string]any{
+					"name":     name,
+					"in":       "path",
+					"required": true,
+					"schema":   schema(field.Type),
+				})
+			}
+			if name := field.Tag.Get("query"); name != "" {
+				parameters = append(parameters, map[string]any{
+					"name":   name,
+					"in":     "query",
+					"schema": schema(field.Type),
+				})
+			}
+			if name := field.Tag.Get("header"); name != "" {
+				parameters = append(parameters, map[string]any{
+					"name":   name,
+					"in":     "header",
+					"schema": schema(field.Type),
+				})
+			}
+		}
+		if parameters != nil {
+			operation["parameters"] = parameters
+		}
+
+		body := schema(route.request)
+		if len(body["properties"].(map[string]any)) > 0 && route.Method != http.MethodGet {
+			schemas[requestName] = body
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content":  jsonContent("#/components/schemas/" + requestName),
+			}
+		}
+		schemas[responseName] = schema(route.response)
+
+		item, ok := paths[route.Path].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[route.Path] = item
+		}
+		item[strings.ToLower(route.Method)] = operation
+	}
+
+	return map[string]any{
+		"openapi": openAPIVersion,
+		"info": map[string]any{
+			"title":   apiTitle,
+			"version": apiVersion,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+		},
+	}
+}
+
+func jsonContent(ref string) map[string]any {
+	return map[string]any{
+		"application/json": map[string]any{
+			"schema": map[string]any{"$ref": ref},
+		},
+	}
+}
+
+// schema describes the JSON encoding of t.
+func schema(t reflect.Type) map[string]any {
+	switch t {
+	case reflect.TypeFor[time.Time]():
+		return map[string]any{"type": "string", "format": "date-time"}
+	case reflect.TypeFor[json.RawMessage]():
+		return map[string]any{"type": "object"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schema(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Int32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schema(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]any{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = schema(field.Type)
+		}
+		return map[string]any{"type": "object", "properties": properties}
+	default:
+		panic("gateway: no schema for " + t.String())
+	}
+}
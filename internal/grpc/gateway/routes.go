@@ -0,0 +1,171 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	authgrpc "sso/internal/grpc/auth"
+	"time"
+
+	ssov1 "github.com/makar182/protos/gen/sso"
+)
+
+// The JSON field names follow the protobuf JSON mapping of the messages.
+
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	AppId    int32  `json:"appId"`
+}
+
+type loginResponse struct {
+	Token string `json:"token"`
+}
+
+type logoutRequest struct {
+	Token string `json:"token"`
+}
+
+type logoutResponse struct {
+	IsLoggedOut bool `json:"isLoggedOut"`
+}
+
+type registerRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type registerResponse struct {
+	UserId int64 `json:"userId"`
+}
+
+type isAdminRequest struct {
+	UserId int64 `json:"-" path:"userId"`
+}
+
+type isAdminResponse struct {
+	IsAdmin bool `json:"isAdmin"`
+}
+
+type setAdminRequest struct {
+	UserId  int64 `json:"-" path:"userId"`
+	IsAdmin bool  `json:"isAdmin"`
+}
+
+type setAdminResponse struct {
+	IsAdmin bool `json:"isAdmin"`
+}
+
+type validateTokenRequest struct {
+	Token string `json:"token"`
+}
+
+type validateTokenResponse struct {
+	UserId    int64     `json:"userId"`
+	Email     string    `json:"email"`
+	AppId     int64     `json:"appId"`
+	SessionId string    `json:"sessionId"`
+	AuthTime  time.Time `json:"authTime"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type refreshRequest struct {
+	Token string `json:"token"`
+}
+
+type refreshResponse struct {
+	Token string `json:"token"`
+}
+
+type stepUpRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type stepUpResponse struct {
+	Token string `json:"token"`
+}
+
+// AuthRoutes maps the REST endpoints to the auth API, new RPCs get their
+// route here.
+func AuthRoutes(api ssov1.AuthServer) []Route {
+	return []Route{
+		Unary(http.MethodPost, "/v1/auth/register", "/auth.Auth/Register", "Register a user",
+			func(ctx context.Context, req *registerRequest) (*registerResponse, error) {
+				res, err := api.Register(ctx, &ssov1.RegisterRequest{Email: req.Email, Password: req.Password})
+				if err != nil {
+					return nil, err
+				}
+				return &registerResponse{UserId: res.GetUserId()}, nil
+			}),
+		Unary(http.MethodPost, "/v1/auth/login", "/auth.Auth/Login", "Log in to an app",
+			func(ctx context.Context, req *loginRequest) (*loginResponse, error) {
+				res, err := api.Login(ctx, &ssov1.LoginRequest{Email: req.Email, Password: req.Password, AppId: req.AppId})
+				if err != nil {
+					return nil, err
+				}
+				return &loginResponse{Token: res.GetToken()}, nil
+			}),
+		Unary(http.MethodPost, "/v1/auth/logout", "/auth.Auth/Logout", "Revoke a token",
+			func(ctx context.Context, req *logoutRequest) (*logoutResponse, error) {
+				res, err := api.Logout(ctx, &ssov1.LogoutRequest{Token: req.Token})
+				if err != nil {
+					return nil, err
+				}
+				return &logoutResponse{IsLoggedOut: res.GetIsLoggedOut()}, nil
+			}),
+		Unary(http.MethodGet, "/v1/users/{userId}/admin", "/auth.Auth/IsAdmin", "Check whether a user is an admin",
+			func(ctx context.Context, req *isAdminRequest) (*isAdminResponse, error) {
+				res, err := api.IsAdmin(ctx, &ssov1.IsAdminRequest{UserId: req.UserId})
+				if err != nil {
+					return nil, err
+				}
+				return &isAdminResponse{IsAdmin: res.GetIsAdmin()}, nil
+			}),
+		Unary(http.MethodPut, "/v1/users/{userId}/admin", "/auth.Auth/SetAdmin", "Grant or revoke admin rights",
+			func(ctx context.Context, req *setAdminRequest) (*setAdminResponse, error) {
+				res, err := api.SetAdmin(ctx, &ssov1.SetAdminRequest{UserId: req.UserId, IsAdmin: req.IsAdmin})
+				if err != nil {
+					return nil, err
+				}
+				return &setAdminResponse{IsAdmin: res.GetIsAdmin()}, nil
+			}),
+	}
+}
+
+// TokenRoutes maps the REST endpoints to the token API. A FailedPrecondition
+// from validate or refresh means the session needs a step-up first.
+func TokenRoutes(api authgrpc.TokenServer) []Route {
+	return []Route{
+		Unary(http.MethodPost, "/v1/auth/validate", "/auth.Tokens/ValidateToken", "Validate a token",
+			func(ctx context.Context, req *validateTokenRequest) (*validateTokenResponse, error) {
+				res, err := api.ValidateToken(ctx, &authgrpc.ValidateTokenRequest{Token: req.Token})
+				if err != nil {
+					return nil, err
+				}
+				return &validateTokenResponse{
+					UserId:    res.UserId,
+					Email:     res.Email,
+					AppId:     res.AppId,
+					SessionId: res.SessionId,
+					AuthTime:  res.AuthTime,
+					ExpiresAt: res.ExpiresAt,
+				}, nil
+			}),
+		Unary(http.MethodPost, "/v1/auth/refresh", "/auth.Tokens/Refresh", "Issue a new token for the session of a token",
+			func(ctx context.Context, req *refreshRequest) (*refreshResponse, error) {
+				res, err := api.Refresh(ctx, &authgrpc.RefreshRequest{Token: req.Token})
+				if err != nil {
+					return nil, err
+				}
+				return &refreshResponse{Token: res.Token}, nil
+			}),
+		Unary(http.MethodPost, "/v1/auth/step-up", "/auth.Tokens/StepUp", "Re-authenticate the session of a token",
+			func(ctx context.Context, req *stepUpRequest) (*stepUpResponse, error) {
+				res, err := api.StepUp(ctx, &authgrpc.StepUpRequest{Token: req.Token, Password: req.Password})
+				if err != nil {
+					return nil, err
+				}
+				return &stepUpResponse{Token: res.Token}, nil
+			}),
+	}
+}
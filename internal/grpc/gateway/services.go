@@ -0,0 +1,107 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Services describes the RPCs of routes as gRPC services, so native gRPC
+// clients reach the RPCs that are not in the published protos too. Their
+// messages are the JSON of the routes, clients call them with the json
+// content subtype, e.g. grpc.CallContentSubtype("json") in Go. Fields read
+// from a path or query parameter over HTTP go by the parameter name in the
+// JSON of a call. Streams send the messages without their ids, a message
+// that resumes a stream has to carry its position itself.
+func Services(routes ...Route) []*grpc.ServiceDesc {
+	var services []*grpc.ServiceDesc
+	byName := map[string]*grpc.ServiceDesc{}
+	for _, route := range routes {
+		name, method, _ := strings.Cut(strings.TrimPrefix(route.RPC, "/"), "/")
+		service, ok := byName[name]
+		if !ok {
+			// the methods call the routes, there is no server value
+			service = &grpc.ServiceDesc{ServiceName: name, HandlerType: (*any)(nil)}
+			byName[name] = service
+			services = append(services, service)
+		}
+		if route.stream != nil {
+			service.Streams = append(service.Streams, grpc.StreamDesc{StreamName: method, Handler: streamMethod(route), ServerStreams: true})
+			continue
+		}
+		service.Methods = append(service.Methods, grpc.MethodDesc{MethodName: method, Handler: unaryMethod(route)})
+	}
+	return services
+}
+
+func unaryMethod(route Route) grpc.MethodHandler {
+	return func(_ any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		var data json.RawMessage
+		if err := dec(&data); err != nil {
+			return nil, err
+		}
+		req, err := decodeMessage(data, route)
+		if err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return route.handler(ctx, req)
+		}
+		return interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: route.RPC}, route.handler)
+	}
+}
+
+// streamMethod reads the only request of the stream, the server runs the
+// stream interceptors around it.
+func streamMethod(route Route) grpc.StreamHandler {
+	return func(_ any, ss grpc.ServerStream) error {
+		var data json.RawMessage
+		if err := ss.RecvMsg(&data); err != nil {
+			return err
+		}
+		req, err := decodeMessage(data, route)
+		if err != nil {
+			return err
+		}
+		return route.stream(ss.Context(), req, func(msg any, _ string) error {
+			return ss.SendMsg(msg)
+		})
+	}
+}
+
+// decodeMessage builds the request of route from the JSON of a gRPC call,
+// failures are InvalidArgument errors.
+func decodeMessage(data json.RawMessage, route Route) (any, error) {
+	req := reflect.New(route.request)
+	if len(data) == 0 {
+		return req.Interface(), nil
+	}
+	if err := json.Unmarshal(data, req.Interface()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request: %v", err)
+	}
+
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request: %v", err)
+	}
+	fields := req.Elem()
+	for i := 0; i < route.request.NumField(); i++ {
+		field := route.request.Field(i)
+		for _, name := range []string{field.Tag.Get("path"), field.Tag.Get("query")} {
+			value, ok := params[name]
+			if name == "" || !ok {
+				continue
+			}
+			if err := json.Unmarshal(value, fields.Field(i).Addr().Interface()); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid %s: %v", name, err)
+			}
+		}
+	}
+
+	return req.Interface(), nil
+}
@@ -0,0 +1,147 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"sso/internal/grpc/admin"
+	authgrpc "sso/internal/grpc/auth"
+	"sso/internal/grpc/interceptors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// jsonCodec stands in for the one of the web package, which imports this
+// package.
+type jsonCodec struct{}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+// newServicesClient serves routes on a gRPC server and returns a client
+// connection to it.
+func newServicesClient(t *testing.T, routes ...Route) *grpc.ClientConn {
+	t.Helper()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(append(interceptors.Unary(log, &rpcRecorder{}, time.Second), interceptors.UnaryAdmin(adminTokens{}, adminTokens{}, adminAppId, admin.Service))...),
+		grpc.ChainStreamInterceptor(append(interceptors.Stream(log, &rpcRecorder{}), interceptors.StreamAdmin(adminTokens{}, adminTokens{}, adminAppId, admin.Service))...),
+	)
+	for _, desc := range Services(routes...) {
+		srv.RegisterService(desc, nil)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	cc, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype("json")),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = cc.Close() })
+	return cc
+}
+
+func TestServices_Unary(t *testing.T) {
+	cc := newServicesClient(t, TokenRoutes(authgrpc.NewTokenAPI(&fakeAuth{}))...)
+
+	var res validateTokenResponse
+	err := cc.Invoke(context.Background(), "/auth.Tokens/ValidateToken", &validateTokenRequest{Token: "t"}, &res)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), res.UserId)
+	assert.Equal(t, "session-of-t", res.SessionId)
+
+	err = cc.Invoke(context.Background(), "/auth.Tokens/ValidateToken", &validateTokenRequest{}, &res)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServices_AdminParameters(t *testing.T) {
+	cc := newServicesClient(t, SessionRoutes(admin.NewSessionsAPI(&fakeSessions{}))...)
+
+	// path parameters are fields of the JSON message
+	req := map[string]any{"sessionId": "s1"}
+	var res admin.GetSessionResponse
+	err := cc.Invoke(context.Background(), admin.Method("GetSession"), req, &res)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer user")
+	err = cc.Invoke(ctx, admin.Method("GetSession"), req, &res)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer admin")
+	require.NoError(t, cc.Invoke(ctx, admin.Method("GetSession"), req, &res))
+	assert.Equal(t, "s1", res.Session.Id)
+
+	err = cc.Invoke(ctx, admin.Method("GetSession"), map[string]any{"sessionId": "s2"}, &res)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	err = cc.Invoke(ctx, admin.Method("GetSession"), map[string]any{"sessionId": 1}, &res)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServices_Stream(t *testing.T) {
+	watcher := &fakeWatcher{}
+	cc := newServicesClient(t, WatchRoutes(admin.NewWatchAPI(watcher))...)
+	desc := &grpc.StreamDesc{ServerStreams: true}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer admin")
+	stream, err := cc.NewStream(ctx, desc, admin.Method("WatchEvents"))
+	require.NoError(t, err)
+	require.NoError(t, stream.SendMsg(map[string]any{"cursor": "c0"}))
+	require.NoError(t, stream.CloseSend())
+
+	var cursors []string
+	for {
+		var res admin.WatchEventsResponse
+		if err := stream.RecvMsg(&res); err != nil {
+			require.ErrorIs(t, err, io.EOF)
+			break
+		}
+		cursors = append(cursors, res.Cursor)
+	}
+	assert.Equal(t, []string{"c1", "c2"}, cursors)
+	assert.Equal(t, "c0", watcher.cursor)
+
+	stream, err = cc.NewStream(ctx, desc, admin.Method("WatchEvents"))
+	require.NoError(t, err)
+	require.NoError(t, stream.SendMsg(map[string]any{"cursor": "bad"}))
+	require.NoError(t, stream.CloseSend())
+	var res admin.WatchEventsResponse
+	assert.Equal(t, codes.InvalidArgument, status.Code(stream.RecvMsg(&res)))
+
+	// the admin check runs before the request is read
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer user")
+	stream, err = cc.NewStream(ctx, desc, admin.Method("WatchEvents"))
+	require.NoError(t, err)
+	require.NoError(t, stream.SendMsg(map[string]any{}))
+	require.NoError(t, stream.CloseSend())
+	assert.Equal(t, codes.PermissionDenied, status.Code(stream.RecvMsg(&res)))
+}
@@ -0,0 +1,133 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// keepaliveInterval is how long a stream may be silent before a comment is
+// sent, proxies close idle responses.
+const keepaliveInterval = 15 * time.Second
+
+// streamMessage is a message of a server stream with the id to resume after
+// it.
+type streamMessage struct {
+	id  string
+	msg any
+}
+
+// eventStream is the grpc.ServerStream of a stream route, it sends messages
+// as server-sent events. The response starts with the first message or
+// keepalive, until then an error is answered like the one of a unary call.
+type eventStream struct {
+	ctx context.Context
+	w   http.ResponseWriter
+
+	mu      sync.Mutex
+	started bool
+}
+
+func (s *eventStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *eventStream) SetHeader(md metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, values := range md {
+		for _, value := range values {
+			s.w.Header().Add(name, value)
+		}
+	}
+	return nil
+}
+
+func (s *eventStream) SendHeader(md metadata.MD) error {
+	if err := s.SetHeader(md); err != nil {
+		return err
+	}
+	return s.write("")
+}
+
+func (s *eventStream) SetTrailer(metadata.MD) {}
+
+func (s *eventStream) SendMsg(m any) error {
+	message := m.(*streamMessage)
+	data, err := json.Marshal(message.msg)
+	if err != nil {
+		return err
+	}
+	return s.write("id: " + message.id + "\ndata: " + string(data) + "\n\n")
+}
+
+// RecvMsg has nothing to receive, the request is decoded from the HTTP
+// request before the stream starts.
+func (s *eventStream) RecvMsg(any) error {
+	return io.EOF
+}
+
+// keepalive sends a comment every interval, which also starts a stream
+// that has no messages yet. The returned func stops it.
+func (s *eventStream) keepalive(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_ = s.write(": keepalive\n\n")
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+		wg.Wait()
+	}
+}
+
+// fail answers err, as an error event if the stream has started.
+func (s *eventStream) fail(err error) {
+	s.mu.Lock()
+	started := s.started
+	s.mu.Unlock()
+	if !started {
+		writeError(s.w, err)
+		return
+	}
+
+	st := status.Convert(err)
+	data, _ := json.Marshal(errorBody{Code: st.Code(), Message: st.Message()})
+	_ = s.write("event: error\ndata: " + string(data) + "\n\n")
+}
+
+// write starts the stream if needed and sends text right away.
+func (s *eventStream) write(text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.started {
+		s.started = true
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+		s.w.WriteHeader(http.StatusOK)
+	}
+	if _, err := io.WriteString(s.w, text); err != nil {
+		return err
+	}
+	return http.NewResponseController(s.w).Flush()
+}
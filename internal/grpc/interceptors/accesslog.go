@@ -0,0 +1,53 @@
+package interceptors
+
+import (
+	"context"
+	"log/slog"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/requestinfo"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryAccessLog writes one line per RPC with the method, status code,
+// latency and peer, using the request logger from the context. A call that
+// panics is logged as Internal, the error recovery turns it into.
+func UnaryAccessLog() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		code := codes.Internal
+		defer func() { logAccess(ctx, info.FullMethod, start, code) }()
+		resp, err := handler(ctx, req)
+		code = status.Code(err)
+		return resp, err
+	}
+}
+
+func StreamAccessLog() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		code := codes.Internal
+		defer func() { logAccess(ss.Context(), info.FullMethod, start, code) }()
+		err := handler(srv, ss)
+		code = status.Code(err)
+		return err
+	}
+}
+
+func logAccess(ctx context.Context, method string, start time.Time, code codes.Code) {
+	level := slog.LevelInfo
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		level = slog.LevelError
+	}
+
+	sl.FromContext(ctx, slog.Default()).LogAttrs(ctx, level, "rpc",
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("latency", time.Since(start)),
+		slog.String("peer", requestinfo.FromContext(ctx).IP),
+	)
+}
@@ -0,0 +1,104 @@
+package interceptors
+
+import (
+	"context"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/requestinfo"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const bearerPrefix = "bearer "
+
+type TokenValidator interface {
+	ValidateToken(ctx context.Context, token string) (*jwt.Claims, error)
+}
+
+type AdminChecker interface {
+	IsAdmin(ctx context.Context, userId int64) (bool, error)
+}
+
+// UnaryAdmin lets calls of methods through only with the token of an admin
+// in the authorization header, as "Bearer <token>", issued for the app
+// adminAppId. Tokens of other apps are refused even for admins, an app
+// could otherwise pass on the tokens of its users to the admin API. Methods
+// are full method names, or service prefixes such as "/admin.Admin/". The
+// admin becomes the actor of the call, see requestinfo.Info.
+func UnaryAdmin(validator TokenValidator, checker AdminChecker, adminAppId int64, methods ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !adminOnly(methods, info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		ctx, err := authorizeAdmin(ctx, validator, checker, adminAppId, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAdmin is UnaryAdmin for streams.
+func StreamAdmin(validator TokenValidator, checker AdminChecker, adminAppId int64, methods ...string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !adminOnly(methods, info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		ctx, err := authorizeAdmin(ss.Context(), validator, checker, adminAppId, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authorizeAdmin checks the bearer token of ctx and returns ctx with the
+// admin as the actor.
+func authorizeAdmin(ctx context.Context, validator TokenValidator, checker AdminChecker, adminAppId int64, method string) (context.Context, error) {
+	token := bearerToken(ctx)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "admin token must be provided in the authorization header")
+	}
+	claims, err := validator.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid admin token: %v", err)
+	}
+	if claims.AppId != adminAppId {
+		return nil, status.Error(codes.PermissionDenied, "only tokens of the admin app may call "+method)
+	}
+	isAdmin, err := checker.IsAdmin(ctx, claims.UserId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to check admin status: %v", err)
+	}
+	if !isAdmin {
+		return nil, status.Error(codes.PermissionDenied, "only admins may call "+method)
+	}
+
+	request := requestinfo.FromContext(ctx)
+	request.ActorId = claims.UserId
+	return requestinfo.NewContext(ctx, request), nil
+}
+
+func adminOnly(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method || (strings.HasSuffix(m, "/") && strings.HasPrefix(method, m)) {
+			return true
+		}
+	}
+	return false
+}
+
+func bearerToken(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if len(value) > len(bearerPrefix) && strings.EqualFold(value[:len(bearerPrefix)], bearerPrefix) {
+			return strings.TrimSpace(value[len(bearerPrefix):])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/requestinfo"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const adminAppId = 1
+
+// fakeTokens accepts the tokens "admin", "user" and "other-app", "admin"
+// and "other-app" belong to user 1 which is an admin, "other-app" was
+// issued for another app than the admin one.
+type fakeTokens struct{}
+
+func (fakeTokens) ValidateToken(_ context.Context, token string) (*jwt.Claims, error) {
+	switch token {
+	case "admin":
+		return &jwt.Claims{UserId: 1, AppId: adminAppId}, nil
+	case "other-app":
+		return &jwt.Claims{UserId: 1, AppId: 2}, nil
+	case "user":
+		return &jwt.Claims{UserId: 2, AppId: adminAppId}, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+func (fakeTokens) IsAdmin(_ context.Context, userId int64) (bool, error) {
+	return userId == 1, nil
+}
+
+func TestUnaryAdmin(t *testing.T) {
+	interceptor := UnaryAdmin(fakeTokens{}, fakeTokens{}, adminAppId, "/test.Test/Admin", "/test.Admin/")
+	call := func(method string, authorization string) (int64, error) {
+		ctx := context.Background()
+		if authorization != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", authorization))
+		}
+		var actorId int64
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, _ any) (any, error) {
+			actorId = requestinfo.FromContext(ctx).ActorId
+			return nil, nil
+		})
+		return actorId, err
+	}
+
+	_, err := call("/test.Test/Public", "")
+	assert.NoError(t, err)
+	_, err = call("/test.Admin/Anything", "")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	tests := []struct {
+		name          string
+		authorization string
+		code          codes.Code
+	}{
+		{name: "no token", code: codes.Unauthenticated},
+		{name: "not a bearer token", authorization: "Basic admin", code: codes.Unauthenticated},
+		{name: "invalid token", authorization: "Bearer forged", code: codes.Unauthenticated},
+		{name: "not an admin", authorization: "Bearer user", code: codes.PermissionDenied},
+		{name: "admin with a token of another app", authorization: "Bearer other-app", code: codes.PermissionDenied},
+		{name: "admin", authorization: "bearer admin", code: codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actorId, err := call("/test.Test/Admin", tt.authorization)
+			assert.Equal(t, tt.code, status.Code(err))
+			if tt.code == codes.OK {
+				assert.Equal(t, int64(1), actorId)
+			}
+		})
+	}
+}
+
+// contextStream is a grpc.ServerStream with only a context.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamAdmin(t *testing.T) {
+	interceptor := StreamAdmin(fakeTokens{}, fakeTokens{}, adminAppId, "/test.Admin/")
+	call := func(method string, authorization string) (int64, error) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", authorization))
+		var actorId int64
+		err := interceptor(nil, contextStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: method}, func(_ any, ss grpc.ServerStream) error {
+			actorId = requestinfo.FromContext(ss.Context()).ActorId
+			return nil
+		})
+		return actorId, err
+	}
+
+	_, err := call("/test.Test/Public", "")
+	assert.NoError(t, err)
+
+	_, err = call("/test.Admin/Watch", "Bearer user")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = call("/test.Admin/Watch", "Bearer other-app")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	actorId, err := call("/test.Admin/Watch", "Bearer admin")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), actorId)
+}
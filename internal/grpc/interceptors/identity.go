@@ -0,0 +1,48 @@
+package interceptors
+
+import (
+	"context"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// ClientIdentity is the subject of a verified client certificate, it
+// identifies the calling service with mutual TLS.
+type ClientIdentity struct {
+	CommonName string
+	DNSNames   []string
+	// URIs holds e.g. SPIFFE ids.
+	URIs []string
+}
+
+// ClientIdentityFromContext returns the identity of the caller if it
+// presented a client certificate that the server verified.
+func ClientIdentityFromContext(ctx context.Context) (ClientIdentity, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ClientIdentity{}, false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return ClientIdentity{}, false
+	}
+
+	cert := info.State.VerifiedChains[0][0]
+	identity := ClientIdentity{
+		CommonName: cert.Subject.CommonName,
+		DNSNames:   cert.DNSNames,
+	}
+	for _, uri := range cert.URIs {
+		identity.URIs = append(identity.URIs, uri.String())
+	}
+	return identity, true
+}
+
+// String names the client in logs, preferring the URI over the common name.
+func (c ClientIdentity) String() string {
+	if len(c.URIs) > 0 {
+		return c.URIs[0]
+	}
+	return c.CommonName
+}
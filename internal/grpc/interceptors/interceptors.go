@@ -0,0 +1,45 @@
+// Package interceptors holds the gRPC server middleware: request ids,
+// access logs, metrics, deadlines and panic recovery.
+package interceptors
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Unary returns the unary interceptor chain in the order they run, calls are
+// cut off after timeout. Recovery comes right after the request id so it
+// also covers the interceptors after it, including those appended to the
+// chain like UnaryAdmin.
+func Unary(log *slog.Logger, observer RPCObserver, timeout time.Duration) []grpc.UnaryServerInterceptor {
+	return []grpc.UnaryServerInterceptor{
+		UnaryRequestId(log),
+		UnaryRecovery(),
+		UnaryAccessLog(),
+		UnaryMetrics(observer),
+		UnaryDeadline(timeout),
+	}
+}
+
+// Stream returns the stream interceptor chain in the order they run.
+func Stream(log *slog.Logger, observer RPCObserver) []grpc.StreamServerInterceptor {
+	return []grpc.StreamServerInterceptor{
+		StreamRequestId(log),
+		StreamRecovery(),
+		StreamAccessLog(),
+		StreamMetrics(observer),
+	}
+}
+
+// serverStream replaces the context of a grpc.ServerStream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
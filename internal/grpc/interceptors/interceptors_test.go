@@ -0,0 +1,296 @@
+package interceptors
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/url"
+	"sso/internal/lib/logger/sl"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const checkMethod = "/test.Test/Check"
+
+// syncBuffer collects log lines written by server goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) lines(t *testing.T) []map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+// rpcRecorder collects the codes reported to the metrics interceptor.
+type rpcRecorder struct {
+	mu    sync.Mutex
+	codes []codes.Code
+}
+
+func (r *rpcRecorder) ObserveRPC(method string, code codes.Code, latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codes = append(r.codes, code)
+}
+
+func (r *rpcRecorder) observed() []codes.Code {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]codes.Code(nil), r.codes...)
+}
+
+// testServer serves a Check method that panics when asked to and otherwise
+// logs through the request logger. The extra interceptors run after the
+// chain of Unary.
+func testServer(t *testing.T, logs *syncBuffer, observer RPCObserver, extra ...grpc.UnaryServerInterceptor) *grpc.ClientConn {
+	log := slog.New(slog.NewJSONHandler(logs, nil))
+	srv := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler(otelgrpc.WithPropagators(propagation.TraceContext{}))),
+		grpc.ChainUnaryInterceptor(append(Unary(log, observer, time.Second), extra...)...),
+		grpc.ChainStreamInterceptor(Stream(log, observer)...),
+	)
+
+	check := func(ctx context.Context, req any) (any, error) {
+		switch req.(*healthpb.HealthCheckRequest).GetService() {
+		case "panic":
+			panic("boom")
+		case "slow":
+			<-ctx.Done()
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		sl.FromContext(ctx, nil).Info("handled")
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+	}
+	srv.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "test.Test",
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Check",
+			Handler: func(_ any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				req := new(healthpb.HealthCheckRequest)
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				return interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: checkMethod}, check)
+			},
+		}},
+	}, struct{}{})
+
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestRequestIdIsPropagated(t *testing.T) {
+	logs := &syncBuffer{}
+	conn := testServer(t, logs, &rpcRecorder{})
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), RequestIdHeader, "req-123")
+	var header metadata.MD
+	err := conn.Invoke(ctx, checkMethod, &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"req-123"}, header.Get(RequestIdHeader))
+
+	lines := logs.lines(t)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "handled", lines[0]["msg"])
+	assert.Equal(t, "req-123", lines[0]["request_id"])
+
+	access := lines[1]
+	assert.Equal(t, "rpc", access["msg"])
+	assert.Equal(t, "req-123", access["request_id"])
+	assert.Equal(t, checkMethod, access["method"])
+	assert.Equal(t, "OK", access["code"])
+	assert.Contains(t, access, "latency")
+	assert.Contains(t, access, "peer")
+}
+
+func TestRequestIdIsGenerated(t *testing.T) {
+	conn := testServer(t, &syncBuffer{}, &rpcRecorder{})
+
+	for _, sent := range []string{"", strings.Repeat("x", maxRequestIdLength+1), "has space"} {
+		ctx := context.Background()
+		if sent != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, RequestIdHeader, sent)
+		}
+		var header metadata.MD
+		err := conn.Invoke(ctx, checkMethod, &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{}, grpc.Header(&header))
+		require.NoError(t, err)
+		require.Len(t, header.Get(RequestIdHeader), 1)
+		assert.Len(t, header.Get(RequestIdHeader)[0], 32)
+	}
+}
+
+func TestTraceIdIsLogged(t *testing.T) {
+	logs := &syncBuffer{}
+	conn := testServer(t, logs, &rpcRecorder{})
+
+	const traceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "traceparent", "00-"+traceId+"-00f067aa0ba902b7-01")
+	err := conn.Invoke(ctx, checkMethod, &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{})
+	require.NoError(t, err)
+
+	for _, line := range logs.lines(t) {
+		assert.Equal(t, traceId, line["trace_id"])
+	}
+}
+
+func TestPanicBecomesInternal(t *testing.T) {
+	logs := &syncBuffer{}
+	rpcs := &rpcRecorder{}
+	conn := testServer(t, logs, rpcs)
+
+	err := conn.Invoke(context.Background(), checkMethod, &healthpb.HealthCheckRequest{Service: "panic"}, &healthpb.HealthCheckResponse{})
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	// the server is still up
+	err = conn.Invoke(context.Background(), checkMethod, &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{})
+	require.NoError(t, err)
+
+	// the access log is written while the panic unwinds, before recovery
+	lines := logs.lines(t)
+	require.GreaterOrEqual(t, len(lines), 2)
+	assert.Equal(t, "ERROR", lines[0]["level"])
+	assert.Equal(t, "Internal", lines[0]["code"])
+	assert.Equal(t, "panic in handler", lines[1]["msg"])
+	assert.Equal(t, "boom", lines[1]["panic"])
+	assert.Contains(t, lines[1]["stack"], "recovery.go")
+	assert.Equal(t, []codes.Code{codes.Internal, codes.OK}, rpcs.observed())
+}
+
+func TestPanicInInterceptorIsRecovered(t *testing.T) {
+	logs := &syncBuffer{}
+	rpcs := &rpcRecorder{}
+	// stands in for an interceptor appended to the chain, like UnaryAdmin
+	panicking := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if req.(*healthpb.HealthCheckRequest).GetService() == "interceptor" {
+			panic("boom")
+		}
+		return handler(ctx, req)
+	}
+	conn := testServer(t, logs, rpcs, panicking)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), RequestIdHeader, "req-123")
+	err := conn.Invoke(ctx, checkMethod, &healthpb.HealthCheckRequest{Service: "interceptor"}, &healthpb.HealthCheckResponse{})
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	err = conn.Invoke(context.Background(), checkMethod, &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{})
+	require.NoError(t, err)
+
+	lines := logs.lines(t)
+	require.GreaterOrEqual(t, len(lines), 2)
+	assert.Equal(t, "panic in handler", lines[1]["msg"])
+	assert.Equal(t, "req-123", lines[1]["request_id"])
+	assert.Equal(t, []codes.Code{codes.Internal, codes.OK}, rpcs.observed())
+}
+
+func TestDeadlineIsCapped(t *testing.T) {
+	conn := testServer(t, &syncBuffer{}, &rpcRecorder{})
+
+	start := time.Now()
+	err := conn.Invoke(context.Background(), checkMethod, &healthpb.HealthCheckRequest{Service: "slow"}, &healthpb.HealthCheckResponse{})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestUnaryDeadline(t *testing.T) {
+	remaining := func(ctx context.Context, timeout time.Duration) time.Duration {
+		var left time.Duration
+		_, _ = UnaryDeadline(timeout)(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ any) (any, error) {
+			deadline, ok := ctx.Deadline()
+			if ok {
+				left = time.Until(deadline)
+			}
+			return nil, nil
+		})
+		return left
+	}
+
+	assert.InDelta(t, time.Second, remaining(context.Background(), time.Second), float64(100*time.Millisecond))
+
+	// a shorter deadline of the client is kept
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.LessOrEqual(t, remaining(ctx, time.Second), 100*time.Millisecond)
+
+	// a longer one is cut
+	ctx, cancel = context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	assert.LessOrEqual(t, remaining(ctx, time.Second), time.Second)
+
+	assert.Zero(t, remaining(context.Background(), 0))
+}
+
+func TestClientIdentity(t *testing.T) {
+	_, ok := ClientIdentityFromContext(context.Background())
+	assert.False(t, ok)
+
+	// a certificate the server did not verify is no identity
+	unverified := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{}})
+	_, ok = ClientIdentityFromContext(unverified)
+	assert.False(t, ok)
+
+	spiffe, err := url.Parse("spiffe://example.org/billing")
+	require.NoError(t, err)
+	cert := &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "billing"},
+		DNSNames: []string{"billing.internal"},
+		URIs:     []*url.URL{spiffe},
+	}
+	state := tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{State: state}})
+
+	identity, ok := ClientIdentityFromContext(ctx)
+	require.True(t, ok)
+	assert.Equal(t, ClientIdentity{
+		CommonName: "billing",
+		DNSNames:   []string{"billing.internal"},
+		URIs:       []string{"spiffe://example.org/billing"},
+	}, identity)
+	assert.Equal(t, "spiffe://example.org/billing", identity.String())
+	assert.Equal(t, "billing", ClientIdentity{CommonName: "billing"}.String())
+}
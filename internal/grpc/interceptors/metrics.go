@@ -0,0 +1,38 @@
+package interceptors
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type RPCObserver interface {
+	ObserveRPC(method string, code codes.Code, latency time.Duration)
+}
+
+// UnaryMetrics reports the latency and status code of every RPC, a call
+// that panics as Internal.
+func UnaryMetrics(observer RPCObserver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		code := codes.Internal
+		defer func() { observer.ObserveRPC(info.FullMethod, code, time.Since(start)) }()
+		resp, err := handler(ctx, req)
+		code = status.Code(err)
+		return resp, err
+	}
+}
+
+func StreamMetrics(observer RPCObserver) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		code := codes.Internal
+		defer func() { observer.ObserveRPC(info.FullMethod, code, time.Since(start)) }()
+		err := handler(srv, ss)
+		code = status.Code(err)
+		return err
+	}
+}
@@ -0,0 +1,87 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"sso/internal/lib/logger/sl"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIdHeader carries the request id in both directions.
+const RequestIdHeader = "x-request-id"
+
+// maxRequestIdLength bounds ids accepted from clients, they end up in logs.
+const maxRequestIdLength = 128
+
+type requestIdKey struct{}
+
+// RequestIdFromContext returns the id of the request being served.
+func RequestIdFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// UnaryRequestId takes the request id from the x-request-id header or
+// generates one, returns it in the response header and attaches a logger
+// with it, the trace id and the client certificate identity to the context,
+// see sl.FromContext.
+func UnaryRequestId(log *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(withRequestId(ctx, log), req)
+	}
+}
+
+func StreamRequestId(log *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &serverStream{ServerStream: ss, ctx: withRequestId(ss.Context(), log)})
+	}
+}
+
+func withRequestId(ctx context.Context, log *slog.Logger) context.Context {
+	id := incomingRequestId(ctx)
+	if id == "" {
+		id = newRequestId()
+	}
+	// fails only outside of a real server transport, e.g. in tests
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIdHeader, id))
+
+	log = log.With(slog.String("request_id", id))
+	// the server span is started by the stats handler before interceptors run
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		log = log.With(slog.String("trace_id", span.TraceID().String()))
+	}
+	if client, ok := ClientIdentityFromContext(ctx); ok {
+		log = log.With(slog.String("client", client.String()))
+	}
+
+	ctx = context.WithValue(ctx, requestIdKey{}, id)
+	return sl.NewContext(ctx, log)
+}
+
+func incomingRequestId(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(RequestIdHeader)
+	if len(values) == 0 || len(values[0]) > maxRequestIdLength {
+		return ""
+	}
+	for _, r := range values[0] {
+		if r < 0x21 || r > 0x7e {
+			return ""
+		}
+	}
+	return values[0]
+}
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,162 @@
+// Package mux shares one listener between the gRPC server and an HTTP
+// server, telling connections apart by their first request.
+package mux
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/soheilhy/cmux"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+type Mux struct {
+	cmux.CMux
+	// GRPC accepts HTTP/2 connections opening with a native gRPC call.
+	GRPC net.Listener
+	// HTTP accepts all other connections, HTTP/1.1 and HTTP/2 alike.
+	HTTP net.Listener
+}
+
+// New splits the connections of lis, each has matchTimeout to show its
+// first request. Call Serve to start accepting.
+func New(lis net.Listener, matchTimeout time.Duration) *Mux {
+	m := cmux.New(lis)
+	m.SetReadTimeout(matchTimeout)
+
+	grpcL := m.MatchWithWriters(matchGRPC)
+	httpL := m.Match(cmux.Any())
+
+	return &Mux{
+		CMux: m,
+		GRPC: grpcL,
+		HTTP: settingsAckListener{httpL},
+	}
+}
+
+// matchGRPC matches HTTP/2 connections whose first request is a native gRPC
+// call, gRPC-Web sent over HTTP/2 is left to the HTTP server. gRPC clients
+// wait for the server SETTINGS before their first call, so they are sent
+// here, once.
+func matchGRPC(w io.Writer, r io.Reader) bool {
+	preface := make([]byte, len(http2.ClientPreface))
+	if _, err := io.ReadFull(r, preface); err != nil || string(preface) != http2.ClientPreface {
+		return false
+	}
+
+	var contentType string
+	found, done := false, false
+	framer := http2.NewFramer(w, r)
+	decoder := hpack.NewDecoder(4<<10, func(f hpack.HeaderField) {
+		if f.Name == "content-type" {
+			contentType, found = f.Value, true
+		}
+	})
+	for !found && !done {
+		f, err := framer.ReadFrame()
+		if err != nil {
+			return false
+		}
+		switch f := f.(type) {
+		case *http2.SettingsFrame:
+			if !f.IsAck() {
+				if err := framer.WriteSettings(); err != nil {
+					return false
+				}
+			}
+		case *http2.HeadersFrame:
+			if _, err := decoder.Write(f.HeaderBlockFragment()); err != nil {
+				return false
+			}
+			done = f.HeadersEnded()
+		case *http2.ContinuationFrame:
+			if _, err := decoder.Write(f.HeaderBlockFragment()); err != nil {
+				return false
+			}
+			done = f.HeadersEnded()
+		}
+	}
+
+	return contentType == "application/grpc" || strings.HasPrefix(contentType, "application/grpc+")
+}
+
+// settingsAckListener hides the client's acknowledgement of the SETTINGS
+// that matching sent on HTTP/2 connections. The HTTP/2 server sends its
+// own and treats an unexpected acknowledgement as a protocol error.
+type settingsAckListener struct {
+	net.Listener
+}
+
+func (l settingsAckListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &settingsAckConn{Conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+const (
+	frameHeaderLen = 9
+	frameSettings  = 0x4
+	flagAck        = 0x1
+)
+
+type settingsAckConn struct {
+	net.Conn
+	r *bufio.Reader
+	// pending holds bytes read ahead but not returned yet, remaining the
+	// payload left of the frame being passed through.
+	pending   []byte
+	remaining int
+	started   bool
+	done      bool
+}
+
+// NetConn returns the connection being read from.
+func (c *settingsAckConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+func (c *settingsAckConn) Read(p []byte) (int, error) {
+	if !c.started {
+		c.started = true
+		preface, err := c.r.Peek(len(http2.ClientPreface))
+		if err != nil || string(preface) != http2.ClientPreface {
+			// HTTP/1.1, nothing was sent on the connection
+			c.done = true
+			return c.r.Read(p)
+		}
+		c.pending = make([]byte, len(preface))
+		_, _ = io.ReadFull(c.r, c.pending)
+	}
+
+	for len(c.pending) == 0 && c.remaining == 0 {
+		if c.done {
+			return c.r.Read(p)
+		}
+		header := make([]byte, frameHeaderLen)
+		if _, err := io.ReadFull(c.r, header); err != nil {
+			return 0, err
+		}
+		length := int(binary.BigEndian.Uint32(append([]byte{0}, header[:3]...)))
+		if header[3] == frameSettings && header[4]&flagAck != 0 {
+			c.done = true
+			continue
+		}
+		c.pending, c.remaining = header, length
+	}
+
+	if len(c.pending) > 0 {
+		n := copy(p, c.pending)
+		c.pending = c.pending[n:]
+		return n, nil
+	}
+	n, err := c.r.Read(p[:min(len(p), c.remaining)])
+	c.remaining -= n
+	return n, err
+}
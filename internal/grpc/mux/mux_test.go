@@ -0,0 +1,158 @@
+package mux
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// syncBuffer collects the error log of the HTTP server.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// serve runs a gRPC server with the health service and an HTTP server
+// answering with the protocol of the request on one listener.
+func serve(t *testing.T) (addr string, httpErrors *syncBuffer) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	m := New(lis, time.Second)
+
+	grpcServer := grpc.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, health.NewServer())
+	httpErrors = &syncBuffer{}
+	httpServer := &http.Server{
+		Handler: h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, r.Proto)
+		}), &http2.Server{}),
+		ErrorLog: log.New(httpErrors, "", 0),
+	}
+
+	go func() { _ = grpcServer.Serve(m.GRPC) }()
+	go func() { _ = httpServer.Serve(m.HTTP) }()
+	go func() { _ = m.Serve() }()
+	t.Cleanup(func() {
+		grpcServer.Stop()
+		_ = httpServer.Close()
+		m.Close()
+	})
+
+	return lis.Addr().String(), httpErrors
+}
+
+func TestMux(t *testing.T) {
+	addr, httpErrors := serve(t)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	res, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.GetStatus())
+
+	get := func(client *http.Client) string {
+		resp, err := client.Get("http://" + addr + "/")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+	assert.Equal(t, "HTTP/1.1", get(&http.Client{}))
+
+	h2cClient := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "HTTP/2.0", get(h2cClient))
+	}
+
+	// the settings acknowledgement arrives after the first response
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, httpErrors.String())
+}
+
+func TestSettingsAckConn(t *testing.T) {
+	settingsAck := []byte{0, 0, 0, frameSettings, flagAck, 0, 0, 0, 0}
+	settings := []byte{0, 0, 6, frameSettings, 0, 0, 0, 0, 0, 0, 3, 0, 0, 0, 100}
+	headers := []byte{0, 0, 2, 0x1, 0x4, 0, 0, 0, 1, 0xaa, 0xbb}
+
+	for name, tc := range map[string]struct {
+		in   [][]byte
+		want [][]byte
+	}{
+		"drops the first ack": {
+			in:   [][]byte{[]byte(http2.ClientPreface), settings, settingsAck, headers, settingsAck},
+			want: [][]byte{[]byte(http2.ClientPreface), settings, headers, settingsAck},
+		},
+		"http1": {
+			in:   [][]byte{[]byte("GET / HTTP/1.1\r\nHost: sso\r\n\r\n")},
+			want: [][]byte{[]byte("GET / HTTP/1.1\r\nHost: sso\r\n\r\n")},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client, server := net.Pipe()
+			go func() {
+				_, _ = client.Write(bytes.Join(tc.in, nil))
+				_ = client.Close()
+			}()
+
+			l := settingsAckListener{Listener: &oneConnListener{conn: server}}
+			conn, err := l.Accept()
+			require.NoError(t, err)
+
+			// small reads split frames across calls
+			var got bytes.Buffer
+			buf := make([]byte, 4)
+			for {
+				n, err := conn.Read(buf)
+				got.Write(buf[:n])
+				if err != nil {
+					break
+				}
+			}
+			assert.Equal(t, bytes.Join(tc.want, nil), got.Bytes())
+		})
+	}
+}
+
+type oneConnListener struct {
+	net.Listener
+	conn net.Conn
+}
+
+func (l *oneConnListener) Accept() (net.Conn, error) {
+	return l.conn, nil
+}
@@ -0,0 +1,90 @@
+// Package readiness drives the grpc.health.v1 status of the server from
+// checks of its dependencies.
+package readiness
+
+import (
+	"context"
+	"log/slog"
+	"sso/internal/lib/logger/sl"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Checker reports the services as SERVING while the database answers pings
+// and as NOT_SERVING otherwise. Until the first successful check everything
+// is NOT_SERVING.
+type Checker struct {
+	log      *slog.Logger
+	server   *health.Server
+	db       Pinger
+	interval time.Duration
+	services []string
+	serving  bool
+}
+
+// New creates a Checker for services, the empty name stands for the server as
+// a whole and is always included.
+func New(log *slog.Logger, server *health.Server, db Pinger, interval time.Duration, services ...string) *Checker {
+	c := &Checker{
+		log:      log,
+		server:   server,
+		db:       db,
+		interval: interval,
+		services: append([]string{""}, services...),
+	}
+	c.set(healthpb.HealthCheckResponse_NOT_SERVING)
+	return c
+}
+
+// Run checks right away and then every interval until ctx is done.
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check pings the database and updates the status, it returns whether the
+// services are serving.
+func (c *Checker) Check(ctx context.Context) bool {
+	const op = "readiness.Checker.Check"
+	log := c.log.With(slog.String("op", op))
+
+	// a ping must not outlast the next check
+	ctx, cancel := context.WithTimeout(ctx, c.interval)
+	defer cancel()
+
+	err := c.db.Ping(ctx)
+	switch {
+	case err == nil && !c.serving:
+		log.Info("database is reachable, serving")
+		c.serving = true
+		c.set(healthpb.HealthCheckResponse_SERVING)
+	case err != nil && c.serving:
+		log.Error("database is unreachable, not serving", sl.Err(err))
+		c.serving = false
+		c.set(healthpb.HealthCheckResponse_NOT_SERVING)
+	case err != nil:
+		log.Warn("database is still unreachable", sl.Err(err))
+	}
+	return c.serving
+}
+
+func (c *Checker) set(status healthpb.HealthCheckResponse_ServingStatus) {
+	for _, service := range c.services {
+		c.server.SetServingStatus(service, status)
+	}
+}
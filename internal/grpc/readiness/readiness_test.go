@@ -0,0 +1,72 @@
+package readiness
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const service = "auth.Auth"
+
+type fakeDB struct {
+	err error
+}
+
+func (db *fakeDB) Ping(ctx context.Context) error {
+	return db.err
+}
+
+func status(t *testing.T, server *health.Server, service string) healthpb.HealthCheckResponse_ServingStatus {
+	resp, err := server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	require.NoError(t, err)
+	return resp.Status
+}
+
+func newChecker(db Pinger) (*Checker, *health.Server) {
+	server := health.NewServer()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return New(log, server, db, time.Second, service), server
+}
+
+func TestNotServingUntilChecked(t *testing.T) {
+	_, server := newChecker(&fakeDB{})
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, server, ""))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, server, service))
+}
+
+func TestTracksDatabase(t *testing.T) {
+	db := &fakeDB{}
+	checker, server := newChecker(db)
+
+	assert.True(t, checker.Check(context.Background()))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status(t, server, ""))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status(t, server, service))
+
+	db.err = errors.New("connection refused")
+	assert.False(t, checker.Check(context.Background()))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, server, ""))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, server, service))
+
+	db.err = nil
+	assert.True(t, checker.Check(context.Background()))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status(t, server, service))
+}
+
+func TestShutdownWins(t *testing.T) {
+	db := &fakeDB{err: errors.New("connection refused")}
+	checker, server := newChecker(db)
+	checker.Check(context.Background())
+
+	server.Shutdown()
+	db.err = nil
+	checker.Check(context.Background())
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, server, service))
+}
@@ -0,0 +1,38 @@
+package web
+
+import (
+	"encoding/json"
+
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// jsonCodec lets grpc.Server read and write messages as protobuf JSON, the
+// way Connect and gRPC-Web clients send them with the json subtype. Messages
+// that are not protobuf ones, e.g. of the admin RPCs, are plain JSON.
+type jsonCodec struct{}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return json.Marshal(v)
+	}
+	return protojson.Marshal(msg)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return json.Unmarshal(data, v)
+	}
+	return protojson.Unmarshal(data, msg)
+}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
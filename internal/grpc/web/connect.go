@@ -0,0 +1,154 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sso/internal/grpc/gateway"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxGRPCTimeout is the largest value of the 8 digit grpc-timeout header.
+const maxGRPCTimeout = 99999999
+
+// connectError is the JSON form of an error in the Connect protocol.
+type connectError struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// connectEnd is the last message of a Connect stream.
+type connectEnd struct {
+	Error    *connectError       `json:"error,omitempty"`
+	Metadata map[string][]string `json:"metadata,omitempty"`
+}
+
+// serveConnectUnary relays a unary Connect call. The request and response
+// bodies are single messages, errors are JSON with a matching HTTP status
+// and trailers are sent as Trailer- headers.
+func (h *Handler) serveConnectUnary(w http.ResponseWriter, r *http.Request, codec string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeConnectError(w, status.New(codes.Unimplemented, "only POST is supported"))
+		return
+	}
+	if encoding := r.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		writeConnectError(w, status.Newf(codes.Unimplemented, "unsupported content encoding %q", encoding))
+		return
+	}
+	msg, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err != nil {
+		writeConnectError(w, status.Newf(codes.ResourceExhausted, "failed to read message: %v", err))
+		return
+	}
+
+	buf := &bufferWriter{header: http.Header{}}
+	rw := newStreamWriter(buf, "")
+	h.grpc.ServeHTTP(rw, connectRequest(r, codec, bytes.NewReader(frame(0, msg))))
+
+	for name, values := range rw.headers() {
+		w.Header()[name] = values
+	}
+	for name, values := range rw.trailer() {
+		if !isTransportHeader(name) {
+			w.Header()["Trailer-"+name] = values
+		}
+	}
+
+	if st := grpcStatus(rw); st.Code() != codes.OK {
+		writeConnectError(w, st)
+		return
+	}
+	res, err := unframe(buf.body.Bytes())
+	if err != nil {
+		writeConnectError(w, status.New(codes.Internal, err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/"+codec)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(res)
+}
+
+// serveConnectStream relays a streaming Connect call. Messages are framed
+// like in gRPC, the status and trailers are sent in a final JSON frame.
+func (h *Handler) serveConnectStream(w http.ResponseWriter, r *http.Request, codec string) {
+	rw := newStreamWriter(w, r.Header.Get("Content-Type"))
+	if encoding := r.Header.Get("Connect-Content-Encoding"); encoding != "" && encoding != "identity" {
+		_, _ = rw.Write(endFrame(status.Newf(codes.Unimplemented, "unsupported content encoding %q", encoding), nil))
+		return
+	}
+
+	h.grpc.ServeHTTP(rw, connectRequest(r, codec, r.Body))
+	if rw.code != http.StatusOK {
+		return
+	}
+
+	metadata := map[string][]string{}
+	for name, values := range rw.trailer() {
+		if !isTransportHeader(name) {
+			metadata[name] = values
+		}
+	}
+	_, _ = rw.Write(endFrame(grpcStatus(rw), metadata))
+}
+
+// connectRequest turns a Connect request into a gRPC one, with the timeout
+// carried over.
+func connectRequest(r *http.Request, codec string, body io.Reader) *http.Request {
+	req := grpcRequest(r, "application/grpc+"+codec, body)
+	if ms, err := strconv.ParseInt(r.Header.Get("Connect-Timeout-Ms"), 10, 64); err == nil && ms > 0 {
+		if ms <= maxGRPCTimeout {
+			req.Header.Set("Grpc-Timeout", strconv.FormatInt(ms, 10)+"m")
+		} else {
+			req.Header.Set("Grpc-Timeout", strconv.FormatInt(min((ms+999)/1000, maxGRPCTimeout), 10)+"S")
+		}
+	}
+	return req
+}
+
+func writeConnectError(w http.ResponseWriter, st *status.Status) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(gateway.HTTPStatus(st.Code()))
+	_ = json.NewEncoder(w).Encode(connectError{Code: connectCode(st.Code()), Message: st.Message()})
+}
+
+func endFrame(st *status.Status, metadata map[string][]string) []byte {
+	end := connectEnd{Metadata: metadata}
+	if st.Code() != codes.OK {
+		end.Error = &connectError{Code: connectCode(st.Code()), Message: st.Message()}
+	}
+	b, _ := json.Marshal(end)
+	return frame(flagConnectEnd, b)
+}
+
+// connectCode names code the way Connect does, e.g. invalid_argument.
+func connectCode(code codes.Code) string {
+	if code > codes.Unauthenticated {
+		return "unknown"
+	}
+	var b strings.Builder
+	for i, r := range code.String() {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToLower(b.String())
+}
+
+// bufferWriter keeps a response in memory.
+type bufferWriter struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (b *bufferWriter) Header() http.Header         { return b.header }
+func (b *bufferWriter) WriteHeader(code int)        { b.code = code }
+func (b *bufferWriter) Write(p []byte) (int, error) { return b.body.Write(p) }
@@ -0,0 +1,21 @@
+package web
+
+import (
+	"net/http"
+	"sso/internal/lib/cors"
+)
+
+// CORS is the policy that lets browser pages on allowedOrigins make
+// gRPC-Web and Connect calls.
+func CORS(allowedOrigins []string) cors.Policy {
+	return cors.Policy{
+		AllowedOrigins: allowedOrigins,
+		AllowedMethods: []string{http.MethodPost},
+		AllowedHeaders: []string{
+			"Content-Type", "X-Grpc-Web", "X-User-Agent", "Grpc-Timeout",
+			"Connect-Protocol-Version", "Connect-Timeout-Ms",
+			"X-Request-Id", "X-Device-Id", "Traceparent", "Tracestate",
+		},
+		ExposedHeaders: []string{"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin", "X-Request-Id"},
+	}
+}
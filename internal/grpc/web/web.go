@@ -0,0 +1,155 @@
+// Package web serves gRPC-Web and Connect requests by translating them to
+// calls of a grpc.Server, so browsers reach the API over HTTP/1.1 or
+// HTTP/2 without a proxy in front.
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxMessageSize matches the default receive limit of grpc.Server.
+const maxMessageSize = 4 << 20
+
+// frame flags of the length-prefixed messages
+const (
+	flagCompressed  = 0x01
+	flagConnectEnd  = 0x02
+	flagGRPCWebTail = 0x80
+)
+
+type Handler struct {
+	grpc http.Handler
+}
+
+// New returns a handler for gRPC-Web and Connect requests that serves them
+// with grpcServer, usually a *grpc.Server. Plain gRPC requests arriving over
+// HTTP/2 are passed on as they are.
+func New(grpcServer http.Handler) *Handler {
+	return &Handler{grpc: grpcServer}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch {
+	case strings.HasPrefix(contentType, "application/grpc-web"):
+		h.serveGRPCWeb(w, r, contentType)
+	case strings.HasPrefix(contentType, "application/grpc"):
+		h.grpc.ServeHTTP(w, r)
+	case strings.HasPrefix(contentType, "application/connect+"):
+		h.serveConnectStream(w, r, strings.TrimPrefix(contentType, "application/connect+"))
+	case contentType == "application/proto" || contentType == "application/json":
+		h.serveConnectUnary(w, r, strings.TrimPrefix(contentType, "application/"))
+	default:
+		http.Error(w, "unsupported content type "+strconv.Quote(contentType), http.StatusUnsupportedMediaType)
+	}
+}
+
+// serveGRPCWeb relays a gRPC-Web call, the status and trailers are sent in
+// a final frame of the body. The -text variants are base64 encoded.
+func (h *Handler) serveGRPCWeb(w http.ResponseWriter, r *http.Request, contentType string) {
+	text := strings.HasPrefix(contentType, "application/grpc-web-text")
+	subtype := strings.TrimPrefix(strings.TrimPrefix(contentType, "application/grpc-web-text"), "application/grpc-web")
+
+	var body io.Reader = r.Body
+	if text {
+		body = base64.NewDecoder(base64.StdEncoding, r.Body)
+	}
+
+	rw := newStreamWriter(w, r.Header.Get("Content-Type"))
+	if text {
+		encoder := base64.NewEncoder(base64.StdEncoding, w)
+		defer encoder.Close()
+		rw.out = encoder
+	}
+
+	h.grpc.ServeHTTP(rw, grpcRequest(r, "application/grpc"+subtype, body))
+	if rw.code != http.StatusOK {
+		return
+	}
+
+	var tail bytes.Buffer
+	for name, values := range rw.trailer() {
+		for _, value := range values {
+			tail.WriteString(strings.ToLower(name) + ": " + value + "\r\n")
+		}
+	}
+	_, _ = rw.Write(frame(flagGRPCWebTail, tail.Bytes()))
+}
+
+// grpcRequest turns r into a gRPC request with body as its framed messages.
+// grpc.Server only serves HTTP/2 requests, the version is only checked and
+// so set regardless of the connection.
+func grpcRequest(r *http.Request, contentType string, body io.Reader) *http.Request {
+	req := r.Clone(r.Context())
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/2.0", 2, 0
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Del("Content-Length")
+	req.ContentLength = -1
+	req.Body = io.NopCloser(io.LimitReader(body, maxMessageSize+5))
+	return req
+}
+
+func frame(flags byte, payload []byte) []byte {
+	b := make([]byte, 5, 5+len(payload))
+	b[0] = flags
+	binary.BigEndian.PutUint32(b[1:], uint32(len(payload)))
+	return append(b, payload...)
+}
+
+// unframe returns the payload of the single message frame in b.
+func unframe(b []byte) ([]byte, error) {
+	if len(b) < 5 {
+		return nil, errors.New("response message is missing")
+	}
+	if b[0]&flagCompressed != 0 {
+		return nil, errors.New("compressed response messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(b[1:5])
+	if uint32(len(b)-5) != size {
+		return nil, errors.New("response message is truncated")
+	}
+	return b[5:], nil
+}
+
+// grpcStatus reads the status grpc.Server left in the response headers. A
+// response without one is an error of the transport, e.g. an unsupported
+// request.
+func grpcStatus(rw *streamWriter) *status.Status {
+	value := rw.header.Get("Grpc-Status")
+	if value == "" {
+		return status.New(codes.Internal, "transport failed with HTTP status "+strconv.Itoa(rw.code))
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil {
+		return status.New(codes.Internal, "malformed grpc-status "+strconv.Quote(value))
+	}
+	return status.New(codes.Code(code), grpcMessage(rw.header.Get("Grpc-Message")))
+}
+
+// grpcMessage decodes the percent-encoding of grpc-message.
+func grpcMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if msg[i] == '%' && i+2 < len(msg) {
+			if n, err := strconv.ParseUint(msg[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(msg[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,187 @@
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	checkPath = "/grpc.health.v1.Health/Check"
+	watchPath = "/grpc.health.v1.Health/Watch"
+)
+
+func newHandler(t *testing.T) http.Handler {
+	srv := grpc.NewServer()
+	hs := health.NewServer()
+	hs.SetServingStatus("sso", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	t.Cleanup(srv.Stop)
+	return New(srv)
+}
+
+func marshal(t *testing.T, msg proto.Message) []byte {
+	b, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	return b
+}
+
+// frames splits a response body into its length-prefixed frames.
+func frames(t *testing.T, body []byte) (flags []byte, payloads [][]byte) {
+	for len(body) > 0 {
+		require.GreaterOrEqual(t, len(body), 5)
+		size := binary.BigEndian.Uint32(body[1:5])
+		require.GreaterOrEqual(t, uint32(len(body)-5), size)
+		flags = append(flags, body[0])
+		payloads = append(payloads, body[5:5+size])
+		body = body[5+size:]
+	}
+	return flags, payloads
+}
+
+func post(h http.Handler, path, contentType string, body []byte, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGRPCWeb(t *testing.T) {
+	h := newHandler(t)
+
+	rec := post(h, checkPath, "application/grpc-web+proto", frame(0, marshal(t, &healthpb.HealthCheckRequest{Service: "sso"})))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/grpc-web+proto", rec.Header().Get("Content-Type"))
+
+	flags, payloads := frames(t, rec.Body.Bytes())
+	require.Equal(t, []byte{0, flagGRPCWebTail}, flags)
+	var res healthpb.HealthCheckResponse
+	require.NoError(t, proto.Unmarshal(payloads[0], &res))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.GetStatus())
+	assert.Contains(t, string(payloads[1]), "grpc-status: 0\r\n")
+}
+
+func TestGRPCWeb_Text(t *testing.T) {
+	h := newHandler(t)
+
+	body := base64.StdEncoding.EncodeToString(frame(0, marshal(t, &healthpb.HealthCheckRequest{Service: "unknown"})))
+	rec := post(h, checkPath, "application/grpc-web-text", []byte(body))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	decoded, err := base64.StdEncoding.DecodeString(rec.Body.String())
+	require.NoError(t, err)
+	flags, payloads := frames(t, decoded)
+	require.Equal(t, []byte{flagGRPCWebTail}, flags)
+	assert.Contains(t, string(payloads[0]), "grpc-status: 5\r\n")
+	assert.Contains(t, string(payloads[0]), "grpc-message: unknown service\r\n")
+}
+
+func TestConnectUnary(t *testing.T) {
+	h := newHandler(t)
+
+	rec := post(h, checkPath, "application/proto", marshal(t, &healthpb.HealthCheckRequest{Service: "sso"}), "Connect-Protocol-Version", "1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/proto", rec.Header().Get("Content-Type"))
+	var res healthpb.HealthCheckResponse
+	require.NoError(t, proto.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.GetStatus())
+
+	rec = post(h, checkPath, "application/json", []byte(`{"service":"sso"}`))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"status":"SERVING"}`, rec.Body.String())
+}
+
+func TestConnectUnary_Errors(t *testing.T) {
+	h := newHandler(t)
+
+	for _, tc := range []struct {
+		name    string
+		path    string
+		body    string
+		headers []string
+		status  int
+		code    string
+	}{
+		{"status", checkPath, `{"service":"unknown"}`, nil, http.StatusNotFound, "not_found"},
+		{"unknown method", "/grpc.health.v1.Health/Nope", `{}`, nil, http.StatusNotImplemented, "unimplemented"},
+		{"malformed message", checkPath, `{"service":`, nil, http.StatusInternalServerError, "internal"},
+		{"compressed", checkPath, `{}`, []string{"Content-Encoding", "br"}, http.StatusNotImplemented, "unimplemented"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := post(h, tc.path, "application/json", []byte(tc.body), tc.headers...)
+
+			assert.Equal(t, tc.status, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			var res connectError
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			assert.Equal(t, tc.code, res.Code)
+			assert.NotEmpty(t, res.Message)
+		})
+	}
+}
+
+func TestConnectStream(t *testing.T) {
+	h := newHandler(t)
+
+	rec := post(h, watchPath, "application/connect+json", frame(0, []byte(`{"service":"sso"}`)), "Connect-Timeout-Ms", "50")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/connect+json", rec.Header().Get("Content-Type"))
+
+	flags, payloads := frames(t, rec.Body.Bytes())
+	require.Equal(t, []byte{0, flagConnectEnd}, flags)
+	assert.JSONEq(t, `{"status":"SERVING"}`, string(payloads[0]))
+
+	// the health server ends Watch as canceled once the deadline passes
+	var end connectEnd
+	require.NoError(t, json.Unmarshal(payloads[1], &end))
+	require.NotNil(t, end.Error)
+	assert.Equal(t, "canceled", end.Error.Code)
+}
+
+func TestConnectRequest_Timeout(t *testing.T) {
+	for timeout, want := range map[string]string{
+		"":           "",
+		"250":        "250m",
+		"abc":        "",
+		"1000000001": "1000001S",
+	} {
+		r := httptest.NewRequest(http.MethodPost, checkPath, nil)
+		r.Header.Set("Connect-Timeout-Ms", timeout)
+		assert.Equal(t, want, connectRequest(r, "proto", r.Body).Header.Get("Grpc-Timeout"), timeout)
+	}
+}
+
+func TestUnsupportedContentType(t *testing.T) {
+	rec := post(newHandler(t), checkPath, "text/plain", []byte("hi"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+}
+
+func TestConnectCode(t *testing.T) {
+	assert.Equal(t, "invalid_argument", connectCode(codes.InvalidArgument))
+	assert.Equal(t, "deadline_exceeded", connectCode(codes.DeadlineExceeded))
+	assert.Equal(t, "unauthenticated", connectCode(codes.Unauthenticated))
+	assert.Equal(t, "unknown", connectCode(codes.Code(42)))
+}
+
+func TestGRPCMessage(t *testing.T) {
+	assert.Equal(t, "100% done: ok", grpcMessage("100%25 done: ok"))
+	assert.Equal(t, "bad %zz", grpcMessage("bad %zz"))
+	assert.Equal(t, "trailing %4", grpcMessage("trailing %4"))
+}
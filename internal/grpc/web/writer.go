@@ -0,0 +1,103 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+// streamWriter relays the response of grpc.Server to w, with the headers
+// rewritten for the client protocol. Headers grpc.Server sets after the body
+// started are the trailers, returned by trailer for the protocol to encode.
+type streamWriter struct {
+	w           http.ResponseWriter
+	out         io.Writer
+	contentType string
+	header      http.Header
+	sent        map[string]bool
+	code        int
+}
+
+func newStreamWriter(w http.ResponseWriter, contentType string) *streamWriter {
+	return &streamWriter{
+		w:           w,
+		out:         w,
+		contentType: contentType,
+		header:      http.Header{},
+	}
+}
+
+func (s *streamWriter) Header() http.Header {
+	return s.header
+}
+
+func (s *streamWriter) WriteHeader(code int) {
+	if s.sent != nil {
+		return
+	}
+	s.code = code
+	s.sent = make(map[string]bool, len(s.header))
+
+	h := s.w.Header()
+	for name, values := range s.header {
+		s.sent[name] = true
+		if !isTransportHeader(name) {
+			h[name] = values
+		}
+	}
+	if code == http.StatusOK {
+		h.Set("Content-Type", s.contentType)
+	} else {
+		h.Set("Content-Type", s.header.Get("Content-Type"))
+	}
+	s.w.WriteHeader(code)
+}
+
+func (s *streamWriter) Write(b []byte) (int, error) {
+	s.WriteHeader(http.StatusOK)
+	return s.out.Write(b)
+}
+
+// Flush is required by grpc.Server, which flushes after every message.
+func (s *streamWriter) Flush() {
+	s.WriteHeader(http.StatusOK)
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// trailer returns the status and metadata grpc.Server set after the headers
+// were sent.
+func (s *streamWriter) trailer() http.Header {
+	trailer := http.Header{}
+	for name, values := range s.header {
+		switch {
+		case strings.HasPrefix(name, http.TrailerPrefix):
+			trailer[http.CanonicalHeaderKey(strings.TrimPrefix(name, http.TrailerPrefix))] = values
+		case !s.sent[name]:
+			trailer[name] = values
+		}
+	}
+	return trailer
+}
+
+// headers returns the metadata headers grpc.Server sent before the body.
+func (s *streamWriter) headers() http.Header {
+	headers := http.Header{}
+	for name, values := range s.header {
+		if s.sent[name] && !isTransportHeader(name) {
+			headers[name] = values
+		}
+	}
+	return headers
+}
+
+// isTransportHeader reports whether the header belongs to the gRPC or HTTP
+// framing rather than the call metadata.
+func isTransportHeader(name string) bool {
+	switch http.CanonicalHeaderKey(name) {
+	case "Content-Type", "Content-Length", "Trailer", "Date":
+		return true
+	}
+	return strings.HasPrefix(name, http.TrailerPrefix) || strings.HasPrefix(http.CanonicalHeaderKey(name), "Grpc-")
+}
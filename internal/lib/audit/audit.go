@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"sso/internal/domain/models"
+)
+
+const personalSaltSize = 16
+
+var (
+	ErrBrokenChain  = errors.New("previous hash does not match the previous event")
+	ErrHashMismatch = errors.New("event hash does not match its contents")
+
+	ErrAnchorMismatch = errors.New("event hash does not match the anchor")
+	ErrAnchorMissing  = errors.New("anchored event is missing")
+)
+
+// Hash returns the chain hash of event following an event with hash prev.
+// Every field is length prefixed so that values cannot be shifted between
+// fields without changing the hash. OccurredAt is hashed with microsecond
+// precision, the precision it is stored with. IP and UserAgent are hashed
+// through PersonalDigest if the event has one.
+func Hash(prev []byte, event *models.AuditEvent) []byte {
+	h := sha256.New()
+	w := writer{h: h}
+
+	w.bytes(prev)
+	w.int(event.OccurredAt.UnixMicro())
+	w.bytes([]byte(event.Action))
+	w.int(event.ActorId)
+	w.int(event.SubjectId)
+	w.int(event.AppId)
+	if event.PersonalDigest != nil {
+		// no length is negative, so this can not be read as an IP
+		w.int(-1)
+		w.bytes(event.PersonalDigest)
+	} else {
+		w.bytes([]byte(event.IP))
+		w.bytes([]byte(event.UserAgent))
+	}
+	w.bytes([]byte(event.Outcome))
+	w.bytes([]byte(event.Reason))
+	return h.Sum(nil)
+}
+
+// Seal gives event a new PersonalSalt and the PersonalDigest of its IP and
+// UserAgent, before it is hashed.
+func Seal(event *models.AuditEvent) error {
+	salt := make([]byte, personalSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	event.PersonalSalt = salt
+	event.PersonalDigest = personalDigest(salt, event)
+	return nil
+}
+
+// Erase drops the IP and UserAgent of a sealed event together with the
+// salt, which keeps the digest from being matched against guessed values.
+// The event stays verifiable through its digest.
+func Erase(event *models.AuditEvent) {
+	event.IP = ""
+	event.UserAgent = ""
+	event.PersonalSalt = nil
+}
+
+// Verify checks that event follows an event with hash prev and that its
+// hash matches its contents. IP and UserAgent of a sealed event have to
+// match its digest, of an erased one be empty.
+func Verify(prev []byte, event *models.AuditEvent) error {
+	if !bytes.Equal(event.PrevHash, prev) {
+		return ErrBrokenChain
+	}
+	if event.PersonalDigest != nil {
+		switch {
+		case event.PersonalSalt != nil && !bytes.Equal(event.PersonalDigest, personalDigest(event.PersonalSalt, event)):
+			return ErrHashMismatch
+		case event.PersonalSalt == nil && (event.IP != "" || event.UserAgent != ""):
+			return ErrHashMismatch
+		}
+	}
+	if !bytes.Equal(event.Hash, Hash(prev, event)) {
+		return ErrHashMismatch
+	}
+	return nil
+}
+
+func personalDigest(salt []byte, event *models.AuditEvent) []byte {
+	h := sha256.New()
+	w := writer{h: h}
+	w.bytes(salt)
+	w.bytes([]byte(event.IP))
+	w.bytes([]byte(event.UserAgent))
+	return h.Sum(nil)
+}
+
+// writer writes integers and length prefixed values to a hash.
+type writer struct {
+	h   hash.Hash
+	buf [8]byte
+}
+
+func (w *writer) int(v int64) {
+	binary.BigEndian.PutUint64(w.buf[:], uint64(v))
+	w.h.Write(w.buf[:])
+}
+
+func (w *writer) bytes(b []byte) {
+	w.int(int64(len(b)))
+	w.h.Write(b)
+}
@@ -0,0 +1,140 @@
+package audit
+
+import (
+	"sso/internal/domain/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func chain(t *testing.T, n int) []models.AuditEvent {
+	t.Helper()
+	events := make([]models.AuditEvent, n)
+	var prev []byte
+	for i := range events {
+		events[i] = models.AuditEvent{
+			Id:         int64(i + 1),
+			OccurredAt: time.Date(2025, 1, 1, 0, 0, i, 0, time.UTC),
+			Action:     models.AuditLogin,
+			SubjectId:  int64(i + 1),
+			AppId:      1,
+			IP:         "10.0.0.1",
+			Outcome:    models.AuditSuccess,
+			PrevHash:   prev,
+		}
+		events[i].Hash = Hash(prev, &events[i])
+		prev = events[i].Hash
+	}
+	return events
+}
+
+func verifyAll(events []models.AuditEvent) error {
+	var prev []byte
+	for i := range events {
+		if err := Verify(prev, &events[i]); err != nil {
+			return err
+		}
+		prev = events[i].Hash
+	}
+	return nil
+}
+
+func TestVerify_ValidChain(t *testing.T) {
+	require.NoError(t, verifyAll(chain(t, 5)))
+}
+
+func TestVerify_ModifiedEvent(t *testing.T) {
+	events := chain(t, 5)
+	events[2].Outcome = models.AuditFailure
+
+	assert.ErrorIs(t, verifyAll(events), ErrHashMismatch)
+}
+
+func TestVerify_DeletedEvent(t *testing.T) {
+	events := chain(t, 5)
+	events = append(events[:2], events[3:]...)
+
+	assert.ErrorIs(t, verifyAll(events), ErrBrokenChain)
+}
+
+func TestVerify_RehashedEvent(t *testing.T) {
+	events := chain(t, 5)
+	events[2].Reason = "tampered"
+	events[2].Hash = Hash(events[2].PrevHash, &events[2])
+
+	// the forged hash no longer matches the next event's link
+	assert.ErrorIs(t, verifyAll(events), ErrBrokenChain)
+}
+
+func TestHash_FieldBoundaries(t *testing.T) {
+	a := &models.AuditEvent{IP: "1.2.3.4", UserAgent: "x"}
+	b := &models.AuditEvent{IP: "1.2.3.4x", UserAgent: ""}
+
+	assert.NotEqual(t, Hash(nil, a), Hash(nil, b))
+}
+
+func TestHash_MicrosecondPrecision(t *testing.T) {
+	at := time.Date(2025, 1, 1, 0, 0, 0, 123456789, time.UTC)
+	a := &models.AuditEvent{OccurredAt: at}
+	b := &models.AuditEvent{OccurredAt: at.Truncate(time.Microsecond)}
+
+	assert.Equal(t, Hash(nil, a), Hash(nil, b))
+}
+
+func sealedChain(t *testing.T, n int) []models.AuditEvent {
+	t.Helper()
+	events := make([]models.AuditEvent, n)
+	var prev []byte
+	for i := range events {
+		events[i] = models.AuditEvent{
+			Id:         int64(i + 1),
+			OccurredAt: time.Date(2025, 1, 1, 0, 0, i, 0, time.UTC),
+			Action:     models.AuditLogin,
+			SubjectId:  int64(i + 1),
+			AppId:      1,
+			IP:         "10.0.0.1",
+			UserAgent:  "curl/8.0",
+			Outcome:    models.AuditSuccess,
+			PrevHash:   prev,
+		}
+		require.NoError(t, Seal(&events[i]))
+		events[i].Hash = Hash(prev, &events[i])
+		prev = events[i].Hash
+	}
+	return events
+}
+
+func TestVerify_ErasedPersonalData(t *testing.T) {
+	events := sealedChain(t, 5)
+	require.NoError(t, verifyAll(events))
+
+	Erase(&events[2])
+	require.NoError(t, verifyAll(events))
+
+	// an erased event can not get its data back, or other data
+	events[2].IP = "10.0.0.2"
+	assert.ErrorIs(t, verifyAll(events), ErrHashMismatch)
+}
+
+func TestVerify_ModifiedPersonalData(t *testing.T) {
+	events := sealedChain(t, 5)
+	events[2].UserAgent = "tampered"
+	assert.ErrorIs(t, verifyAll(events), ErrHashMismatch)
+
+	events = sealedChain(t, 5)
+	events[2].PersonalDigest = append([]byte(nil), events[1].PersonalDigest...)
+	assert.ErrorIs(t, verifyAll(events), ErrHashMismatch)
+}
+
+func TestHash_PersonalDigest(t *testing.T) {
+	a := &models.AuditEvent{IP: "1.2.3.4", UserAgent: "x"}
+	b := &models.AuditEvent{IP: "1.2.3.4", UserAgent: "x"}
+	require.NoError(t, Seal(a))
+	require.NoError(t, Seal(b))
+
+	// the salts differ, so do the digests of equal values
+	assert.NotEqual(t, a.PersonalDigest, b.PersonalDigest)
+	assert.NotEqual(t, Hash(nil, a), Hash(nil, &models.AuditEvent{IP: "1.2.3.4", UserAgent: "x"}))
+}
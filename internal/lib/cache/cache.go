@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache is a size-bounded LRU cache with per-entry expiration. Besides regular
+// values it can remember that a key is known to be missing (negative caching).
+type Cache[K comparable, V any] struct {
+	mu          sync.Mutex
+	maxSize     int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	ll          *list.List
+	items       map[K]*list.Element
+	now         func() time.Time
+	// generation is bumped by Delete and Purge, see Generation.
+	generation uint64
+}
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	missing   bool
+	expiresAt time.Time
+}
+
+func New[K comparable, V any](maxSize int, ttl time.Duration, negativeTTL time.Duration) *Cache[K, V] {
+	return &Cache[K, V]{
+		maxSize:     maxSize,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		ll:          list.New(),
+		items:       make(map[K]*list.Element),
+		now:         time.Now,
+	}
+}
+
+// Get returns the cached value for key. cached reports whether the key was
+// found in the cache at all; missing reports a negatively cached key.
+func (c *Cache[K, V]) Get(key K) (value V, missing bool, cached bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return value, false, false
+	}
+
+	e := el.Value.(*entry[K, V])
+	if !c.now().Before(e.expiresAt) {
+		c.removeElement(el)
+		return value, false, false
+	}
+
+	c.ll.MoveToFront(el)
+	return e.value, e.missing, true
+}
+
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(key, value, false, c.ttl)
+}
+
+// SetMissing remembers that key does not exist for the negative TTL.
+func (c *Cache[K, V]) SetMissing(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	c.set(key, zero, true, c.negativeTTL)
+}
+
+// Generation returns a value that changes on every Delete and Purge. A
+// read-through caller takes it before loading a value and stores the value
+// with SetIfGeneration, so a value loaded before an invalidation is not
+// cached after it.
+func (c *Cache[K, V]) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// SetIfGeneration is Set if the generation is still gen.
+func (c *Cache[K, V]) SetIfGeneration(gen uint64, key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation == gen {
+		c.set(key, value, false, c.ttl)
+	}
+}
+
+// SetMissingIfGeneration is SetMissing if the generation is still gen.
+func (c *Cache[K, V]) SetMissingIfGeneration(gen uint64, key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation == gen {
+		var zero V
+		c.set(key, zero, true, c.negativeTTL)
+	}
+}
+
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+func (c *Cache[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.ll.Init()
+	c.items = make(map[K]*list.Element)
+}
+
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ll.Len()
+}
+
+func (c *Cache[K, V]) set(key K, value V, missing bool, ttl time.Duration) {
+	if ttl <= 0 || c.maxSize <= 0 {
+		return
+	}
+
+	expiresAt := c.now().Add(ttl)
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		e.value, e.missing, e.expiresAt = value, missing, expiresAt
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&entry[K, V]{key: key, value: value, missing: missing, expiresAt: expiresAt})
+	for c.ll.Len() > c.maxSize {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *Cache[K, V]) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*entry[K, V]).key)
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_GetSet(t *testing.T) {
+	c := New[int, string](2, time.Minute, time.Minute)
+
+	_, _, cached := c.Get(1)
+	assert.False(t, cached)
+
+	c.Set(1, "one")
+	value, missing, cached := c.Get(1)
+	require.True(t, cached)
+	assert.False(t, missing)
+	assert.Equal(t, "one", value)
+}
+
+func TestCache_NegativeEntries(t *testing.T) {
+	c := New[int, string](2, time.Minute, time.Minute)
+
+	c.SetMissing(1)
+	_, missing, cached := c.Get(1)
+	require.True(t, cached)
+	assert.True(t, missing)
+}
+
+func TestCache_Expiration(t *testing.T) {
+	now := time.Now()
+	c := New[int, string](2, time.Minute, time.Second)
+	c.now = func() time.Time { return now }
+
+	c.Set(1, "one")
+	c.SetMissing(2)
+
+	now = now.Add(2 * time.Second)
+	_, _, cached := c.Get(2)
+	assert.False(t, cached, "negative entry should expire after negative TTL")
+	_, _, cached = c.Get(1)
+	assert.True(t, cached)
+
+	now = now.Add(time.Minute)
+	_, _, cached = c.Get(1)
+	assert.False(t, cached)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := New[int, string](2, time.Minute, time.Minute)
+
+	c.Set(1, "one")
+	c.Set(2, "two")
+	c.Get(1)
+	c.Set(3, "three")
+
+	_, _, cached := c.Get(2)
+	assert.False(t, cached)
+	_, _, cached = c.Get(1)
+	assert.True(t, cached)
+	_, _, cached = c.Get(3)
+	assert.True(t, cached)
+}
+
+func TestCache_DeleteAndPurge(t *testing.T) {
+	c := New[int, string](4, time.Minute, time.Minute)
+
+	c.Set(1, "one")
+	c.Set(2, "two")
+	c.Delete(1)
+	_, _, cached := c.Get(1)
+	assert.False(t, cached)
+
+	c.Purge()
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestCache_SetIfGeneration(t *testing.T) {
+	c := New[int, string](4, time.Minute, time.Minute)
+
+	gen := c.Generation()
+	c.SetIfGeneration(gen, 1, "one")
+	_, _, cached := c.Get(1)
+	assert.True(t, cached)
+
+	gen = c.Generation()
+	c.Delete(1)
+	c.SetIfGeneration(gen, 1, "stale")
+	c.SetMissingIfGeneration(gen, 2)
+	_, _, cached = c.Get(1)
+	assert.False(t, cached, "a value loaded before Delete must not be cached")
+	_, _, cached = c.Get(2)
+	assert.False(t, cached)
+
+	gen = c.Generation()
+	c.Purge()
+	c.SetIfGeneration(gen, 1, "stale")
+	assert.Equal(t, 0, c.Len())
+}
@@ -0,0 +1,58 @@
+// Package cors answers CORS requests for browser pages on allowed origins.
+package cors
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const maxAge = 10 * 60 // seconds
+
+// Policy lets browser pages on AllowedOrigins call a handler with the
+// allowed methods and request headers and read the exposed response
+// headers. A "*" origin allows any page; without origins cross-origin
+// requests are left to the browser to refuse.
+type Policy struct {
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	ExposedHeaders []string
+}
+
+func (p Policy) Handler(next http.Handler) http.Handler {
+	anyOrigin := slices.Contains(p.AllowedOrigins, "*")
+	methods := strings.Join(p.AllowedMethods, ", ")
+	allowed := strings.Join(p.AllowedHeaders, ", ")
+	exposed := strings.Join(p.ExposedHeaders, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !(anyOrigin || slices.Contains(p.AllowedOrigins, origin)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		h.Set("Access-Control-Allow-Origin", origin)
+		if exposed != "" {
+			h.Set("Access-Control-Expose-Headers", exposed)
+		}
+
+		// preflight, answered here as handlers usually only serve their own
+		// methods
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", methods)
+			h.Set("Access-Control-Allow-Headers", allowed)
+			h.Set("Access-Control-Max-Age", strconv.Itoa(maxAge))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
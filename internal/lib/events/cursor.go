@@ -0,0 +1,48 @@
+package events
+
+import (
+	"errors"
+	"sso/internal/domain/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid event cursor")
+
+// Cursor is a position in the event log. Clients get one with every watched
+// event and pass the last one back to resume after it.
+type Cursor struct {
+	Seq int64
+	// OccurredAt of the event at Seq tells whether the events after it may
+	// have been purged already.
+	OccurredAt time.Time
+}
+
+// CursorAt returns the cursor right after event.
+func CursorAt(event models.Event) Cursor {
+	return Cursor{Seq: event.Seq, OccurredAt: event.OccurredAt}
+}
+
+// String encodes the cursor, clients should treat it as opaque.
+func (c Cursor) String() string {
+	return strconv.FormatInt(c.Seq, 10) + "." + strconv.FormatInt(c.OccurredAt.UnixMicro(), 10)
+}
+
+func ParseCursor(value string) (Cursor, error) {
+	seq, micro, ok := strings.Cut(value, ".")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var c Cursor
+	var err error
+	if c.Seq, err = strconv.ParseInt(seq, 10, 64); err != nil || c.Seq < 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	unixMicro, err := strconv.ParseInt(micro, 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	c.OccurredAt = time.UnixMicro(unixMicro).UTC()
+	return c, nil
+}
@@ -0,0 +1,44 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+)
+
+const (
+	PublisherMemory  = "memory"
+	PublisherNATS    = "nats"
+	PublisherWebhook = "webhook"
+)
+
+var (
+	ErrUnknownPublisher = errors.New("unknown event publisher")
+	ErrInvalidConfig    = errors.New("invalid events config")
+)
+
+// Publisher delivers domain events to downstream consumers. An event may be
+// published more than once, implementations pass Event.Id along so consumers
+// can deduplicate.
+type Publisher interface {
+	Publish(ctx context.Context, event models.Event) error
+}
+
+// New returns the publisher selected by cfg.Publisher.
+func New(cfg config.Events) (Publisher, error) {
+	switch cfg.Publisher {
+	case PublisherMemory:
+		return NewMemory(), nil
+	case PublisherNATS:
+		return NewNATS(cfg.NATSURL, cfg.NATSSubjectPrefix)
+	case PublisherWebhook:
+		if cfg.WebhookURL == "" {
+			return nil, fmt.Errorf("%w: webhook_url is required", ErrInvalidConfig)
+		}
+		return NewWebhook(cfg.WebhookURL, cfg.WebhookTimeout), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownPublisher, cfg.Publisher)
+	}
+}
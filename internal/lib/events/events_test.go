@@ -0,0 +1,103 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testEvent = models.Event{
+	Id:         "3f2b6c1e-0d4a-4c47-9a57-2b8f7d0e6a11",
+	Type:       models.EventUserRegistered,
+	Payload:    json.RawMessage(`{"user_id":1,"email":"user@example.com"}`),
+	OccurredAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+}
+
+func TestNew(t *testing.T) {
+	publisher, err := New(config.Events{Publisher: PublisherMemory})
+	require.NoError(t, err)
+	assert.IsType(t, &Memory{}, publisher)
+
+	publisher, err = New(config.Events{Publisher: PublisherWebhook, WebhookURL: "https://events.example.com"})
+	require.NoError(t, err)
+	assert.IsType(t, &Webhook{}, publisher)
+
+	_, err = New(config.Events{Publisher: PublisherWebhook})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+
+	_, err = New(config.Events{Publisher: "kafka"})
+	assert.ErrorIs(t, err, ErrUnknownPublisher)
+}
+
+func TestMemoryDeduplicates(t *testing.T) {
+	memory := NewMemory()
+	require.NoError(t, memory.Publish(context.Background(), testEvent))
+	require.NoError(t, memory.Publish(context.Background(), testEvent))
+
+	assert.Equal(t, []models.Event{testEvent}, memory.Events())
+}
+
+func TestWebhook(t *testing.T) {
+	var got models.Event
+	var header http.Header
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &got)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	webhook := NewWebhook(srv.URL, time.Second)
+	require.NoError(t, webhook.Publish(context.Background(), testEvent))
+	assert.Equal(t, testEvent.Id, header.Get("Idempotency-Key"))
+	assert.Equal(t, testEvent.Type, header.Get("X-Event-Type"))
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, testEvent.Id, got.Id)
+	assert.JSONEq(t, string(testEvent.Payload), string(got.Payload))
+
+	status = http.StatusServiceUnavailable
+	assert.Error(t, webhook.Publish(context.Background(), testEvent))
+}
+
+func TestNATS(t *testing.T) {
+	srv, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true})
+	require.NoError(t, err)
+	go srv.Start()
+	defer srv.Shutdown()
+	require.True(t, srv.ReadyForConnections(5*time.Second))
+
+	sub, err := nats.Connect(srv.ClientURL())
+	require.NoError(t, err)
+	defer sub.Close()
+	messages, err := sub.SubscribeSync("sso.>")
+	require.NoError(t, err)
+	require.NoError(t, sub.Flush())
+
+	publisher, err := NewNATS(srv.ClientURL(), "sso")
+	require.NoError(t, err)
+	defer publisher.Close()
+	require.NoError(t, publisher.Publish(context.Background(), testEvent))
+
+	msg, err := messages.NextMsg(5 * time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "sso.user.registered", msg.Subject)
+	assert.Equal(t, testEvent.Id, msg.Header.Get(nats.MsgIdHdr))
+
+	var got models.Event
+	require.NoError(t, json.Unmarshal(msg.Data, &got))
+	assert.Equal(t, testEvent.Type, got.Type)
+	assert.True(t, testEvent.OccurredAt.Equal(got.OccurredAt))
+}
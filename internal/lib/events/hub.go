@@ -0,0 +1,45 @@
+package events
+
+import "sync"
+
+// Hub wakes up watchers when new events are written to the outbox. Wake-ups
+// carry no data and coalesce, watchers read the events themselves from their
+// own cursor, so a slow watcher never holds up the others.
+type Hub struct {
+	mu       sync.Mutex
+	watchers map[chan struct{}]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{watchers: make(map[chan struct{}]struct{})}
+}
+
+// Subscribe returns a channel receiving wake-ups and a function that
+// unsubscribes.
+func (h *Hub) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	h.watchers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.watchers, ch)
+		h.mu.Unlock()
+	}
+}
+
+// Notify wakes up every watcher, it never blocks.
+func (h *Hub) Notify() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// a wake-up is already pending
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package events
+
+import (
+	"context"
+	"sso/internal/domain/models"
+	"sync"
+)
+
+// Memory keeps published events in memory, deduplicated by id. It is meant
+// for development and tests.
+type Memory struct {
+	mu     sync.Mutex
+	events []models.Event
+	seen   map[string]struct{}
+}
+
+func NewMemory() *Memory {
+	return &Memory{seen: make(map[string]struct{})}
+}
+
+func (m *Memory) Publish(ctx context.Context, event models.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.seen[event.Id]; ok {
+		return nil
+	}
+	m.seen[event.Id] = struct{}{}
+	m.events = append(m.events, event)
+	return nil
+}
+
+// Events returns the published events in publishing order.
+func (m *Memory) Events() []models.Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.Event(nil), m.events...)
+}
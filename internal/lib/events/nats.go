@@ -0,0 +1,52 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"sso/internal/domain/models"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// flushTimeout bounds waiting for the server to acknowledge a publish.
+const flushTimeout = 10 * time.Second
+
+// NATS publishes events to <prefix>.<event type> subjects. The event id is
+// sent as the Nats-Msg-Id header, so JetStream streams drop duplicates
+// within their duplicate window.
+type NATS struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+func NewNATS(url string, prefix string) (*NATS, error) {
+	conn, err := nats.Connect(url, nats.Name("sso"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+	return &NATS{conn: conn, prefix: prefix}, nil
+}
+
+func (n *NATS) Publish(ctx context.Context, event models.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(n.prefix + "." + event.Type)
+	msg.Header.Set(nats.MsgIdHdr, event.Id)
+	msg.Data = data
+	if err := n.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+	// core NATS publishing is fire and forget, the flush makes sure the
+	// server got the event before it is marked published
+	ctx, cancel := context.WithTimeout(ctx, flushTimeout)
+	defer cancel()
+	return n.conn.FlushWithContext(ctx)
+}
+
+func (n *NATS) Close() {
+	n.conn.Close()
+}
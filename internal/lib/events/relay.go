@@ -0,0 +1,120 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+// claimLease is how long claimed events are hidden from other relays. A
+// relay that dies mid-batch leaves its events to be claimed again after it.
+const claimLease = time.Minute
+
+// Outbox is the storage the relay reads events from.
+type Outbox interface {
+	ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]models.Event, error)
+	MarkOutboxEventPublished(ctx context.Context, seq int64) error
+	MarkOutboxEventFailed(ctx context.Context, seq int64, retryAt time.Time, lastErr string) error
+}
+
+// Relay moves events from the outbox to a publisher. Failed events are
+// retried with exponential backoff, so delivery is at least once and events
+// of different types may be delivered out of order.
+type Relay struct {
+	log          *slog.Logger
+	outbox       Outbox
+	publisher    Publisher
+	batchSize    int
+	pollInterval time.Duration
+	maxBackoff   time.Duration
+}
+
+func NewRelay(log *slog.Logger, outbox Outbox, publisher Publisher, cfg config.Events) *Relay {
+	return &Relay{
+		log:          log,
+		outbox:       outbox,
+		publisher:    publisher,
+		batchSize:    cfg.BatchSize,
+		pollInterval: cfg.PollInterval,
+		maxBackoff:   cfg.MaxBackoff,
+	}
+}
+
+// Run relays events until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	const op = "Events.Relay.Run"
+	log := r.log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+	for {
+		n, err := r.RelayOnce(ctx)
+		if err != nil {
+			log.Error("failed to relay events", sl.Err(err))
+		}
+		// a full batch means more events are likely waiting
+		if err == nil && n == r.batchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce publishes one batch of due events and returns how many were
+// claimed.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	const op = "Events.Relay.RelayOnce"
+	log := r.log.With(slog.String("op", op))
+
+	events, err := r.outbox.ClaimOutboxEvents(ctx, r.batchSize, claimLease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		if err := r.publisher.Publish(ctx, event); err != nil {
+			retryAt := time.Now().Add(Backoff(event.Attempts, r.maxBackoff))
+			log.Warn("failed to publish event",
+				slog.String("event_id", event.Id),
+				slog.String("type", event.Type),
+				slog.Int("attempts", event.Attempts),
+				slog.Time("retry_at", retryAt),
+				sl.Err(err),
+			)
+			if err := r.outbox.MarkOutboxEventFailed(ctx, event.Seq, retryAt, err.Error()); err != nil {
+				return len(events), err
+			}
+			continue
+		}
+		if err := r.outbox.MarkOutboxEventPublished(ctx, event.Seq); err != nil {
+			return len(events), err
+		}
+	}
+	return len(events), nil
+}
+
+// Backoff returns the delay before the next delivery attempt of an event
+// that failed attempts times: 1s, 2s, 4s and so on up to max.
+func Backoff(attempts int, max time.Duration) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > 30 {
+		return max
+	}
+	delay := time.Second << (attempts - 1)
+	if delay > max {
+		return max
+	}
+	return delay
+}
@@ -0,0 +1,98 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeOutbox struct {
+	pending   []models.Event
+	published []int64
+	failed    map[int64]time.Time
+}
+
+func (o *fakeOutbox) ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]models.Event, error) {
+	n := min(limit, len(o.pending))
+	claimed := o.pending[:n]
+	o.pending = o.pending[n:]
+	for i := range claimed {
+		claimed[i].Attempts++
+	}
+	return claimed, nil
+}
+
+func (o *fakeOutbox) MarkOutboxEventPublished(ctx context.Context, seq int64) error {
+	o.published = append(o.published, seq)
+	return nil
+}
+
+func (o *fakeOutbox) MarkOutboxEventFailed(ctx context.Context, seq int64, retryAt time.Time, lastErr string) error {
+	o.failed[seq] = retryAt
+	return nil
+}
+
+type failingPublisher struct {
+	Publisher
+	failType string
+}
+
+func (p failingPublisher) Publish(ctx context.Context, event models.Event) error {
+	if event.Type == p.failType {
+		return errors.New("unavailable")
+	}
+	return p.Publisher.Publish(ctx, event)
+}
+
+func TestRelayOnce(t *testing.T) {
+	outbox := &fakeOutbox{
+		pending: []models.Event{
+			{Seq: 1, Id: "a", Type: models.EventUserRegistered},
+			{Seq: 2, Id: "b", Type: models.EventAdminGranted, Attempts: 3},
+			{Seq: 3, Id: "c", Type: models.EventUserDeleted},
+		},
+		failed: make(map[int64]time.Time),
+	}
+	memory := NewMemory()
+	relay := NewRelay(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		outbox,
+		failingPublisher{Publisher: memory, failType: models.EventAdminGranted},
+		config.Events{BatchSize: 2, MaxBackoff: time.Minute},
+	)
+
+	before := time.Now()
+	n, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []int64{1}, outbox.published)
+	// fourth attempt failed, retried after 8s
+	assert.WithinDuration(t, before.Add(8*time.Second), outbox.failed[2], time.Second)
+
+	n, err = relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []int64{1, 3}, outbox.published)
+
+	var ids []string
+	for _, event := range memory.Events() {
+		ids = append(ids, event.Id)
+	}
+	assert.Equal(t, []string{"a", "c"}, ids)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, Backoff(0, time.Minute))
+	assert.Equal(t, time.Second, Backoff(1, time.Minute))
+	assert.Equal(t, 4*time.Second, Backoff(3, time.Minute))
+	assert.Equal(t, time.Minute, Backoff(7, time.Minute))
+	assert.Equal(t, time.Minute, Backoff(1000, time.Minute))
+}
@@ -0,0 +1,56 @@
+package events
+
+import (
+	"context"
+	"sso/internal/domain/models"
+	"time"
+)
+
+// watchBatchSize bounds the events read from the log at once per watcher.
+const watchBatchSize = 100
+
+// Log is the event log watchers read from. Events must become visible in
+// seq order, a watcher whose cursor passed an event committed late would
+// never get it.
+type Log interface {
+	ListOutboxEvents(ctx context.Context, afterSeq int64, limit int) ([]models.Event, error)
+}
+
+// Watch calls send for every event after the cursor, in order, until ctx is
+// done, send fails or reading the log fails. It waits for wake-ups from the
+// hub between reads and rereads the log every pollInterval anyway, in case a
+// notification was lost. send blocking applies backpressure to this watcher
+// only.
+func Watch(ctx context.Context, log Log, hub *Hub, cursor Cursor, pollInterval time.Duration, send func(event models.Event, cursor Cursor) error) error {
+	wakeups, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		events, err := log.ListOutboxEvents(ctx, cursor.Seq, watchBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			cursor = CursorAt(event)
+			if err := send(event, cursor); err != nil {
+				return err
+			}
+		}
+		if len(events) == watchBatchSize {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wakeups:
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryLog struct {
+	mu     sync.Mutex
+	events []models.Event
+}
+
+func (l *memoryLog) append(eventType string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	seq := int64(len(l.events) + 1)
+	l.events = append(l.events, models.Event{Seq: seq, Id: eventType, Type: eventType, OccurredAt: time.UnixMicro(seq).UTC()})
+}
+
+func (l *memoryLog) ListOutboxEvents(ctx context.Context, afterSeq int64, limit int) ([]models.Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var events []models.Event
+	for _, event := range l.events {
+		if event.Seq > afterSeq && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func TestCursor(t *testing.T) {
+	cursor := Cursor{Seq: 42, OccurredAt: time.Date(2025, 1, 2, 3, 4, 5, 6000, time.UTC)}
+	parsed, err := ParseCursor(cursor.String())
+	require.NoError(t, err)
+	assert.Equal(t, cursor, parsed)
+
+	for _, value := range []string{"", "42", "x.1", "42.x", "-1.0"} {
+		_, err := ParseCursor(value)
+		assert.ErrorIs(t, err, ErrInvalidCursor, value)
+	}
+}
+
+func TestHubCoalesces(t *testing.T) {
+	hub := NewHub()
+	wakeups, unsubscribe := hub.Subscribe()
+
+	hub.Notify()
+	hub.Notify()
+	<-wakeups
+	select {
+	case <-wakeups:
+		t.Fatal("wake-ups were not coalesced")
+	default:
+	}
+
+	unsubscribe()
+	hub.Notify()
+	assert.Empty(t, wakeups)
+}
+
+var errEnough = errors.New("enough")
+
+func TestWatchResumesAndFollows(t *testing.T) {
+	log := &memoryLog{}
+	for _, eventType := range []string{"a", "b", "c"} {
+		log.append(eventType)
+	}
+	hub := NewHub()
+
+	// resume after b, then follow new events as the hub announces them
+	var got []string
+	var cursors []Cursor
+	done := make(chan error, 1)
+	go func() {
+		done <- Watch(context.Background(), log, hub, Cursor{Seq: 2}, time.Hour, func(event models.Event, cursor Cursor) error {
+			got = append(got, event.Type)
+			cursors = append(cursors, cursor)
+			if len(got) == 2 {
+				return errEnough
+			}
+			if len(got) == 1 {
+				log.append("d")
+				hub.Notify()
+			}
+			return nil
+		})
+	}()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, errEnough)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not follow the new event")
+	}
+	assert.Equal(t, []string{"c", "d"}, got)
+	assert.Equal(t, int64(4), cursors[1].Seq)
+}
+
+func TestWatchStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Watch(ctx, &memoryLog{}, NewHub(), Cursor{}, time.Hour, func(models.Event, Cursor) error { return nil })
+	assert.ErrorIs(t, err, context.Canceled)
+}
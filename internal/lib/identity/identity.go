@@ -0,0 +1,93 @@
+package identity
+
+import (
+	"errors"
+	"golang.org/x/net/idna"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+type Kind int
+
+const (
+	KindEmail Kind = iota
+	KindUsername
+	KindPhone
+)
+
+var (
+	ErrInvalidEmail      = errors.New("invalid email")
+	ErrInvalidUsername   = errors.New("username must be 3-32 characters of letters, digits, '.', '_' or '-' starting with a letter or digit")
+	ErrInvalidPhone      = errors.New("phone must be in E.164 format, e.g. +15551234567")
+	ErrInvalidIdentifier = errors.New("invalid login identifier")
+)
+
+var (
+	usernameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{2,31}$`)
+	phoneRe    = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+	phoneSeparators = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "")
+)
+
+// NormalizeEmail trims and lowercases the address and converts its domain to
+// the IDNA ASCII form, so that equal mailboxes always get the same spelling.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 {
+		return "", ErrInvalidEmail
+	}
+
+	domain, err := idna.Lookup.ToASCII(strings.ToLower(email[at+1:]))
+	if err != nil || !strings.Contains(domain, ".") {
+		return "", ErrInvalidEmail
+	}
+	normalized := strings.ToLower(email[:at]) + "@" + domain
+
+	addr, err := mail.ParseAddress(normalized)
+	if err != nil || addr.Address != normalized || addr.Name != "" {
+		return "", ErrInvalidEmail
+	}
+	return normalized, nil
+}
+
+func NormalizeUsername(username string) (string, error) {
+	username = strings.ToLower(strings.TrimSpace(username))
+	if !usernameRe.MatchString(username) {
+		return "", ErrInvalidUsername
+	}
+	return username, nil
+}
+
+// NormalizePhone strips common separators and checks the E.164 format.
+func NormalizePhone(phone string) (string, error) {
+	phone = phoneSeparators.Replace(strings.TrimSpace(phone))
+	if !phoneRe.MatchString(phone) {
+		return "", ErrInvalidPhone
+	}
+	return phone, nil
+}
+
+// Parse detects what kind of login identifier was given and normalizes it:
+// anything with '@' is an email, a leading '+' means a phone number and
+// everything else is a username.
+func Parse(identifier string) (Kind, string, error) {
+	identifier = strings.TrimSpace(identifier)
+
+	switch {
+	case strings.Contains(identifier, "@"):
+		email, err := NormalizeEmail(identifier)
+		return KindEmail, email, err
+	case strings.HasPrefix(identifier, "+"):
+		phone, err := NormalizePhone(identifier)
+		return KindPhone, phone, err
+	default:
+		username, err := NormalizeUsername(identifier)
+		if err != nil {
+			return KindUsername, "", ErrInvalidIdentifier
+		}
+		return KindUsername, username, nil
+	}
+}
@@ -0,0 +1,68 @@
+package identity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		expected string
+	}{
+		{name: "Already normalized", email: "bob@x.com", expected: "bob@x.com"},
+		{name: "Mixed case", email: "Bob@X.Com", expected: "bob@x.com"},
+		{name: "Surrounding spaces", email: "  bob@x.com\t", expected: "bob@x.com"},
+		{name: "Unicode domain", email: "bob@Bücher.example", expected: "bob@xn--bcher-kva.example"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email, err := NormalizeEmail(tt.email)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, email)
+		})
+	}
+}
+
+func TestNormalizeEmail_Invalid(t *testing.T) {
+	for _, email := range []string{"", "bob", "@x.com", "bob@", "bob@localhost", "bob smith@x.com", "Bob <bob@x.com>", "bob@x..com"} {
+		t.Run(email, func(t *testing.T) {
+			_, err := NormalizeEmail(email)
+			assert.ErrorIs(t, err, ErrInvalidEmail)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		identifier string
+		kind       Kind
+		expected   string
+	}{
+		{identifier: "Bob@X.com", kind: KindEmail, expected: "bob@x.com"},
+		{identifier: "+1 (555) 123-4567", kind: KindPhone, expected: "+15551234567"},
+		{identifier: "Bob_Smith", kind: KindUsername, expected: "bob_smith"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.identifier, func(t *testing.T) {
+			kind, value, err := Parse(tt.identifier)
+			require.NoError(t, err)
+			assert.Equal(t, tt.kind, kind)
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, identifier := range []string{"", "ab", "+12", "bob@", "-bob", "bob smith"} {
+		t.Run(identifier, func(t *testing.T) {
+			_, _, err := Parse(identifier)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -1,17 +1,60 @@
 package jwt
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"sso/internal/domain/models"
 	"time"
 )
 
-func NewToken(user *models.User, app *models.App, duration time.Duration) (string, error) {
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims are the claims issued by NewToken.
+type Claims struct {
+	UserId    int64
+	Email     string
+	AppId     int64
+	SessionId string
+	AuthTime  time.Time
+	ExpiresAt time.Time
+}
+
+// reservedClaims can not be overridden by app claim rules.
+var reservedClaims = map[string]bool{
+	"user_id": true, "email": true, "app_id": true, "sid": true, "auth_time": true,
+	"exp": true, "iat": true, "nbf": true, "iss": true, "sub": true, "aud": true, "jti": true,
+}
+
+// IsReservedClaim reports whether claim is set by NewToken itself.
+func IsReservedClaim(claim string) bool {
+	return reservedClaims[claim]
+}
+
+// NewToken issues a token for the user bound to the session. Besides the
+// standard claims it adds the profile attributes selected by the app claim
+// rules; profile may be nil when the app has no rules.
+func NewToken(user *models.User, app *models.App, session *models.Session, profile *models.Profile, metadata json.RawMessage, duration time.Duration) (string, error) {
 	token := jwt.New(jwt.SigningMethodHS256)
 	claims := token.Claims.(jwt.MapClaims)
+
+	if profile != nil {
+		for _, rule := range app.ClaimRules {
+			if IsReservedClaim(rule.Claim) {
+				continue
+			}
+			if value, ok := profile.Attribute(rule.Attribute, metadata); ok {
+				claims[rule.Claim] = value
+			}
+		}
+	}
+
 	claims["user_id"] = user.Id
 	claims["email"] = user.Email
 	claims["app_id"] = app.Id
+	claims["sid"] = session.Id
+	claims["auth_time"] = session.AuthenticatedAt.Unix()
 	claims["exp"] = time.Now().Add(duration).Unix()
 
 	tokenString, err := token.SignedString([]byte(app.Secret))
@@ -20,3 +63,51 @@ func NewToken(user *models.User, app *models.App, duration time.Duration) (strin
 	}
 	return tokenString, nil
 }
+
+// AppId extracts the app id from a token without verifying it, so that the
+// secret to verify the token with can be looked up.
+func AppId(tokenString string) (int64, error) {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	appId, ok := claims["app_id"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("%w: app_id claim is missing", ErrInvalidToken)
+	}
+	return int64(appId), nil
+}
+
+// ParseToken verifies the token signature and expiration with the app secret.
+func ParseToken(tokenString string, app *models.App) (*Claims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	).ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(app.Secret), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	userId, okUser := claims["user_id"].(float64)
+	appId, okApp := claims["app_id"].(float64)
+	email, _ := claims["email"].(string)
+	sessionId, _ := claims["sid"].(string)
+	authTime, _ := claims["auth_time"].(float64)
+	exp, err := claims.GetExpirationTime()
+	if !okUser || !okApp || err != nil || int64(appId) != app.Id {
+		return nil, fmt.Errorf("%w: unexpected claims", ErrInvalidToken)
+	}
+
+	return &Claims{
+		UserId:    int64(userId),
+		Email:     email,
+		AppId:     int64(appId),
+		SessionId: sessionId,
+		AuthTime:  time.Unix(int64(authTime), 0),
+		ExpiresAt: exp.Time,
+	}, nil
+}
@@ -0,0 +1,87 @@
+package jwt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sso/internal/domain/models"
+)
+
+func TestParseToken_HappyPath(t *testing.T) {
+	user := &models.User{Id: 7, Email: "user@example.com"}
+	session := &models.Session{Id: "session-1", AuthenticatedAt: time.Now().Add(-time.Minute)}
+	app := &models.App{Id: 3, Secret: "secret"}
+
+	token, err := NewToken(user, app, session, nil, nil, time.Hour)
+	require.NoError(t, err)
+
+	appId, err := AppId(token)
+	require.NoError(t, err)
+	assert.Equal(t, app.Id, appId)
+
+	claims, err := ParseToken(token, app)
+	require.NoError(t, err)
+	assert.Equal(t, user.Id, claims.UserId)
+	assert.Equal(t, user.Email, claims.Email)
+	assert.Equal(t, app.Id, claims.AppId)
+	assert.Equal(t, "session-1", claims.SessionId)
+	assert.Equal(t, session.AuthenticatedAt.Unix(), claims.AuthTime.Unix())
+	assert.WithinDuration(t, time.Now().Add(time.Hour), claims.ExpiresAt, 2*time.Second)
+}
+
+func TestParseToken_FailCases(t *testing.T) {
+	user := &models.User{Id: 7, Email: "user@example.com"}
+	session := &models.Session{Id: "session-1", AuthenticatedAt: time.Now().Add(-time.Minute)}
+	app := &models.App{Id: 3, Secret: "secret"}
+
+	valid, err := NewToken(user, app, session, nil, nil, time.Hour)
+	require.NoError(t, err)
+	expired, err := NewToken(user, app, session, nil, nil, -time.Minute)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		token string
+		app   *models.App
+	}{
+		{name: "Wrong secret", token: valid, app: &models.App{Id: 3, Secret: "other"}},
+		{name: "Other app", token: valid, app: &models.App{Id: 4, Secret: "secret"}},
+		{name: "Expired", token: expired, app: app},
+		{name: "Garbage", token: "not-a-token", app: app},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseToken(tt.token, tt.app)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestNewToken_ClaimRules(t *testing.T) {
+	user := &models.User{Id: 7, Email: "user@example.com"}
+	session := &models.Session{Id: "session-1", AuthenticatedAt: time.Now().Add(-time.Minute)}
+	app := &models.App{Id: 3, Secret: "secret", ClaimRules: []models.ClaimRule{
+		{Claim: "name", Attribute: models.AttributeDisplayName},
+		{Claim: "tz", Attribute: models.AttributeTimezone},
+		{Claim: "plan", Attribute: "metadata.plan"},
+		{Claim: "email", Attribute: models.AttributeDisplayName},
+	}}
+	profile := &models.Profile{UserId: 7, DisplayName: "Bob"}
+
+	token, err := NewToken(user, app, session, profile, []byte(`{"plan":"pro","quota":5}`), time.Hour)
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(token, claims)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Bob", claims["name"])
+	assert.Equal(t, "pro", claims["plan"])
+	assert.NotContains(t, claims, "tz", "empty attributes are not put into the token")
+	assert.NotContains(t, claims, "quota", "only mapped metadata keys are put into the token")
+	assert.Equal(t, user.Email, claims["email"], "rules can not override reserved claims")
+}
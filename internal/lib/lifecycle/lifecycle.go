@@ -0,0 +1,155 @@
+// Package lifecycle starts the components of the application in order and
+// stops them in reverse, within a bounded shutdown window.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/lib/logger/sl"
+	"sync"
+	"time"
+)
+
+// forcedStopTimeout is how long components get to return from Run once the
+// shutdown window is over.
+const forcedStopTimeout = time.Second
+
+// ErrStart marks errors of components that failed to start.
+var ErrStart = errors.New("failed to start")
+
+// Component is a part of the application with its own resources. Stop is
+// called for every added component, also when it was never started or its
+// Start failed, so it must tolerate both.
+type Component struct {
+	Name string
+	// Start returns once the component is ready, e.g. listening. Optional.
+	Start func() error
+	// Run serves in the background until Stop, an error stops the whole
+	// application. Optional.
+	Run func() error
+	// Stop releases the component. It stops gracefully until ctx is done and
+	// is expected to force its way out soon after. Optional.
+	Stop func(ctx context.Context) error
+}
+
+type Manager struct {
+	log             *slog.Logger
+	shutdownTimeout time.Duration
+	components      []Component
+	failed          chan error
+	running         sync.WaitGroup
+	shutdown        sync.Once
+	shutdownErr     error
+}
+
+// New returns a manager that gives components shutdownTimeout in total to
+// stop gracefully.
+func New(log *slog.Logger, shutdownTimeout time.Duration) *Manager {
+	return &Manager{
+		log:             log,
+		shutdownTimeout: shutdownTimeout,
+		failed:          make(chan error, 1),
+	}
+}
+
+// Add appends c to the components, it starts after and stops before the
+// ones added earlier.
+func (m *Manager) Add(c Component) {
+	m.components = append(m.components, c)
+}
+
+// Run starts the components in order and blocks until ctx is done or one of
+// them fails, then shuts all of them down. A start failure is returned
+// wrapping ErrStart, later components are not started then.
+func (m *Manager) Run(ctx context.Context) error {
+	const op = "lifecycle.Manager.Run"
+	log := m.log.With(slog.String("operation", op))
+
+	for _, c := range m.components {
+		if c.Start == nil {
+			continue
+		}
+		if err := c.Start(); err != nil {
+			log.Error("component failed to start", slog.String("component", c.Name), sl.Err(err))
+			return errors.Join(fmt.Errorf("%s:%w: %s: %w", op, ErrStart, c.Name, err), m.Shutdown())
+		}
+	}
+
+	for _, c := range m.components {
+		if c.Run == nil {
+			continue
+		}
+		m.running.Add(1)
+		go func() {
+			defer m.running.Done()
+			if err := c.Run(); err != nil {
+				select {
+				case m.failed <- fmt.Errorf("%s: %s: %w", op, c.Name, err):
+				default:
+				}
+			}
+		}()
+	}
+	log.Info("all components started", slog.Int("count", len(m.components)))
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-m.failed:
+		log.Error("component failed, shutting down", sl.Err(err))
+	}
+
+	return errors.Join(err, m.Shutdown())
+}
+
+// Shutdown stops the components in reverse order. They share one graceful
+// window, components left when it is over are stopped forcibly. Calls after
+// the first return its result.
+func (m *Manager) Shutdown() error {
+	m.shutdown.Do(func() {
+		m.shutdownErr = m.stop()
+	})
+	return m.shutdownErr
+}
+
+func (m *Manager) stop() error {
+	const op = "lifecycle.Manager.Shutdown"
+	log := m.log.With(slog.String("operation", op))
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.shutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(m.components) - 1; i >= 0; i-- {
+		c := m.components[i]
+		if c.Stop == nil {
+			continue
+		}
+		if err := c.Stop(ctx); err != nil {
+			log.Error("component failed to stop", slog.String("component", c.Name), sl.Err(err))
+			errs = append(errs, fmt.Errorf("%s: %s: %w", op, c.Name, err))
+		}
+	}
+	if ctx.Err() != nil {
+		log.Warn("graceful shutdown window exceeded", slog.Duration("timeout", m.shutdownTimeout))
+	}
+
+	// stopped components return from Run, a stuck one is not waited for
+	done := make(chan struct{})
+	go func() {
+		m.running.Wait()
+		close(done)
+	}()
+	deadline, _ := ctx.Deadline()
+	wait := time.NewTimer(time.Until(deadline) + forcedStopTimeout)
+	defer wait.Stop()
+	select {
+	case <-done:
+	case <-wait.C:
+		errs = append(errs, fmt.Errorf("%s: components still running after the shutdown window", op))
+	}
+
+	return errors.Join(errs...)
+}
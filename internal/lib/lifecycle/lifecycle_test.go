@@ -0,0 +1,149 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder collects the calls made to components.
+type recorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *recorder) add(call string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func (r *recorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+// server is a component that runs until stopped.
+func server(name string, rec *recorder) Component {
+	stopped := make(chan struct{})
+	return Component{
+		Name: name,
+		Start: func() error {
+			rec.add("start " + name)
+			return nil
+		},
+		Run: func() error {
+			<-stopped
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			rec.add("stop " + name)
+			close(stopped)
+			return nil
+		},
+	}
+}
+
+func newManager(timeout time.Duration) *Manager {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), timeout)
+}
+
+func TestRun_StopsInReverse(t *testing.T) {
+	rec := &recorder{}
+	m := newManager(time.Second)
+	m.Add(Component{Name: "storage", Stop: func(ctx context.Context) error {
+		rec.add("stop storage")
+		return nil
+	}})
+	m.Add(server("grpc", rec))
+	m.Add(server("gateway", rec))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	require.NoError(t, m.Run(ctx))
+
+	assert.Equal(t, []string{"start grpc", "start gateway", "stop gateway", "stop grpc", "stop storage"}, rec.get())
+}
+
+func TestRun_StartFails(t *testing.T) {
+	rec := &recorder{}
+	m := newManager(time.Second)
+	m.Add(server("grpc", rec))
+	m.Add(Component{
+		Name:  "gateway",
+		Start: func() error { return errors.New("address in use") },
+		Stop: func(ctx context.Context) error {
+			rec.add("stop gateway")
+			return nil
+		},
+	})
+	m.Add(server("metrics", rec))
+
+	err := m.Run(context.Background())
+	require.ErrorIs(t, err, ErrStart)
+	assert.ErrorContains(t, err, "gateway: address in use")
+	assert.Equal(t, []string{"start grpc", "stop metrics", "stop gateway", "stop grpc"}, rec.get())
+}
+
+func TestRun_ComponentFails(t *testing.T) {
+	rec := &recorder{}
+	m := newManager(time.Second)
+	m.Add(server("grpc", rec))
+	m.Add(Component{Name: "metrics", Run: func() error { return errors.New("listener closed") }})
+
+	err := m.Run(context.Background())
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrStart)
+	assert.ErrorContains(t, err, "metrics: listener closed")
+	assert.Equal(t, []string{"start grpc", "stop grpc"}, rec.get())
+}
+
+func TestShutdown_Window(t *testing.T) {
+	m := newManager(50 * time.Millisecond)
+	var remaining []time.Duration
+	stop := func(ctx context.Context) error {
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		remaining = append(remaining, time.Until(deadline))
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	m.Add(Component{Name: "first", Stop: stop})
+	m.Add(Component{Name: "second", Stop: stop})
+
+	start := time.Now()
+	err := m.Shutdown()
+	assert.Less(t, time.Since(start), time.Second)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	// the window is shared, the component stopped last gets what is left
+	require.Len(t, remaining, 2)
+	assert.LessOrEqual(t, remaining[1], time.Duration(0))
+
+	assert.Equal(t, err, m.Shutdown())
+}
+
+func TestShutdown_StuckComponent(t *testing.T) {
+	stuck := make(chan struct{})
+	defer close(stuck)
+	m := newManager(10 * time.Millisecond)
+	m.Add(Component{Name: "stuck", Run: func() error {
+		<-stuck
+		return nil
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := m.Run(ctx)
+	assert.ErrorContains(t, err, "still running")
+}
@@ -1,7 +1,26 @@
 package sl
 
-import "log/slog"
+import (
+	"context"
+	"log/slog"
+)
 
 func Err(err error) slog.Attr {
 	return slog.String("error", err.Error())
 }
+
+type loggerKey struct{}
+
+// NewContext attaches a request scoped logger to ctx.
+func NewContext(ctx context.Context, log *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, log)
+}
+
+// FromContext returns the logger attached with NewContext, or fallback when
+// ctx has none.
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if log, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return log
+	}
+	return fallback
+}
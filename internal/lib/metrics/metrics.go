@@ -0,0 +1,135 @@
+// Package metrics exports the service metrics in the Prometheus format:
+// RPC latencies and status codes, auth outcomes and the database pool.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/codes"
+)
+
+const namespace = "sso"
+
+// unknownApp labels attempts for app ids that do not exist, so bogus ids
+// from clients can not blow up the number of series.
+const unknownApp = "unknown"
+
+type Metrics struct {
+	registry      *prometheus.Registry
+	rpcLatency    *prometheus.HistogramVec
+	rpcCodes      *prometheus.CounterVec
+	logins        *prometheus.CounterVec
+	registrations prometheus.Counter
+	disabledLogin prometheus.Counter
+	tokens        *prometheus.CounterVec
+}
+
+// DB is the source of the connection pool stats, e.g. *sql.DB.
+type DB interface {
+	Stats() sql.DBStats
+}
+
+// New creates a registry with the service metrics and the Go runtime and
+// process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		rpcLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "grpc",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of gRPC requests by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+		rpcCodes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "grpc",
+			Name:      "requests_total",
+			Help:      "gRPC requests by method and status code.",
+		}, []string{"method", "code"}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "auth",
+			Name:      "logins_total",
+			Help:      "Login attempts by app and outcome.",
+		}, []string{"app", "outcome"}),
+		registrations: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "auth",
+			Name:      "registrations_total",
+			Help:      "Registered users.",
+		}),
+		disabledLogin: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "auth",
+			Name:      "logins_rejected_disabled_total",
+			Help:      "Logins refused because the user is disabled.",
+		}),
+		tokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "auth",
+			Name:      "tokens_issued_total",
+			Help:      "Issued tokens by app.",
+		}, []string{"app"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.rpcLatency,
+		m.rpcCodes,
+		m.logins,
+		m.registrations,
+		m.disabledLogin,
+		m.tokens,
+	)
+	return m
+}
+
+// Handler serves the registered metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveRPC records a finished RPC.
+func (m *Metrics) ObserveRPC(method string, code codes.Code, latency time.Duration) {
+	m.rpcLatency.WithLabelValues(method).Observe(latency.Seconds())
+	m.rpcCodes.WithLabelValues(method, code.String()).Inc()
+}
+
+// LoginAttempt counts a login to the app, appId 0 stands for an app that
+// does not exist.
+func (m *Metrics) LoginAttempt(appId int64, outcome string) {
+	m.logins.WithLabelValues(appLabel(appId), outcome).Inc()
+}
+
+func (m *Metrics) Registered() {
+	m.registrations.Inc()
+}
+
+// LoginRejectedDisabled counts a login refused because the user is disabled.
+func (m *Metrics) LoginRejectedDisabled() {
+	m.disabledLogin.Inc()
+}
+
+func (m *Metrics) TokenIssued(appId int64) {
+	m.tokens.WithLabelValues(appLabel(appId)).Inc()
+}
+
+// RegisterDB exports the connection pool stats of db.
+func (m *Metrics) RegisterDB(db DB) {
+	m.registry.MustRegister(newDBCollector(db))
+}
+
+func appLabel(appId int64) string {
+	if appId == 0 {
+		return unknownApp
+	}
+	return strconv.FormatInt(appId, 10)
+}
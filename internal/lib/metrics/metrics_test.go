@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+type fakeDB struct {
+	stats sql.DBStats
+}
+
+func (db *fakeDB) Stats() sql.DBStats {
+	return db.stats
+}
+
+func TestRPC(t *testing.T) {
+	m := New()
+	m.ObserveRPC("/auth.Auth/Login", codes.OK, 20*time.Millisecond)
+	m.ObserveRPC("/auth.Auth/Login", codes.OK, 30*time.Millisecond)
+	m.ObserveRPC("/auth.Auth/Login", codes.Unauthenticated, time.Millisecond)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.rpcCodes.WithLabelValues("/auth.Auth/Login", "OK")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.rpcCodes.WithLabelValues("/auth.Auth/Login", "Unauthenticated")))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.rpcLatency))
+}
+
+func TestAuth(t *testing.T) {
+	m := New()
+	m.LoginAttempt(1, "success")
+	m.LoginAttempt(1, "password_mismatch")
+	m.LoginAttempt(0, "user_not_found")
+	m.Registered()
+	m.LoginRejectedDisabled()
+	m.TokenIssued(1)
+	m.TokenIssued(1)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.logins.WithLabelValues("1", "success")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.logins.WithLabelValues("1", "password_mismatch")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.logins.WithLabelValues(unknownApp, "user_not_found")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.registrations))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.disabledLogin))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.tokens.WithLabelValues("1")))
+}
+
+func TestHandler(t *testing.T) {
+	m := New()
+	m.RegisterDB(&fakeDB{stats: sql.DBStats{MaxOpenConnections: 10, OpenConnections: 3, InUse: 1, Idle: 2, WaitDuration: 2 * time.Second}})
+	m.ObserveRPC("/auth.Auth/Register", codes.OK, time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, 200, rec.Code)
+
+	body := rec.Body.String()
+	for _, line := range []string{
+		"sso_db_max_open_connections 10",
+		"sso_db_open_connections 3",
+		"sso_db_in_use_connections 1",
+		"sso_db_idle_connections 2",
+		"sso_db_wait_duration_seconds_total 2",
+		`sso_grpc_requests_total{code="OK",method="/auth.Auth/Register"} 1`,
+	} {
+		assert.True(t, strings.Contains(body, line+"\n"), "missing %q", line)
+	}
+	assert.Contains(t, body, "go_goroutines")
+}
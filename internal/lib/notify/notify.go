@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"sso/internal/config"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SenderLog  = "log"
+	SenderSMTP = "smtp"
+)
+
+var (
+	ErrUnknownSender = errors.New("unknown notification sender")
+	ErrInvalidConfig = errors.New("invalid notification config")
+)
+
+// Message is a notification for a user.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers notifications to users.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the sender selected by cfg.Sender.
+func New(log *slog.Logger, cfg config.Notify) (Sender, error) {
+	switch cfg.Sender {
+	case SenderLog:
+		return &LogSender{log: log}, nil
+	case SenderSMTP:
+		if cfg.SMTPHost == "" || cfg.From == "" {
+			return nil, fmt.Errorf("%w: smtp_host and from are required", ErrInvalidConfig)
+		}
+		return NewSMTPSender(cfg), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSender, cfg.Sender)
+	}
+}
+
+// LogSender only logs notifications, it is meant for development.
+type LogSender struct {
+	log *slog.Logger
+}
+
+func (s *LogSender) Send(ctx context.Context, msg Message) error {
+	s.log.Info("notification",
+		slog.String("to", msg.To),
+		slog.String("subject", msg.Subject),
+		slog.String("body", msg.Body),
+	)
+	return nil
+}
+
+// SMTPSender sends notifications as plain text emails.
+type SMTPSender struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTPSender(cfg config.Notify) *SMTPSender {
+	s := &SMTPSender{
+		addr: net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+		from: cfg.From,
+	}
+	if cfg.SMTPUsername != "" {
+		s.auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return s
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	const op = "notify.SMTPSender.Send"
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{msg.To}, buildMail(s.from, msg, time.Now())); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
+
+// buildMail formats msg as an RFC 5322 message. Header values are stripped of
+// line breaks so that they cannot inject headers.
+func buildMail(from string, msg Message, date time.Time) []byte {
+	header := strings.NewReplacer("\r", "", "\n", "")
+	var b strings.Builder
+	b.WriteString("From: " + header.Replace(from) + "\r\n")
+	b.WriteString("To: " + header.Replace(msg.To) + "\r\n")
+	b.WriteString("Subject: " + header.Replace(msg.Subject) + "\r\n")
+	b.WriteString("Date: " + date.Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"io"
+	"log/slog"
+	"sso/internal/config"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	sender, err := New(log, config.Notify{Sender: SenderLog})
+	require.NoError(t, err)
+	assert.IsType(t, &LogSender{}, sender)
+
+	sender, err = New(log, config.Notify{Sender: SenderSMTP, SMTPHost: "smtp.example.com", SMTPPort: 587, From: "sso@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "smtp.example.com:587", sender.(*SMTPSender).addr)
+
+	_, err = New(log, config.Notify{Sender: SenderSMTP})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+
+	_, err = New(log, config.Notify{Sender: "pigeon"})
+	assert.ErrorIs(t, err, ErrUnknownSender)
+}
+
+func TestBuildMail(t *testing.T) {
+	date := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	mail := string(buildMail("sso@example.com", Message{
+		To:      "user@example.com",
+		Subject: "New login\r\nBcc: attacker@example.com",
+		Body:    "line one\nline two",
+	}, date))
+
+	headers, body, ok := strings.Cut(mail, "\r\n\r\n")
+	require.True(t, ok)
+	assert.Contains(t, headers, "To: user@example.com\r\n")
+	assert.Contains(t, headers, "Subject: New loginBcc: attacker@example.com\r\n")
+	assert.NotContains(t, headers, "\r\nBcc:")
+	assert.Contains(t, headers, "Date: Thu, 02 Jan 2025 03:04:05 +0000\r\n")
+	assert.Equal(t, "line one\r\nline two", body)
+}
@@ -0,0 +1,349 @@
+package password
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// Formats accepted by ImportHash. Hashes in these formats are verified on
+// login and then replaced with a hash made by the configured algorithm.
+const (
+	// FormatSaltedSHA256 is hex(sha256(salt + password)).
+	FormatSaltedSHA256 = "sha256-salted"
+	// FormatSHA256Salted is hex(sha256(password + salt)).
+	FormatSHA256Salted = "sha256-salted-suffix"
+	// FormatPBKDF2 is a PHC string: $pbkdf2-sha256$i=<iterations>$<salt>$<hash>
+	// with sha1, sha256 or sha512 and unpadded base64 salt and hash.
+	FormatPBKDF2 = "pbkdf2"
+	// FormatMD5Crypt is the $1$salt$hash format of crypt(3).
+	FormatMD5Crypt = "md5-crypt"
+	// FormatDjango is <algorithm>$<iterations>$<salt>$<hash> as stored by
+	// Django with pbkdf2_sha256 or pbkdf2_sha1.
+	FormatDjango = "django"
+	// FormatASPNetIdentity is the base64 blob of ASP.NET Identity v2 or v3.
+	FormatASPNetIdentity = "aspnet-identity"
+)
+
+// Prefixes of legacy hashes in storage.
+const (
+	saltedSHA256Prefix = "$sha256-salted$"
+	pbkdf2Prefix       = "$pbkdf2-"
+	md5CryptPrefix     = "$1$"
+	djangoSHA256Prefix = "pbkdf2_sha256$"
+	djangoSHA1Prefix   = "pbkdf2_sha1$"
+	aspNetPrefix       = "$aspnet-identity$"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported password hash format")
+
+// ImportHash converts a hash exported from another system into the stored
+// representation, which records everything needed to verify it. Native
+// argon2id, scrypt and bcrypt hashes are kept as they are, argon2id and
+// scrypt ones only with parameters within the limits that Verify accepts.
+// Only the structure is checked, verifying every imported hash would make
+// bulk imports too slow.
+func ImportHash(format string, encoded string, salt string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+
+	switch format {
+	case FormatSaltedSHA256, FormatSHA256Salted:
+		digest, err := hex.DecodeString(encoded)
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("%w: sha256 digest must be 64 hex characters", ErrMalformedHash)
+		}
+		position := "prefix"
+		if format == FormatSHA256Salted {
+			position = "suffix"
+		}
+		return []byte(fmt.Sprintf("%spos=%s$%s$%s", saltedSHA256Prefix, position, b64.EncodeToString([]byte(salt)), b64.EncodeToString(digest))), nil
+	case FormatPBKDF2:
+		parts := strings.Split(encoded, "$")
+		if len(parts) != 5 || !strings.HasPrefix(encoded, pbkdf2Prefix) {
+			return nil, fmt.Errorf("%w: expected $pbkdf2-<digest>$i=<iterations>$<salt>$<hash>", ErrMalformedHash)
+		}
+		if _, err := pbkdf2Hash(strings.TrimPrefix(parts[1], "pbkdf2-")); err != nil {
+			return nil, err
+		}
+	case FormatMD5Crypt:
+		if parts := strings.Split(encoded, "$"); len(parts) != 4 || !strings.HasPrefix(encoded, md5CryptPrefix) {
+			return nil, fmt.Errorf("%w: expected $1$<salt>$<hash>", ErrMalformedHash)
+		}
+	case FormatDjango:
+		parts := strings.Split(encoded, "$")
+		if len(parts) != 4 || !(strings.HasPrefix(encoded, djangoSHA256Prefix) || strings.HasPrefix(encoded, djangoSHA1Prefix)) {
+			return nil, fmt.Errorf("%w: expected pbkdf2_sha256$<iterations>$<salt>$<hash>", ErrMalformedHash)
+		}
+	case FormatASPNetIdentity:
+		blob, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(blob) == 0 || blob[0] > 0x01 {
+			return nil, fmt.Errorf("%w: expected a base64 ASP.NET Identity v2 or v3 hash", ErrMalformedHash)
+		}
+		return []byte(aspNetPrefix + encoded), nil
+	case Argon2id:
+		if !strings.HasPrefix(encoded, "$"+format+"$") {
+			return nil, fmt.Errorf("%w: expected a %s PHC string", ErrMalformedHash, format)
+		}
+		if _, _, _, err := parseArgon2id(encoded); err != nil {
+			return nil, err
+		}
+	case Scrypt:
+		if !strings.HasPrefix(encoded, "$"+format+"$") {
+			return nil, fmt.Errorf("%w: expected a %s PHC string", ErrMalformedHash, format)
+		}
+		if _, _, _, err := parseScrypt(encoded); err != nil {
+			return nil, err
+		}
+	case Bcrypt:
+		if _, err := bcrypt.Cost([]byte(encoded)); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+	return []byte(encoded), nil
+}
+
+// verifyLegacy checks password against a hash imported from another system.
+// It returns ErrUnknownAlgorithm for anything that is not a legacy format.
+func verifyLegacy(encoded string, password string) error {
+	var ok bool
+	var err error
+
+	switch {
+	case strings.HasPrefix(encoded, saltedSHA256Prefix):
+		ok, err = verifySaltedSHA256(encoded, password)
+	case strings.HasPrefix(encoded, pbkdf2Prefix):
+		ok, err = verifyPBKDF2(encoded, password)
+	case strings.HasPrefix(encoded, md5CryptPrefix):
+		ok, err = verifyMD5Crypt(encoded, password)
+	case strings.HasPrefix(encoded, djangoSHA256Prefix), strings.HasPrefix(encoded, djangoSHA1Prefix):
+		ok, err = verifyDjango(encoded, password)
+	case strings.HasPrefix(encoded, aspNetPrefix):
+		ok, err = verifyASPNetIdentity(strings.TrimPrefix(encoded, aspNetPrefix), password)
+	default:
+		return ErrUnknownAlgorithm
+	}
+
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrMismatch
+	}
+	return nil
+}
+
+func verifySaltedSHA256(encoded string, password string) (bool, error) {
+	// $sha256-salted$pos=prefix$salt$digest
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 || (parts[2] != "pos=prefix" && parts[2] != "pos=suffix") {
+		return false, ErrMalformedHash
+	}
+	salt, digest, err := decodeSaltAndKey(parts[3], parts[4])
+	if err != nil {
+		return false, err
+	}
+
+	var actual [sha256.Size]byte
+	if parts[2] == "pos=prefix" {
+		actual = sha256.Sum256(append(salt, password...))
+	} else {
+		actual = sha256.Sum256(append([]byte(password), salt...))
+	}
+	return subtle.ConstantTimeCompare(actual[:], digest) == 1, nil
+}
+
+func verifyPBKDF2(encoded string, password string) (bool, error) {
+	// $pbkdf2-sha256$i=10000$salt$hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 {
+		return false, ErrMalformedHash
+	}
+	hashFunc, err := pbkdf2Hash(strings.TrimPrefix(parts[1], "pbkdf2-"))
+	if err != nil {
+		return false, err
+	}
+	var iterations int
+	if _, err := fmt.Sscanf(parts[2], "i=%d", &iterations); err != nil || iterations <= 0 {
+		return false, ErrMalformedHash
+	}
+	salt, key, err := decodeSaltAndKey(parts[3], parts[4])
+	if err != nil {
+		return false, err
+	}
+
+	actual := pbkdf2.Key([]byte(password), salt, iterations, len(key), hashFunc)
+	return subtle.ConstantTimeCompare(actual, key) == 1, nil
+}
+
+func verifyDjango(encoded string, password string) (bool, error) {
+	// pbkdf2_sha256$260000$salt$base64hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 {
+		return false, ErrMalformedHash
+	}
+	hashFunc, err := pbkdf2Hash(strings.TrimPrefix(parts[0], "pbkdf2_"))
+	if err != nil {
+		return false, err
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false, ErrMalformedHash
+	}
+	key, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return false, ErrMalformedHash
+	}
+
+	actual := pbkdf2.Key([]byte(password), []byte(parts[2]), iterations, len(key), hashFunc)
+	return subtle.ConstantTimeCompare(actual, key) == 1, nil
+}
+
+func verifyASPNetIdentity(encoded string, password string) (bool, error) {
+	blob, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(blob) == 0 {
+		return false, ErrMalformedHash
+	}
+
+	var hashFunc func() hash.Hash
+	var iterations int
+	var salt, key []byte
+
+	switch blob[0] {
+	case 0x00:
+		// v2: PBKDF2 with HMAC-SHA1, 1000 iterations, 128-bit salt, 256-bit subkey
+		if len(blob) != 1+16+32 {
+			return false, ErrMalformedHash
+		}
+		hashFunc, iterations, salt, key = sha1.New, 1000, blob[1:17], blob[17:]
+	case 0x01:
+		// v3: prf, iteration count and salt length as big-endian uint32
+		if len(blob) < 13 {
+			return false, ErrMalformedHash
+		}
+		prf := binary.BigEndian.Uint32(blob[1:5])
+		iterations = int(binary.BigEndian.Uint32(blob[5:9]))
+		saltLen := int(binary.BigEndian.Uint32(blob[9:13]))
+		if saltLen < 8 || 13+saltLen >= len(blob) || iterations <= 0 {
+			return false, ErrMalformedHash
+		}
+		switch prf {
+		case 0:
+			hashFunc = sha1.New
+		case 1:
+			hashFunc = sha256.New
+		case 2:
+			hashFunc = sha512.New
+		default:
+			return false, ErrMalformedHash
+		}
+		salt, key = blob[13:13+saltLen], blob[13+saltLen:]
+	default:
+		return false, ErrMalformedHash
+	}
+
+	actual := pbkdf2.Key([]byte(password), salt, iterations, len(key), hashFunc)
+	return subtle.ConstantTimeCompare(actual, key) == 1, nil
+}
+
+func pbkdf2Hash(name string) (func() hash.Hash, error) {
+	switch name {
+	case "sha1":
+		return sha1.New, nil
+	case "sha256":
+		return sha256.New, nil
+	case "sha512":
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("%w: pbkdf2 with %q", ErrUnsupportedFormat, name)
+	}
+}
+
+const md5CryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+func verifyMD5Crypt(encoded string, password string) (bool, error) {
+	// $1$salt$hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || len(parts[2]) > 8 || len(parts[3]) != 22 {
+		return false, ErrMalformedHash
+	}
+
+	actual := md5Crypt([]byte(password), []byte(parts[2]))
+	return subtle.ConstantTimeCompare([]byte(actual), []byte(encoded)) == 1, nil
+}
+
+// md5Crypt implements the FreeBSD MD5-based crypt(3).
+func md5Crypt(password []byte, salt []byte) string {
+	alternate := md5.New()
+	alternate.Write(password)
+	alternate.Write(salt)
+	alternate.Write(password)
+	altSum := alternate.Sum(nil)
+
+	ctx := md5.New()
+	ctx.Write(password)
+	ctx.Write([]byte(md5CryptPrefix))
+	ctx.Write(salt)
+	for i := len(password); i > 0; i -= md5.Size {
+		ctx.Write(altSum[:min(i, md5.Size)])
+	}
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 == 1 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write(password[:1])
+		}
+	}
+	sum := ctx.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		round := md5.New()
+		if i&1 == 1 {
+			round.Write(password)
+		} else {
+			round.Write(sum)
+		}
+		if i%3 != 0 {
+			round.Write(salt)
+		}
+		if i%7 != 0 {
+			round.Write(password)
+		}
+		if i&1 == 1 {
+			round.Write(sum)
+		} else {
+			round.Write(password)
+		}
+		sum = round.Sum(nil)
+	}
+
+	var out strings.Builder
+	out.WriteString(md5CryptPrefix)
+	out.Write(salt)
+	out.WriteByte('$')
+	to64 := func(v uint32, n int) {
+		for ; n > 0; n-- {
+			out.WriteByte(md5CryptAlphabet[v&0x3f])
+			v >>= 6
+		}
+	}
+	for _, idx := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		to64(uint32(sum[idx[0]])<<16|uint32(sum[idx[1]])<<8|uint32(sum[idx[2]]), 4)
+	}
+	to64(uint32(sum[11]), 2)
+
+	return out.String()
+}
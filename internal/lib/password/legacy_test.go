@@ -0,0 +1,74 @@
+package password
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The hashes below were produced by the reference implementations
+// (openssl passwd, Python hashlib) for the password "correct horse".
+func TestImportHash_VerifyAndUpgrade(t *testing.T) {
+	tests := []struct {
+		format string
+		hash   string
+		salt   string
+	}{
+		{format: FormatSaltedSHA256, hash: "cc0c19e7e854dad3a1ac59d270a830024c7d2efa248ea80d0a27223f30bbf504", salt: "NaCl"},
+		{format: FormatSHA256Salted, hash: "459b757df3d40423c89b513188199edbf3eae8b8f198e57e52ec130f16141179", salt: "NaCl"},
+		{format: FormatPBKDF2, hash: "$pbkdf2-sha512$i=1000$MDEyMzQ1Njc4OWFiY2RlZg$OM0FAoIqCVK1sWtxDiffVlBejtLa+ks4TP71JiecwuSZCG8iLbnlIEPOMoVX+i2B2wkSxjQ8CRGR9OkNGuIPMQ"},
+		{format: FormatMD5Crypt, hash: "$1$s4ltS4lt$AJzB0j6Ix6HYnbU0UBuQz1"},
+		{format: FormatDjango, hash: "pbkdf2_sha256$1000$seasalt$mQnueSakb748zqBAC1tmWVZsZbi2zPGZarEzTGdfmso="},
+		{format: FormatASPNetIdentity, hash: "ADAxMjM0NTY3ODlhYmNkZWZpR8Ta/HlDvOBtvSPHj0Bo2ldRBBVbql48o3lpvfsbjQ=="},
+		{format: FormatASPNetIdentity, hash: "AQAAAAEAACcQAAAAEDAxMjM0NTY3ODlhYmNkZWZMjaCu29XUdbmfPasFji1y2WuY6PltmzR3D2Pw0elFFw=="},
+	}
+
+	h, err := New(testConfig(Argon2id))
+	require.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			stored, err := ImportHash(tt.format, tt.hash, tt.salt)
+			require.NoError(t, err)
+
+			needsRehash, err := h.Verify(stored, "correct horse")
+			require.NoError(t, err)
+			assert.True(t, needsRehash, "legacy hashes are always upgraded")
+
+			_, err = h.Verify(stored, "wrong horse")
+			assert.ErrorIs(t, err, ErrMismatch)
+		})
+	}
+}
+
+func TestMD5Crypt_KnownVector(t *testing.T) {
+	assert.Equal(t, "$1$3azHgidD$SrJPt7B.9rekpmwJwtON31", md5Crypt([]byte("password"), []byte("3azHgidD")))
+}
+
+func TestImportHash_Invalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		hash        string
+		expectedErr error
+	}{
+		{name: "Unknown format", format: "crc32", hash: "abc", expectedErr: ErrUnsupportedFormat},
+		{name: "Short sha256", format: FormatSaltedSHA256, hash: "abcd", expectedErr: ErrMalformedHash},
+		{name: "Unknown pbkdf2 digest", format: FormatPBKDF2, hash: "$pbkdf2-md5$i=1$c2FsdA$a2V5", expectedErr: ErrUnsupportedFormat},
+		{name: "Not md5-crypt", format: FormatMD5Crypt, hash: "$5$salt$hash", expectedErr: ErrMalformedHash},
+		{name: "Not django", format: FormatDjango, hash: "argon2$argon2id$v=19", expectedErr: ErrMalformedHash},
+		{name: "Not ASP.NET", format: FormatASPNetIdentity, hash: "not base64!", expectedErr: ErrMalformedHash},
+		{name: "Not bcrypt", format: Bcrypt, hash: "$2a$", expectedErr: ErrMalformedHash},
+		{name: "Argon2id over the limits", format: Argon2id, hash: "$argon2id$v=19$m=4294967295,t=1,p=1$c2FsdA$a2V5", expectedErr: ErrMalformedHash},
+		{name: "Scrypt over the limits", format: Scrypt, hash: "$scrypt$ln=4,r=8,p=100000$c2FsdA$a2V5", expectedErr: ErrMalformedHash},
+		{name: "Truncated scrypt", format: Scrypt, hash: "$scrypt$ln=4,r=8,p=1", expectedErr: ErrMalformedHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ImportHash(tt.format, tt.hash, "")
+			assert.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
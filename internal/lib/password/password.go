@@ -0,0 +1,293 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+	"sso/internal/config"
+	"strings"
+)
+
+const (
+	Argon2id = "argon2id"
+	Scrypt   = "scrypt"
+	Bcrypt   = "bcrypt"
+)
+
+const (
+	saltSize = 16
+	keySize  = 32
+)
+
+// Limits of the parameters of configured, stored and imported hashes. A
+// hash with more could make every login with it take seconds or gigabytes,
+// so stored and imported ones are refused as malformed.
+const (
+	maxArgon2Memory      = 256 * 1024 // KiB
+	maxArgon2Iterations  = 16
+	maxArgon2Parallelism = 16
+	maxScryptLogN        = 20
+	maxScryptR           = 32
+	maxScryptP           = 16
+	maxScryptMemory      = 256 << 20 // bytes, 128 * N * r
+	maxSaltSize          = 64
+	maxKeySize           = 64
+)
+
+var (
+	ErrMismatch             = errors.New("password does not match")
+	ErrUnknownAlgorithm     = errors.New("unknown password hash algorithm")
+	ErrMalformedHash        = errors.New("malformed password hash")
+	ErrUnsupportedAlgorithm = errors.New("unsupported password hash algorithm")
+)
+
+// b64 is the unpadded standard base64 used by the PHC string format.
+var b64 = base64.RawStdEncoding
+
+// Hasher hashes new passwords with the configured algorithm and verifies
+// hashes made with any supported algorithm and parameters.
+type Hasher struct {
+	cfg config.Password
+}
+
+func New(cfg config.Password) (*Hasher, error) {
+	switch cfg.Algorithm {
+	case Argon2id, Scrypt, Bcrypt:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, cfg.Algorithm)
+	}
+	if cfg.Algorithm == Bcrypt && (cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost) {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	// hashes made with parameters over the limits could not be verified
+	if cfg.Algorithm == Argon2id {
+		if err := (argon2Params{memory: cfg.Argon2Memory, iterations: cfg.Argon2Iterations, parallelism: cfg.Argon2Parallelism}).check(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Algorithm == Scrypt {
+		if err := (scryptParams{logN: cfg.ScryptLogN, r: cfg.ScryptR, p: cfg.ScryptP}).check(); err != nil {
+			return nil, err
+		}
+	}
+	return &Hasher{cfg: cfg}, nil
+}
+
+// Hash returns a self-describing hash of password: a PHC string for argon2id
+// and scrypt, the modular crypt format for bcrypt.
+func (h *Hasher) Hash(password string) ([]byte, error) {
+	switch h.cfg.Algorithm {
+	case Bcrypt:
+		return bcrypt.GenerateFromPassword([]byte(password), h.cfg.BcryptCost)
+	case Scrypt:
+		return hashScrypt(password, scryptParams{logN: h.cfg.ScryptLogN, r: h.cfg.ScryptR, p: h.cfg.ScryptP})
+	default:
+		return hashArgon2id(password, argon2Params{memory: h.cfg.Argon2Memory, iterations: h.cfg.Argon2Iterations, parallelism: h.cfg.Argon2Parallelism})
+	}
+}
+
+// Verify checks password against hash. needsRehash reports that the hash was
+// made with another algorithm or other parameters than the configured ones.
+func (h *Hasher) Verify(hash []byte, password string) (needsRehash bool, err error) {
+	encoded := string(hash)
+
+	switch {
+	case strings.HasPrefix(encoded, "$"+Argon2id+"$"):
+		params, err := verifyArgon2id(encoded, password)
+		if err != nil {
+			return false, err
+		}
+		wanted := argon2Params{memory: h.cfg.Argon2Memory, iterations: h.cfg.Argon2Iterations, parallelism: h.cfg.Argon2Parallelism}
+		return h.cfg.Algorithm != Argon2id || params != wanted, nil
+	case strings.HasPrefix(encoded, "$"+Scrypt+"$"):
+		params, err := verifyScrypt(encoded, password)
+		if err != nil {
+			return false, err
+		}
+		wanted := scryptParams{logN: h.cfg.ScryptLogN, r: h.cfg.ScryptR, p: h.cfg.ScryptP}
+		return h.cfg.Algorithm != Scrypt || params != wanted, nil
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, ErrMismatch
+			}
+			return false, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+		}
+		cost, err := bcrypt.Cost(hash)
+		if err != nil {
+			return false, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+		}
+		return h.cfg.Algorithm != Bcrypt || cost != h.cfg.BcryptCost, nil
+	default:
+		// hashes imported from other systems are always upgraded
+		if err := verifyLegacy(encoded, password); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+}
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+func (p argon2Params) check() error {
+	if p.iterations == 0 || p.parallelism == 0 {
+		return errors.New("argon2id iterations and parallelism must not be 0")
+	}
+	if p.memory > maxArgon2Memory || p.iterations > maxArgon2Iterations || p.parallelism > maxArgon2Parallelism {
+		return fmt.Errorf("argon2id parameters must be at most m=%d,t=%d,p=%d", maxArgon2Memory, maxArgon2Iterations, maxArgon2Parallelism)
+	}
+	return nil
+}
+
+func hashArgon2id(password string, params argon2Params) ([]byte, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return nil, err
+	}
+	key := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, keySize)
+
+	return []byte(fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		Argon2id, argon2.Version, params.memory, params.iterations, params.parallelism,
+		b64.EncodeToString(salt), b64.EncodeToString(key),
+	)), nil
+}
+
+// parseArgon2id splits an argon2id PHC string, its parameters are within
+// the limits.
+func parseArgon2id(encoded string) (argon2Params, []byte, []byte, error) {
+	// $argon2id$v=19$m=65536,t=3,p=2$salt$hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return argon2Params{}, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2Params{}, nil, nil, ErrMalformedHash
+	}
+	var params argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return argon2Params{}, nil, nil, ErrMalformedHash
+	}
+	if err := params.check(); err != nil {
+		return argon2Params{}, nil, nil, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+	}
+	salt, key, err := decodeSaltAndKey(parts[4], parts[5])
+	if err != nil {
+		return argon2Params{}, nil, nil, err
+	}
+	return params, salt, key, nil
+}
+
+func verifyArgon2id(encoded string, password string) (argon2Params, error) {
+	params, salt, key, err := parseArgon2id(encoded)
+	if err != nil {
+		return argon2Params{}, err
+	}
+
+	actual := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(actual, key) != 1 {
+		return params, ErrMismatch
+	}
+	return params, nil
+}
+
+type scryptParams struct {
+	logN int
+	r    int
+	p    int
+}
+
+func (p scryptParams) check() error {
+	if p.logN < 1 || p.r < 1 || p.p < 1 {
+		return errors.New("scrypt parameters must be positive")
+	}
+	if p.logN > maxScryptLogN || p.r > maxScryptR || p.p > maxScryptP || 128*p.r<<p.logN > maxScryptMemory {
+		return fmt.Errorf("scrypt parameters must be at most ln=%d,r=%d,p=%d and %d bytes of memory", maxScryptLogN, maxScryptR, maxScryptP, maxScryptMemory)
+	}
+	return nil
+}
+
+func hashScrypt(password string, params scryptParams) ([]byte, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key([]byte(password), salt, 1<<params.logN, params.r, params.p, keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(fmt.Sprintf("$%s$ln=%d,r=%d,p=%d$%s$%s",
+		Scrypt, params.logN, params.r, params.p,
+		b64.EncodeToString(salt), b64.EncodeToString(key),
+	)), nil
+}
+
+// parseScrypt splits a scrypt PHC string, its parameters are within the
+// limits.
+func parseScrypt(encoded string) (scryptParams, []byte, []byte, error) {
+	// $scrypt$ln=15,r=8,p=1$salt$hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 {
+		return scryptParams{}, nil, nil, ErrMalformedHash
+	}
+
+	var params scryptParams
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &params.logN, &params.r, &params.p); err != nil {
+		return scryptParams{}, nil, nil, ErrMalformedHash
+	}
+	if err := params.check(); err != nil {
+		return scryptParams{}, nil, nil, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+	}
+	salt, key, err := decodeSaltAndKey(parts[3], parts[4])
+	if err != nil {
+		return scryptParams{}, nil, nil, err
+	}
+	return params, salt, key, nil
+}
+
+func verifyScrypt(encoded string, password string) (scryptParams, error) {
+	params, salt, key, err := parseScrypt(encoded)
+	if err != nil {
+		return scryptParams{}, err
+	}
+
+	actual, err := scrypt.Key([]byte(password), salt, 1<<params.logN, params.r, params.p, len(key))
+	if err != nil {
+		return scryptParams{}, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+	}
+	if subtle.ConstantTimeCompare(actual, key) != 1 {
+		return params, ErrMismatch
+	}
+	return params, nil
+}
+
+func decodeSaltAndKey(encodedSalt string, encodedKey string) ([]byte, []byte, error) {
+	salt, err := b64.DecodeString(encodedSalt)
+	if err != nil || len(salt) > maxSaltSize {
+		return nil, nil, ErrMalformedHash
+	}
+	key, err := b64.DecodeString(encodedKey)
+	if err != nil || len(key) == 0 || len(key) > maxKeySize {
+		return nil, nil, ErrMalformedHash
+	}
+	return salt, key, nil
+}
+
+func newSalt() ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
@@ -0,0 +1,121 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sso/internal/config"
+)
+
+// testConfig uses cheap parameters to keep the tests fast.
+func testConfig(algorithm string) config.Password {
+	return config.Password{
+		Algorithm:         algorithm,
+		Argon2Memory:      1024,
+		Argon2Iterations:  1,
+		Argon2Parallelism: 1,
+		ScryptLogN:        4,
+		ScryptR:           8,
+		ScryptP:           1,
+		BcryptCost:        4,
+	}
+}
+
+func TestHasher_HashVerify(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		prefix    string
+	}{
+		{algorithm: Argon2id, prefix: "$argon2id$v=19$m=1024,t=1,p=1$"},
+		{algorithm: Scrypt, prefix: "$scrypt$ln=4,r=8,p=1$"},
+		{algorithm: Bcrypt, prefix: "$2a$04$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			h, err := New(testConfig(tt.algorithm))
+			require.NoError(t, err)
+
+			hash, err := h.Hash("correct horse")
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(string(hash), tt.prefix), "unexpected hash %s", hash)
+
+			needsRehash, err := h.Verify(hash, "correct horse")
+			require.NoError(t, err)
+			assert.False(t, needsRehash)
+
+			_, err = h.Verify(hash, "battery staple")
+			assert.ErrorIs(t, err, ErrMismatch)
+		})
+	}
+}
+
+func TestHasher_NeedsRehash(t *testing.T) {
+	old, err := New(testConfig(Bcrypt))
+	require.NoError(t, err)
+	hash, err := old.Hash("correct horse")
+	require.NoError(t, err)
+
+	cfg := testConfig(Argon2id)
+	current, err := New(cfg)
+	require.NoError(t, err)
+	needsRehash, err := current.Verify(hash, "correct horse")
+	require.NoError(t, err)
+	assert.True(t, needsRehash, "hash made with another algorithm")
+
+	hash, err = current.Hash("correct horse")
+	require.NoError(t, err)
+	cfg.Argon2Iterations = 2
+	stronger, err := New(cfg)
+	require.NoError(t, err)
+	needsRehash, err = stronger.Verify(hash, "correct horse")
+	require.NoError(t, err)
+	assert.True(t, needsRehash, "hash made with outdated parameters")
+}
+
+func TestHasher_VerifyMalformed(t *testing.T) {
+	h, err := New(testConfig(Argon2id))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		hash        string
+		expectedErr error
+	}{
+		{name: "Unknown algorithm", hash: "$md4$abc", expectedErr: ErrUnknownAlgorithm},
+		{name: "Plain text", hash: "secret", expectedErr: ErrUnknownAlgorithm},
+		{name: "Truncated argon2id", hash: "$argon2id$v=19$m=1024,t=1,p=1$c2FsdA", expectedErr: ErrMalformedHash},
+		{name: "Bad scrypt params", hash: "$scrypt$ln=x,r=8,p=1$c2FsdA$a2V5", expectedErr: ErrMalformedHash},
+		{name: "Argon2id memory over the limit", hash: "$argon2id$v=19$m=4294967295,t=1,p=1$c2FsdA$a2V5", expectedErr: ErrMalformedHash},
+		{name: "Argon2id iterations over the limit", hash: "$argon2id$v=19$m=1024,t=1000000,p=1$c2FsdA$a2V5", expectedErr: ErrMalformedHash},
+		{name: "Scrypt r over the limit", hash: "$scrypt$ln=4,r=100000,p=1$c2FsdA$a2V5", expectedErr: ErrMalformedHash},
+		{name: "Scrypt p over the limit", hash: "$scrypt$ln=4,r=8,p=100000$c2FsdA$a2V5", expectedErr: ErrMalformedHash},
+		{name: "Scrypt memory over the limit", hash: "$scrypt$ln=20,r=32,p=1$c2FsdA$a2V5", expectedErr: ErrMalformedHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := h.Verify([]byte(tt.hash), "secret")
+			assert.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestNew_UnsupportedAlgorithm(t *testing.T) {
+	_, err := New(testConfig("md5"))
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+}
+
+func TestNew_ParametersOverTheLimits(t *testing.T) {
+	cfg := testConfig(Argon2id)
+	cfg.Argon2Memory = maxArgon2Memory + 1
+	_, err := New(cfg)
+	assert.Error(t, err)
+
+	cfg = testConfig(Scrypt)
+	cfg.ScryptP = maxScryptP + 1
+	_, err = New(cfg)
+	assert.Error(t, err)
+}
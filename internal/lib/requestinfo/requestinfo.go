@@ -0,0 +1,67 @@
+package requestinfo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// deviceIdHeader carries a stable device identifier chosen by the client.
+const deviceIdHeader = "x-device-id"
+
+// Info describes where a request came from.
+type Info struct {
+	IP        string
+	UserAgent string
+	// DeviceId is the x-device-id header, or a fingerprint of the user agent
+	// for clients that do not send one.
+	DeviceId string
+	// ActorId is the user an admin call is made by, 0 for other calls.
+	ActorId int64
+}
+
+type contextKey struct{}
+
+// NewContext attaches info to ctx, it takes precedence over the gRPC peer.
+func NewContext(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, contextKey{}, info)
+}
+
+// FromContext returns the info attached with NewContext or, for gRPC
+// requests, the peer address and the user-agent and x-device-id headers.
+func FromContext(ctx context.Context) Info {
+	if info, ok := ctx.Value(contextKey{}).(Info); ok {
+		return info
+	}
+
+	var info Info
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		info.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(info.IP); err == nil {
+			info.IP = host
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			info.UserAgent = ua[0]
+		}
+		if deviceId := md.Get(deviceIdHeader); len(deviceId) > 0 {
+			info.DeviceId = deviceId[0]
+		}
+	}
+	if info.DeviceId == "" && info.UserAgent != "" {
+		info.DeviceId = Fingerprint(info.UserAgent)
+	}
+	return info
+}
+
+// Fingerprint derives a device id from the user agent. It tells apart
+// browsers and client versions, not two identical devices.
+func Fingerprint(userAgent string) string {
+	sum := sha256.Sum256([]byte(userAgent))
+	return "ua:" + hex.EncodeToString(sum[:16])
+}
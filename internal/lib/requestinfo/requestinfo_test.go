@@ -0,0 +1,43 @@
+package requestinfo
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func grpcContext(md metadata.MD) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 53211},
+	})
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+func TestFromContext_GRPC(t *testing.T) {
+	ctx := grpcContext(metadata.Pairs("user-agent", "grpc-go/1.73.0", "x-device-id", "device-1"))
+
+	info := FromContext(ctx)
+
+	assert.Equal(t, Info{IP: "192.0.2.10", UserAgent: "grpc-go/1.73.0", DeviceId: "device-1"}, info)
+}
+
+func TestFromContext_FingerprintWithoutDeviceId(t *testing.T) {
+	info := FromContext(grpcContext(metadata.Pairs("user-agent", "grpc-go/1.73.0")))
+
+	assert.Equal(t, Fingerprint("grpc-go/1.73.0"), info.DeviceId)
+	assert.NotEqual(t, Fingerprint("grpc-go/1.72.0"), info.DeviceId)
+}
+
+func TestFromContext_Explicit(t *testing.T) {
+	ctx := NewContext(grpcContext(metadata.Pairs("user-agent", "grpc-go")), Info{UserAgent: "ssoctl"})
+
+	assert.Equal(t, Info{UserAgent: "ssoctl"}, FromContext(ctx))
+}
+
+func TestFromContext_Empty(t *testing.T) {
+	assert.Equal(t, Info{}, FromContext(context.Background()))
+}
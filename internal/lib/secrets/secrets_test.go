@@ -0,0 +1,52 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testKey = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", keySize)))
+
+func TestCipher_EncryptDecrypt(t *testing.T) {
+	c, err := New(testKey)
+	require.NoError(t, err)
+
+	encrypted, err := c.Encrypt("app-secret")
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+	assert.NotContains(t, encrypted, "app-secret")
+
+	decrypted, err := c.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "app-secret", decrypted)
+}
+
+func TestCipher_DecryptLegacyPlaintext(t *testing.T) {
+	c, err := New(testKey)
+	require.NoError(t, err)
+
+	decrypted, err := c.Decrypt("default_secret")
+	require.NoError(t, err)
+	assert.Equal(t, "default_secret", decrypted)
+}
+
+func TestCipher_DecryptWithWrongKey(t *testing.T) {
+	c, err := New(testKey)
+	require.NoError(t, err)
+	encrypted, err := c.Encrypt("app-secret")
+	require.NoError(t, err)
+
+	other, err := New(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("o", keySize))))
+	require.NoError(t, err)
+	_, err = other.Decrypt(encrypted)
+	assert.Error(t, err)
+}
+
+func TestNew_InvalidKey(t *testing.T) {
+	_, err := New("short")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
@@ -0,0 +1,145 @@
+// Package systemd integrates the service with systemd: listening sockets
+// passed by socket activation and state notifications over sd_notify. Both
+// are no-ops when the process is not started by systemd.
+package systemd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"sso/internal/lib/logger/sl"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/activation"
+	"github.com/coreos/go-systemd/v22/daemon"
+)
+
+// FileDescriptorName= of the socket units in deployment/
+const (
+	SocketGRPC    = "grpc"
+	SocketGateway = "gateway"
+	SocketMetrics = "metrics"
+)
+
+var ErrUnexpectedSocket = errors.New("unexpected socket")
+
+// Listeners returns the sockets passed by socket activation by their name,
+// an empty map without activation. Every name must be one of names and be
+// passed once, so a mistake in the socket units fails the start instead of
+// leaving a port unserved.
+func Listeners(names ...string) (map[string]net.Listener, error) {
+	const op = "systemd.Listeners"
+
+	inherited, err := activation.ListenersWithNames()
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	listeners, err := byName(inherited, names)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return listeners, nil
+}
+
+func byName(inherited map[string][]net.Listener, names []string) (map[string]net.Listener, error) {
+	listeners := make(map[string]net.Listener, len(inherited))
+	var unexpected []string
+	for name, passed := range inherited {
+		if len(passed) != 1 || !contains(names, name) {
+			unexpected = append(unexpected, fmt.Sprintf("%s (%d)", name, len(passed)))
+			continue
+		}
+		listeners[name] = passed[0]
+	}
+	if len(unexpected) > 0 {
+		for _, passed := range inherited {
+			for _, lis := range passed {
+				_ = lis.Close()
+			}
+		}
+		sort.Strings(unexpected)
+		return nil, fmt.Errorf("%w: %s, expected one of each of %s", ErrUnexpectedSocket, strings.Join(unexpected, ", "), strings.Join(names, ", "))
+	}
+	return listeners, nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Notifier reports the state of the service to systemd and pings its
+// watchdog while the service runs.
+type Notifier struct {
+	log      *slog.Logger
+	watchdog time.Duration
+	stop     chan struct{}
+}
+
+// NewNotifier reads the watchdog interval systemd asks for, if any.
+func NewNotifier(log *slog.Logger) (*Notifier, error) {
+	const op = "systemd.NewNotifier"
+
+	watchdog, err := daemon.SdWatchdogEnabled(false)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return &Notifier{log: log, watchdog: watchdog, stop: make(chan struct{})}, nil
+}
+
+// Ready tells systemd that the service accepts requests, Type=notify units
+// count as started only then.
+func (n *Notifier) Ready() error {
+	return n.notify(daemon.SdNotifyReady)
+}
+
+// Watchdog pings the watchdog twice per interval until Stopping, it returns
+// at once if the unit has no WatchdogSec=.
+func (n *Notifier) Watchdog() error {
+	if n.watchdog <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(n.watchdog / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.stop:
+			return nil
+		case <-ticker.C:
+			if err := n.notify(daemon.SdNotifyWatchdog); err != nil {
+				n.log.Warn("failed to ping systemd watchdog", sl.Err(err))
+			}
+		}
+	}
+}
+
+// Stopping tells systemd that the service is shutting down and ends
+// Watchdog.
+func (n *Notifier) Stopping(context.Context) error {
+	select {
+	case <-n.stop:
+		return nil
+	default:
+		close(n.stop)
+	}
+	return n.notify(daemon.SdNotifyStopping)
+}
+
+func (n *Notifier) notify(state string) error {
+	const op = "systemd.Notifier.notify"
+
+	if _, err := daemon.SdNotify(false, state); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
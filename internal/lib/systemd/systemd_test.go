@@ -0,0 +1,119 @@
+package systemd
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func listen(t *testing.T) net.Listener {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = lis.Close() })
+	return lis
+}
+
+func TestListeners_WithoutActivation(t *testing.T) {
+	t.Setenv("LISTEN_PID", "")
+	t.Setenv("LISTEN_FDS", "")
+
+	listeners, err := Listeners(SocketGRPC)
+	require.NoError(t, err)
+	assert.Empty(t, listeners)
+}
+
+func TestByName(t *testing.T) {
+	grpcL, metricsL := listen(t), listen(t)
+
+	listeners, err := byName(map[string][]net.Listener{
+		SocketGRPC:    {grpcL},
+		SocketMetrics: {metricsL},
+	}, []string{SocketGRPC, SocketGateway, SocketMetrics})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]net.Listener{SocketGRPC: grpcL, SocketMetrics: metricsL}, listeners)
+}
+
+func TestByName_Unexpected(t *testing.T) {
+	for name, inherited := range map[string]map[string][]net.Listener{
+		"unknown name": {SocketGRPC: {listen(t)}, "LISTEN_FD_4": {listen(t)}},
+		"disabled":     {SocketGRPC: {listen(t)}, SocketGateway: {listen(t)}},
+		"twice":        {SocketGRPC: {listen(t), listen(t)}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := byName(inherited, []string{SocketGRPC})
+			require.ErrorIs(t, err, ErrUnexpectedSocket)
+
+			// none are left open
+			for _, passed := range inherited {
+				for _, lis := range passed {
+					_, err := lis.Accept()
+					assert.ErrorIs(t, err, net.ErrClosed)
+				}
+			}
+		})
+	}
+}
+
+// notifySocket listens on a NOTIFY_SOCKET for the states sent to systemd.
+func notifySocket(t *testing.T) *net.UnixConn {
+	addr := &net.UnixAddr{Name: filepath.Join(t.TempDir(), "notify.sock"), Net: "unixgram"}
+	conn, err := net.ListenUnixgram("unixgram", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", addr.Name)
+	return conn
+}
+
+func read(t *testing.T, conn *net.UnixConn) string {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	b := make([]byte, 64)
+	n, err := conn.Read(b)
+	require.NoError(t, err)
+	return string(b[:n])
+}
+
+func TestNotifier(t *testing.T) {
+	conn := notifySocket(t)
+	t.Setenv("WATCHDOG_PID", "")
+	t.Setenv("WATCHDOG_USEC", "40000")
+
+	n, err := NewNotifier(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	assert.Equal(t, 40*time.Millisecond, n.watchdog)
+
+	require.NoError(t, n.Ready())
+	assert.Equal(t, "READY=1", read(t, conn))
+
+	done := make(chan error, 1)
+	go func() { done <- n.Watchdog() }()
+	assert.Equal(t, "WATCHDOG=1", read(t, conn))
+
+	require.NoError(t, n.Stopping(context.Background()))
+	require.NoError(t, <-done)
+	// pings already sent may arrive first
+	for {
+		if state := read(t, conn); state != "WATCHDOG=1" {
+			assert.Equal(t, "STOPPING=1", state)
+			break
+		}
+	}
+	require.NoError(t, n.Stopping(context.Background()))
+}
+
+func TestNotifier_WithoutSystemd(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	t.Setenv("WATCHDOG_USEC", "")
+
+	n, err := NewNotifier(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	require.NoError(t, n.Ready())
+	require.NoError(t, n.Watchdog())
+	require.NoError(t, n.Stopping(context.Background()))
+}
@@ -0,0 +1,184 @@
+// Package tlsreload serves TLS certificates that are reloaded from disk when
+// the files change, so rotated certificates are picked up without a restart.
+package tlsreload
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sso/internal/config"
+	"sso/internal/lib/logger/sl"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidConfig = errors.New("invalid tls config")
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Reloader holds the certificate and client CAs last loaded from the files
+// in config.TLS.
+type Reloader struct {
+	log        *slog.Logger
+	cfg        config.TLS
+	minVersion uint16
+	clientAuth tls.ClientAuthType
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	stamp     string
+}
+
+// New loads the files in cfg, it fails if they can not be used.
+func New(log *slog.Logger, cfg config.TLS) (*Reloader, error) {
+	const op = "tlsreload.New"
+
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, fmt.Errorf("%s:%w: cert_file and key_file are required", op, ErrInvalidConfig)
+	}
+	minVersion, ok := tlsVersions[cfg.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("%s:%w: unsupported min_version %q", op, ErrInvalidConfig, cfg.MinVersion)
+	}
+	clientAuth := tls.NoClientCert
+	switch {
+	case cfg.ClientCAFile != "" && cfg.RequireClientCert:
+		clientAuth = tls.RequireAndVerifyClientCert
+	case cfg.ClientCAFile != "":
+		clientAuth = tls.VerifyClientCertIfGiven
+	case cfg.RequireClientCert:
+		return nil, fmt.Errorf("%s:%w: require_client_cert needs client_ca_file", op, ErrInvalidConfig)
+	}
+
+	r := &Reloader{
+		log:        log,
+		cfg:        cfg,
+		minVersion: minVersion,
+		clientAuth: clientAuth,
+	}
+	if _, err := r.Reload(); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return r, nil
+}
+
+// Config returns a server config that uses the files loaded last for every
+// new connection.
+func (r *Reloader) Config() *tls.Config {
+	// gRPC clients insist on negotiating HTTP/2
+	return r.config("h2")
+}
+
+// HTTPConfig is Config for HTTP servers, browsers may only speak HTTP/1.1.
+func (r *Reloader) HTTPConfig() *tls.Config {
+	return r.config("h2", "http/1.1")
+}
+
+func (r *Reloader) config(nextProtos ...string) *tls.Config {
+	return &tls.Config{
+		MinVersion: r.minVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			return &tls.Config{
+				MinVersion:   r.minVersion,
+				Certificates: []tls.Certificate{*r.cert},
+				ClientAuth:   r.clientAuth,
+				ClientCAs:    r.clientCAs,
+				NextProtos:   nextProtos,
+			}, nil
+		},
+	}
+}
+
+// Reload loads the files again if any of them changed since the last load
+// and reports whether it did. On error the previous certificates stay in use.
+func (r *Reloader) Reload() (bool, error) {
+	stamp, err := r.fileStamp()
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	unchanged := stamp == r.stamp
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return false, err
+	}
+	var clientCAs *x509.CertPool
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return false, err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return false, fmt.Errorf("%w: no certificates in %s", ErrInvalidConfig, r.cfg.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.stamp = stamp
+	r.mu.Unlock()
+	return true, nil
+}
+
+// Run checks the files every cfg.ReloadInterval until ctx is done.
+func (r *Reloader) Run(ctx context.Context) {
+	const op = "tlsreload.Reloader.Run"
+	log := r.log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(r.cfg.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := r.Reload()
+		if err != nil {
+			// e.g. the key is replaced before the certificate
+			log.Warn("failed to reload certificates, keeping the previous ones", sl.Err(err))
+			continue
+		}
+		if reloaded {
+			log.Info("certificates reloaded")
+		}
+	}
+}
+
+// fileStamp identifies the current version of the files by their size and
+// modification time.
+func (r *Reloader) fileStamp() (string, error) {
+	var stamp strings.Builder
+	for _, path := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&stamp, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+	}
+	return stamp.String(), nil
+}
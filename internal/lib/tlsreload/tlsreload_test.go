@@ -0,0 +1,149 @@
+package tlsreload
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sso/internal/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testLog = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// writeCert writes a self-signed certificate for commonName and its key,
+// bumping the modification time so a reload notices the change.
+func writeCert(t *testing.T, dir string, commonName string, modTime time.Time) (certFile string, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              []string{commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+	return certFile, keyFile
+}
+
+func servedCommonName(t *testing.T, r *Reloader) string {
+	t.Helper()
+	cfg, err := r.Config().GetConfigForClient(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	require.Len(t, cfg.Certificates, 1)
+	leaf, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestNewInvalid(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "sso", time.Now())
+
+	for _, cfg := range []config.TLS{
+		{KeyFile: keyFile, MinVersion: "1.2"},
+		{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.0"},
+		{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.2", RequireClientCert: true},
+	} {
+		_, err := New(testLog, cfg)
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+	}
+
+	_, err := New(testLog, config.TLS{CertFile: certFile, KeyFile: filepath.Join(dir, "missing.pem"), MinVersion: "1.2"})
+	assert.Error(t, err)
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Minute)
+	certFile, keyFile := writeCert(t, dir, "old.sso", start)
+
+	r, err := New(testLog, config.TLS{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3"})
+	require.NoError(t, err)
+	assert.Equal(t, "old.sso", servedCommonName(t, r))
+	assert.Equal(t, uint16(tls.VersionTLS13), r.Config().MinVersion)
+
+	reloaded, err := r.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+
+	writeCert(t, dir, "new.sso", start.Add(time.Second))
+	reloaded, err = r.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "new.sso", servedCommonName(t, r))
+
+	// a half written rotation keeps the served certificate
+	require.NoError(t, os.WriteFile(keyFile, []byte("garbage"), 0o600))
+	_, err = r.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, "new.sso", servedCommonName(t, r))
+}
+
+func TestClientAuth(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "sso", time.Now())
+	caDir := t.TempDir()
+	caFile, _ := writeCert(t, caDir, "clients", time.Now())
+
+	for _, tc := range []struct {
+		cfg  config.TLS
+		want tls.ClientAuthType
+	}{
+		{config.TLS{}, tls.NoClientCert},
+		{config.TLS{ClientCAFile: caFile}, tls.VerifyClientCertIfGiven},
+		{config.TLS{ClientCAFile: caFile, RequireClientCert: true}, tls.RequireAndVerifyClientCert},
+	} {
+		tc.cfg.CertFile, tc.cfg.KeyFile, tc.cfg.MinVersion = certFile, keyFile, "1.2"
+		r, err := New(testLog, tc.cfg)
+		require.NoError(t, err)
+
+		cfg, err := r.Config().GetConfigForClient(&tls.ClientHelloInfo{})
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, cfg.ClientAuth)
+		assert.Equal(t, tc.cfg.ClientCAFile != "", cfg.ClientCAs != nil)
+		assert.Equal(t, []string{"h2"}, cfg.NextProtos)
+	}
+}
+
+func TestNextProtos(t *testing.T) {
+	certFile, keyFile := writeCert(t, t.TempDir(), "sso", time.Now())
+	r, err := New(testLog, config.TLS{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.2"})
+	require.NoError(t, err)
+
+	cfg, err := r.Config().GetConfigForClient(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"h2"}, cfg.NextProtos)
+
+	cfg, err = r.HTTPConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"h2", "http/1.1"}, cfg.NextProtos)
+}
@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sso/internal/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+func TestNewWithoutExporter(t *testing.T) {
+	provider, err := New(context.Background(), config.Tracing{})
+	require.NoError(t, err)
+	assert.Nil(t, provider)
+	assert.Contains(t, otel.GetTextMapPropagator().Fields(), "traceparent")
+}
+
+func TestNewInvalid(t *testing.T) {
+	_, err := New(context.Background(), config.Tracing{Exporter: "zipkin", SampleRatio: 1})
+	assert.ErrorIs(t, err, ErrUnknownExporter)
+
+	_, err = New(context.Background(), config.Tracing{Exporter: ExporterStdout, SampleRatio: 2})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+
+	_, err = New(context.Background(), config.Tracing{Exporter: ExporterFile, SampleRatio: 1})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	provider, err := New(context.Background(), config.Tracing{Exporter: ExporterFile, File: path, ServiceName: "sso-test", SampleRatio: 1})
+	require.NoError(t, err)
+	require.NotNil(t, provider)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "Auth.Login")
+	traceId := span.SpanContext().TraceID().String()
+	span.End()
+	require.NoError(t, provider.Shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Name":"Auth.Login"`)
+	assert.Contains(t, string(data), traceId)
+	assert.Contains(t, string(data), "sso-test")
+}
@@ -0,0 +1,124 @@
+package userimport
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sso/internal/domain/models"
+	"strings"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+const maxLineSize = 1 << 20
+
+var ErrUnsupportedFormat = errors.New("unsupported import file format, expected csv or jsonl")
+
+// csvColumns are the recognized CSV header names. email, password_hash and
+// hash_format are required, the order is free.
+var csvColumns = []string{"email", "username", "phone", "password_hash", "hash_format", "salt"}
+
+// Reader reads users to import one by one.
+type Reader interface {
+	// Next returns the next user or io.EOF after the last one.
+	Next() (models.ImportedUser, error)
+}
+
+func NewReader(r io.Reader, format string) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+		return &jsonlReader{scanner: scanner}, nil
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+type csvReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"email", "password_hash", "hash_format"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("csv header must contain %s, known columns are %s", required, strings.Join(csvColumns, ", "))
+		}
+	}
+
+	return &csvReader{reader: reader, columns: columns}, nil
+}
+
+func (r *csvReader) Next() (models.ImportedUser, error) {
+	record, err := r.reader.Read()
+	if err != nil {
+		return models.ImportedUser{}, err
+	}
+	line, _ := r.reader.FieldPos(0)
+
+	field := func(name string) string {
+		if i, ok := r.columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	return models.ImportedUser{
+		Line:         line,
+		Email:        field("email"),
+		Username:     field("username"),
+		Phone:        field("phone"),
+		PasswordHash: field("password_hash"),
+		HashFormat:   field("hash_format"),
+		Salt:         field("salt"),
+	}, nil
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *jsonlReader) Next() (models.ImportedUser, error) {
+	for r.scanner.Scan() {
+		r.line++
+		text := strings.TrimSpace(r.scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		user := models.ImportedUser{}
+		if err := json.Unmarshal([]byte(text), &user); err != nil {
+			return models.ImportedUser{}, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		user.Line = r.line
+		return user, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return models.ImportedUser{}, err
+	}
+	return models.ImportedUser{}, io.EOF
+}
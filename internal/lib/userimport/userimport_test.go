@@ -0,0 +1,65 @@
+package userimport
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sso/internal/domain/models"
+)
+
+func readAll(t *testing.T, r Reader) []models.ImportedUser {
+	t.Helper()
+
+	var users []models.ImportedUser
+	for {
+		user, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return users
+		}
+		require.NoError(t, err)
+		users = append(users, user)
+	}
+}
+
+func TestNewReader_CSV(t *testing.T) {
+	input := "hash_format,email,password_hash,salt\n" +
+		"md5-crypt,bob@x.com,$1$salt$hash,\n" +
+		"sha256-salted,alice@x.com,abcd,NaCl\n"
+
+	r, err := NewReader(strings.NewReader(input), FormatCSV)
+	require.NoError(t, err)
+
+	users := readAll(t, r)
+	require.Len(t, users, 2)
+	assert.Equal(t, models.ImportedUser{Line: 2, Email: "bob@x.com", PasswordHash: "$1$salt$hash", HashFormat: "md5-crypt"}, users[0])
+	assert.Equal(t, "NaCl", users[1].Salt)
+	assert.Equal(t, 3, users[1].Line)
+}
+
+func TestNewReader_CSVMissingColumn(t *testing.T) {
+	_, err := NewReader(strings.NewReader("email,salt\n"), FormatCSV)
+	assert.ErrorContains(t, err, "password_hash")
+}
+
+func TestNewReader_JSONL(t *testing.T) {
+	input := `{"email":"bob@x.com","password_hash":"$1$salt$hash","hash_format":"md5-crypt"}` + "\n\n" +
+		`{"email":"alice@x.com","username":"alice","password_hash":"abcd","hash_format":"sha256-salted","salt":"NaCl"}` + "\n"
+
+	r, err := NewReader(strings.NewReader(input), FormatJSONL)
+	require.NoError(t, err)
+
+	users := readAll(t, r)
+	require.Len(t, users, 2)
+	assert.Equal(t, 1, users[0].Line)
+	assert.Equal(t, 3, users[1].Line)
+	assert.Equal(t, "alice", users[1].Username)
+}
+
+func TestNewReader_UnsupportedFormat(t *testing.T) {
+	_, err := NewReader(strings.NewReader(""), "xml")
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
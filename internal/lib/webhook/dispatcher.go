@@ -0,0 +1,239 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/lib/events"
+	"sso/internal/lib/logger/sl"
+	"syscall"
+	"time"
+)
+
+// leaseMargin is added to the time a batch of requests may take at most, for
+// the storage calls around them.
+const leaseMargin = time.Minute
+
+// maxErrorLength bounds the response excerpt kept in delivery logs.
+const maxErrorLength = 512
+
+// ErrPrivateAddress fails deliveries to receivers resolving to an address
+// that is not public.
+var ErrPrivateAddress = errors.New("receiver address is not public")
+
+// sharedAddressSpace is the carrier-grade NAT range, not covered by
+// netip.Addr.IsPrivate.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// PublicAddr reports whether addr is a public unicast address. Anything else
+// is the network of the service itself, e.g. loopback, private ranges or the
+// link-local cloud metadata endpoint, which webhooks must not reach.
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// Store is the storage the dispatcher reads deliveries from.
+type Store interface {
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	GetWebhookSubscription(ctx context.Context, id int64) (*models.WebhookSubscription, error)
+	RecordWebhookAttempt(ctx context.Context, attempt models.WebhookAttempt, status string, nextAttemptAt time.Time) error
+}
+
+// Dispatcher POSTs queued deliveries to subscription URLs. Failed deliveries
+// are retried with exponential backoff until maxAttempts, then they are dead
+// until redelivered manually.
+type Dispatcher struct {
+	log          *slog.Logger
+	store        Store
+	client       *http.Client
+	batchSize    int
+	pollInterval time.Duration
+	maxAttempts  int
+	maxBackoff   time.Duration
+	// lease is how long claimed deliveries are hidden from other
+	// dispatchers, it outlasts a batch of requests timing out one after
+	// another.
+	lease time.Duration
+}
+
+func NewDispatcher(log *slog.Logger, store Store, cfg config.Webhooks) *Dispatcher {
+	return &Dispatcher{
+		log:          log,
+		store:        store,
+		client:       newClient(cfg),
+		batchSize:    cfg.BatchSize,
+		pollInterval: cfg.PollInterval,
+		maxAttempts:  cfg.MaxAttempts,
+		maxBackoff:   cfg.MaxBackoff,
+		lease:        time.Duration(cfg.BatchSize)*cfg.Timeout + leaseMargin,
+	}
+}
+
+// newClient returns the client sending deliveries. Subscription URLs come
+// from admins, so unless cfg allows private hosts the client only connects
+// to public addresses. The address is checked when dialing, after DNS
+// resolution, and redirects are not followed, a receiver cannot point the
+// request elsewhere.
+func newClient(cfg config.Webhooks) *http.Client {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivateHosts {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !PublicAddr(addr.Addr()) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, addr.Addr())
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// a proxy would connect to the receiver without the check
+	transport.Proxy = nil
+
+	return &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Run dispatches deliveries until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	const op = "Webhook.Dispatcher.Run"
+	log := d.log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+	for {
+		n, err := d.DispatchOnce(ctx)
+		if err != nil {
+			log.Error("failed to dispatch webhooks", sl.Err(err))
+		}
+		if err == nil && n == d.batchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOnce sends one batch of due deliveries and returns how many were
+// claimed. A delivery that fails to be sent or recorded does not hold up
+// the rest of the batch, the errors are returned together.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	deliveries, err := d.store.ClaimWebhookDeliveries(ctx, d.batchSize, d.lease)
+	if err != nil {
+		return 0, err
+	}
+
+	subs := make(map[int64]*models.WebhookSubscription)
+	subErrs := make(map[int64]error)
+	var errs []error
+	for _, delivery := range deliveries {
+		sub, ok := subs[delivery.SubscriptionId]
+		subErr := subErrs[delivery.SubscriptionId]
+		if !ok && subErr == nil {
+			if sub, subErr = d.store.GetWebhookSubscription(ctx, delivery.SubscriptionId); subErr != nil {
+				subErrs[delivery.SubscriptionId] = subErr
+				errs = append(errs, subErr)
+			} else {
+				subs[delivery.SubscriptionId] = sub
+			}
+		}
+
+		var attempt models.WebhookAttempt
+		if subErr != nil {
+			// retried like a failed request
+			attempt = models.WebhookAttempt{DeliveryId: delivery.Id, AttemptedAt: time.Now(), Error: "failed to get subscription: " + subErr.Error()}
+		} else {
+			attempt = d.send(ctx, sub, delivery)
+		}
+		if err := d.record(ctx, delivery, attempt); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return len(deliveries), errors.Join(errs...)
+}
+
+// record stores the outcome of an attempt, failed deliveries are scheduled
+// for a retry or dead after maxAttempts.
+func (d *Dispatcher) record(ctx context.Context, delivery models.WebhookDelivery, attempt models.WebhookAttempt) error {
+	const op = "Webhook.Dispatcher.Record"
+	log := d.log.With(slog.String("op", op))
+
+	status, nextAttemptAt := models.DeliveryDelivered, attempt.AttemptedAt
+	if attempt.Error != "" {
+		status = models.DeliveryPending
+		nextAttemptAt = attempt.AttemptedAt.Add(events.Backoff(delivery.Attempts, d.maxBackoff))
+		if delivery.Attempts >= d.maxAttempts {
+			status = models.DeliveryDead
+		}
+		log.Warn("webhook delivery failed",
+			slog.Int64("deliveryId", delivery.Id),
+			slog.Int64("subscriptionId", delivery.SubscriptionId),
+			slog.Int("attempts", delivery.Attempts),
+			slog.String("status", status),
+			slog.String("error", attempt.Error),
+		)
+	}
+	return d.store.RecordWebhookAttempt(ctx, attempt, status, nextAttemptAt)
+}
+
+// send makes one delivery attempt, a failed attempt has a non-empty Error.
+func (d *Dispatcher) send(ctx context.Context, sub *models.WebhookSubscription, delivery models.WebhookDelivery) models.WebhookAttempt {
+	attempt := models.WebhookAttempt{DeliveryId: delivery.Id, AttemptedAt: time.Now()}
+
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "sso-webhooks")
+	req.Header.Set(HeaderEventId, delivery.Event.Id)
+	req.Header.Set(HeaderEventType, delivery.Event.Type)
+	req.Header.Set(HeaderTimestamp, fmt.Sprint(attempt.AttemptedAt.Unix()))
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, attempt.AttemptedAt, body))
+
+	resp, err := d.client.Do(req)
+	attempt.Duration = time.Since(attempt.AttemptedAt)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	attempt.StatusCode = resp.StatusCode
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("%s: %s", resp.Status, bytes.TrimSpace(excerpt))
+	}
+	return attempt
+}
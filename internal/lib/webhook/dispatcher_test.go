@@ -0,0 +1,273 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recorded struct {
+	attempt       models.WebhookAttempt
+	status        string
+	nextAttemptAt time.Time
+}
+
+// fakeStore has the subscription sub, getting any other one fails.
+type fakeStore struct {
+	pending  []models.WebhookDelivery
+	sub      models.WebhookSubscription
+	recorded []recorded
+	lease    time.Duration
+}
+
+func (s *fakeStore) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	claimed := s.pending
+	s.pending = nil
+	s.lease = lease
+	for i := range claimed {
+		claimed[i].Attempts++
+	}
+	return claimed, nil
+}
+
+func (s *fakeStore) GetWebhookSubscription(ctx context.Context, id int64) (*models.WebhookSubscription, error) {
+	if id != s.sub.Id {
+		return nil, errors.New("connection reset")
+	}
+	sub := s.sub
+	return &sub, nil
+}
+
+func (s *fakeStore) RecordWebhookAttempt(ctx context.Context, attempt models.WebhookAttempt, status string, nextAttemptAt time.Time) error {
+	s.recorded = append(s.recorded, recorded{attempt: attempt, status: status, nextAttemptAt: nextAttemptAt})
+	return nil
+}
+
+// receiver is a webhook endpoint that verifies signatures like a consumer
+// would and fails while down is set.
+type receiver struct {
+	mu     sync.Mutex
+	secret string
+	down   bool
+	events []models.Event
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	body, _ := io.ReadAll(req.Body)
+	if err := Verify(r.secret, req.Header, body, time.Minute, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if r.down {
+		http.Error(w, "maintenance", http.StatusServiceUnavailable)
+		return
+	}
+	var event models.Event
+	_ = json.Unmarshal(body, &event)
+	r.events = append(r.events, event)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newTestDispatcher allows private hosts, the receivers are on loopback.
+func newTestDispatcher(store Store) *Dispatcher {
+	return NewDispatcher(slog.New(slog.NewTextHandler(io.Discard, nil)), store, config.Webhooks{
+		Timeout:           time.Second,
+		BatchSize:         10,
+		MaxAttempts:       3,
+		MaxBackoff:        time.Minute,
+		AllowPrivateHosts: true,
+	})
+}
+
+func delivery(id int64, attempts int) models.WebhookDelivery {
+	return models.WebhookDelivery{
+		Id:             id,
+		SubscriptionId: 1,
+		Attempts:       attempts,
+		Event: models.Event{
+			Id:         fmt.Sprintf("event-%d", id),
+			Type:       models.EventUserRegistered,
+			Payload:    json.RawMessage(`{"user_id":7}`),
+			OccurredAt: time.Now().UTC(),
+		},
+	}
+}
+
+func TestDispatcherDelivers(t *testing.T) {
+	recv := &receiver{secret: "whsec"}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	store := &fakeStore{
+		pending: []models.WebhookDelivery{delivery(1, 0)},
+		sub:     models.WebhookSubscription{Id: 1, URL: srv.URL, Secret: "whsec", Enabled: true},
+	}
+	n, err := newTestDispatcher(store).DispatchOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	require.Len(t, store.recorded, 1)
+	assert.Equal(t, models.DeliveryDelivered, store.recorded[0].status)
+	assert.Equal(t, http.StatusNoContent, store.recorded[0].attempt.StatusCode)
+	assert.Empty(t, store.recorded[0].attempt.Error)
+
+	require.Len(t, recv.events, 1)
+	assert.Equal(t, "event-1", recv.events[0].Id)
+	assert.JSONEq(t, `{"user_id":7}`, string(recv.events[0].Payload))
+}
+
+func TestDispatcherRetriesAndDeadLetters(t *testing.T) {
+	recv := &receiver{secret: "whsec", down: true}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	store := &fakeStore{
+		pending: []models.WebhookDelivery{delivery(1, 0), delivery(2, 2)},
+		sub:     models.WebhookSubscription{Id: 1, URL: srv.URL, Secret: "whsec", Enabled: true},
+	}
+	_, err := newTestDispatcher(store).DispatchOnce(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, store.recorded, 2)
+	first, last := store.recorded[0], store.recorded[1]
+	assert.Equal(t, models.DeliveryPending, first.status)
+	assert.Equal(t, http.StatusServiceUnavailable, first.attempt.StatusCode)
+	assert.Contains(t, first.attempt.Error, "maintenance")
+	assert.WithinDuration(t, first.attempt.AttemptedAt.Add(time.Second), first.nextAttemptAt, time.Millisecond)
+	// third attempt of the second delivery was the last one
+	assert.Equal(t, models.DeliveryDead, last.status)
+	assert.Empty(t, recv.events)
+}
+
+func TestDispatcherLeaseOutlastsBatch(t *testing.T) {
+	store := &fakeStore{}
+	_, err := newTestDispatcher(store).DispatchOnce(context.Background())
+	require.NoError(t, err)
+
+	// ten requests timing out after a second each
+	assert.Greater(t, store.lease, 10*time.Second)
+}
+
+func TestDispatcherContinuesAfterSubscriptionError(t *testing.T) {
+	recv := &receiver{secret: "whsec"}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	unknown := delivery(1, 0)
+	unknown.SubscriptionId = 2
+	store := &fakeStore{
+		pending: []models.WebhookDelivery{unknown, delivery(2, 0)},
+		sub:     models.WebhookSubscription{Id: 1, URL: srv.URL, Secret: "whsec", Enabled: true},
+	}
+	n, err := newTestDispatcher(store).DispatchOnce(context.Background())
+	assert.ErrorContains(t, err, "connection reset")
+	assert.Equal(t, 2, n)
+
+	// the failed delivery is retried later, the rest of the batch is sent
+	require.Len(t, store.recorded, 2)
+	assert.Equal(t, models.DeliveryPending, store.recorded[0].status)
+	assert.Contains(t, store.recorded[0].attempt.Error, "failed to get subscription")
+	assert.True(t, store.recorded[0].nextAttemptAt.After(store.recorded[0].attempt.AttemptedAt))
+	assert.Equal(t, models.DeliveryDelivered, store.recorded[1].status)
+	require.Len(t, recv.events, 1)
+	assert.Equal(t, "event-2", recv.events[0].Id)
+}
+
+func TestDispatcherRejectedBySecret(t *testing.T) {
+	recv := &receiver{secret: "rotated"}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	store := &fakeStore{
+		pending: []models.WebhookDelivery{delivery(1, 0)},
+		sub:     models.WebhookSubscription{Id: 1, URL: srv.URL, Secret: "whsec", Enabled: true},
+	}
+	_, err := newTestDispatcher(store).DispatchOnce(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, store.recorded, 1)
+	assert.Equal(t, http.StatusUnauthorized, store.recorded[0].attempt.StatusCode)
+	assert.Equal(t, models.DeliveryPending, store.recorded[0].status)
+}
+
+func TestDispatcherRefusesPrivateAddresses(t *testing.T) {
+	recv := &receiver{secret: "whsec"}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	store := &fakeStore{
+		pending: []models.WebhookDelivery{delivery(1, 0)},
+		sub:     models.WebhookSubscription{Id: 1, URL: srv.URL, Secret: "whsec", Enabled: true},
+	}
+	d := NewDispatcher(slog.New(slog.NewTextHandler(io.Discard, nil)), store, config.Webhooks{
+		Timeout:     time.Second,
+		BatchSize:   10,
+		MaxAttempts: 3,
+		MaxBackoff:  time.Minute,
+	})
+	_, err := d.DispatchOnce(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, store.recorded, 1)
+	assert.Equal(t, models.DeliveryPending, store.recorded[0].status)
+	assert.Contains(t, store.recorded[0].attempt.Error, ErrPrivateAddress.Error())
+	assert.Empty(t, recv.events)
+}
+
+func TestDispatcherDoesNotFollowRedirects(t *testing.T) {
+	recv := &receiver{secret: "whsec"}
+	target := httptest.NewServer(recv)
+	defer target.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer redirect.Close()
+
+	store := &fakeStore{
+		pending: []models.WebhookDelivery{delivery(1, 0)},
+		sub:     models.WebhookSubscription{Id: 1, URL: redirect.URL, Secret: "whsec", Enabled: true},
+	}
+	_, err := newTestDispatcher(store).DispatchOnce(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, store.recorded, 1)
+	assert.Equal(t, models.DeliveryPending, store.recorded[0].status)
+	assert.Equal(t, http.StatusTemporaryRedirect, store.recorded[0].attempt.StatusCode)
+	assert.Empty(t, recv.events)
+}
+
+func TestPublicAddr(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::1":   true,
+		"127.0.0.1":            false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"100.64.0.1":           false,
+		"169.254.169.254":      false,
+		"0.0.0.0":              false,
+		"::1":                  false,
+		"fd00::1":              false,
+		"fe80::1":              false,
+		"::ffff:10.0.0.1":      false,
+		"::ffff:93.184.216.34": true,
+	} {
+		assert.Equal(t, public, PublicAddr(netip.MustParseAddr(addr)), addr)
+	}
+}
@@ -0,0 +1,31 @@
+package webhook
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignVerify(t *testing.T) {
+	const secret = "whsec"
+	body := []byte(`{"id":"1","type":"user.registered"}`)
+	sentAt := time.Unix(1735787045, 0)
+
+	header := http.Header{}
+	header.Set(HeaderTimestamp, "1735787045")
+	header.Set(HeaderSignature, Sign(secret, sentAt, body))
+
+	assert.NoError(t, Verify(secret, header, body, 5*time.Minute, sentAt.Add(time.Minute)))
+	assert.ErrorIs(t, Verify("other", header, body, 5*time.Minute, sentAt), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify(secret, header, []byte(`{"id":"2"}`), 5*time.Minute, sentAt), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify(secret, header, body, 5*time.Minute, sentAt.Add(10*time.Minute)), ErrStaleTimestamp)
+
+	// replaying the body with a fresh timestamp breaks the signature
+	header.Set(HeaderTimestamp, "1735787645")
+	assert.ErrorIs(t, Verify(secret, header, body, 5*time.Minute, sentAt.Add(10*time.Minute)), ErrInvalidSignature)
+
+	header.Set(HeaderSignature, "v0=abc")
+	assert.ErrorIs(t, Verify(secret, header, body, 5*time.Minute, sentAt.Add(10*time.Minute)), ErrInvalidSignature)
+}
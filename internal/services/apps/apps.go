@@ -0,0 +1,341 @@
+package apps
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/secrets"
+	"sso/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	secretSize = 32
+
+	minIdleTimeout = 2 * time.Minute
+
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+type Apps struct {
+	log         *slog.Logger
+	appSaver    AppSaver
+	appProvider AppProvider
+	appUpdater  AppUpdater
+	appDeleter  AppDeleter
+}
+
+type AppSaver interface {
+	SaveApp(ctx context.Context, app *models.App) (int64, error)
+}
+
+type AppProvider interface {
+	GetAppById(ctx context.Context, appId int) (*models.App, error)
+	ListApps(ctx context.Context, afterId int64, limit int) ([]models.App, error)
+}
+
+type AppUpdater interface {
+	UpdateApp(ctx context.Context, app *models.App) error
+	UpdateAppSecret(ctx context.Context, appId int64, secret string) error
+}
+
+type AppDeleter interface {
+	DeleteApp(ctx context.Context, appId int64) error
+}
+
+var (
+	ErrAppNotFound         = errors.New("app not found")
+	ErrAppAlreadyExists    = errors.New("app already exists")
+	ErrInvalidApp          = errors.New("invalid app")
+	ErrInvalidPageToken    = errors.New("invalid page token")
+	ErrInternalServerError = errors.New("internal server error")
+)
+
+// NewAppsService creates a new instance of Apps with the provided dependencies.
+func NewAppsService(
+	log *slog.Logger,
+	appSaver AppSaver,
+	appProvider AppProvider,
+	appUpdater AppUpdater,
+	appDeleter AppDeleter) *Apps {
+	return &Apps{
+		log:         log,
+		appSaver:    appSaver,
+		appProvider: appProvider,
+		appUpdater:  appUpdater,
+		appDeleter:  appDeleter,
+	}
+}
+
+// CreateApp registers a new app with a generated secret. The returned app is
+// the only place where the plaintext secret is ever exposed.
+func (a *Apps) CreateApp(ctx context.Context, app models.App) (*models.App, error) {
+	const op = "Apps.CreateApp"
+	log := a.log.With(slog.String("op", op), slog.String("name", app.Name))
+
+	if err := validateApp(&app); err != nil {
+		log.Info("invalid app", sl.Err(err))
+		return nil, err
+	}
+
+	secret, err := secrets.Generate(secretSize)
+	if err != nil {
+		log.Error("failed to generate app secret", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+	app.Secret = secret
+
+	appId, err := a.appSaver.SaveApp(ctx, &app)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppAlreadyExists) {
+			log.Info("app already exists", sl.Err(err))
+			return nil, ErrAppAlreadyExists
+		}
+		log.Error("failed to save app", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+
+	created, err := a.getApp(ctx, appId)
+	if err != nil {
+		log.Error("failed to get created app", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+
+	log.Info("app created successfully", slog.Int64("appId", appId))
+	return created, nil
+}
+
+// GetApp returns app metadata without its secret.
+func (a *Apps) GetApp(ctx context.Context, appId int64) (*models.App, error) {
+	const op = "Apps.GetApp"
+	log := a.log.With(slog.String("op", op), slog.Int64("appId", appId))
+
+	app, err := a.getApp(ctx, appId)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Info("app not found", sl.Err(err))
+			return nil, ErrAppNotFound
+		}
+		log.Error("failed to get app", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+
+	app.Secret = ""
+	return app, nil
+}
+
+// ListApps returns one page of apps without secrets and the token of the next
+// page, which is empty on the last page.
+func (a *Apps) ListApps(ctx context.Context, pageToken string, pageSize int) ([]models.App, string, error) {
+	const op = "Apps.ListApps"
+	log := a.log.With(slog.String("op", op))
+
+	var afterId int64
+	if pageToken != "" {
+		var err error
+		if afterId, err = strconv.ParseInt(pageToken, 10, 64); err != nil {
+			return nil, "", ErrInvalidPageToken
+		}
+	}
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	pageSize = min(pageSize, maxPageSize)
+
+	apps, err := a.appProvider.ListApps(ctx, afterId, pageSize+1)
+	if err != nil {
+		log.Error("failed to list apps", sl.Err(err))
+		return nil, "", ErrInternalServerError
+	}
+
+	nextPageToken := ""
+	if len(apps) > pageSize {
+		apps = apps[:pageSize]
+		nextPageToken = strconv.FormatInt(apps[pageSize-1].Id, 10)
+	}
+	for i := range apps {
+		apps[i].Secret = ""
+	}
+
+	return apps, nextPageToken, nil
+}
+
+func (a *Apps) UpdateApp(ctx context.Context, appId int64, update models.AppUpdate) (*models.App, error) {
+	const op = "Apps.UpdateApp"
+	log := a.log.With(slog.String("op", op), slog.Int64("appId", appId))
+
+	app, err := a.getApp(ctx, appId)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Info("app not found", sl.Err(err))
+			return nil, ErrAppNotFound
+		}
+		log.Error("failed to get app", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+
+	if update.Name != nil {
+		app.Name = *update.Name
+	}
+	if update.RedirectURIs != nil {
+		app.RedirectURIs = *update.RedirectURIs
+	}
+	if update.TokenTTL != nil {
+		app.TokenTTL = *update.TokenTTL
+	}
+	if update.Enabled != nil {
+		app.Enabled = *update.Enabled
+	}
+	if update.ClaimRules != nil {
+		app.ClaimRules = *update.ClaimRules
+	}
+	if update.IdleTimeout != nil {
+		app.SessionPolicy.IdleTimeout = *update.IdleTimeout
+	}
+	if update.SessionLifetime != nil {
+		app.SessionPolicy.Lifetime = *update.SessionLifetime
+	}
+	if update.MaxSessions != nil {
+		app.SessionPolicy.MaxSessions = *update.MaxSessions
+	}
+	if update.SessionLimitAction != nil {
+		app.SessionPolicy.LimitAction = *update.SessionLimitAction
+	}
+	if update.StepUpAfter != nil {
+		app.SessionPolicy.StepUpAfter = *update.StepUpAfter
+	}
+
+	if err := validateApp(app); err != nil {
+		log.Info("invalid app", sl.Err(err))
+		return nil, err
+	}
+
+	if err := a.appUpdater.UpdateApp(ctx, app); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Info("app not found", sl.Err(err))
+			return nil, ErrAppNotFound
+		}
+		if errors.Is(err, storage.ErrAppAlreadyExists) {
+			log.Info("app name is taken", sl.Err(err))
+			return nil, ErrAppAlreadyExists
+		}
+		log.Error("failed to update app", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+
+	log.Info("app updated successfully")
+	return a.GetApp(ctx, appId)
+}
+
+// RotateAppSecret replaces the app secret and returns the new one. Tokens
+// signed with the old secret stop validating immediately.
+func (a *Apps) RotateAppSecret(ctx context.Context, appId int64) (string, error) {
+	const op = "Apps.RotateAppSecret"
+	log := a.log.With(slog.String("op", op), slog.Int64("appId", appId))
+
+	secret, err := secrets.Generate(secretSize)
+	if err != nil {
+		log.Error("failed to generate app secret", sl.Err(err))
+		return "", ErrInternalServerError
+	}
+
+	if err := a.appUpdater.UpdateAppSecret(ctx, appId, secret); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Info("app not found", sl.Err(err))
+			return "", ErrAppNotFound
+		}
+		log.Error("failed to update app secret", sl.Err(err))
+		return "", ErrInternalServerError
+	}
+
+	log.Info("app secret rotated successfully")
+	return secret, nil
+}
+
+func (a *Apps) DeleteApp(ctx context.Context, appId int64) error {
+	const op = "Apps.DeleteApp"
+	log := a.log.With(slog.String("op", op), slog.Int64("appId", appId))
+
+	if err := a.appDeleter.DeleteApp(ctx, appId); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Info("app not found", sl.Err(err))
+			return ErrAppNotFound
+		}
+		log.Error("failed to delete app", sl.Err(err))
+		return ErrInternalServerError
+	}
+
+	log.Info("app deleted successfully")
+	return nil
+}
+
+func (a *Apps) getApp(ctx context.Context, appId int64) (*models.App, error) {
+	return a.appProvider.GetAppById(ctx, int(appId))
+}
+
+func validateApp(app *models.App) error {
+	app.Name = strings.TrimSpace(app.Name)
+	if app.Name == "" {
+		return errors.Join(ErrInvalidApp, errors.New("name must be provided"))
+	}
+	if app.TokenTTL < 0 || app.TokenTTL%time.Second != 0 {
+		return errors.Join(ErrInvalidApp, errors.New("token ttl must be a non-negative number of seconds"))
+	}
+	for _, uri := range app.RedirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" {
+			return errors.Join(ErrInvalidApp, errors.New("redirect uri must be an absolute url without fragment: "+uri))
+		}
+	}
+
+	claims := make(map[string]bool, len(app.ClaimRules))
+	for _, rule := range app.ClaimRules {
+		switch {
+		case rule.Claim == "":
+			return errors.Join(ErrInvalidApp, errors.New("claim rule must name a claim"))
+		case jwt.IsReservedClaim(rule.Claim):
+			return errors.Join(ErrInvalidApp, errors.New("claim is reserved: "+rule.Claim))
+		case claims[rule.Claim]:
+			return errors.Join(ErrInvalidApp, errors.New("claim is mapped twice: "+rule.Claim))
+		case !models.IsKnownAttribute(rule.Attribute):
+			return errors.Join(ErrInvalidApp, errors.New("unknown profile attribute: "+rule.Attribute))
+		}
+		claims[rule.Claim] = true
+	}
+
+	return validateSessionPolicy(&app.SessionPolicy)
+}
+
+func validateSessionPolicy(policy *models.SessionPolicy) error {
+	for name, d := range map[string]time.Duration{
+		"idle timeout":     policy.IdleTimeout,
+		"session lifetime": policy.Lifetime,
+		"step-up age":      policy.StepUpAfter,
+	} {
+		if d < 0 || d%time.Second != 0 {
+			return errors.Join(ErrInvalidApp, errors.New(name+" must be a non-negative number of seconds"))
+		}
+	}
+	// last use of a session is recorded with minute granularity
+	if policy.IdleTimeout > 0 && policy.IdleTimeout < minIdleTimeout {
+		return errors.Join(ErrInvalidApp, errors.New("idle timeout must be at least "+minIdleTimeout.String()))
+	}
+	if policy.MaxSessions < 0 {
+		return errors.Join(ErrInvalidApp, errors.New("max sessions must not be negative"))
+	}
+
+	if policy.LimitAction == "" {
+		policy.LimitAction = models.SessionLimitEvictOldest
+	}
+	if policy.LimitAction != models.SessionLimitEvictOldest && policy.LimitAction != models.SessionLimitDeny {
+		return errors.Join(ErrInvalidApp, errors.New("session limit action must be evict_oldest or deny"))
+	}
+	return nil
+}
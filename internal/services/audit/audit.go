@@ -0,0 +1,177 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"sso/internal/domain/models"
+	auditlib "sso/internal/lib/audit"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/requestinfo"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+
+	verifyBatchSize = 1000
+)
+
+type Audit struct {
+	log           *slog.Logger
+	eventSaver    EventSaver
+	eventProvider EventProvider
+}
+
+type EventSaver interface {
+	SaveAuditEvent(ctx context.Context, event *models.AuditEvent) error
+}
+
+type EventProvider interface {
+	ListAuditEvents(ctx context.Context, filter models.AuditFilter, afterId int64, limit int) ([]models.AuditEvent, error)
+	AuditHead(ctx context.Context) (*models.AuditAnchor, error)
+}
+
+var (
+	ErrInvalidPageToken    = errors.New("invalid page token")
+	ErrInternalServerError = errors.New("internal server error")
+)
+
+// NewAuditService creates a new instance of Audit with the provided dependencies.
+func NewAuditService(
+	log *slog.Logger,
+	eventSaver EventSaver,
+	eventProvider EventProvider) *Audit {
+	return &Audit{
+		log:           log,
+		eventSaver:    eventSaver,
+		eventProvider: eventProvider,
+	}
+}
+
+// Record appends the event to the audit log, filling in the request origin
+// and, for admin calls, the actor from ctx. A failure is logged but does not
+// fail the audited action.
+func (a *Audit) Record(ctx context.Context, event models.AuditEvent) {
+	const op = "Audit.Record"
+
+	info := requestinfo.FromContext(ctx)
+	event.IP = info.IP
+	event.UserAgent = info.UserAgent
+	if event.ActorId == 0 {
+		event.ActorId = info.ActorId
+	}
+
+	if err := a.eventSaver.SaveAuditEvent(ctx, &event); err != nil {
+		a.log.Error("failed to save audit event",
+			slog.String("op", op),
+			slog.String("action", event.Action),
+			slog.String("outcome", event.Outcome),
+			slog.Int64("subjectId", event.SubjectId),
+			sl.Err(err),
+		)
+	}
+}
+
+// ListAuditEvents returns one page of events matching filter and the token
+// of the next page, which is empty on the last page.
+func (a *Audit) ListAuditEvents(ctx context.Context, filter models.AuditFilter, pageToken string, pageSize int) ([]models.AuditEvent, string, error) {
+	const op = "Audit.ListAuditEvents"
+	log := a.log.With(slog.String("op", op))
+
+	var afterId int64
+	if pageToken != "" {
+		var err error
+		if afterId, err = strconv.ParseInt(pageToken, 10, 64); err != nil {
+			return nil, "", ErrInvalidPageToken
+		}
+	}
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	pageSize = min(pageSize, maxPageSize)
+
+	events, err := a.eventProvider.ListAuditEvents(ctx, filter, afterId, pageSize+1)
+	if err != nil {
+		log.Error("failed to list audit events", sl.Err(err))
+		return nil, "", ErrInternalServerError
+	}
+
+	nextPageToken := ""
+	if len(events) > pageSize {
+		events = events[:pageSize]
+		nextPageToken = strconv.FormatInt(events[pageSize-1].Id, 10)
+	}
+	return events, nextPageToken, nil
+}
+
+// Head returns the id and hash of the newest event, to be kept outside the
+// database as an anchor for VerifyChain. The anchor is zero if there are no
+// events.
+func (a *Audit) Head(ctx context.Context) (*models.AuditAnchor, error) {
+	const op = "Audit.Head"
+
+	head, err := a.eventProvider.AuditHead(ctx)
+	if err != nil {
+		a.log.Error("failed to get audit head", slog.String("op", op), sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+	return head, nil
+}
+
+// VerifyChain walks the whole audit log and reports the first event whose
+// hash or link to the previous event does not match. With an anchor the
+// anchored event also has to be there with the anchored hash.
+func (a *Audit) VerifyChain(ctx context.Context, anchor *models.AuditAnchor) (*models.AuditVerification, error) {
+	const op = "Audit.VerifyChain"
+	log := a.log.With(slog.String("op", op))
+
+	result := &models.AuditVerification{Valid: true}
+	broken := func(eventId int64, err error) *models.AuditVerification {
+		result.Valid = false
+		result.BrokenAt = eventId
+		result.Reason = err.Error()
+		log.Warn("audit chain is broken", slog.Int64("eventId", eventId), sl.Err(err))
+		return result
+	}
+
+	anchored := anchor == nil
+	var prev []byte
+	var afterId int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		events, err := a.eventProvider.ListAuditEvents(ctx, models.AuditFilter{}, afterId, verifyBatchSize)
+		if err != nil {
+			log.Error("failed to list audit events", sl.Err(err))
+			return nil, ErrInternalServerError
+		}
+
+		for i := range events {
+			result.Checked++
+			if err := auditlib.Verify(prev, &events[i]); err != nil {
+				return broken(events[i].Id, err), nil
+			}
+			if anchor != nil && events[i].Id == anchor.Id {
+				if !bytes.Equal(events[i].Hash, anchor.Hash) {
+					return broken(events[i].Id, auditlib.ErrAnchorMismatch), nil
+				}
+				anchored = true
+			}
+			prev = events[i].Hash
+			result.Head = &models.AuditAnchor{Id: events[i].Id, Hash: events[i].Hash}
+		}
+
+		if len(events) < verifyBatchSize {
+			if !anchored {
+				return broken(anchor.Id, auditlib.ErrAnchorMissing), nil
+			}
+			return result, nil
+		}
+		afterId = events[len(events)-1].Id
+	}
+}
@@ -2,45 +2,120 @@ package auth
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
-	"golang.org/x/crypto/bcrypt"
+	"fmt"
 	"log/slog"
 	"sso/internal/domain/models"
-	"sso/internal/lib/jwt"
+	"sso/internal/lib/identity"
 	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/notify"
+	passwordlib "sso/internal/lib/password"
+	"sso/internal/lib/requestinfo"
 	"sso/internal/storage"
+	"strconv"
+	"strings"
 	"time"
+
+	"go.opentelemetry.io/otel"
 )
 
+var tracer = otel.Tracer("sso/internal/services/auth")
+
 type Auth struct {
-	log          *slog.Logger
-	userSaver    UserSaver
-	userProvider UserProvider
-	appProvider  AppProvider
-	adminSetter  AdminSetter
-	tokenTTL     time.Duration
+	log             *slog.Logger
+	userSaver       UserSaver
+	userProvider    UserProvider
+	appProvider     AppProvider
+	adminSetter     AdminSetter
+	profileProvider ProfileProvider
+	passUpdater     PasswordUpdater
+	hasher          PasswordHasher
+	auditor         Auditor
+	loginHistory    LoginHistory
+	notifier        Notifier
+	sessions        SessionStore
+	metrics         Metrics
+	tokenTTL        time.Duration
 }
 
 type UserSaver interface {
-	SaveUser(email string, passHash []byte) (int64, error)
+	SaveUser(ctx context.Context, email string, passHash []byte) (int64, error)
 }
 
 type UserProvider interface {
-	GetUserByEmail(email string) (*models.User, error)
-	IsAdmin(userId int64) (bool, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+	GetUserByPhone(ctx context.Context, phone string) (*models.User, error)
+	GetUserById(ctx context.Context, userId int64) (*models.User, error)
+	IsAdmin(ctx context.Context, userId int64) (bool, error)
 }
 
 type AppProvider interface {
-	GetAppById(appId int) (*models.App, error)
+	GetAppById(ctx context.Context, appId int) (*models.App, error)
 }
 
 type AdminSetter interface {
-	SetAdmin(userId int64, isAdmin bool) (bool, error)
+	SetAdmin(ctx context.Context, userId int64, isAdmin bool) (bool, error)
+}
+
+type ProfileProvider interface {
+	GetProfile(ctx context.Context, userId int64) (*models.Profile, error)
+	GetAppMetadata(ctx context.Context, userId int64, appId int64) (json.RawMessage, error)
+}
+
+type PasswordUpdater interface {
+	UpdatePassHash(ctx context.Context, userId int64, passHash []byte) error
+}
+
+type PasswordHasher interface {
+	Hash(password string) ([]byte, error)
+	Verify(hash []byte, password string) (needsRehash bool, err error)
+}
+
+type Auditor interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+type LoginHistory interface {
+	SaveLoginAttempt(ctx context.Context, attempt *models.LoginAttempt) error
+	HasDevices(ctx context.Context, userId int64) (bool, error)
+	SaveDevice(ctx context.Context, userId int64, device *models.Device) (bool, error)
+}
+
+type SessionStore interface {
+	SaveSession(ctx context.Context, session *models.Session) error
+	GetSession(ctx context.Context, sessionId string) (*models.Session, error)
+	SaveSessionWithinLimit(ctx context.Context, session *models.Session, limit int, evict bool) ([]string, error)
+	TouchSession(ctx context.Context, sessionId string) error
+	ExtendSession(ctx context.Context, sessionId string, expiresAt time.Time) error
+	ReauthenticateSession(ctx context.Context, sessionId string) error
+	RevokeSession(ctx context.Context, sessionId string) error
+}
+
+type Notifier interface {
+	Send(ctx context.Context, msg notify.Message) error
+}
+
+// Metrics counts auth outcomes, appId 0 stands for an unknown app.
+type Metrics interface {
+	LoginAttempt(appId int64, outcome string)
+	Registered()
+	LoginRejectedDisabled()
+	TokenIssued(appId int64)
 }
 
 var (
 	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrUserAlreadyExists   = errors.New("user already exists")
 	ErrInternalServerError = errors.New("internal server error")
+	ErrAppDisabled         = errors.New("app is disabled")
+	ErrUserDisabled        = errors.New("user is disabled")
+	ErrInvalidToken        = errors.New("invalid token")
+	ErrInvalidEmail        = errors.New("invalid email")
+	ErrTooManySessions     = errors.New("too many active sessions")
+	ErrStepUpRequired      = errors.New("password must be entered again")
 )
 
 // NewAuthService creates a new instance of Auth with the provided dependencies.
@@ -50,40 +125,93 @@ func NewAuthService(
 	userProvider UserProvider,
 	appProvider AppProvider,
 	adminSetter AdminSetter,
+	profileProvider ProfileProvider,
+	passUpdater PasswordUpdater,
+	hasher PasswordHasher,
+	auditor Auditor,
+	loginHistory LoginHistory,
+	notifier Notifier,
+	sessions SessionStore,
+	metrics Metrics,
 	tokenTTL time.Duration) *Auth {
 	return &Auth{
-		log:          log,
-		userSaver:    userSaver,
-		userProvider: userProvider,
-		appProvider:  appProvider,
-		tokenTTL:     tokenTTL,
+		log:             log,
+		userSaver:       userSaver,
+		userProvider:    userProvider,
+		appProvider:     appProvider,
+		adminSetter:     adminSetter,
+		profileProvider: profileProvider,
+		passUpdater:     passUpdater,
+		hasher:          hasher,
+		auditor:         auditor,
+		loginHistory:    loginHistory,
+		notifier:        notifier,
+		sessions:        sessions,
+		metrics:         metrics,
+		tokenTTL:        tokenTTL,
 	}
 }
 
-func (a *Auth) Login(ctx context.Context, email string, password string, appId int) (string, error) {
+// Login issues a token for the user identified by email, username or phone number.
+func (a *Auth) Login(ctx context.Context, login string, password string, appId int) (string, error) {
 	const op = "Auth.Login"
-	log := a.log.With(slog.String("op", op), slog.String("email", email), slog.Int("appId", appId))
-	user, err := a.userProvider.GetUserByEmail(email)
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	log := sl.FromContext(ctx, a.log).With(slog.String("op", op), slog.String("login", login), slog.Int("appId", appId))
+
+	kind, identifier, err := identity.Parse(login)
+	if err != nil {
+		log.Info("invalid login identifier", sl.Err(err))
+		a.recordLogin(ctx, login, 0, appId, "invalid login identifier")
+		return "", ErrInvalidCredentials
+	}
+
+	var user *models.User
+	switch kind {
+	case identity.KindUsername:
+		user, err = a.userProvider.GetUserByUsername(ctx, identifier)
+	case identity.KindPhone:
+		user, err = a.userProvider.GetUserByPhone(ctx, identifier)
+	default:
+		user, err = a.userProvider.GetUserByEmail(ctx, identifier)
+	}
 	//зарефакторить этот блок по итогу реализации стореджа, потому что не ясно как будет выглядеть ненайденный юзер
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) || user == nil {
 			log.Info("user not found", sl.Err(err))
+			a.recordLogin(ctx, identifier, 0, appId, "user not found")
 			return "", ErrInvalidCredentials
 		}
-		log.Error("failed to get user by email", sl.Err(err))
+		log.Error("failed to get user", sl.Err(err))
 		return "", ErrInternalServerError
 	}
 
-	err = bcrypt.CompareHashAndPassword(user.PassHash, []byte(password))
+	needsRehash, err := a.verifyPassword(ctx, user.PassHash, password)
 	if err != nil {
-		log.Info("password mismatch", sl.Err(err))
+		if errors.Is(err, passwordlib.ErrMismatch) {
+			log.Info("password mismatch", sl.Err(err))
+			a.recordLogin(ctx, identifier, user.Id, appId, "password mismatch")
+			return "", ErrInvalidCredentials
+		}
+		log.Error("failed to verify password", sl.Err(err))
 		return "", ErrInternalServerError
 	}
+	if needsRehash {
+		a.rehashPassword(ctx, log, user.Id, password)
+	}
 
-	app, err := a.appProvider.GetAppById(appId)
+	if user.IsDisabled() {
+		log.Info("user is disabled", slog.Int64("userId", user.Id))
+		a.recordLogin(ctx, identifier, user.Id, appId, "user is disabled")
+		a.metrics.LoginRejectedDisabled()
+		return "", ErrUserDisabled
+	}
+
+	app, err := a.appProvider.GetAppById(ctx, appId)
 	if err != nil {
 		if errors.Is(err, storage.ErrAppNotFound) {
 			log.Info("app not found", sl.Err(err))
+			a.recordLogin(ctx, identifier, user.Id, appId, "app not found")
 			return "", ErrInvalidCredentials
 		}
 
@@ -91,58 +219,205 @@ func (a *Auth) Login(ctx context.Context, email string, password string, appId i
 		return "", ErrInternalServerError
 	}
 
-	token, err := jwt.NewToken(user, app, a.tokenTTL)
+	if !app.Enabled {
+		log.Info("app is disabled")
+		a.recordLogin(ctx, identifier, user.Id, appId, "app is disabled")
+		return "", ErrAppDisabled
+	}
+
+	session, err := a.newSession(ctx, log, user, app)
+	if err != nil {
+		if errors.Is(err, ErrTooManySessions) {
+			a.recordLogin(ctx, identifier, user.Id, appId, "session limit reached")
+			return "", err
+		}
+		log.Error("failed to create session", sl.Err(err))
+		return "", ErrInternalServerError
+	}
+
+	token, err := a.issueToken(ctx, log, user, app, session)
+	if err != nil {
+		return "", err
+	}
 
 	log.Info("user logged in successfully", slog.Int64("userId", user.Id), slog.String("appName", app.Name))
+	a.recordLogin(ctx, identifier, user.Id, appId, "")
+	a.checkDevice(ctx, log, user)
 	return token, nil
 }
 
-func (a *Auth) Logout(ctx context.Context, token string) (bool, error) {
-	// Implement logout logic here
-	return false, nil
-}
-
 func (a *Auth) Register(ctx context.Context, email string, password string) (int64, error) {
 	const op = "Auth.RegisterNewUser"
-	log := a.log.With(slog.String("op", op), slog.String("email", email))
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	log := sl.FromContext(ctx, a.log).With(slog.String("op", op), slog.String("email", email))
 
-	passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	email, err := identity.NormalizeEmail(email)
+	if err != nil {
+		log.Info("invalid email", sl.Err(err))
+		a.audit(ctx, models.AuditRegister, 0, 0, "invalid email")
+		return 0, ErrInvalidEmail
+	}
+
+	passHash, err := a.hashPassword(ctx, password)
 	if err != nil {
 		log.Error("failed to hash password", sl.Err(err))
 		return 0, ErrInternalServerError
 	}
 
-	_, err = a.userProvider.GetUserByEmail(email)
+	userId, err := a.userSaver.SaveUser(ctx, email, passHash)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserAlreadyExists) {
 			log.Info("user already exists", sl.Err(err))
-			return 0, ErrInvalidCredentials
-		} else if !errors.Is(err, storage.ErrUserNotFound) {
-			log.Error("failed to get user by email", sl.Err(err))
-			return 0, ErrInternalServerError
+			a.audit(ctx, models.AuditRegister, 0, 0, "user already exists")
+			return 0, ErrUserAlreadyExists
 		}
-	}
-
-	userId, err := a.userSaver.SaveUser(email, passHash)
-	if err != nil {
 		log.Error("failed to save user", sl.Err(err))
 		return 0, ErrInternalServerError
 	}
 
 	log.Info("user registered successfully", slog.Int64("userId", userId))
+	a.audit(ctx, models.AuditRegister, userId, 0, "")
+	a.metrics.Registered()
 	return userId, nil
 }
 
+// hashPassword and verifyPassword trace the hasher, it is the slowest part of
+// logins and registrations by design.
+func (a *Auth) hashPassword(ctx context.Context, password string) ([]byte, error) {
+	_, span := tracer.Start(ctx, "PasswordHasher.Hash")
+	defer span.End()
+	return a.hasher.Hash(password)
+}
+
+func (a *Auth) verifyPassword(ctx context.Context, hash []byte, password string) (bool, error) {
+	_, span := tracer.Start(ctx, "PasswordHasher.Verify")
+	defer span.End()
+	return a.hasher.Verify(hash, password)
+}
+
+// rehashPassword upgrades a hash made with outdated settings. Failing to do so
+// is not a reason to fail the login, the next login retries.
+func (a *Auth) rehashPassword(ctx context.Context, log *slog.Logger, userId int64, password string) {
+	passHash, err := a.hashPassword(ctx, password)
+	if err != nil {
+		log.Error("failed to rehash password", sl.Err(err))
+		return
+	}
+	if err := a.passUpdater.UpdatePassHash(ctx, userId, passHash); err != nil {
+		log.Error("failed to save rehashed password", sl.Err(err))
+		return
+	}
+	log.Info("password rehashed with current settings", slog.Int64("userId", userId))
+}
+
+// audit records an action on the user, a non-empty reason marks a failure.
+// The user is both the actor and the subject of auth actions.
+func (a *Auth) audit(ctx context.Context, action string, userId int64, appId int, reason string) {
+	event := models.AuditEvent{
+		Action:    action,
+		ActorId:   userId,
+		SubjectId: userId,
+		AppId:     int64(appId),
+		Outcome:   models.AuditSuccess,
+		Reason:    reason,
+	}
+	if reason != "" {
+		event.Outcome = models.AuditFailure
+	}
+	a.auditor.Record(ctx, event)
+}
+
+// recordLogin saves the login attempt to the login history, the audit log
+// and the metrics. A non-empty reason marks a failed attempt.
+func (a *Auth) recordLogin(ctx context.Context, login string, userId int64, appId int, reason string) {
+	a.audit(ctx, models.AuditLogin, userId, appId, reason)
+	a.metrics.LoginAttempt(a.knownAppId(ctx, appId), loginOutcome(reason))
+
+	info := requestinfo.FromContext(ctx)
+	attempt := &models.LoginAttempt{
+		UserId:    userId,
+		AppId:     int64(appId),
+		Login:     login,
+		IP:        info.IP,
+		UserAgent: info.UserAgent,
+		DeviceId:  info.DeviceId,
+		Success:   reason == "",
+		Reason:    reason,
+	}
+	if err := a.loginHistory.SaveLoginAttempt(ctx, attempt); err != nil {
+		sl.FromContext(ctx, a.log).Error("failed to save login attempt", slog.Int64("userId", userId), sl.Err(err))
+	}
+}
+
+// knownAppId returns appId if the app exists and 0 otherwise, so metrics are
+// not labeled with whatever ids clients send.
+func (a *Auth) knownAppId(ctx context.Context, appId int) int64 {
+	if _, err := a.appProvider.GetAppById(ctx, appId); err != nil {
+		return 0
+	}
+	return int64(appId)
+}
+
+func loginOutcome(reason string) string {
+	if reason == "" {
+		return "success"
+	}
+	return strings.ReplaceAll(reason, " ", "_")
+}
+
+// checkDevice remembers the device the user logged in from and notifies the
+// user the first time a device shows up. The first device of an account is
+// not reported, it is the one the account was created from.
+func (a *Auth) checkDevice(ctx context.Context, log *slog.Logger, user *models.User) {
+	info := requestinfo.FromContext(ctx)
+	if info.DeviceId == "" {
+		return
+	}
+
+	hadDevices, err := a.loginHistory.HasDevices(ctx, user.Id)
+	if err != nil {
+		log.Error("failed to check known devices", sl.Err(err))
+		return
+	}
+	isNew, err := a.loginHistory.SaveDevice(ctx, user.Id, &models.Device{DeviceId: info.DeviceId, IP: info.IP, UserAgent: info.UserAgent})
+	if err != nil {
+		log.Error("failed to save device", sl.Err(err))
+		return
+	}
+	if !isNew || !hadDevices {
+		return
+	}
+
+	log.Info("login from a new device", slog.String("deviceId", info.DeviceId))
+	msg := notify.Message{
+		To:      user.Email,
+		Subject: "New login to your account",
+		Body: fmt.Sprintf(
+			"Your account was just used to log in from a new device.\n\nTime: %s\nIP address: %s\nDevice: %s\n\nIf this was not you, change your password.",
+			time.Now().UTC().Format(time.RFC1123), info.IP, info.UserAgent,
+		),
+	}
+	// the login does not wait for the notification to be delivered
+	go func() {
+		if err := a.notifier.Send(context.WithoutCancel(ctx), msg); err != nil {
+			log.Error("failed to send new device notification", sl.Err(err))
+		}
+	}()
+}
+
 func (a *Auth) IsAdmin(ctx context.Context, userId int64) (bool, error) {
 	// Implement admin check logic here
 	const op = "Auth.IsAdmin"
-	log := a.log.With(slog.String("op", op), slog.Int64("userId", userId))
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	log := sl.FromContext(ctx, a.log).With(slog.String("op", op), slog.Int64("userId", userId))
 
-	isAdmin, err := a.userProvider.IsAdmin(userId)
+	isAdmin, err := a.userProvider.IsAdmin(ctx, userId)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
 			log.Info("user not found", sl.Err(err))
-			return false, ErrInvalidCredentials
+			return false, ErrUserNotFound
 		}
 		log.Error("failed to check if user is admin", sl.Err(err))
 		return false, ErrInternalServerError
@@ -152,18 +427,32 @@ func (a *Auth) IsAdmin(ctx context.Context, userId int64) (bool, error) {
 
 func (a *Auth) SetAdmin(ctx context.Context, userId int64, isAdmin bool) (bool, error) {
 	const op = "Auth.SetAdmin"
-	log := a.log.With(slog.String("op", op), slog.Int64("userId", userId), slog.Bool("isAdmin", isAdmin))
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	log := sl.FromContext(ctx, a.log).With(slog.String("op", op), slog.Int64("userId", userId), slog.Bool("isAdmin", isAdmin))
 
-	isAdmin, err := a.adminSetter.SetAdmin(userId, isAdmin)
+	isAdmin, err := a.adminSetter.SetAdmin(ctx, userId, isAdmin)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
 			log.Info("user not found", sl.Err(err))
-			return false, ErrInvalidCredentials
+			a.auditor.Record(ctx, models.AuditEvent{
+				Action:    models.AuditSetAdmin,
+				SubjectId: userId,
+				Outcome:   models.AuditFailure,
+				Reason:    "user not found",
+			})
+			return false, ErrUserNotFound
 		}
 		log.Error("failed to set admin status", sl.Err(err))
 		return false, ErrInternalServerError
 	}
 
 	log.Info("admin status updated successfully", slog.Int64("userId", userId), slog.Bool("isAdmin", isAdmin))
+	a.auditor.Record(ctx, models.AuditEvent{
+		Action:    models.AuditSetAdmin,
+		SubjectId: userId,
+		Outcome:   models.AuditSuccess,
+		Reason:    "is_admin=" + strconv.FormatBool(isAdmin),
+	})
 	return isAdmin, nil
 }
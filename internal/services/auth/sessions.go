@@ -0,0 +1,322 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/logger/sl"
+	passwordlib "sso/internal/lib/password"
+	"sso/internal/lib/requestinfo"
+	"sso/internal/lib/secrets"
+	"sso/internal/storage"
+	"time"
+)
+
+// sessionIdSize is the number of random bytes in a session id.
+const sessionIdSize = 16
+
+// tokenState is what a valid token resolves to.
+type tokenState struct {
+	claims  *jwt.Claims
+	app     *models.App
+	user    *models.User
+	session *models.Session
+}
+
+// ValidateToken checks the token signature and expiration, that its user is
+// still allowed to use it and that its session satisfies the app session
+// policy. ErrStepUpRequired means the session is valid but StepUp has to be
+// called before the token is accepted again.
+func (a *Auth) ValidateToken(ctx context.Context, token string) (*jwt.Claims, error) {
+	const op = "Auth.ValidateToken"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	log := sl.FromContext(ctx, a.log).With(slog.String("op", op))
+
+	state, err := a.validateToken(ctx, log, token, true)
+	if err != nil {
+		return nil, err
+	}
+	return state.claims, nil
+}
+
+// Refresh issues a new token for the session of a valid token. Without a
+// session lifetime in the app policy the session is extended by the token TTL.
+func (a *Auth) Refresh(ctx context.Context, token string) (string, error) {
+	const op = "Auth.Refresh"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	log := sl.FromContext(ctx, a.log).With(slog.String("op", op))
+
+	state, err := a.validateToken(ctx, log, token, true)
+	if err != nil {
+		return "", err
+	}
+
+	if state.app.SessionPolicy.Lifetime == 0 {
+		expiresAt := time.Now().UTC().Add(a.appTokenTTL(state.app))
+		if err := a.sessions.ExtendSession(ctx, state.session.Id, expiresAt); err != nil {
+			if errors.Is(err, storage.ErrSessionNotFound) {
+				log.Info("session not found", sl.Err(err))
+				return "", ErrInvalidToken
+			}
+			log.Error("failed to extend session", sl.Err(err))
+			return "", ErrInternalServerError
+		}
+		state.session.ExpiresAt = expiresAt
+	}
+
+	newToken, err := a.issueToken(ctx, log, state.user, state.app, state.session)
+	if err != nil {
+		return "", err
+	}
+
+	log.Info("token refreshed", slog.Int64("userId", state.user.Id), slog.String("sessionId", state.session.Id))
+	return newToken, nil
+}
+
+// StepUp re-authenticates the session of the token with the user password
+// and issues a new token, it is how a session past the app step-up age is
+// accepted again.
+func (a *Auth) StepUp(ctx context.Context, token string, password string) (string, error) {
+	const op = "Auth.StepUp"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	log := sl.FromContext(ctx, a.log).With(slog.String("op", op))
+
+	state, err := a.validateToken(ctx, log, token, false)
+	if err != nil {
+		return "", err
+	}
+	log = log.With(slog.Int64("userId", state.user.Id), slog.String("sessionId", state.session.Id))
+
+	if _, err := a.verifyPassword(ctx, state.user.PassHash, password); err != nil {
+		if errors.Is(err, passwordlib.ErrMismatch) {
+			log.Info("password mismatch", sl.Err(err))
+			a.audit(ctx, models.AuditStepUp, state.user.Id, int(state.app.Id), "password mismatch")
+			return "", ErrInvalidCredentials
+		}
+		log.Error("failed to verify password", sl.Err(err))
+		return "", ErrInternalServerError
+	}
+
+	if err := a.sessions.ReauthenticateSession(ctx, state.session.Id); err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			log.Info("session not found", sl.Err(err))
+			return "", ErrInvalidToken
+		}
+		log.Error("failed to reauthenticate session", sl.Err(err))
+		return "", ErrInternalServerError
+	}
+	state.session.AuthenticatedAt = time.Now().UTC()
+
+	newToken, err := a.issueToken(ctx, log, state.user, state.app, state.session)
+	if err != nil {
+		return "", err
+	}
+
+	log.Info("session reauthenticated")
+	a.audit(ctx, models.AuditStepUp, state.user.Id, int(state.app.Id), "")
+	return newToken, nil
+}
+
+// Logout revokes the session of the token, tokens issued for it are rejected
+// from now on.
+func (a *Auth) Logout(ctx context.Context, token string) (bool, error) {
+	const op = "Auth.Logout"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	log := sl.FromContext(ctx, a.log).With(slog.String("op", op))
+
+	// a session waiting for a step-up can still be ended
+	state, err := a.validateToken(ctx, log, token, false)
+	if err != nil {
+		return false, err
+	}
+
+	if err := a.sessions.RevokeSession(ctx, state.session.Id); err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			log.Info("session not found", sl.Err(err))
+			return false, ErrInvalidToken
+		}
+		log.Error("failed to revoke session", sl.Err(err))
+		return false, ErrInternalServerError
+	}
+
+	log.Info("user logged out successfully", slog.Int64("userId", state.user.Id), slog.String("sessionId", state.session.Id))
+	a.audit(ctx, models.AuditLogout, state.user.Id, int(state.app.Id), "")
+	return true, nil
+}
+
+func (a *Auth) validateToken(ctx context.Context, log *slog.Logger, token string, enforceStepUp bool) (*tokenState, error) {
+	appId, err := jwt.AppId(token)
+	if err != nil {
+		log.Info("malformed token", sl.Err(err))
+		return nil, ErrInvalidToken
+	}
+
+	app, err := a.appProvider.GetAppById(ctx, int(appId))
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Info("app not found", sl.Err(err))
+			return nil, ErrInvalidToken
+		}
+		log.Error("failed to get app by id", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+
+	claims, err := jwt.ParseToken(token, app)
+	if err != nil {
+		log.Info("token rejected", sl.Err(err))
+		return nil, ErrInvalidToken
+	}
+	if !app.Enabled {
+		log.Info("app is disabled", slog.Int64("appId", app.Id))
+		return nil, ErrInvalidToken
+	}
+
+	user, err := a.userProvider.GetUserById(ctx, claims.UserId)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("user not found", sl.Err(err))
+			return nil, ErrInvalidToken
+		}
+		log.Error("failed to get user by id", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+	if user.IsDisabled() {
+		log.Info("user is disabled", slog.Int64("userId", user.Id))
+		return nil, ErrInvalidToken
+	}
+
+	session, err := a.sessions.GetSession(ctx, claims.SessionId)
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			log.Info("session not found", sl.Err(err))
+			return nil, ErrInvalidToken
+		}
+		log.Error("failed to get session", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+	if err := a.checkSessionPolicy(ctx, log, session, claims, app, enforceStepUp); err != nil {
+		return nil, err
+	}
+	if err := a.sessions.TouchSession(ctx, session.Id); err != nil {
+		log.Error("failed to touch session", sl.Err(err))
+	}
+
+	return &tokenState{claims: claims, app: app, user: user, session: session}, nil
+}
+
+func (a *Auth) checkSessionPolicy(ctx context.Context, log *slog.Logger, session *models.Session, claims *jwt.Claims, app *models.App, enforceStepUp bool) error {
+	log = log.With(slog.String("sessionId", session.Id))
+	now := time.Now().UTC()
+	policy := app.SessionPolicy
+
+	if session.UserId != claims.UserId || session.AppId != app.Id || !session.IsActive(now) {
+		log.Info("session is not active")
+		return ErrInvalidToken
+	}
+
+	if policy.IdleTimeout > 0 && now.Sub(session.LastSeenAt) > policy.IdleTimeout {
+		log.Info("session idle timeout exceeded", slog.Time("lastSeenAt", session.LastSeenAt))
+		if err := a.sessions.RevokeSession(ctx, session.Id); err != nil {
+			log.Error("failed to revoke idle session", sl.Err(err))
+		}
+		return ErrInvalidToken
+	}
+
+	if enforceStepUp && policy.StepUpAfter > 0 && now.Sub(session.AuthenticatedAt) > policy.StepUpAfter {
+		log.Info("session needs step-up", slog.Time("authenticatedAt", session.AuthenticatedAt))
+		return ErrStepUpRequired
+	}
+	return nil
+}
+
+// newSession starts a session for the user in the app. It lasts for the app
+// session lifetime or, without one, as long as the first token. The app limit
+// of concurrent sessions is enforced by the storage together with the insert.
+func (a *Auth) newSession(ctx context.Context, log *slog.Logger, user *models.User, app *models.App) (*models.Session, error) {
+	id, err := secrets.Generate(sessionIdSize)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := app.SessionPolicy
+	lifetime := policy.Lifetime
+	if lifetime == 0 {
+		lifetime = a.appTokenTTL(app)
+	}
+
+	info := requestinfo.FromContext(ctx)
+	// sessions are stored without a zone, see the storage
+	now := time.Now().UTC()
+	session := &models.Session{
+		Id:              id,
+		UserId:          user.Id,
+		AppId:           app.Id,
+		DeviceId:        info.DeviceId,
+		IP:              info.IP,
+		UserAgent:       info.UserAgent,
+		CreatedAt:       now,
+		AuthenticatedAt: now,
+		LastSeenAt:      now,
+		ExpiresAt:       now.Add(lifetime),
+	}
+	if policy.MaxSessions <= 0 {
+		if err := a.sessions.SaveSession(ctx, session); err != nil {
+			return nil, err
+		}
+		return session, nil
+	}
+
+	evicted, err := a.sessions.SaveSessionWithinLimit(ctx, session, policy.MaxSessions, policy.LimitAction != models.SessionLimitDeny)
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionLimit) {
+			log.Info("session limit reached", slog.Int("maxSessions", policy.MaxSessions))
+			return nil, ErrTooManySessions
+		}
+		return nil, err
+	}
+	for _, sessionId := range evicted {
+		log.Info("oldest session evicted", slog.String("sessionId", sessionId))
+	}
+	return session, nil
+}
+
+// issueToken creates a token for the session, it never outlives the session.
+func (a *Auth) issueToken(ctx context.Context, log *slog.Logger, user *models.User, app *models.App, session *models.Session) (string, error) {
+	// profile attributes are only loaded for apps that map them into claims
+	var profile *models.Profile
+	var metadata json.RawMessage
+	if len(app.ClaimRules) > 0 {
+		var err error
+		if profile, err = a.profileProvider.GetProfile(ctx, user.Id); err != nil {
+			log.Error("failed to get profile", sl.Err(err))
+			return "", ErrInternalServerError
+		}
+		if metadata, err = a.profileProvider.GetAppMetadata(ctx, user.Id, app.Id); err != nil {
+			log.Error("failed to get app metadata", sl.Err(err))
+			return "", ErrInternalServerError
+		}
+	}
+
+	ttl := min(a.appTokenTTL(app), time.Until(session.ExpiresAt))
+	token, err := jwt.NewToken(user, app, session, profile, metadata, ttl)
+	if err != nil {
+		log.Error("failed to create token", sl.Err(err))
+		return "", ErrInternalServerError
+	}
+	a.metrics.TokenIssued(app.Id)
+	return token, nil
+}
+
+func (a *Auth) appTokenTTL(app *models.App) time.Duration {
+	if app.TokenTTL > 0 {
+		return app.TokenTTL
+	}
+	return a.tokenTTL
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/logger/handlers/slogdiscard"
+	"sso/internal/storage"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// timestampStore keeps sessions the way a TIMESTAMP column does: the wall
+// clock is kept, the zone is dropped and values are read back as UTC.
+type timestampStore struct {
+	SessionStore
+	sessions map[string]models.Session
+}
+
+func newTimestampStore() *timestampStore {
+	return &timestampStore{sessions: map[string]models.Session{}}
+}
+
+func (s *timestampStore) SaveSession(_ context.Context, session *models.Session) error {
+	stored := *session
+	stored.CreatedAt = timestamp(session.CreatedAt)
+	stored.AuthenticatedAt = timestamp(session.AuthenticatedAt)
+	stored.LastSeenAt = timestamp(session.LastSeenAt)
+	stored.ExpiresAt = timestamp(session.ExpiresAt)
+	s.sessions[session.Id] = stored
+	return nil
+}
+
+// SaveSessionWithinLimit counts the sessions of the user in the app, it does
+// not model their expiry and evicts all of them, the tests use a limit of one.
+func (s *timestampStore) SaveSessionWithinLimit(ctx context.Context, session *models.Session, limit int, evict bool) ([]string, error) {
+	var active []string
+	for _, stored := range s.sessions {
+		if stored.UserId == session.UserId && stored.AppId == session.AppId && stored.RevokedAt == nil {
+			active = append(active, stored.Id)
+		}
+	}
+	if len(active) >= limit {
+		if !evict {
+			return nil, storage.ErrSessionLimit
+		}
+		for _, id := range active {
+			stored := s.sessions[id]
+			now := time.Now().UTC()
+			stored.RevokedAt = &now
+			s.sessions[id] = stored
+		}
+	}
+	return active, s.SaveSession(ctx, session)
+}
+
+func (s *timestampStore) GetSession(_ context.Context, sessionId string) (*models.Session, error) {
+	session, ok := s.sessions[sessionId]
+	if !ok {
+		return nil, storage.ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+func timestamp(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+func TestAuth_SessionTimesOutsideUTC(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC-5", -5*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	log := slogdiscard.NewDiscardLogger()
+	store := newTimestampStore()
+	a := &Auth{log: log, sessions: store, tokenTTL: time.Hour}
+	app := &models.App{Id: 1, Enabled: true, SessionPolicy: models.SessionPolicy{
+		IdleTimeout: 30 * time.Minute,
+		Lifetime:    2 * time.Hour,
+		StepUpAfter: 30 * time.Minute,
+	}}
+	user := &models.User{Id: 1}
+
+	session, err := a.newSession(context.Background(), log, user, app)
+	require.NoError(t, err)
+
+	stored, err := store.GetSession(context.Background(), session.Id)
+	require.NoError(t, err)
+	assert.True(t, stored.ExpiresAt.Equal(session.ExpiresAt), "expires_at must survive the round trip")
+	assert.InDelta(t, float64(2*time.Hour), float64(time.Until(stored.ExpiresAt)), float64(time.Minute))
+
+	claims := &jwt.Claims{UserId: user.Id, AppId: app.Id, SessionId: session.Id}
+	assert.NoError(t, a.checkSessionPolicy(context.Background(), log, stored, claims, app, true))
+}
+
+func TestAuth_NewSessionLimit(t *testing.T) {
+	log := slogdiscard.NewDiscardLogger()
+	user := &models.User{Id: 1}
+
+	t.Run("deny", func(t *testing.T) {
+		a := &Auth{log: log, sessions: newTimestampStore(), tokenTTL: time.Hour}
+		app := &models.App{Id: 1, SessionPolicy: models.SessionPolicy{MaxSessions: 1, LimitAction: models.SessionLimitDeny}}
+
+		_, err := a.newSession(context.Background(), log, user, app)
+		require.NoError(t, err)
+		_, err = a.newSession(context.Background(), log, user, app)
+		assert.ErrorIs(t, err, ErrTooManySessions)
+	})
+
+	t.Run("evict oldest", func(t *testing.T) {
+		store := newTimestampStore()
+		a := &Auth{log: log, sessions: store, tokenTTL: time.Hour}
+		app := &models.App{Id: 1, SessionPolicy: models.SessionPolicy{MaxSessions: 1, LimitAction: models.SessionLimitEvictOldest}}
+
+		first, err := a.newSession(context.Background(), log, user, app)
+		require.NoError(t, err)
+		second, err := a.newSession(context.Background(), log, user, app)
+		require.NoError(t, err)
+
+		stored, err := store.GetSession(context.Background(), first.Id)
+		require.NoError(t, err)
+		assert.NotNil(t, stored.RevokedAt)
+		stored, err = store.GetSession(context.Background(), second.Id)
+		require.NoError(t, err)
+		assert.Nil(t, stored.RevokedAt)
+	})
+}
@@ -0,0 +1,152 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"strconv"
+	"time"
+)
+
+type Sessions struct {
+	log             *slog.Logger
+	sessionProvider SessionProvider
+	sessionRevoker  SessionRevoker
+	auditor         Auditor
+}
+
+type SessionProvider interface {
+	GetSession(ctx context.Context, sessionId string) (*models.Session, error)
+	ListSessions(ctx context.Context, userId int64, includeInactive bool) ([]models.Session, error)
+}
+
+type SessionRevoker interface {
+	RevokeSession(ctx context.Context, sessionId string) error
+	RevokeUserSessions(ctx context.Context, userId int64) (int64, error)
+	PurgeSessions(ctx context.Context, expiredBefore time.Time) (int64, error)
+}
+
+type Auditor interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+var (
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrInternalServerError = errors.New("internal server error")
+)
+
+// NewSessionsService creates a new instance of Sessions with the provided dependencies.
+func NewSessionsService(
+	log *slog.Logger,
+	sessionProvider SessionProvider,
+	sessionRevoker SessionRevoker,
+	auditor Auditor) *Sessions {
+	return &Sessions{
+		log:             log,
+		sessionProvider: sessionProvider,
+		sessionRevoker:  sessionRevoker,
+		auditor:         auditor,
+	}
+}
+
+// ListSessions returns the active sessions of the user, newest first.
+func (s *Sessions) ListSessions(ctx context.Context, userId int64) ([]models.Session, error) {
+	const op = "Sessions.ListSessions"
+	log := s.log.With(slog.String("op", op), slog.Int64("userId", userId))
+
+	sessions, err := s.sessionProvider.ListSessions(ctx, userId, false)
+	if err != nil {
+		log.Error("failed to list sessions", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+	return sessions, nil
+}
+
+// GetSession returns the session, including revoked and expired ones.
+func (s *Sessions) GetSession(ctx context.Context, sessionId string) (*models.Session, error) {
+	const op = "Sessions.GetSession"
+	log := s.log.With(slog.String("op", op), slog.String("sessionId", sessionId))
+
+	session, err := s.sessionProvider.GetSession(ctx, sessionId)
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			log.Info("session not found", sl.Err(err))
+			return nil, ErrSessionNotFound
+		}
+		log.Error("failed to get session", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+	return session, nil
+}
+
+// RevokeSession revokes the session, its tokens are rejected from now on.
+func (s *Sessions) RevokeSession(ctx context.Context, sessionId string) error {
+	const op = "Sessions.RevokeSession"
+	log := s.log.With(slog.String("op", op), slog.String("sessionId", sessionId))
+
+	session, err := s.GetSession(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+
+	if err := s.sessionRevoker.RevokeSession(ctx, sessionId); err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			log.Info("session not found", sl.Err(err))
+			return ErrSessionNotFound
+		}
+		log.Error("failed to revoke session", sl.Err(err))
+		return ErrInternalServerError
+	}
+
+	log.Info("session revoked successfully")
+	s.auditor.Record(ctx, models.AuditEvent{
+		Action:    models.AuditRevokeSession,
+		SubjectId: session.UserId,
+		AppId:     session.AppId,
+		Outcome:   models.AuditSuccess,
+		Reason:    "session=" + sessionId,
+	})
+	return nil
+}
+
+// RevokeAllSessions revokes every active session of the user and returns
+// how many were revoked.
+func (s *Sessions) RevokeAllSessions(ctx context.Context, userId int64) (int64, error) {
+	const op = "Sessions.RevokeAllSessions"
+	log := s.log.With(slog.String("op", op), slog.Int64("userId", userId))
+
+	revoked, err := s.sessionRevoker.RevokeUserSessions(ctx, userId)
+	if err != nil {
+		log.Error("failed to revoke sessions", sl.Err(err))
+		return 0, ErrInternalServerError
+	}
+
+	log.Info("sessions revoked successfully", slog.Int64("count", revoked))
+	s.auditor.Record(ctx, models.AuditEvent{
+		Action:    models.AuditRevokeAllSessions,
+		SubjectId: userId,
+		Outcome:   models.AuditSuccess,
+		Reason:    "revoked=" + strconv.FormatInt(revoked, 10),
+	})
+	return revoked, nil
+}
+
+// PurgeExpiredSessions removes expired sessions, they can not be used anymore.
+func (s *Sessions) PurgeExpiredSessions(ctx context.Context) (int64, error) {
+	const op = "Sessions.PurgeExpiredSessions"
+	log := s.log.With(slog.String("op", op))
+
+	purged, err := s.sessionRevoker.PurgeSessions(ctx, time.Now().UTC())
+	if err != nil {
+		log.Error("failed to purge expired sessions", sl.Err(err))
+		return 0, ErrInternalServerError
+	}
+
+	if purged > 0 {
+		log.Info("expired sessions purged", slog.Int64("count", purged))
+	}
+	return purged, nil
+}
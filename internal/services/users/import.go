@@ -0,0 +1,93 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/identity"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/password"
+	"sso/internal/storage"
+)
+
+// ImportUsers creates users migrated from other auth systems. Their password
+// hashes are kept in the foreign format and upgraded on the first login.
+// Users whose email, username or phone already exists are skipped, invalid
+// records are reported in the result without stopping the import. With
+// dryRun set the records are only validated.
+func (u *Users) ImportUsers(ctx context.Context, records []models.ImportedUser, dryRun bool) (models.ImportResult, error) {
+	const op = "Users.ImportUsers"
+	log := u.log.With(slog.String("op", op), slog.Bool("dryRun", dryRun))
+
+	var result models.ImportResult
+	fail := func(record models.ImportedUser, err error) {
+		result.Failed++
+		result.Errors = append(result.Errors, models.ImportError{Line: record.Line, Email: record.Email, Error: err.Error()})
+	}
+
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		user, err := importedUser(record)
+		if err != nil {
+			fail(record, err)
+			continue
+		}
+		if dryRun {
+			result.Imported++
+			continue
+		}
+
+		if _, err := u.userImporter.SaveImportedUser(ctx, user); err != nil {
+			if errors.Is(err, storage.ErrUserAlreadyExists) {
+				result.Skipped++
+				continue
+			}
+			log.Error("failed to save imported user", sl.Err(err))
+			return result, ErrInternalServerError
+		}
+		result.Imported++
+	}
+
+	log.Info("users imported",
+		slog.Int("imported", result.Imported),
+		slog.Int("skipped", result.Skipped),
+		slog.Int("failed", result.Failed),
+	)
+	if !dryRun {
+		u.auditor.Record(ctx, models.AuditEvent{
+			Action:  models.AuditImportUsers,
+			Outcome: models.AuditSuccess,
+			Reason:  fmt.Sprintf("imported=%d skipped=%d failed=%d", result.Imported, result.Skipped, result.Failed),
+		})
+	}
+	return result, nil
+}
+
+func importedUser(record models.ImportedUser) (*models.User, error) {
+	email, err := identity.NormalizeEmail(record.Email)
+	if err != nil {
+		return nil, err
+	}
+	user := &models.User{Email: email}
+
+	if record.Username != "" {
+		if user.Username, err = identity.NormalizeUsername(record.Username); err != nil {
+			return nil, err
+		}
+	}
+	if record.Phone != "" {
+		if user.Phone, err = identity.NormalizePhone(record.Phone); err != nil {
+			return nil, err
+		}
+	}
+
+	if user.PassHash, err = password.ImportHash(record.HashFormat, record.PasswordHash, record.Salt); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
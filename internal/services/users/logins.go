@@ -0,0 +1,62 @@
+package users
+
+import (
+	"context"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"strconv"
+)
+
+// GetLoginHistory returns one page of the user's login attempts, newest
+// first, and the token of the next page, which is empty on the last page.
+func (u *Users) GetLoginHistory(ctx context.Context, userId int64, pageToken string, pageSize int) ([]models.LoginAttempt, string, error) {
+	const op = "Users.GetLoginHistory"
+	log := u.log.With(slog.String("op", op), slog.Int64("userId", userId))
+
+	var beforeId int64
+	if pageToken != "" {
+		var err error
+		if beforeId, err = strconv.ParseInt(pageToken, 10, 64); err != nil {
+			return nil, "", ErrInvalidPageToken
+		}
+	}
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	pageSize = min(pageSize, maxPageSize)
+
+	if _, err := u.GetUser(ctx, userId); err != nil {
+		return nil, "", err
+	}
+
+	attempts, err := u.loginHistory.ListLoginAttempts(ctx, userId, beforeId, pageSize+1)
+	if err != nil {
+		log.Error("failed to list login attempts", sl.Err(err))
+		return nil, "", ErrInternalServerError
+	}
+
+	nextPageToken := ""
+	if len(attempts) > pageSize {
+		attempts = attempts[:pageSize]
+		nextPageToken = strconv.FormatInt(attempts[pageSize-1].Id, 10)
+	}
+	return attempts, nextPageToken, nil
+}
+
+// ListDevices returns the devices the user has logged in from.
+func (u *Users) ListDevices(ctx context.Context, userId int64) ([]models.Device, error) {
+	const op = "Users.ListDevices"
+	log := u.log.With(slog.String("op", op), slog.Int64("userId", userId))
+
+	if _, err := u.GetUser(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	devices, err := u.loginHistory.ListDevices(ctx, userId)
+	if err != nil {
+		log.Error("failed to list devices", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+	return devices, nil
+}
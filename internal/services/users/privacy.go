@@ -0,0 +1,53 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+)
+
+// ExportUser returns everything stored about the user, including soft deleted
+// users that were not purged yet.
+func (u *Users) ExportUser(ctx context.Context, userId int64) (*models.UserExport, error) {
+	const op = "Users.ExportUser"
+	log := u.log.With(slog.String("op", op), slog.Int64("userId", userId))
+
+	export, err := u.userProvider.ExportUser(ctx, userId)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("user not found", sl.Err(err))
+			u.audit(ctx, models.AuditExportUser, userId, ErrUserNotFound)
+			return nil, ErrUserNotFound
+		}
+		log.Error("failed to export user", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+
+	log.Info("user data exported")
+	u.audit(ctx, models.AuditExportUser, userId, nil)
+	return export, nil
+}
+
+// EraseUser anonymizes the user and deletes their profile, app metadata,
+// login history, known devices and sessions. It cannot be undone.
+func (u *Users) EraseUser(ctx context.Context, userId int64) error {
+	const op = "Users.EraseUser"
+	log := u.log.With(slog.String("op", op), slog.Int64("userId", userId))
+
+	if err := u.userDeleter.EraseUser(ctx, userId); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("user not found", sl.Err(err))
+			u.audit(ctx, models.AuditEraseUser, userId, ErrUserNotFound)
+			return ErrUserNotFound
+		}
+		log.Error("failed to erase user", sl.Err(err))
+		return ErrInternalServerError
+	}
+
+	log.Info("user data erased")
+	u.audit(ctx, models.AuditEraseUser, userId, nil)
+	return nil
+}
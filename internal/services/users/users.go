@@ -0,0 +1,437 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"golang.org/x/text/language"
+	"log/slog"
+	"net/url"
+	"sso/internal/domain/models"
+	"sso/internal/lib/identity"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+
+	maxMetadataSize    = 16 << 10
+	maxDisplayNameSize = 256
+)
+
+type Users struct {
+	log             *slog.Logger
+	userProvider    UserProvider
+	userUpdater     UserUpdater
+	userDeleter     UserDeleter
+	profileProvider ProfileProvider
+	profileSaver    ProfileSaver
+	userImporter    UserImporter
+	loginHistory    LoginHistoryProvider
+	auditor         Auditor
+	purgeAfter      time.Duration
+}
+
+type UserProvider interface {
+	GetUserById(ctx context.Context, userId int64) (*models.User, error)
+	ListUsers(ctx context.Context, filter models.UserFilter, afterId int64, limit int) ([]models.User, error)
+	ExportUser(ctx context.Context, userId int64) (*models.UserExport, error)
+}
+
+type UserUpdater interface {
+	UpdateUser(ctx context.Context, user *models.User) error
+	SetUserDisabled(ctx context.Context, userId int64, disabled bool) error
+}
+
+type UserDeleter interface {
+	DeleteUser(ctx context.Context, userId int64) error
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error)
+	EraseUser(ctx context.Context, userId int64) error
+}
+
+type ProfileProvider interface {
+	GetProfile(ctx context.Context, userId int64) (*models.Profile, error)
+	GetAppMetadata(ctx context.Context, userId int64, appId int64) (json.RawMessage, error)
+}
+
+type ProfileSaver interface {
+	SaveProfile(ctx context.Context, profile *models.Profile) error
+	SaveAppMetadata(ctx context.Context, userId int64, appId int64, metadata json.RawMessage) error
+}
+
+type UserImporter interface {
+	SaveImportedUser(ctx context.Context, user *models.User) (int64, error)
+}
+
+type LoginHistoryProvider interface {
+	ListLoginAttempts(ctx context.Context, userId int64, beforeId int64, limit int) ([]models.LoginAttempt, error)
+	ListDevices(ctx context.Context, userId int64) ([]models.Device, error)
+}
+
+type Auditor interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+var (
+	ErrUserNotFound        = errors.New("user not found")
+	ErrUserAlreadyExists   = errors.New("user already exists")
+	ErrInvalidEmail        = errors.New("invalid email")
+	ErrInvalidIdentifier   = errors.New("invalid login identifier")
+	ErrInvalidPageToken    = errors.New("invalid page token")
+	ErrInvalidProfile      = errors.New("invalid profile")
+	ErrInvalidMetadata     = errors.New("metadata must be a JSON object of at most 16 KiB")
+	ErrAppNotFound         = errors.New("app not found")
+	ErrInternalServerError = errors.New("internal server error")
+)
+
+// NewUsersService creates a new instance of Users with the provided dependencies.
+func NewUsersService(
+	log *slog.Logger,
+	userProvider UserProvider,
+	userUpdater UserUpdater,
+	userDeleter UserDeleter,
+	profileProvider ProfileProvider,
+	profileSaver ProfileSaver,
+	userImporter UserImporter,
+	loginHistory LoginHistoryProvider,
+	auditor Auditor,
+	purgeAfter time.Duration) *Users {
+	return &Users{
+		log:             log,
+		userProvider:    userProvider,
+		userUpdater:     userUpdater,
+		userDeleter:     userDeleter,
+		profileProvider: profileProvider,
+		profileSaver:    profileSaver,
+		userImporter:    userImporter,
+		loginHistory:    loginHistory,
+		auditor:         auditor,
+		purgeAfter:      purgeAfter,
+	}
+}
+
+func (u *Users) GetUser(ctx context.Context, userId int64) (*models.User, error) {
+	const op = "Users.GetUser"
+	log := u.log.With(slog.String("op", op), slog.Int64("userId", userId))
+
+	user, err := u.userProvider.GetUserById(ctx, userId)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("user not found", sl.Err(err))
+			return nil, ErrUserNotFound
+		}
+		log.Error("failed to get user by id", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+	return user, nil
+}
+
+// ListUsers returns one page of users matching filter and the token of the
+// next page, which is empty on the last page.
+func (u *Users) ListUsers(ctx context.Context, filter models.UserFilter, pageToken string, pageSize int) ([]models.User, string, error) {
+	const op = "Users.ListUsers"
+	log := u.log.With(slog.String("op", op))
+
+	var afterId int64
+	if pageToken != "" {
+		var err error
+		if afterId, err = strconv.ParseInt(pageToken, 10, 64); err != nil {
+			return nil, "", ErrInvalidPageToken
+		}
+	}
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	pageSize = min(pageSize, maxPageSize)
+
+	users, err := u.userProvider.ListUsers(ctx, filter, afterId, pageSize+1)
+	if err != nil {
+		log.Error("failed to list users", sl.Err(err))
+		return nil, "", ErrInternalServerError
+	}
+
+	nextPageToken := ""
+	if len(users) > pageSize {
+		users = users[:pageSize]
+		nextPageToken = strconv.FormatInt(users[pageSize-1].Id, 10)
+	}
+	return users, nextPageToken, nil
+}
+
+func (u *Users) UpdateUser(ctx context.Context, userId int64, update models.UserUpdate) (*models.User, error) {
+	user, err := u.updateUser(ctx, userId, update)
+	u.audit(ctx, models.AuditUpdateUser, userId, err)
+	return user, err
+}
+
+func (u *Users) updateUser(ctx context.Context, userId int64, update models.UserUpdate) (*models.User, error) {
+	const op = "Users.UpdateUser"
+	log := u.log.With(slog.String("op", op), slog.Int64("userId", userId))
+
+	user, err := u.GetUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Email != nil {
+		email, err := identity.NormalizeEmail(*update.Email)
+		if err != nil {
+			log.Info("invalid email", sl.Err(err))
+			return nil, ErrInvalidEmail
+		}
+		user.Email = email
+	}
+	if update.Username != nil {
+		user.Username = ""
+		if *update.Username != "" {
+			username, err := identity.NormalizeUsername(*update.Username)
+			if err != nil {
+				log.Info("invalid username", sl.Err(err))
+				return nil, errors.Join(ErrInvalidIdentifier, err)
+			}
+			user.Username = username
+		}
+	}
+	if update.Phone != nil {
+		user.Phone = ""
+		if *update.Phone != "" {
+			phone, err := identity.NormalizePhone(*update.Phone)
+			if err != nil {
+				log.Info("invalid phone", sl.Err(err))
+				return nil, errors.Join(ErrInvalidIdentifier, err)
+			}
+			user.Phone = phone
+		}
+	}
+	if update.IsAdmin != nil {
+		user.IsAdmin = *update.IsAdmin
+	}
+
+	if err := u.userUpdater.UpdateUser(ctx, user); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("user not found", sl.Err(err))
+			return nil, ErrUserNotFound
+		}
+		if errors.Is(err, storage.ErrUserAlreadyExists) {
+			log.Info("email, username or phone is taken", sl.Err(err))
+			return nil, ErrUserAlreadyExists
+		}
+		log.Error("failed to update user", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+
+	log.Info("user updated successfully")
+	return user, nil
+}
+
+// DisableUser blocks the user from logging in and invalidates their tokens.
+func (u *Users) DisableUser(ctx context.Context, userId int64) error {
+	err := u.setDisabled(ctx, "Users.DisableUser", userId, true)
+	u.audit(ctx, models.AuditDisableUser, userId, err)
+	return err
+}
+
+func (u *Users) EnableUser(ctx context.Context, userId int64) error {
+	err := u.setDisabled(ctx, "Users.EnableUser", userId, false)
+	u.audit(ctx, models.AuditEnableUser, userId, err)
+	return err
+}
+
+// DeleteUser soft deletes the user. The account is purged permanently once
+// the purge window has passed.
+func (u *Users) DeleteUser(ctx context.Context, userId int64) error {
+	const op = "Users.DeleteUser"
+	log := u.log.With(slog.String("op", op), slog.Int64("userId", userId))
+
+	if err := u.userDeleter.DeleteUser(ctx, userId); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("user not found", sl.Err(err))
+			u.audit(ctx, models.AuditDeleteUser, userId, ErrUserNotFound)
+			return ErrUserNotFound
+		}
+		log.Error("failed to delete user", sl.Err(err))
+		return ErrInternalServerError
+	}
+
+	log.Info("user deleted successfully", slog.Time("purgeAt", time.Now().Add(u.purgeAfter)))
+	u.audit(ctx, models.AuditDeleteUser, userId, nil)
+	return nil
+}
+
+// PurgeDeletedUsers permanently removes users deleted longer than the purge window ago.
+func (u *Users) PurgeDeletedUsers(ctx context.Context) (int64, error) {
+	const op = "Users.PurgeDeletedUsers"
+	log := u.log.With(slog.String("op", op))
+
+	purged, err := u.userDeleter.PurgeDeletedUsers(ctx, time.Now().UTC().Add(-u.purgeAfter))
+	if err != nil {
+		log.Error("failed to purge deleted users", sl.Err(err))
+		return 0, ErrInternalServerError
+	}
+
+	if purged > 0 {
+		log.Info("deleted users purged", slog.Int64("count", purged))
+	}
+	return purged, nil
+}
+
+func (u *Users) GetProfile(ctx context.Context, userId int64) (*models.Profile, error) {
+	const op = "Users.GetProfile"
+	log := u.log.With(slog.String("op", op), slog.Int64("userId", userId))
+
+	if _, err := u.GetUser(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	profile, err := u.profileProvider.GetProfile(ctx, userId)
+	if err != nil {
+		log.Error("failed to get profile", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+	return profile, nil
+}
+
+func (u *Users) UpdateProfile(ctx context.Context, userId int64, update models.ProfileUpdate) (*models.Profile, error) {
+	const op = "Users.UpdateProfile"
+	log := u.log.With(slog.String("op", op), slog.Int64("userId", userId))
+
+	profile, err := u.GetProfile(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.DisplayName != nil {
+		profile.DisplayName = strings.TrimSpace(*update.DisplayName)
+	}
+	if update.Locale != nil {
+		profile.Locale = strings.TrimSpace(*update.Locale)
+	}
+	if update.Timezone != nil {
+		profile.Timezone = strings.TrimSpace(*update.Timezone)
+	}
+	if update.AvatarURL != nil {
+		profile.AvatarURL = strings.TrimSpace(*update.AvatarURL)
+	}
+
+	if err := validateProfile(profile); err != nil {
+		log.Info("invalid profile", sl.Err(err))
+		return nil, err
+	}
+
+	if err := u.profileSaver.SaveProfile(ctx, profile); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("user not found", sl.Err(err))
+			return nil, ErrUserNotFound
+		}
+		log.Error("failed to save profile", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+
+	log.Info("profile updated successfully")
+	return u.GetProfile(ctx, userId)
+}
+
+func (u *Users) GetAppMetadata(ctx context.Context, userId int64, appId int64) (json.RawMessage, error) {
+	const op = "Users.GetAppMetadata"
+	log := u.log.With(slog.String("op", op), slog.Int64("userId", userId), slog.Int64("appId", appId))
+
+	if _, err := u.GetUser(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	metadata, err := u.profileProvider.GetAppMetadata(ctx, userId, appId)
+	if err != nil {
+		log.Error("failed to get app metadata", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+	if metadata == nil {
+		metadata = json.RawMessage("{}")
+	}
+	return metadata, nil
+}
+
+// SetAppMetadata replaces the metadata the user has for an app.
+func (u *Users) SetAppMetadata(ctx context.Context, userId int64, appId int64, metadata json.RawMessage) error {
+	const op = "Users.SetAppMetadata"
+	log := u.log.With(slog.String("op", op), slog.Int64("userId", userId), slog.Int64("appId", appId))
+
+	var object map[string]any
+	if len(metadata) > maxMetadataSize || json.Unmarshal(metadata, &object) != nil || object == nil {
+		log.Info("invalid metadata", slog.Int("size", len(metadata)))
+		return ErrInvalidMetadata
+	}
+
+	if _, err := u.GetUser(ctx, userId); err != nil {
+		return err
+	}
+
+	if err := u.profileSaver.SaveAppMetadata(ctx, userId, appId, metadata); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Info("app not found", sl.Err(err))
+			return ErrAppNotFound
+		}
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("user not found", sl.Err(err))
+			return ErrUserNotFound
+		}
+		log.Error("failed to save app metadata", sl.Err(err))
+		return ErrInternalServerError
+	}
+
+	log.Info("app metadata updated successfully")
+	return nil
+}
+
+func (u *Users) setDisabled(ctx context.Context, op string, userId int64, disabled bool) error {
+	log := u.log.With(slog.String("op", op), slog.Int64("userId", userId))
+
+	if err := u.userUpdater.SetUserDisabled(ctx, userId, disabled); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("user not found", sl.Err(err))
+			return ErrUserNotFound
+		}
+		log.Error("failed to change user disabled state", sl.Err(err))
+		return ErrInternalServerError
+	}
+
+	log.Info("user disabled state changed successfully", slog.Bool("disabled", disabled))
+	return nil
+}
+
+// audit records an admin action on the user, a non-nil err marks a failure.
+func (u *Users) audit(ctx context.Context, action string, userId int64, err error) {
+	event := models.AuditEvent{Action: action, SubjectId: userId, Outcome: models.AuditSuccess}
+	if err != nil {
+		event.Outcome = models.AuditFailure
+		event.Reason = err.Error()
+	}
+	u.auditor.Record(ctx, event)
+}
+
+func validateProfile(profile *models.Profile) error {
+	if len(profile.DisplayName) > maxDisplayNameSize {
+		return errors.Join(ErrInvalidProfile, errors.New("display name is too long"))
+	}
+	if profile.Locale != "" {
+		if _, err := language.Parse(profile.Locale); err != nil {
+			return errors.Join(ErrInvalidProfile, errors.New("locale must be a BCP 47 language tag"))
+		}
+	}
+	if profile.Timezone != "" {
+		if _, err := time.LoadLocation(profile.Timezone); err != nil {
+			return errors.Join(ErrInvalidProfile, errors.New("timezone must be an IANA time zone name"))
+		}
+	}
+	if profile.AvatarURL != "" {
+		avatar, err := url.Parse(profile.AvatarURL)
+		if err != nil || (avatar.Scheme != "https" && avatar.Scheme != "http") || avatar.Host == "" {
+			return errors.Join(ErrInvalidProfile, errors.New("avatar url must be an http(s) url"))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/events"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+type Watch struct {
+	log          *slog.Logger
+	eventLog     EventLog
+	hub          *events.Hub
+	retention    time.Duration
+	pollInterval time.Duration
+}
+
+type EventLog interface {
+	ListOutboxEvents(ctx context.Context, afterSeq int64, limit int) ([]models.Event, error)
+	LastOutboxEvent(ctx context.Context) (*models.Event, error)
+}
+
+var (
+	ErrInvalidCursor       = errors.New("invalid cursor")
+	ErrCursorExpired       = errors.New("cursor is older than the event retention, resync and watch from now")
+	ErrInternalServerError = errors.New("internal server error")
+)
+
+// NewWatchService creates a new instance of Watch with the provided dependencies.
+// Events are kept for retention, cursors older than that can not be resumed.
+// Callers check who may watch, e.g. interceptors.StreamAdmin.
+func NewWatchService(
+	log *slog.Logger,
+	eventLog EventLog,
+	hub *events.Hub,
+	retention time.Duration,
+	pollInterval time.Duration) *Watch {
+	return &Watch{
+		log:          log,
+		eventLog:     eventLog,
+		hub:          hub,
+		retention:    retention,
+		pollInterval: pollInterval,
+	}
+}
+
+// Watch streams events after cursor in order until ctx is done or send
+// fails, every event comes with the cursor to resume after it. An empty
+// cursor starts at the current end of the log.
+func (w *Watch) Watch(ctx context.Context, cursor string, send func(event models.Event, cursor string) error) error {
+	const op = "Watch.Watch"
+	log := w.log.With(slog.String("op", op))
+
+	start, err := w.startCursor(ctx, cursor)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCursor) || errors.Is(err, ErrCursorExpired) {
+			log.Info("cursor can not be resumed", slog.String("cursor", cursor), sl.Err(err))
+			return err
+		}
+		log.Error("failed to get the end of the event log", sl.Err(err))
+		return ErrInternalServerError
+	}
+
+	log.Info("watching events", slog.Int64("afterSeq", start.Seq))
+	var sendErr error
+	err = events.Watch(ctx, w.eventLog, w.hub, start, w.pollInterval, func(event models.Event, cursor events.Cursor) error {
+		sendErr = send(event, cursor.String())
+		return sendErr
+	})
+	switch {
+	case ctx.Err() != nil:
+		return nil
+	case sendErr != nil:
+		log.Info("watcher went away", sl.Err(sendErr))
+		return sendErr
+	default:
+		log.Error("failed to read the event log", sl.Err(err))
+		return ErrInternalServerError
+	}
+}
+
+func (w *Watch) startCursor(ctx context.Context, value string) (events.Cursor, error) {
+	if value == "" {
+		last, err := w.eventLog.LastOutboxEvent(ctx)
+		if err != nil {
+			return events.Cursor{}, err
+		}
+		if last == nil {
+			return events.Cursor{OccurredAt: time.Now()}, nil
+		}
+		return events.CursorAt(*last), nil
+	}
+
+	cursor, err := events.ParseCursor(value)
+	if err != nil {
+		return events.Cursor{}, ErrInvalidCursor
+	}
+	// events after the cursor may have been purged already
+	if cursor.OccurredAt.Before(time.Now().Add(-w.retention)) {
+		return events.Cursor{}, ErrCursorExpired
+	}
+	return cursor, nil
+}
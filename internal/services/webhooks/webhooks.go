@@ -0,0 +1,381 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/secrets"
+	"sso/internal/lib/webhook"
+	"sso/internal/storage"
+	"strconv"
+	"time"
+)
+
+const (
+	secretSize = 32
+
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+type Webhooks struct {
+	log                  *slog.Logger
+	subscriptionSaver    SubscriptionSaver
+	subscriptionProvider SubscriptionProvider
+	subscriptionUpdater  SubscriptionUpdater
+	subscriptionDeleter  SubscriptionDeleter
+	deliveryProvider     DeliveryProvider
+	deliveryRedeliverer  DeliveryRedeliverer
+}
+
+type SubscriptionSaver interface {
+	SaveWebhookSubscription(ctx context.Context, sub *models.WebhookSubscription) (int64, error)
+}
+
+type SubscriptionProvider interface {
+	GetWebhookSubscription(ctx context.Context, id int64) (*models.WebhookSubscription, error)
+	ListWebhookSubscriptions(ctx context.Context, appId int64) ([]models.WebhookSubscription, error)
+}
+
+type SubscriptionUpdater interface {
+	UpdateWebhookSubscription(ctx context.Context, sub *models.WebhookSubscription) error
+	UpdateWebhookSecret(ctx context.Context, id int64, secret string) error
+}
+
+type SubscriptionDeleter interface {
+	DeleteWebhookSubscription(ctx context.Context, id int64) error
+}
+
+type DeliveryProvider interface {
+	GetWebhookDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error)
+	ListWebhookDeliveries(ctx context.Context, subscriptionId int64, status string, beforeId int64, limit int) ([]models.WebhookDelivery, error)
+	ListWebhookAttempts(ctx context.Context, deliveryId int64) ([]models.WebhookAttempt, error)
+}
+
+type DeliveryRedeliverer interface {
+	RedeliverWebhook(ctx context.Context, id int64) error
+	PurgeWebhookDeliveries(ctx context.Context, createdBefore time.Time) (int64, error)
+}
+
+var (
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrDeliveryNotFound     = errors.New("webhook delivery not found")
+	ErrAppNotFound          = errors.New("app not found")
+	ErrInvalidSubscription  = errors.New("invalid webhook subscription")
+	ErrInvalidStatus        = errors.New("invalid delivery status")
+	ErrInvalidPageToken     = errors.New("invalid page token")
+	ErrInternalServerError  = errors.New("internal server error")
+)
+
+// NewWebhooksService creates a new instance of Webhooks with the provided dependencies.
+func NewWebhooksService(
+	log *slog.Logger,
+	subscriptionSaver SubscriptionSaver,
+	subscriptionProvider SubscriptionProvider,
+	subscriptionUpdater SubscriptionUpdater,
+	subscriptionDeleter SubscriptionDeleter,
+	deliveryProvider DeliveryProvider,
+	deliveryRedeliverer DeliveryRedeliverer) *Webhooks {
+	return &Webhooks{
+		log:                  log,
+		subscriptionSaver:    subscriptionSaver,
+		subscriptionProvider: subscriptionProvider,
+		subscriptionUpdater:  subscriptionUpdater,
+		subscriptionDeleter:  subscriptionDeleter,
+		deliveryProvider:     deliveryProvider,
+		deliveryRedeliverer:  deliveryRedeliverer,
+	}
+}
+
+// CreateSubscription subscribes an app to events with a generated signing
+// secret. The returned subscription is the only place where the secret is
+// ever exposed.
+func (w *Webhooks) CreateSubscription(ctx context.Context, sub models.WebhookSubscription) (*models.WebhookSubscription, error) {
+	const op = "Webhooks.CreateSubscription"
+	log := w.log.With(slog.String("op", op), slog.Int64("appId", sub.AppId))
+
+	if err := validateSubscription(&sub); err != nil {
+		log.Info("invalid webhook subscription", sl.Err(err))
+		return nil, err
+	}
+
+	secret, err := secrets.Generate(secretSize)
+	if err != nil {
+		log.Error("failed to generate webhook secret", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+	sub.Secret = secret
+
+	id, err := w.subscriptionSaver.SaveWebhookSubscription(ctx, &sub)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Info("app not found", sl.Err(err))
+			return nil, ErrAppNotFound
+		}
+		log.Error("failed to save webhook subscription", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+
+	created, err := w.subscriptionProvider.GetWebhookSubscription(ctx, id)
+	if err != nil {
+		log.Error("failed to get created webhook subscription", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+
+	log.Info("webhook subscription created successfully", slog.Int64("subscriptionId", id))
+	return created, nil
+}
+
+// GetSubscription returns the subscription without its secret.
+func (w *Webhooks) GetSubscription(ctx context.Context, id int64) (*models.WebhookSubscription, error) {
+	const op = "Webhooks.GetSubscription"
+	log := w.log.With(slog.String("op", op), slog.Int64("subscriptionId", id))
+
+	sub, err := w.subscriptionProvider.GetWebhookSubscription(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrWebhookNotFound) {
+			log.Info("webhook subscription not found", sl.Err(err))
+			return nil, ErrSubscriptionNotFound
+		}
+		log.Error("failed to get webhook subscription", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+
+	sub.Secret = ""
+	return sub, nil
+}
+
+// ListSubscriptions returns the subscriptions of an app without secrets.
+func (w *Webhooks) ListSubscriptions(ctx context.Context, appId int64) ([]models.WebhookSubscription, error) {
+	const op = "Webhooks.ListSubscriptions"
+	log := w.log.With(slog.String("op", op), slog.Int64("appId", appId))
+
+	subs, err := w.subscriptionProvider.ListWebhookSubscriptions(ctx, appId)
+	if err != nil {
+		log.Error("failed to list webhook subscriptions", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	return subs, nil
+}
+
+func (w *Webhooks) UpdateSubscription(ctx context.Context, id int64, update models.WebhookSubscriptionUpdate) (*models.WebhookSubscription, error) {
+	const op = "Webhooks.UpdateSubscription"
+	log := w.log.With(slog.String("op", op), slog.Int64("subscriptionId", id))
+
+	sub, err := w.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.URL != nil {
+		sub.URL = *update.URL
+	}
+	if update.EventTypes != nil {
+		sub.EventTypes = *update.EventTypes
+	}
+	if update.Enabled != nil {
+		sub.Enabled = *update.Enabled
+	}
+
+	if err := validateSubscription(sub); err != nil {
+		log.Info("invalid webhook subscription", sl.Err(err))
+		return nil, err
+	}
+
+	if err := w.subscriptionUpdater.UpdateWebhookSubscription(ctx, sub); err != nil {
+		if errors.Is(err, storage.ErrWebhookNotFound) {
+			log.Info("webhook subscription not found", sl.Err(err))
+			return nil, ErrSubscriptionNotFound
+		}
+		log.Error("failed to update webhook subscription", sl.Err(err))
+		return nil, ErrInternalServerError
+	}
+
+	log.Info("webhook subscription updated successfully")
+	return w.GetSubscription(ctx, id)
+}
+
+// RotateSubscriptionSecret replaces the signing secret and returns the new
+// one. Deliveries sent from now on are signed with it.
+func (w *Webhooks) RotateSubscriptionSecret(ctx context.Context, id int64) (string, error) {
+	const op = "Webhooks.RotateSubscriptionSecret"
+	log := w.log.With(slog.String("op", op), slog.Int64("subscriptionId", id))
+
+	secret, err := secrets.Generate(secretSize)
+	if err != nil {
+		log.Error("failed to generate webhook secret", sl.Err(err))
+		return "", ErrInternalServerError
+	}
+
+	if err := w.subscriptionUpdater.UpdateWebhookSecret(ctx, id, secret); err != nil {
+		if errors.Is(err, storage.ErrWebhookNotFound) {
+			log.Info("webhook subscription not found", sl.Err(err))
+			return "", ErrSubscriptionNotFound
+		}
+		log.Error("failed to update webhook secret", sl.Err(err))
+		return "", ErrInternalServerError
+	}
+
+	log.Info("webhook secret rotated successfully")
+	return secret, nil
+}
+
+// DeleteSubscription removes the subscription together with its delivery
+// logs.
+func (w *Webhooks) DeleteSubscription(ctx context.Context, id int64) error {
+	const op = "Webhooks.DeleteSubscription"
+	log := w.log.With(slog.String("op", op), slog.Int64("subscriptionId", id))
+
+	if err := w.subscriptionDeleter.DeleteWebhookSubscription(ctx, id); err != nil {
+		if errors.Is(err, storage.ErrWebhookNotFound) {
+			log.Info("webhook subscription not found", sl.Err(err))
+			return ErrSubscriptionNotFound
+		}
+		log.Error("failed to delete webhook subscription", sl.Err(err))
+		return ErrInternalServerError
+	}
+
+	log.Info("webhook subscription deleted successfully")
+	return nil
+}
+
+// ListDeliveries returns one page of deliveries of a subscription, newest
+// first, and the token of the next page, which is empty on the last page.
+// An empty status matches all deliveries.
+func (w *Webhooks) ListDeliveries(ctx context.Context, subscriptionId int64, status string, pageToken string, pageSize int) ([]models.WebhookDelivery, string, error) {
+	const op = "Webhooks.ListDeliveries"
+	log := w.log.With(slog.String("op", op), slog.Int64("subscriptionId", subscriptionId))
+
+	switch status {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead:
+	default:
+		return nil, "", ErrInvalidStatus
+	}
+
+	beforeId := int64(1<<63 - 1)
+	if pageToken != "" {
+		var err error
+		if beforeId, err = strconv.ParseInt(pageToken, 10, 64); err != nil {
+			return nil, "", ErrInvalidPageToken
+		}
+	}
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	pageSize = min(pageSize, maxPageSize)
+
+	deliveries, err := w.deliveryProvider.ListWebhookDeliveries(ctx, subscriptionId, status, beforeId, pageSize+1)
+	if err != nil {
+		log.Error("failed to list webhook deliveries", sl.Err(err))
+		return nil, "", ErrInternalServerError
+	}
+
+	nextPageToken := ""
+	if len(deliveries) > pageSize {
+		deliveries = deliveries[:pageSize]
+		nextPageToken = strconv.FormatInt(deliveries[pageSize-1].Id, 10)
+	}
+	return deliveries, nextPageToken, nil
+}
+
+// GetDelivery returns a delivery with the log of its attempts.
+func (w *Webhooks) GetDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, []models.WebhookAttempt, error) {
+	const op = "Webhooks.GetDelivery"
+	log := w.log.With(slog.String("op", op), slog.Int64("deliveryId", id))
+
+	delivery, err := w.deliveryProvider.GetWebhookDelivery(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrDeliveryNotFound) {
+			log.Info("webhook delivery not found", sl.Err(err))
+			return nil, nil, ErrDeliveryNotFound
+		}
+		log.Error("failed to get webhook delivery", sl.Err(err))
+		return nil, nil, ErrInternalServerError
+	}
+
+	attempts, err := w.deliveryProvider.ListWebhookAttempts(ctx, id)
+	if err != nil {
+		log.Error("failed to list webhook attempts", sl.Err(err))
+		return nil, nil, ErrInternalServerError
+	}
+	return delivery, attempts, nil
+}
+
+// Redeliver queues a delivery again right away, typically a dead one after
+// the receiver was fixed.
+func (w *Webhooks) Redeliver(ctx context.Context, id int64) error {
+	const op = "Webhooks.Redeliver"
+	log := w.log.With(slog.String("op", op), slog.Int64("deliveryId", id))
+
+	if err := w.deliveryRedeliverer.RedeliverWebhook(ctx, id); err != nil {
+		if errors.Is(err, storage.ErrDeliveryNotFound) {
+			log.Info("webhook delivery not found", sl.Err(err))
+			return ErrDeliveryNotFound
+		}
+		log.Error("failed to redeliver webhook", sl.Err(err))
+		return ErrInternalServerError
+	}
+
+	log.Info("webhook queued for redelivery")
+	return nil
+}
+
+// PurgeDeliveries removes finished deliveries created more than retention
+// ago and returns how many were removed.
+func (w *Webhooks) PurgeDeliveries(ctx context.Context, retention time.Duration) (int64, error) {
+	const op = "Webhooks.PurgeDeliveries"
+	log := w.log.With(slog.String("op", op))
+
+	purged, err := w.deliveryRedeliverer.PurgeWebhookDeliveries(ctx, time.Now().UTC().Add(-retention))
+	if err != nil {
+		log.Error("failed to purge webhook deliveries", sl.Err(err))
+		return 0, ErrInternalServerError
+	}
+	if purged > 0 {
+		log.Info("webhook deliveries purged", slog.Int64("count", purged))
+	}
+	return purged, nil
+}
+
+func validateSubscription(sub *models.WebhookSubscription) error {
+	u, err := url.Parse(sub.URL)
+	if err != nil || u.Host == "" || u.Fragment != "" || u.User != nil {
+		return errors.Join(ErrInvalidSubscription, errors.New("url must be an absolute url without credentials or fragment"))
+	}
+	// hosts resolving to private addresses are refused by the dispatcher
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && !addr.IsLoopback() && !webhook.PublicAddr(addr) {
+		return errors.Join(ErrInvalidSubscription, errors.New("url must not point to a private address"))
+	}
+	// plain http is only accepted for receivers on the same host, e.g. in development
+	if u.Scheme != "https" && !(u.Scheme == "http" && isLoopback(u.Hostname())) {
+		return errors.Join(ErrInvalidSubscription, errors.New("url must use https"))
+	}
+
+	seen := make(map[string]bool, len(sub.EventTypes))
+	for _, eventType := range sub.EventTypes {
+		if !models.IsEventType(eventType) {
+			return errors.Join(ErrInvalidSubscription, errors.New("unknown event type: "+eventType))
+		}
+		if seen[eventType] {
+			return errors.Join(ErrInvalidSubscription, errors.New("event type is listed twice: "+eventType))
+		}
+		seen[eventType] = true
+	}
+	return nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,85 @@
+package cached
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/lib/cache"
+	"sso/internal/storage"
+	"strconv"
+)
+
+// InvalidateAll is the notification payload that drops every cached entry.
+const InvalidateAll = storage.NotifyAll
+
+type AppProvider interface {
+	GetAppById(ctx context.Context, appId int) (*models.App, error)
+}
+
+// Apps is a read-through cache in front of an AppProvider.
+type Apps struct {
+	log      *slog.Logger
+	provider AppProvider
+	cache    *cache.Cache[int, *models.App]
+}
+
+func NewApps(log *slog.Logger, provider AppProvider, cfg config.Cache) *Apps {
+	return &Apps{
+		log:      log,
+		provider: provider,
+		cache:    cache.New[int, *models.App](cfg.MaxSize, cfg.AppTTL, cfg.NegativeTTL),
+	}
+}
+
+func (a *Apps) GetAppById(ctx context.Context, appId int) (*models.App, error) {
+	const op = "Storage.Cached.GetAppById"
+
+	app, missing, cached := a.cache.Get(appId)
+	if cached {
+		if missing {
+			return nil, fmt.Errorf("%s:%w", op, storage.ErrAppNotFound)
+		}
+		appCopy := *app
+		return &appCopy, nil
+	}
+
+	// an invalidation during the fetch may be about the fetched row, it is
+	// returned but not cached then
+	gen := a.cache.Generation()
+	app, err := a.provider.GetAppById(ctx, appId)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			a.cache.SetMissingIfGeneration(gen, appId)
+		}
+		return nil, err
+	}
+
+	appCopy := *app
+	a.cache.SetIfGeneration(gen, appId, &appCopy)
+	return app, nil
+}
+
+// Invalidate handles a change notification. The payload is either an app id
+// or InvalidateAll.
+func (a *Apps) Invalidate(payload string) {
+	const op = "Storage.Cached.Invalidate"
+
+	if payload == InvalidateAll {
+		a.cache.Purge()
+		a.log.Debug("apps cache purged", slog.String("op", op))
+		return
+	}
+
+	appId, err := strconv.Atoi(payload)
+	if err != nil {
+		a.log.Warn("unexpected invalidation payload, purging apps cache", slog.String("op", op), slog.String("payload", payload))
+		a.cache.Purge()
+		return
+	}
+
+	a.cache.Delete(appId)
+	a.log.Debug("app evicted from cache", slog.String("op", op), slog.Int("appId", appId))
+}
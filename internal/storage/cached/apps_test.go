@@ -0,0 +1,84 @@
+package cached
+
+import (
+	"context"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/handlers/slogdiscard"
+	"sso/internal/storage"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type appProviderFunc func(ctx context.Context, appId int) (*models.App, error)
+
+func (f appProviderFunc) GetAppById(ctx context.Context, appId int) (*models.App, error) {
+	return f(ctx, appId)
+}
+
+var testCacheConfig = config.Cache{MaxSize: 8, AppTTL: time.Minute, NegativeTTL: time.Minute}
+
+func TestApps_GetAppById_Caches(t *testing.T) {
+	calls := 0
+	apps := NewApps(slogdiscard.NewDiscardLogger(), appProviderFunc(func(_ context.Context, appId int) (*models.App, error) {
+		calls++
+		return &models.App{Id: int64(appId), Name: "app"}, nil
+	}), testCacheConfig)
+
+	for range 2 {
+		app, err := apps.GetAppById(context.Background(), 1)
+		require.NoError(t, err)
+		assert.Equal(t, "app", app.Name)
+	}
+	assert.Equal(t, 1, calls)
+
+	apps.Invalidate("1")
+	_, err := apps.GetAppById(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestApps_GetAppById_InvalidatedDuringFetch(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		err     error
+	}{
+		{name: "app changed", payload: "1"},
+		{name: "all apps changed", payload: InvalidateAll},
+		{name: "app created", payload: "1", err: storage.ErrAppNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var apps *Apps
+			version := 0
+			apps = NewApps(slogdiscard.NewDiscardLogger(), appProviderFunc(func(_ context.Context, appId int) (*models.App, error) {
+				version++
+				if version == 1 {
+					// the row changes after it was read but before it is cached
+					apps.Invalidate(tt.payload)
+					if tt.err != nil {
+						return nil, tt.err
+					}
+				}
+				return &models.App{Id: int64(appId), Name: "v" + strconv.Itoa(version)}, nil
+			}), testCacheConfig)
+
+			app, err := apps.GetAppById(context.Background(), 1)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "v1", app.Name)
+			}
+
+			app, err = apps.GetAppById(context.Background(), 1)
+			require.NoError(t, err)
+			assert.Equal(t, "v2", app.Name, "the stale result must not have been cached")
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package postgreSQL
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"sso/internal/domain/models"
+	"sso/internal/lib/secrets"
+	"sso/internal/storage"
+	"time"
+)
+
+const appColumns = "id, name, secret, redirect_uris, token_ttl_seconds, enabled, claim_rules, " +
+	"idle_timeout_seconds, session_lifetime_seconds, max_sessions, session_limit_action, step_up_after_seconds, timestamp, updated_at"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func (s *Storage) SaveApp(ctx context.Context, app *models.App) (int64, error) {
+	const op = "Storage.PostgreSQL.SaveApp"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+
+	secret, err := s.secrets.Encrypt(app.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+	redirectURIs, claimRules, err := marshalAppLists(app)
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	var id int64
+	now := time.Now().UTC()
+	policy := app.SessionPolicy
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO apps(name, secret, redirect_uris, token_ttl_seconds, enabled, claim_rules,
+			                 idle_timeout_seconds, session_lifetime_seconds, max_sessions, session_limit_action, step_up_after_seconds,
+			                 timestamp, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $12) RETURNING id`,
+			app.Name, secret, redirectURIs, seconds(app.TokenTTL), app.Enabled, claimRules,
+			seconds(policy.IdleTimeout), seconds(policy.Lifetime), policy.MaxSessions, policy.LimitAction, seconds(policy.StepUpAfter),
+			now,
+		).Scan(&id)
+		if err != nil {
+			return err
+		}
+		return saveEvent(ctx, tx, models.EventAppCreated, models.AppEvent{AppId: id, Name: app.Name})
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return 0, fmt.Errorf("%s:%w", op, storage.ErrAppAlreadyExists)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+	return id, nil
+}
+
+func (s *Storage) GetAppById(ctx context.Context, appId int) (*models.App, error) {
+	const op = "Storage.PostgreSQL.GetAppById"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	row := s.db.QueryRowContext(ctx, "SELECT "+appColumns+" FROM apps WHERE id = $1", appId)
+
+	app, err := s.scanApp(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s:%w", op, storage.ErrAppNotFound)
+		}
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return app, nil
+}
+
+// ListApps returns up to limit apps with id greater than afterId, ordered by id.
+func (s *Storage) ListApps(ctx context.Context, afterId int64, limit int) ([]models.App, error) {
+	const op = "Storage.PostgreSQL.ListApps"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	rows, err := s.db.QueryContext(ctx, "SELECT "+appColumns+" FROM apps WHERE id > $1 ORDER BY id LIMIT $2", afterId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	var apps []models.App
+	for rows.Next() {
+		app, err := s.scanApp(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+		apps = append(apps, *app)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return apps, nil
+}
+
+// UpdateApp stores every field of app except the secret.
+func (s *Storage) UpdateApp(ctx context.Context, app *models.App) error {
+	const op = "Storage.PostgreSQL.UpdateApp"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+
+	redirectURIs, claimRules, err := marshalAppLists(app)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	policy := app.SessionPolicy
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE apps
+			SET name = $1, redirect_uris = $2, token_ttl_seconds = $3, enabled = $4, claim_rules = $5,
+			    idle_timeout_seconds = $6, session_lifetime_seconds = $7, max_sessions = $8, session_limit_action = $9,
+			    step_up_after_seconds = $10, updated_at = $11
+			WHERE id = $12`,
+			app.Name, redirectURIs, seconds(app.TokenTTL), app.Enabled, claimRules,
+			seconds(policy.IdleTimeout), seconds(policy.Lifetime), policy.MaxSessions, policy.LimitAction,
+			seconds(policy.StepUpAfter), time.Now().UTC(), app.Id,
+		)
+		if err != nil {
+			return err
+		}
+		if err := checkAffected(res, storage.ErrAppNotFound); err != nil {
+			return err
+		}
+		return saveEvent(ctx, tx, models.EventAppUpdated, models.AppEvent{AppId: app.Id, Name: app.Name})
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return fmt.Errorf("%s:%w", op, storage.ErrAppAlreadyExists)
+	}
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) UpdateAppSecret(ctx context.Context, appId int64, secret string) error {
+	const op = "Storage.PostgreSQL.UpdateAppSecret"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+
+	encrypted, err := s.secrets.Encrypt(secret)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "UPDATE apps SET secret = $1, updated_at = $2 WHERE id = $3", encrypted, time.Now().UTC(), appId)
+		if err != nil {
+			return err
+		}
+		if err := checkAffected(res, storage.ErrAppNotFound); err != nil {
+			return err
+		}
+		return saveEvent(ctx, tx, models.EventAppSecretRotated, models.AppEvent{AppId: appId})
+	})
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) DeleteApp(ctx context.Context, appId int64) error {
+	const op = "Storage.PostgreSQL.DeleteApp"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "DELETE FROM apps WHERE id = $1", appId)
+		if err != nil {
+			return err
+		}
+		if err := checkAffected(res, storage.ErrAppNotFound); err != nil {
+			return err
+		}
+		return saveEvent(ctx, tx, models.EventAppDeleted, models.AppEvent{AppId: appId})
+	})
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
+
+// EncryptAppSecrets encrypts secrets that are still stored in plaintext,
+// e.g. the ones seeded by migrations. It returns the number of updated apps.
+func (s *Storage) EncryptAppSecrets(ctx context.Context) (int, error) {
+	const op = "Storage.PostgreSQL.EncryptAppSecrets"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	rows, err := s.db.QueryContext(ctx, "SELECT id, secret FROM apps")
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	plain := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var secret string
+		if err := rows.Scan(&id, &secret); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("%s:%w", op, err)
+		}
+		if !secrets.IsEncrypted(secret) {
+			plain[id] = secret
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	for id, secret := range plain {
+		encrypted, err := s.secrets.Encrypt(secret)
+		if err != nil {
+			return 0, fmt.Errorf("%s:%w", op, err)
+		}
+		// the condition keeps a concurrently rotated secret intact
+		if _, err := s.db.ExecContext(ctx, "UPDATE apps SET secret = $1 WHERE id = $2 AND secret = $3", encrypted, id, secret); err != nil {
+			return 0, fmt.Errorf("%s:%w", op, err)
+		}
+	}
+	return len(plain), nil
+}
+
+func (s *Storage) scanApp(row rowScanner) (*models.App, error) {
+	app := &models.App{}
+	var secret string
+	var redirectURIs, claimRules []byte
+	var tokenTTLSeconds, idleTimeoutSeconds, lifetimeSeconds, stepUpAfterSeconds int64
+
+	err := row.Scan(
+		&app.Id, &app.Name, &secret, &redirectURIs, &tokenTTLSeconds, &app.Enabled, &claimRules,
+		&idleTimeoutSeconds, &lifetimeSeconds, &app.SessionPolicy.MaxSessions, &app.SessionPolicy.LimitAction, &stepUpAfterSeconds,
+		&app.CreatedAt, &app.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if app.Secret, err = s.secrets.Decrypt(secret); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(redirectURIs, &app.RedirectURIs); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(claimRules, &app.ClaimRules); err != nil {
+		return nil, err
+	}
+	app.TokenTTL = time.Duration(tokenTTLSeconds) * time.Second
+	app.SessionPolicy.IdleTimeout = time.Duration(idleTimeoutSeconds) * time.Second
+	app.SessionPolicy.Lifetime = time.Duration(lifetimeSeconds) * time.Second
+	app.SessionPolicy.StepUpAfter = time.Duration(stepUpAfterSeconds) * time.Second
+
+	return app, nil
+}
+
+func seconds(d time.Duration) int64 {
+	return int64(d / time.Second)
+}
+
+func expectAffected(op string, res sql.Result, notFound error) error {
+	if err := checkAffected(res, notFound); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
+
+// checkAffected is expectAffected for use inside inTx, whose caller wraps
+// the error.
+func checkAffected(res sql.Result, notFound error) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return notFound
+	}
+	return nil
+}
+
+func marshalAppLists(app *models.App) (redirectURIs []byte, claimRules []byte, err error) {
+	if redirectURIs, err = json.Marshal(nonNil(app.RedirectURIs)); err != nil {
+		return nil, nil, err
+	}
+	if claimRules, err = json.Marshal(nonNil(app.ClaimRules)); err != nil {
+		return nil, nil, err
+	}
+	return redirectURIs, claimRules, nil
+}
+
+func nonNil[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
@@ -0,0 +1,162 @@
+package postgreSQL
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/lib/audit"
+	"time"
+)
+
+const auditColumns = "id, occurred_at, action, COALESCE(actor_id, 0), COALESCE(subject_id, 0), COALESCE(app_id, 0), ip, user_agent, outcome, reason, prev_hash, hash, personal_salt, personal_digest"
+
+// auditLockKey is the advisory lock serializing audit appends, every event
+// has to be chained to the one saved right before it.
+const auditLockKey = 0x61756469
+
+// SaveAuditEvent appends the event to the audit hash chain and sets its id,
+// time and hashes. The event is sealed, see audit.Seal, so EraseUser can
+// remove its IP and user agent.
+//
+// Appends are serialized by auditLockKey, so audited calls, logins among
+// them, wait for each other here. The lock is held by a transaction of its
+// own, for reading the head and one insert; the wait for it is traced as a
+// span of its own and the tests measure it with BenchmarkLogin_Concurrent.
+func (s *Storage) SaveAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	const op = "Storage.PostgreSQL.SaveAuditEvent"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	defer tx.Rollback()
+
+	lockCtx, lockSpan := startSpan(ctx, op+".Lock")
+	_, err = tx.ExecContext(lockCtx, "SELECT pg_advisory_xact_lock($1)", auditLockKey)
+	lockSpan.End()
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	var prev []byte
+	err = tx.QueryRowContext(ctx, "SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1").Scan(&prev)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	// stored without time zone and with microsecond precision, the hash has
+	// to be computed over exactly what is read back
+	event.OccurredAt = time.Now().UTC().Truncate(time.Microsecond)
+	event.PrevHash = prev
+	if err := audit.Seal(event); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	event.Hash = audit.Hash(prev, event)
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO audit_events(occurred_at, action, actor_id, subject_id, app_id, ip, user_agent, outcome, reason, prev_hash, hash, personal_salt, personal_digest)
+		VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, 0), NULLIF($5, 0), $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id`,
+		event.OccurredAt, event.Action, event.ActorId, event.SubjectId, event.AppId,
+		event.IP, event.UserAgent, event.Outcome, event.Reason, event.PrevHash, event.Hash,
+		event.PersonalSalt, event.PersonalDigest,
+	).Scan(&event.Id)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
+
+// AuditHead returns the id and hash of the newest event, a zero anchor if
+// there are none.
+func (s *Storage) AuditHead(ctx context.Context) (*models.AuditAnchor, error) {
+	const op = "Storage.PostgreSQL.AuditHead"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+
+	head := &models.AuditAnchor{}
+	err := s.db.QueryRowContext(ctx, "SELECT id, hash FROM audit_events ORDER BY id DESC LIMIT 1").Scan(&head.Id, &head.Hash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return head, nil
+}
+
+// ListAuditEvents returns up to limit events matching filter with id greater
+// than afterId, ordered by id.
+func (s *Storage) ListAuditEvents(ctx context.Context, filter models.AuditFilter, afterId int64, limit int) ([]models.AuditEvent, error) {
+	const op = "Storage.PostgreSQL.ListAuditEvents"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+
+	query := "SELECT " + auditColumns + " FROM audit_events WHERE id > $1"
+	args := []any{afterId}
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		query += fmt.Sprintf(" AND "+condition, len(args))
+	}
+
+	if filter.Action != "" {
+		where("action = $%d", filter.Action)
+	}
+	if filter.ActorId != 0 {
+		where("actor_id = $%d", filter.ActorId)
+	}
+	if filter.SubjectId != 0 {
+		where("subject_id = $%d", filter.SubjectId)
+	}
+	if filter.AppId != 0 {
+		where("app_id = $%d", filter.AppId)
+	}
+	if filter.Outcome != "" {
+		where("outcome = $%d", filter.Outcome)
+	}
+	if !filter.After.IsZero() {
+		where("occurred_at >= $%d", filter.After.UTC())
+	}
+	if !filter.Before.IsZero() {
+		where("occurred_at < $%d", filter.Before.UTC())
+	}
+
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY id LIMIT $%d", len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+		events = append(events, *event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return events, nil
+}
+
+func scanAuditEvent(row rowScanner) (*models.AuditEvent, error) {
+	event := &models.AuditEvent{}
+	err := row.Scan(
+		&event.Id, &event.OccurredAt, &event.Action, &event.ActorId, &event.SubjectId, &event.AppId,
+		&event.IP, &event.UserAgent, &event.Outcome, &event.Reason, &event.PrevHash, &event.Hash,
+		&event.PersonalSalt, &event.PersonalDigest,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
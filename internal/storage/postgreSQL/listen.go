@@ -0,0 +1,49 @@
+package postgreSQL
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"sso/internal/storage"
+)
+
+// AppsChannel is notified by a trigger on the apps table with the id of the changed app.
+const AppsChannel = "apps_changed"
+
+// Listen subscribes to a PostgreSQL notification channel and calls handler for
+// every received payload until ctx is done or the connection breaks. Right after
+// subscribing handler receives storage.NotifyAll, because notifications sent
+// while the listener was disconnected are lost.
+func (s *Storage) Listen(ctx context.Context, channel string, handler func(payload string)) error {
+	const op = "Storage.PostgreSQL.Listen"
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	defer conn.Close()
+
+	err = conn.Raw(func(driverConn any) error {
+		pgxConn := driverConn.(*stdlib.Conn).Conn()
+
+		if _, err := pgxConn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return err
+		}
+		handler(storage.NotifyAll)
+
+		for {
+			notification, err := pgxConn.WaitForNotification(ctx)
+			if err != nil {
+				// the connection is still subscribed, never give it back to the pool
+				return fmt.Errorf("%w: %w", driver.ErrBadConn, err)
+			}
+			handler(notification.Payload)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
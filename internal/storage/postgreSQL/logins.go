@@ -0,0 +1,121 @@
+package postgreSQL
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"time"
+)
+
+const loginAttemptColumns = "id, COALESCE(user_id, 0), COALESCE(app_id, 0), login, ip, user_agent, device_id, success, reason, occurred_at"
+
+const deviceColumns = "device_id, ip, user_agent, first_seen_at, last_seen_at"
+
+func (s *Storage) SaveLoginAttempt(ctx context.Context, attempt *models.LoginAttempt) error {
+	const op = "Storage.PostgreSQL.SaveLoginAttempt"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	attempt.OccurredAt = time.Now().UTC()
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO login_attempts(user_id, app_id, login, ip, user_agent, device_id, success, reason, occurred_at)
+		VALUES (NULLIF($1, 0), NULLIF($2, 0), $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+		attempt.UserId, attempt.AppId, attempt.Login, attempt.IP, attempt.UserAgent, attempt.DeviceId,
+		attempt.Success, attempt.Reason, attempt.OccurredAt,
+	).Scan(&attempt.Id)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
+
+// ListLoginAttempts returns up to limit login attempts of the user with id
+// less than beforeId, newest first. Zero beforeId starts from the newest.
+func (s *Storage) ListLoginAttempts(ctx context.Context, userId int64, beforeId int64, limit int) ([]models.LoginAttempt, error) {
+	const op = "Storage.PostgreSQL.ListLoginAttempts"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+loginAttemptColumns+" FROM login_attempts WHERE user_id = $1 AND ($2 = 0 OR id < $2) ORDER BY id DESC LIMIT $3",
+		userId, beforeId, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	var attempts []models.LoginAttempt
+	for rows.Next() {
+		var attempt models.LoginAttempt
+		err := rows.Scan(
+			&attempt.Id, &attempt.UserId, &attempt.AppId, &attempt.Login, &attempt.IP, &attempt.UserAgent,
+			&attempt.DeviceId, &attempt.Success, &attempt.Reason, &attempt.OccurredAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+		attempts = append(attempts, attempt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return attempts, nil
+}
+
+func (s *Storage) HasDevices(ctx context.Context, userId int64) (bool, error) {
+	const op = "Storage.PostgreSQL.HasDevices"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	var exists bool
+	err := s.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM known_devices WHERE user_id = $1)", userId).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("%s:%w", op, err)
+	}
+	return exists, nil
+}
+
+// SaveDevice remembers the device of the user or refreshes when it was last
+// seen, and reports whether the device was not known before.
+func (s *Storage) SaveDevice(ctx context.Context, userId int64, device *models.Device) (bool, error) {
+	const op = "Storage.PostgreSQL.SaveDevice"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	now := time.Now().UTC()
+	var isNew bool
+	// xmax is zero only for rows inserted by this statement
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO known_devices(user_id, device_id, ip, user_agent, first_seen_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		ON CONFLICT (user_id, device_id) DO UPDATE
+		SET ip = EXCLUDED.ip, user_agent = EXCLUDED.user_agent, last_seen_at = EXCLUDED.last_seen_at
+		RETURNING xmax = 0`,
+		userId, device.DeviceId, device.IP, device.UserAgent, now,
+	).Scan(&isNew)
+	if err != nil {
+		return false, fmt.Errorf("%s:%w", op, err)
+	}
+	return isNew, nil
+}
+
+func (s *Storage) ListDevices(ctx context.Context, userId int64) ([]models.Device, error) {
+	const op = "Storage.PostgreSQL.ListDevices"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	rows, err := s.db.QueryContext(ctx, "SELECT "+deviceColumns+" FROM known_devices WHERE user_id = $1 ORDER BY first_seen_at", userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	devices := []models.Device{}
+	for rows.Next() {
+		var device models.Device
+		if err := rows.Scan(&device.DeviceId, &device.IP, &device.UserAgent, &device.FirstSeenAt, &device.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+		devices = append(devices, device)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return devices, nil
+}
@@ -0,0 +1,211 @@
+package postgreSQL
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sso/internal/domain/models"
+	"time"
+)
+
+// EventsChannel is notified by a trigger on the outbox_events table with the
+// seq of every new event.
+const EventsChannel = "outbox_events"
+
+// saveEvent writes a domain event to the outbox in the transaction of the
+// state change it describes, so the event is published if and only if the
+// change is committed. The event is queued for delivery to matching webhook
+// subscriptions in the same transaction. The insert waits for other writers
+// of the outbox to commit, see migration 15.
+func saveEvent(ctx context.Context, tx *sql.Tx, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	var eventId string
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO outbox_events(type, payload, occurred_at, next_attempt_at) VALUES ($1, $2, $3, $3) RETURNING id",
+		eventType, data, now,
+	).Scan(&eventId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries(subscription_id, event_id, event_type, payload, occurred_at, next_attempt_at, created_at)
+		SELECT id, $1, $2, $3, $4, $4, $4 FROM webhook_subscriptions
+		WHERE enabled AND (event_types = '[]'::jsonb OR event_types @> to_jsonb($2::text))`,
+		eventId, eventType, data, now,
+	)
+	return err
+}
+
+func adminEventType(isAdmin bool) string {
+	if isAdmin {
+		return models.EventAdminGranted
+	}
+	return models.EventAdminRevoked
+}
+
+// ClaimOutboxEvents returns up to limit unpublished events that are due and
+// hides them from other relays for the lease duration. Events not marked
+// published or failed before the lease expires are claimed again.
+func (s *Storage) ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]models.Event, error) {
+	const op = "Storage.PostgreSQL.ClaimOutboxEvents"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+
+	now := time.Now().UTC()
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE outbox_events
+		SET attempts = attempts + 1, next_attempt_at = $1
+		WHERE seq IN (
+			SELECT seq FROM outbox_events
+			WHERE published_at IS NULL AND next_attempt_at <= $2
+			ORDER BY seq
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING seq, id, type, payload, occurred_at, attempts`,
+		now.Add(lease), now, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	events, err := scanEvents(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+
+	// RETURNING does not keep the order of the subquery
+	sort.Slice(events, func(i, j int) bool { return events[i].Seq < events[j].Seq })
+	return events, nil
+}
+
+// ListOutboxEvents returns up to limit events with seq greater than afterSeq,
+// ordered by seq, whether published or not. Events commit in seq order, so
+// an event with a smaller seq can not show up after the ones returned.
+func (s *Storage) ListOutboxEvents(ctx context.Context, afterSeq int64, limit int) ([]models.Event, error) {
+	const op = "Storage.PostgreSQL.ListOutboxEvents"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT seq, id, type, payload, occurred_at, attempts FROM outbox_events WHERE seq > $1 ORDER BY seq LIMIT $2",
+		afterSeq, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	events, err := scanEvents(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return events, nil
+}
+
+// LastOutboxEvent returns the most recent event, nil if the outbox is empty.
+func (s *Storage) LastOutboxEvent(ctx context.Context) (*models.Event, error) {
+	const op = "Storage.PostgreSQL.LastOutboxEvent"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	rows, err := s.db.QueryContext(ctx, "SELECT seq, id, type, payload, occurred_at, attempts FROM outbox_events ORDER BY seq DESC LIMIT 1")
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	events, err := scanEvents(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	if len(events) == 0 {
+		return nil, nil
+	}
+	return &events[0], nil
+}
+
+func (s *Storage) MarkOutboxEventPublished(ctx context.Context, seq int64) error {
+	const op = "Storage.PostgreSQL.MarkOutboxEventPublished"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	_, err := s.db.ExecContext(ctx, "UPDATE outbox_events SET published_at = $1, last_error = '' WHERE seq = $2", time.Now().UTC(), seq)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
+
+// MarkOutboxEventFailed records a failed delivery, the event is retried at
+// retryAt.
+func (s *Storage) MarkOutboxEventFailed(ctx context.Context, seq int64, retryAt time.Time, lastErr string) error {
+	const op = "Storage.PostgreSQL.MarkOutboxEventFailed"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE outbox_events SET next_attempt_at = $1, last_error = $2 WHERE seq = $3 AND published_at IS NULL",
+		retryAt.UTC(), lastErr, seq,
+	)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
+
+// PurgeOutboxEvents removes events published before the given time and
+// returns how many were removed.
+func (s *Storage) PurgeOutboxEvents(ctx context.Context, publishedBefore time.Time) (int64, error) {
+	const op = "Storage.PostgreSQL.PurgeOutboxEvents"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	res, err := s.db.ExecContext(ctx, "DELETE FROM outbox_events WHERE published_at < $1", publishedBefore.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+	return purged, nil
+}
+
+// PurgeUnpublishedOutboxEvents removes events that occurred before the given
+// time and were never published, for when there is no publisher to relay
+// them, and returns how many were removed.
+func (s *Storage) PurgeUnpublishedOutboxEvents(ctx context.Context, occurredBefore time.Time) (int64, error) {
+	const op = "Storage.PostgreSQL.PurgeUnpublishedOutboxEvents"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	res, err := s.db.ExecContext(ctx, "DELETE FROM outbox_events WHERE published_at IS NULL AND occurred_at < $1", occurredBefore.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+	return purged, nil
+}
+
+func scanEvents(rows *sql.Rows) ([]models.Event, error) {
+	var events []models.Event
+	for rows.Next() {
+		var event models.Event
+		var payload []byte
+		if err := rows.Scan(&event.Seq, &event.Id, &event.Type, &payload, &event.OccurredAt, &event.Attempts); err != nil {
+			return nil, err
+		}
+		event.Payload = payload
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
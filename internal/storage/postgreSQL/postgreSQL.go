@@ -1,110 +1,89 @@
 package postgreSQL
 
 import (
+	"context"
 	"database/sql"
-	"errors"
 	"fmt"
-	"github.com/jackc/pgx/v5/pgconn"
 	"sso/internal/config"
-	"sso/internal/domain/models"
-	"sso/internal/storage"
-	"time"
+	"sso/internal/lib/secrets"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
+var (
+	tracer   = otel.Tracer("sso/internal/storage/postgreSQL")
+	dbSystem = attribute.String("db.system", "postgresql")
+)
+
 type Storage struct {
-	db *sql.DB
+	db      *sql.DB
+	secrets *secrets.Cipher
 }
 
 func New(cfg *config.Config) (*Storage, error) {
 	const op = "Storage.PostgreSQL.New"
 
-	db, err := sql.Open("pgx", cfg.StoragePath)
+	cipher, err := secrets.New(cfg.SecretsKey)
 	if err != nil {
 		return nil, fmt.Errorf("%s:%w", op, err)
 	}
 
-	return &Storage{db: db}, nil
-}
-
-func (s *Storage) SaveUser(email string, passHash []byte) (int64, error) {
-	const op = "Storage.PostgreSQL.SaveUser"
-	var id int64
-	err := s.db.QueryRow("INSERT INTO users(email, pass_hash, timestamp) VALUES ($1, $2, $3) RETURNING id", email, passHash, time.Now()).Scan(&id)
-	var pgErr *pgconn.PgError
-	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
-		return 0, fmt.Errorf("%s:%w", op, storage.ErrUserAlreadyExists)
-	}
+	db, err := sql.Open("pgx", cfg.StoragePath)
 	if err != nil {
-		return 0, fmt.Errorf("%s:%w", op, err)
+		return nil, fmt.Errorf("%s:%w", op, err)
 	}
-	return id, nil
+
+	return &Storage{db: db, secrets: cipher}, nil
 }
 
-func (s *Storage) GetUserByEmail(email string) (*models.User, error) {
-	const op = "Storage.PostgreSQL.GetUserByEmail"
-	row := s.db.QueryRow("SELECT id, email, pass_hash FROM users WHERE email = $1", email)
-	user := &models.User{}
+// startSpan starts the span of a storage call, named after its op.
+func startSpan(ctx context.Context, op string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(dbSystem))
+}
 
-	err := row.Scan(&user.Id, &user.Email, &user.PassHash)
+// inTx runs fn in a transaction that is committed if fn succeeds.
+func (s *Storage) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("%s:%w", op, storage.ErrUserNotFound)
-		}
-		return nil, fmt.Errorf("%s:%w", op, err)
+		return err
 	}
-	return &models.User{
-		Id:       user.Id,
-		Email:    user.Email,
-		PassHash: user.PassHash,
-	}, nil
-}
-
-func (s *Storage) GetAppById(appId int) (*models.App, error) {
-	const op = "Storage.PostgreSQL.GetAppById"
-	row := s.db.QueryRow("SELECT id, name, secret FROM apps WHERE id = $1", appId)
-	app := &models.App{}
+	defer tx.Rollback()
 
-	err := row.Scan(&app.Id, &app.Name, &app.Secret)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("%s:%w", op, storage.ErrAppNotFound)
-		}
-		return nil, fmt.Errorf("%s:%w", op, err)
+	if err := fn(tx); err != nil {
+		return err
 	}
-	return &models.App{
-		Id:     app.Id,
-		Name:   app.Name,
-		Secret: app.Secret,
-	}, nil
+	return tx.Commit()
 }
 
-func (s *Storage) IsAdmin(userId int64) (bool, error) {
-	const op = "Storage.PostgreSQL.IsAdmin"
-	row := s.db.QueryRow("SELECT is_admin FROM users WHERE id = $1", userId)
-	var isAdmin bool
+// Ping checks that the database is reachable.
+func (s *Storage) Ping(ctx context.Context) error {
+	const op = "Storage.PostgreSQL.Ping"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
 
-	err := row.Scan(&isAdmin)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return false, fmt.Errorf("%s:%w", op, storage.ErrUserNotFound)
-		}
-		return false, fmt.Errorf("%s:%w", op, err)
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
 	}
-	return isAdmin, nil
+	return nil
 }
 
-func (s *Storage) SetAdmin(userId int64, isAdmin bool) (bool, error) {
-	const op = "Storage.PostgreSQL.SetAdmin"
-	_, err := s.db.Exec("UPDATE users SET is_admin = $1 WHERE id = $2", isAdmin, userId)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return false, fmt.Errorf("%s:%w", op, storage.ErrUserNotFound)
-		}
-		return false, fmt.Errorf("%s:%w", op, err)
+// Stats returns the connection pool stats.
+func (s *Storage) Stats() sql.DBStats {
+	return s.db.Stats()
+}
+
+// Close closes the connection pool, waiting for the queries in progress.
+func (s *Storage) Close() error {
+	const op = "Storage.PostgreSQL.Close"
+
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
 	}
-	return true, nil
+	return nil
 }
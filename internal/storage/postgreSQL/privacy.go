@@ -0,0 +1,171 @@
+package postgreSQL
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// ExportUser collects everything stored about the user. Unlike GetUserById it
+// includes soft deleted users, their data is kept until the purge.
+func (s *Storage) ExportUser(ctx context.Context, userId int64) (*models.UserExport, error) {
+	const op = "Storage.PostgreSQL.ExportUser"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+
+	user, err := scanUser(s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", userId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s:%w", op, storage.ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	profile, err := s.GetProfile(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+
+	export := &models.UserExport{
+		ExportedAt:  time.Now().UTC(),
+		User:        *user,
+		Roles:       []string{},
+		Profile:     *profile,
+		AppMetadata: []models.AppMetadata{},
+		AuditEvents: []models.AuditEvent{},
+	}
+	if user.IsAdmin {
+		export.Roles = append(export.Roles, models.RoleAdmin)
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT app_id, metadata, updated_at FROM user_app_metadata WHERE user_id = $1 ORDER BY app_id", userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var metadata models.AppMetadata
+		if err := rows.Scan(&metadata.AppId, &metadata.Metadata, &metadata.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+		export.AppMetadata = append(export.AppMetadata, metadata)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+
+	auditRows, err := s.db.QueryContext(ctx, "SELECT "+auditColumns+" FROM audit_events WHERE subject_id = $1 OR actor_id = $1 ORDER BY id", userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer auditRows.Close()
+
+	for auditRows.Next() {
+		event, err := scanAuditEvent(auditRows)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+		export.AuditEvents = append(export.AuditEvents, *event)
+	}
+	if err := auditRows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+
+	if export.Logins, err = s.ListLoginAttempts(ctx, userId, 0, math.MaxInt32); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	if export.Logins == nil {
+		export.Logins = []models.LoginAttempt{}
+	}
+	if export.Devices, err = s.ListDevices(ctx, userId); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	if export.Sessions, err = s.ListSessions(ctx, userId, true); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return export, nil
+}
+
+// EraseUser removes the personal data of the user. The users row is kept
+// anonymized and soft deleted so that the id stays valid for anything that
+// references it, the purge removes it later as for any deleted user. The
+// email is scrubbed from the payloads of the user's domain events and webhook
+// deliveries, not yet relayed ones are still sent without it. The IP and
+// user agent of the user's audit events are erased as audit.Erase does, the
+// chain stays verifiable through their digests. Events saved before
+// migration 16 hash them directly and keep them, erasing them would break
+// the chain.
+func (s *Storage) EraseUser(ctx context.Context, userId int64) error {
+	const op = "Storage.PostgreSQL.EraseUser"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	res, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET email = 'erased-' || id || '@erased.invalid', username = NULL, phone = NULL, pass_hash = '',
+		    is_admin = FALSE, disabled_at = COALESCE(disabled_at, $1), deleted_at = COALESCE(deleted_at, $1)
+		WHERE id = $2`,
+		now, userId,
+	)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	if err := expectAffected(op, res, storage.ErrUserNotFound); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_profiles WHERE user_id = $1", userId); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_app_metadata WHERE user_id = $1", userId); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM login_attempts WHERE user_id = $1", userId); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM known_devices WHERE user_id = $1", userId); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM sessions WHERE user_id = $1", userId); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE outbox_events SET payload = payload - 'email' WHERE payload @> jsonb_build_object('user_id', $1::bigint) AND payload ? 'email'",
+		userId,
+	); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE webhook_deliveries SET payload = payload - 'email' WHERE payload @> jsonb_build_object('user_id', $1::bigint) AND payload ? 'email'",
+		userId,
+	); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE audit_events SET ip = '', user_agent = '', personal_salt = NULL
+		WHERE (subject_id = $1 OR actor_id = $1) AND personal_digest IS NOT NULL`,
+		userId,
+	); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	if err := saveEvent(ctx, tx, models.EventUserErased, models.UserEvent{UserId: userId}); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
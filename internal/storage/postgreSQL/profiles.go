@@ -0,0 +1,98 @@
+package postgreSQL
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// pgForeignKeyViolation is returned when a profile references a missing user or app.
+const pgForeignKeyViolation = "23503"
+
+// GetProfile returns the user profile, or an empty profile if the user never set one.
+func (s *Storage) GetProfile(ctx context.Context, userId int64) (*models.Profile, error) {
+	const op = "Storage.PostgreSQL.GetProfile"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	row := s.db.QueryRowContext(ctx, "SELECT user_id, display_name, locale, timezone, avatar_url, updated_at FROM user_profiles WHERE user_id = $1", userId)
+	profile := &models.Profile{}
+
+	err := row.Scan(&profile.UserId, &profile.DisplayName, &profile.Locale, &profile.Timezone, &profile.AvatarURL, &profile.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &models.Profile{UserId: userId}, nil
+		}
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return profile, nil
+}
+
+func (s *Storage) SaveProfile(ctx context.Context, profile *models.Profile) error {
+	const op = "Storage.PostgreSQL.SaveProfile"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO user_profiles(user_id, display_name, locale, timezone, avatar_url, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE
+		SET display_name = EXCLUDED.display_name, locale = EXCLUDED.locale, timezone = EXCLUDED.timezone,
+		    avatar_url = EXCLUDED.avatar_url, updated_at = EXCLUDED.updated_at`,
+		profile.UserId, profile.DisplayName, profile.Locale, profile.Timezone, profile.AvatarURL, time.Now().UTC(),
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation {
+		return fmt.Errorf("%s:%w", op, storage.ErrUserNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
+
+// GetAppMetadata returns the user metadata kept for an app, nil if there is none.
+func (s *Storage) GetAppMetadata(ctx context.Context, userId int64, appId int64) (json.RawMessage, error) {
+	const op = "Storage.PostgreSQL.GetAppMetadata"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	row := s.db.QueryRowContext(ctx, "SELECT metadata FROM user_app_metadata WHERE user_id = $1 AND app_id = $2", userId, appId)
+	var metadata []byte
+
+	err := row.Scan(&metadata)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return metadata, nil
+}
+
+func (s *Storage) SaveAppMetadata(ctx context.Context, userId int64, appId int64, metadata json.RawMessage) error {
+	const op = "Storage.PostgreSQL.SaveAppMetadata"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO user_app_metadata(user_id, app_id, metadata, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, app_id) DO UPDATE
+		SET metadata = EXCLUDED.metadata, updated_at = EXCLUDED.updated_at`,
+		userId, appId, []byte(metadata), time.Now().UTC(),
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation {
+		if pgErr.ConstraintName == "user_app_metadata_app_id_fkey" {
+			return fmt.Errorf("%s:%w", op, storage.ErrAppNotFound)
+		}
+		return fmt.Errorf("%s:%w", op, storage.ErrUserNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package postgreSQL
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// Session times are stored in TIMESTAMP columns, which drop the zone and are
+// read back as UTC, so they are always written in UTC.
+const sessionColumns = "id, user_id, app_id, device_id, ip, user_agent, created_at, authenticated_at, last_seen_at, expires_at, revoked_at"
+
+// sessionTouchInterval limits how often last_seen_at is written for a
+// session that is used continuously.
+const sessionTouchInterval = time.Minute
+
+func (s *Storage) SaveSession(ctx context.Context, session *models.Session) error {
+	const op = "Storage.PostgreSQL.SaveSession"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO sessions(id, user_id, app_id, device_id, ip, user_agent, created_at, authenticated_at, last_seen_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		session.Id, session.UserId, session.AppId, session.DeviceId, session.IP, session.UserAgent,
+		session.CreatedAt.UTC(), session.AuthenticatedAt.UTC(), session.LastSeenAt.UTC(), session.ExpiresAt.UTC(),
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation {
+		return fmt.Errorf("%s:%w", op, storage.ErrUserNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
+
+// SaveSessionWithinLimit saves the session unless the user already has limit
+// active sessions in its app, then it fails with storage.ErrSessionLimit or,
+// with evict, revokes the oldest of them to make room and returns their ids.
+// The check and the insert run under a lock on the user row, so concurrent
+// logins can not exceed the limit.
+func (s *Storage) SaveSessionWithinLimit(ctx context.Context, session *models.Session, limit int, evict bool) ([]string, error) {
+	const op = "Storage.PostgreSQL.SaveSessionWithinLimit"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+
+	var evicted []string
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var userId int64
+		err := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id = $1 FOR NO KEY UPDATE", session.UserId).Scan(&userId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return storage.ErrUserNotFound
+			}
+			return err
+		}
+
+		now := time.Now().UTC()
+		rows, err := tx.QueryContext(ctx,
+			"SELECT id FROM sessions WHERE user_id = $1 AND app_id = $2 AND revoked_at IS NULL AND expires_at > $3 ORDER BY created_at DESC",
+			session.UserId, session.AppId, now,
+		)
+		if err != nil {
+			return err
+		}
+		var active []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			active = append(active, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(active) >= limit {
+			if !evict {
+				return storage.ErrSessionLimit
+			}
+			// sessions are listed newest first
+			evicted = active[limit-1:]
+			for _, id := range evicted {
+				if _, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked_at = $1 WHERE id = $2", now, id); err != nil {
+					return err
+				}
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO sessions(id, user_id, app_id, device_id, ip, user_agent, created_at, authenticated_at, last_seen_at, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+			session.Id, session.UserId, session.AppId, session.DeviceId, session.IP, session.UserAgent,
+			session.CreatedAt.UTC(), session.AuthenticatedAt.UTC(), session.LastSeenAt.UTC(), session.ExpiresAt.UTC(),
+		)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return evicted, nil
+}
+
+func (s *Storage) GetSession(ctx context.Context, sessionId string) (*models.Session, error) {
+	const op = "Storage.PostgreSQL.GetSession"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	row := s.db.QueryRowContext(ctx, "SELECT "+sessionColumns+" FROM sessions WHERE id = $1", sessionId)
+
+	session, err := scanSession(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s:%w", op, storage.ErrSessionNotFound)
+		}
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return session, nil
+}
+
+// ListSessions returns the sessions of the user, newest first. Revoked and
+// expired sessions are only included with includeInactive.
+func (s *Storage) ListSessions(ctx context.Context, userId int64, includeInactive bool) ([]models.Session, error) {
+	const op = "Storage.PostgreSQL.ListSessions"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+sessionColumns+" FROM sessions WHERE user_id = $1 AND ($2 OR (revoked_at IS NULL AND expires_at > $3)) ORDER BY created_at DESC",
+		userId, includeInactive, time.Now().UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+		sessions = append(sessions, *session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return sessions, nil
+}
+
+// TouchSession records that the session was just used.
+func (s *Storage) TouchSession(ctx context.Context, sessionId string) error {
+	const op = "Storage.PostgreSQL.TouchSession"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	now := time.Now().UTC()
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE sessions SET last_seen_at = $1 WHERE id = $2 AND last_seen_at < $3",
+		now, sessionId, now.Add(-sessionTouchInterval),
+	)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
+
+// ExtendSession moves the expiry of an active session.
+func (s *Storage) ExtendSession(ctx context.Context, sessionId string, expiresAt time.Time) error {
+	const op = "Storage.PostgreSQL.ExtendSession"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	res, err := s.db.ExecContext(ctx, "UPDATE sessions SET expires_at = $1 WHERE id = $2 AND revoked_at IS NULL", expiresAt.UTC(), sessionId)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return expectAffected(op, res, storage.ErrSessionNotFound)
+}
+
+// ReauthenticateSession records that the user has just entered the password
+// for an active session.
+func (s *Storage) ReauthenticateSession(ctx context.Context, sessionId string) error {
+	const op = "Storage.PostgreSQL.ReauthenticateSession"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	res, err := s.db.ExecContext(ctx, "UPDATE sessions SET authenticated_at = $1 WHERE id = $2 AND revoked_at IS NULL", time.Now().UTC(), sessionId)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return expectAffected(op, res, storage.ErrSessionNotFound)
+}
+
+// RevokeSession revokes the session, revoking it again is not an error.
+func (s *Storage) RevokeSession(ctx context.Context, sessionId string) error {
+	const op = "Storage.PostgreSQL.RevokeSession"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	res, err := s.db.ExecContext(ctx, "UPDATE sessions SET revoked_at = COALESCE(revoked_at, $1) WHERE id = $2", time.Now().UTC(), sessionId)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return expectAffected(op, res, storage.ErrSessionNotFound)
+}
+
+// RevokeUserSessions revokes all active sessions of the user and returns how
+// many were revoked.
+func (s *Storage) RevokeUserSessions(ctx context.Context, userId int64) (int64, error) {
+	const op = "Storage.PostgreSQL.RevokeUserSessions"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	now := time.Now().UTC()
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL AND expires_at > $1",
+		now, userId,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+	revoked, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+	return revoked, nil
+}
+
+// PurgeSessions removes sessions that expired before the given time and
+// returns how many were removed.
+func (s *Storage) PurgeSessions(ctx context.Context, expiredBefore time.Time) (int64, error) {
+	const op = "Storage.PostgreSQL.PurgeSessions"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	res, err := s.db.ExecContext(ctx, "DELETE FROM sessions WHERE expires_at < $1", expiredBefore.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+	return purged, nil
+}
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	session := &models.Session{}
+	err := row.Scan(
+		&session.Id, &session.UserId, &session.AppId, &session.DeviceId, &session.IP, &session.UserAgent,
+		&session.CreatedAt, &session.AuthenticatedAt, &session.LastSeenAt, &session.ExpiresAt, &session.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
@@ -0,0 +1,324 @@
+package postgreSQL
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+const userColumns = "id, email, COALESCE(username, ''), COALESCE(phone, ''), pass_hash, is_admin, timestamp, disabled_at, deleted_at"
+
+func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (int64, error) {
+	const op = "Storage.PostgreSQL.SaveUser"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	var id int64
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, "INSERT INTO users(email, pass_hash, timestamp) VALUES ($1, $2, $3) RETURNING id", email, passHash, time.Now().UTC()).Scan(&id)
+		if err != nil {
+			return err
+		}
+		return saveEvent(ctx, tx, models.EventUserRegistered, models.UserEvent{UserId: id, Email: email})
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return 0, fmt.Errorf("%s:%w", op, storage.ErrUserAlreadyExists)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+	return id, nil
+}
+
+// SaveImportedUser stores a user migrated from another system with its
+// username, phone and already hashed password.
+func (s *Storage) SaveImportedUser(ctx context.Context, user *models.User) (int64, error) {
+	const op = "Storage.PostgreSQL.SaveImportedUser"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	var id int64
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx,
+			"INSERT INTO users(email, username, phone, pass_hash, timestamp) VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4, $5) RETURNING id",
+			user.Email, user.Username, user.Phone, user.PassHash, time.Now().UTC(),
+		).Scan(&id)
+		if err != nil {
+			return err
+		}
+		return saveEvent(ctx, tx, models.EventUserRegistered, models.UserEvent{UserId: id, Email: user.Email, Source: "import"})
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return 0, fmt.Errorf("%s:%w", op, storage.ErrUserAlreadyExists)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+	return id, nil
+}
+
+func (s *Storage) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	const op = "Storage.PostgreSQL.GetUserByEmail"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	return s.getUser(ctx, op, "lower(email) = lower($1)", email)
+}
+
+func (s *Storage) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	const op = "Storage.PostgreSQL.GetUserByUsername"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	return s.getUser(ctx, op, "lower(username) = lower($1)", username)
+}
+
+func (s *Storage) GetUserByPhone(ctx context.Context, phone string) (*models.User, error) {
+	const op = "Storage.PostgreSQL.GetUserByPhone"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	return s.getUser(ctx, op, "phone = $1", phone)
+}
+
+func (s *Storage) GetUserById(ctx context.Context, userId int64) (*models.User, error) {
+	const op = "Storage.PostgreSQL.GetUserById"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	return s.getUser(ctx, op, "id = $1", userId)
+}
+
+func (s *Storage) getUser(ctx context.Context, op string, condition string, arg any) (*models.User, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE "+condition+" AND deleted_at IS NULL", arg)
+
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s:%w", op, storage.ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return user, nil
+}
+
+// ListUsers returns up to limit not deleted users matching filter with id
+// greater than afterId, ordered by id.
+func (s *Storage) ListUsers(ctx context.Context, filter models.UserFilter, afterId int64, limit int) ([]models.User, error) {
+	const op = "Storage.PostgreSQL.ListUsers"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+
+	query := "SELECT " + userColumns + " FROM users WHERE deleted_at IS NULL AND id > $1"
+	args := []any{afterId}
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		query += fmt.Sprintf(" AND "+condition, len(args))
+	}
+
+	if filter.EmailPrefix != "" {
+		where("email LIKE $%d", escapeLike(strings.ToLower(filter.EmailPrefix))+"%")
+	}
+	if !filter.CreatedAfter.IsZero() {
+		where("timestamp >= $%d", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		where("timestamp < $%d", filter.CreatedBefore)
+	}
+	if filter.IsAdmin != nil {
+		where("is_admin = $%d", *filter.IsAdmin)
+	}
+	if filter.IsDisabled != nil {
+		where("(disabled_at IS NOT NULL) = $%d", *filter.IsDisabled)
+	}
+
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY id LIMIT $%d", len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+		users = append(users, *user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return users, nil
+}
+
+func (s *Storage) UpdateUser(ctx context.Context, user *models.User) error {
+	const op = "Storage.PostgreSQL.UpdateUser"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var wasAdmin bool
+		err := tx.QueryRowContext(ctx, "SELECT is_admin FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", user.Id).Scan(&wasAdmin)
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			"UPDATE users SET email = $1, username = NULLIF($2, ''), phone = NULLIF($3, ''), is_admin = $4 WHERE id = $5",
+			user.Email, user.Username, user.Phone, user.IsAdmin, user.Id,
+		)
+		if err != nil {
+			return err
+		}
+		if err := saveEvent(ctx, tx, models.EventUserUpdated, models.UserEvent{UserId: user.Id, Email: user.Email}); err != nil {
+			return err
+		}
+		if user.IsAdmin != wasAdmin {
+			return saveEvent(ctx, tx, adminEventType(user.IsAdmin), models.UserEvent{UserId: user.Id})
+		}
+		return nil
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return fmt.Errorf("%s:%w", op, storage.ErrUserAlreadyExists)
+	}
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) UpdatePassHash(ctx context.Context, userId int64, passHash []byte) error {
+	const op = "Storage.PostgreSQL.UpdatePassHash"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	res, err := s.db.ExecContext(ctx, "UPDATE users SET pass_hash = $1 WHERE id = $2 AND deleted_at IS NULL", passHash, userId)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return expectAffected(op, res, storage.ErrUserNotFound)
+}
+
+func (s *Storage) SetUserDisabled(ctx context.Context, userId int64, disabled bool) error {
+	const op = "Storage.PostgreSQL.SetUserDisabled"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			"UPDATE users SET disabled_at = CASE WHEN $1 THEN COALESCE(disabled_at, $2) END WHERE id = $3 AND deleted_at IS NULL",
+			disabled, time.Now().UTC(), userId,
+		)
+		if err != nil {
+			return err
+		}
+		if err := checkAffected(res, storage.ErrUserNotFound); err != nil {
+			return err
+		}
+		eventType := models.EventUserEnabled
+		if disabled {
+			eventType = models.EventUserDisabled
+		}
+		return saveEvent(ctx, tx, eventType, models.UserEvent{UserId: userId})
+	})
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
+
+// DeleteUser soft deletes the user, the row is removed by PurgeDeletedUsers
+// once the purge window has passed.
+func (s *Storage) DeleteUser(ctx context.Context, userId int64) error {
+	const op = "Storage.PostgreSQL.DeleteUser"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "UPDATE users SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL", time.Now().UTC(), userId)
+		if err != nil {
+			return err
+		}
+		if err := checkAffected(res, storage.ErrUserNotFound); err != nil {
+			return err
+		}
+		return saveEvent(ctx, tx, models.EventUserDeleted, models.UserEvent{UserId: userId})
+	})
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
+
+// PurgeDeletedUsers removes users soft deleted before the given time and
+// returns how many were removed.
+func (s *Storage) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	const op = "Storage.PostgreSQL.PurgeDeletedUsers"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	res, err := s.db.ExecContext(ctx, "DELETE FROM users WHERE deleted_at < $1", deletedBefore.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+	return purged, nil
+}
+
+func (s *Storage) IsAdmin(ctx context.Context, userId int64) (bool, error) {
+	const op = "Storage.PostgreSQL.IsAdmin"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	row := s.db.QueryRowContext(ctx, "SELECT is_admin FROM users WHERE id = $1 AND deleted_at IS NULL", userId)
+	var isAdmin bool
+
+	err := row.Scan(&isAdmin)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, fmt.Errorf("%s:%w", op, storage.ErrUserNotFound)
+		}
+		return false, fmt.Errorf("%s:%w", op, err)
+	}
+	return isAdmin, nil
+}
+
+func (s *Storage) SetAdmin(ctx context.Context, userId int64, isAdmin bool) (bool, error) {
+	const op = "Storage.PostgreSQL.SetAdmin"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "UPDATE users SET is_admin = $1 WHERE id = $2 AND deleted_at IS NULL", isAdmin, userId)
+		if err != nil {
+			return err
+		}
+		if err := checkAffected(res, storage.ErrUserNotFound); err != nil {
+			return err
+		}
+		return saveEvent(ctx, tx, adminEventType(isAdmin), models.UserEvent{UserId: userId})
+	})
+	if err != nil {
+		return false, fmt.Errorf("%s:%w", op, err)
+	}
+	return isAdmin, nil
+}
+
+func scanUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+	err := row.Scan(&user.Id, &user.Email, &user.Username, &user.Phone, &user.PassHash, &user.IsAdmin, &user.CreatedAt, &user.DisabledAt, &user.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -0,0 +1,354 @@
+package postgreSQL
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"sort"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+const (
+	webhookColumns  = "id, app_id, url, secret, event_types, enabled, created_at, updated_at"
+	deliveryColumns = "id, subscription_id, event_id, event_type, payload, occurred_at, status, attempts, next_attempt_at, " +
+		"last_status_code, last_error, created_at, delivered_at"
+)
+
+func (s *Storage) SaveWebhookSubscription(ctx context.Context, sub *models.WebhookSubscription) (int64, error) {
+	const op = "Storage.PostgreSQL.SaveWebhookSubscription"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+
+	secret, err := s.secrets.Encrypt(sub.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+	eventTypes, err := json.Marshal(nonNil(sub.EventTypes))
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	var id int64
+	err = s.db.QueryRowContext(ctx,
+		"INSERT INTO webhook_subscriptions(app_id, url, secret, event_types, enabled, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $6) RETURNING id",
+		sub.AppId, sub.URL, secret, eventTypes, sub.Enabled, time.Now().UTC(),
+	).Scan(&id)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return 0, fmt.Errorf("%s:%w", op, storage.ErrAppNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+	return id, nil
+}
+
+// GetWebhookSubscription returns the subscription with its decrypted secret.
+func (s *Storage) GetWebhookSubscription(ctx context.Context, id int64) (*models.WebhookSubscription, error) {
+	const op = "Storage.PostgreSQL.GetWebhookSubscription"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	row := s.db.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhook_subscriptions WHERE id = $1", id)
+
+	sub, err := s.scanWebhookSubscription(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s:%w", op, storage.ErrWebhookNotFound)
+		}
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return sub, nil
+}
+
+func (s *Storage) ListWebhookSubscriptions(ctx context.Context, appId int64) ([]models.WebhookSubscription, error) {
+	const op = "Storage.PostgreSQL.ListWebhookSubscriptions"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	rows, err := s.db.QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhook_subscriptions WHERE app_id = $1 ORDER BY id", appId)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	var subs []models.WebhookSubscription
+	for rows.Next() {
+		sub, err := s.scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+		subs = append(subs, *sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return subs, nil
+}
+
+// UpdateWebhookSubscription stores every field of sub except the secret and
+// the app.
+func (s *Storage) UpdateWebhookSubscription(ctx context.Context, sub *models.WebhookSubscription) error {
+	const op = "Storage.PostgreSQL.UpdateWebhookSubscription"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+
+	eventTypes, err := json.Marshal(nonNil(sub.EventTypes))
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE webhook_subscriptions SET url = $1, event_types = $2, enabled = $3, updated_at = $4 WHERE id = $5",
+		sub.URL, eventTypes, sub.Enabled, time.Now().UTC(), sub.Id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return expectAffected(op, res, storage.ErrWebhookNotFound)
+}
+
+func (s *Storage) UpdateWebhookSecret(ctx context.Context, id int64, secret string) error {
+	const op = "Storage.PostgreSQL.UpdateWebhookSecret"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+
+	encrypted, err := s.secrets.Encrypt(secret)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	res, err := s.db.ExecContext(ctx, "UPDATE webhook_subscriptions SET secret = $1, updated_at = $2 WHERE id = $3", encrypted, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return expectAffected(op, res, storage.ErrWebhookNotFound)
+}
+
+// DeleteWebhookSubscription removes the subscription with its deliveries.
+func (s *Storage) DeleteWebhookSubscription(ctx context.Context, id int64) error {
+	const op = "Storage.PostgreSQL.DeleteWebhookSubscription"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	res, err := s.db.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return expectAffected(op, res, storage.ErrWebhookNotFound)
+}
+
+// ClaimWebhookDeliveries returns up to limit pending deliveries of enabled
+// subscriptions that are due and hides them from other dispatchers for the
+// lease duration.
+func (s *Storage) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	const op = "Storage.PostgreSQL.ClaimWebhookDeliveries"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+
+	now := time.Now().UTC()
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1, next_attempt_at = $1
+		WHERE id IN (
+			SELECT d.id FROM webhook_deliveries d
+			JOIN webhook_subscriptions s ON s.id = d.subscription_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= $2 AND s.enabled
+			ORDER BY d.id
+			LIMIT $3
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		RETURNING `+deliveryColumns,
+		now.Add(lease), now, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	deliveries, err := scanWebhookDeliveries(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].Id < deliveries[j].Id })
+	return deliveries, nil
+}
+
+// RecordWebhookAttempt logs a delivery attempt and moves the delivery to
+// status. Pending deliveries are retried at nextAttemptAt.
+func (s *Storage) RecordWebhookAttempt(ctx context.Context, attempt models.WebhookAttempt, status string, nextAttemptAt time.Time) error {
+	const op = "Storage.PostgreSQL.RecordWebhookAttempt"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		attemptedAt := attempt.AttemptedAt.UTC()
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO webhook_attempts(delivery_id, attempted_at, status_code, error, duration_ms) VALUES ($1, $2, $3, $4, $5)",
+			attempt.DeliveryId, attemptedAt, attempt.StatusCode, attempt.Error, attempt.Duration.Milliseconds(),
+		)
+		if err != nil {
+			return err
+		}
+
+		var deliveredAt *time.Time
+		if status == models.DeliveryDelivered {
+			deliveredAt = &attemptedAt
+		}
+		res, err := tx.ExecContext(ctx,
+			"UPDATE webhook_deliveries SET status = $1, next_attempt_at = $2, last_status_code = $3, last_error = $4, delivered_at = $5 WHERE id = $6",
+			status, nextAttemptAt.UTC(), attempt.StatusCode, attempt.Error, deliveredAt, attempt.DeliveryId,
+		)
+		if err != nil {
+			return err
+		}
+		return checkAffected(res, storage.ErrDeliveryNotFound)
+	})
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) GetWebhookDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+	const op = "Storage.PostgreSQL.GetWebhookDelivery"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	rows, err := s.db.QueryContext(ctx, "SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = $1", id)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	deliveries, err := scanWebhookDeliveries(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	if len(deliveries) == 0 {
+		return nil, fmt.Errorf("%s:%w", op, storage.ErrDeliveryNotFound)
+	}
+	return &deliveries[0], nil
+}
+
+// ListWebhookDeliveries returns up to limit deliveries of the subscription
+// with id less than beforeId, newest first. An empty status matches all.
+func (s *Storage) ListWebhookDeliveries(ctx context.Context, subscriptionId int64, status string, beforeId int64, limit int) ([]models.WebhookDelivery, error) {
+	const op = "Storage.PostgreSQL.ListWebhookDeliveries"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE subscription_id = $1 AND ($2 = '' OR status = $2) AND id < $3 ORDER BY id DESC LIMIT $4",
+		subscriptionId, status, beforeId, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	deliveries, err := scanWebhookDeliveries(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return deliveries, nil
+}
+
+// ListWebhookAttempts returns the attempts of a delivery, oldest first.
+func (s *Storage) ListWebhookAttempts(ctx context.Context, deliveryId int64) ([]models.WebhookAttempt, error) {
+	const op = "Storage.PostgreSQL.ListWebhookAttempts"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, delivery_id, attempted_at, status_code, error, duration_ms FROM webhook_attempts WHERE delivery_id = $1 ORDER BY id",
+		deliveryId,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	var attempts []models.WebhookAttempt
+	for rows.Next() {
+		var attempt models.WebhookAttempt
+		var durationMs int64
+		if err := rows.Scan(&attempt.Id, &attempt.DeliveryId, &attempt.AttemptedAt, &attempt.StatusCode, &attempt.Error, &durationMs); err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+		attempt.Duration = time.Duration(durationMs) * time.Millisecond
+		attempts = append(attempts, attempt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	return attempts, nil
+}
+
+// RedeliverWebhook queues a delivery again right away with a fresh attempt
+// budget, whatever its status.
+func (s *Storage) RedeliverWebhook(ctx context.Context, id int64) error {
+	const op = "Storage.PostgreSQL.RedeliverWebhook"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = $1, delivered_at = NULL WHERE id = $2",
+		time.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	return expectAffected(op, res, storage.ErrDeliveryNotFound)
+}
+
+// PurgeWebhookDeliveries removes delivered and dead deliveries created
+// before the given time and returns how many were removed.
+func (s *Storage) PurgeWebhookDeliveries(ctx context.Context, createdBefore time.Time) (int64, error) {
+	const op = "Storage.PostgreSQL.PurgeWebhookDeliveries"
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	res, err := s.db.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < $1", createdBefore.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+	return purged, nil
+}
+
+func (s *Storage) scanWebhookSubscription(row rowScanner) (*models.WebhookSubscription, error) {
+	sub := &models.WebhookSubscription{}
+	var secret string
+	var eventTypes []byte
+	err := row.Scan(&sub.Id, &sub.AppId, &sub.URL, &secret, &eventTypes, &sub.Enabled, &sub.CreatedAt, &sub.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Secret, err = s.secrets.Decrypt(secret); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(eventTypes, &sub.EventTypes); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+func scanWebhookDeliveries(rows *sql.Rows) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var payload []byte
+		err := rows.Scan(
+			&d.Id, &d.SubscriptionId, &d.Event.Id, &d.Event.Type, &payload, &d.Event.OccurredAt, &d.Status, &d.Attempts,
+			&d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		d.Event.Payload = payload
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
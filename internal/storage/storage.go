@@ -6,5 +6,13 @@ var (
 	ErrUserAlreadyExists = errors.New("user already exists")
 	ErrUserNotFound      = errors.New("user not found")
 	ErrAppNotFound       = errors.New("app not found")
+	ErrAppAlreadyExists  = errors.New("app already exists")
+	ErrSessionNotFound   = errors.New("session not found")
+	ErrSessionLimit      = errors.New("session limit reached")
+	ErrWebhookNotFound   = errors.New("webhook subscription not found")
+	ErrDeliveryNotFound  = errors.New("webhook delivery not found")
 	//ErrSomeStorageProblem = errors.New("some storage problem")
 )
+
+// NotifyAll is a change notification payload meaning that any cached row may be stale.
+const NotifyAll = "*"
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS public.sessions_user_app_idx;
+
+ALTER TABLE public.sessions
+    DROP COLUMN IF EXISTS authenticated_at;
+
+ALTER TABLE public.apps
+    DROP COLUMN IF EXISTS step_up_after_seconds,
+    DROP COLUMN IF EXISTS session_limit_action,
+    DROP COLUMN IF EXISTS max_sessions,
+    DROP COLUMN IF EXISTS session_lifetime_seconds,
+    DROP COLUMN IF EXISTS idle_timeout_seconds;
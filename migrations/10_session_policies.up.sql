@@ -0,0 +1,15 @@
+ALTER TABLE public.apps
+    ADD COLUMN idle_timeout_seconds     INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN session_lifetime_seconds INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN max_sessions             INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN session_limit_action     TEXT    NOT NULL DEFAULT 'evict_oldest',
+    ADD COLUMN step_up_after_seconds    INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE public.sessions
+    ADD COLUMN authenticated_at TIMESTAMP;
+UPDATE public.sessions
+SET authenticated_at = created_at;
+ALTER TABLE public.sessions
+    ALTER COLUMN authenticated_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS sessions_user_app_idx ON public.sessions (user_id, app_id) WHERE revoked_at IS NULL;
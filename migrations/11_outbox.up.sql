@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS public.outbox_events
+(
+    seq             BIGSERIAL PRIMARY KEY,
+    id              TEXT      NOT NULL UNIQUE DEFAULT gen_random_uuid()::text,
+    type            TEXT      NOT NULL,
+    payload         JSONB     NOT NULL,
+    occurred_at     TIMESTAMP NOT NULL,
+    attempts        INTEGER   NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_error      TEXT      NOT NULL DEFAULT '',
+    published_at    TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS outbox_events_pending_idx ON public.outbox_events (next_attempt_at) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_events_published_at_idx ON public.outbox_events (published_at);
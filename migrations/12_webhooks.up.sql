@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS public.webhook_subscriptions
+(
+    id          SERIAL PRIMARY KEY,
+    app_id      INTEGER   NOT NULL REFERENCES public.apps (id) ON DELETE CASCADE,
+    url         TEXT      NOT NULL,
+    secret      TEXT      NOT NULL,
+    event_types JSONB     NOT NULL DEFAULT '[]',
+    enabled     BOOLEAN   NOT NULL DEFAULT TRUE,
+    created_at  TIMESTAMP NOT NULL,
+    updated_at  TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS webhook_subscriptions_app_id_idx ON public.webhook_subscriptions (app_id);
+
+CREATE TABLE IF NOT EXISTS public.webhook_deliveries
+(
+    id               BIGSERIAL PRIMARY KEY,
+    subscription_id  INTEGER   NOT NULL REFERENCES public.webhook_subscriptions (id) ON DELETE CASCADE,
+    event_id         TEXT      NOT NULL,
+    event_type       TEXT      NOT NULL,
+    payload          JSONB     NOT NULL,
+    occurred_at      TIMESTAMP NOT NULL,
+    status           TEXT      NOT NULL DEFAULT 'pending',
+    attempts         INTEGER   NOT NULL DEFAULT 0,
+    next_attempt_at  TIMESTAMP NOT NULL,
+    last_status_code INTEGER   NOT NULL DEFAULT 0,
+    last_error       TEXT      NOT NULL DEFAULT '',
+    created_at       TIMESTAMP NOT NULL,
+    delivered_at     TIMESTAMP,
+    UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON public.webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS public.webhook_attempts
+(
+    id           BIGSERIAL PRIMARY KEY,
+    delivery_id  BIGINT    NOT NULL REFERENCES public.webhook_deliveries (id) ON DELETE CASCADE,
+    attempted_at TIMESTAMP NOT NULL,
+    status_code  INTEGER   NOT NULL DEFAULT 0,
+    error        TEXT      NOT NULL DEFAULT '',
+    duration_ms  INTEGER   NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS webhook_attempts_delivery_id_idx ON public.webhook_attempts (delivery_id);
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS outbox_event_saved ON public.outbox_events;
+DROP FUNCTION IF EXISTS public.notify_outbox_event();
@@ -0,0 +1,13 @@
+CREATE OR REPLACE FUNCTION public.notify_outbox_event() RETURNS TRIGGER AS
+$$
+BEGIN
+    PERFORM pg_notify('outbox_events', NEW.seq::TEXT);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER outbox_event_saved
+    AFTER INSERT
+    ON public.outbox_events
+    FOR EACH ROW
+EXECUTE FUNCTION public.notify_outbox_event();
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS public.users_phone_key;
+DROP INDEX IF EXISTS public.users_username_lower_key;
+DROP INDEX IF EXISTS public.users_email_lower_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_lower_key ON public.users (lower(email));
+CREATE UNIQUE INDEX IF NOT EXISTS users_username_lower_key ON public.users (lower(username)) WHERE username IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS users_phone_key ON public.users (phone) WHERE phone IS NOT NULL;
+
+ALTER TABLE public.users
+    ADD CONSTRAINT users_email_key UNIQUE (email);
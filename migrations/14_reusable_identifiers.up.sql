@@ -0,0 +1,12 @@
+-- A soft deleted user keeps its row until it is purged, its email, username
+-- and phone number can be registered again in the meantime.
+ALTER TABLE public.users
+    DROP CONSTRAINT IF EXISTS users_email_key;
+
+DROP INDEX IF EXISTS public.users_email_lower_key;
+DROP INDEX IF EXISTS public.users_username_lower_key;
+DROP INDEX IF EXISTS public.users_phone_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_lower_key ON public.users (lower(email)) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS users_username_lower_key ON public.users (lower(username)) WHERE username IS NOT NULL AND deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS users_phone_key ON public.users (phone) WHERE phone IS NOT NULL AND deleted_at IS NULL;
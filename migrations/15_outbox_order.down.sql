@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS outbox_event_ordered ON public.outbox_events;
+DROP FUNCTION IF EXISTS public.order_outbox_event();
@@ -0,0 +1,17 @@
+-- Watch cursors read the outbox by seq and must never see a smaller seq after
+-- a greater one. The seq is assigned under a lock held until commit, so
+-- concurrent writers commit in seq order whichever code inserts the event.
+CREATE OR REPLACE FUNCTION public.order_outbox_event() RETURNS TRIGGER AS
+$$
+BEGIN
+    PERFORM pg_advisory_xact_lock(1869968482); -- 'outb'
+    NEW.seq := nextval(pg_get_serial_sequence('public.outbox_events', 'seq'));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER outbox_event_ordered
+    BEFORE INSERT
+    ON public.outbox_events
+    FOR EACH ROW
+EXECUTE FUNCTION public.order_outbox_event();
@@ -0,0 +1,3 @@
+ALTER TABLE public.audit_events
+    DROP COLUMN IF EXISTS personal_digest,
+    DROP COLUMN IF EXISTS personal_salt;
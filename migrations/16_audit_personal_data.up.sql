@@ -0,0 +1,3 @@
+ALTER TABLE public.audit_events
+    ADD COLUMN IF NOT EXISTS personal_salt   BYTEA,
+    ADD COLUMN IF NOT EXISTS personal_digest BYTEA;
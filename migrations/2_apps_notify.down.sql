@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS apps_truncated ON public.apps;
+DROP TRIGGER IF EXISTS apps_changed ON public.apps;
+DROP FUNCTION IF EXISTS public.notify_apps_changed();
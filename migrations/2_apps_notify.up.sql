@@ -0,0 +1,25 @@
+CREATE OR REPLACE FUNCTION public.notify_apps_changed() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF TG_OP = 'TRUNCATE' THEN
+        PERFORM pg_notify('apps_changed', '*');
+    ELSIF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('apps_changed', OLD.id::TEXT);
+    ELSE
+        PERFORM pg_notify('apps_changed', NEW.id::TEXT);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER apps_changed
+    AFTER INSERT OR UPDATE OR DELETE
+    ON public.apps
+    FOR EACH ROW
+EXECUTE FUNCTION public.notify_apps_changed();
+
+CREATE TRIGGER apps_truncated
+    AFTER TRUNCATE
+    ON public.apps
+    FOR EACH STATEMENT
+EXECUTE FUNCTION public.notify_apps_changed();
@@ -0,0 +1,5 @@
+ALTER TABLE public.apps
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS enabled,
+    DROP COLUMN IF EXISTS token_ttl_seconds,
+    DROP COLUMN IF EXISTS redirect_uris;
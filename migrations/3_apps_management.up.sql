@@ -0,0 +1,5 @@
+ALTER TABLE public.apps
+    ADD COLUMN redirect_uris     JSONB     NOT NULL DEFAULT '[]',
+    ADD COLUMN token_ttl_seconds INTEGER   NOT NULL DEFAULT 0,
+    ADD COLUMN enabled           BOOLEAN   NOT NULL DEFAULT TRUE,
+    ADD COLUMN updated_at        TIMESTAMP NOT NULL DEFAULT NOW();
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS public.users_deleted_at_idx;
+DROP INDEX IF EXISTS public.users_email_prefix_idx;
+
+ALTER TABLE public.users
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS disabled_at;
@@ -0,0 +1,6 @@
+ALTER TABLE public.users
+    ADD COLUMN disabled_at TIMESTAMP,
+    ADD COLUMN deleted_at  TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS users_email_prefix_idx ON public.users (email text_pattern_ops);
+CREATE INDEX IF NOT EXISTS users_deleted_at_idx ON public.users (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE public.apps
+    DROP COLUMN IF EXISTS claim_rules;
+
+DROP TABLE IF EXISTS public.user_app_metadata;
+DROP TABLE IF EXISTS public.user_profiles;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS public.user_profiles
+(
+    user_id      INTEGER PRIMARY KEY REFERENCES public.users (id) ON DELETE CASCADE,
+    display_name TEXT      NOT NULL DEFAULT '',
+    locale       TEXT      NOT NULL DEFAULT '',
+    timezone     TEXT      NOT NULL DEFAULT '',
+    avatar_url   TEXT      NOT NULL DEFAULT '',
+    updated_at   TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS public.user_app_metadata
+(
+    user_id    INTEGER   NOT NULL REFERENCES public.users (id) ON DELETE CASCADE,
+    app_id     INTEGER   NOT NULL REFERENCES public.apps (id) ON DELETE CASCADE,
+    metadata   JSONB     NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, app_id)
+);
+
+ALTER TABLE public.apps
+    ADD COLUMN claim_rules JSONB NOT NULL DEFAULT '[]';
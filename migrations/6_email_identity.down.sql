@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS public.users_phone_key;
+DROP INDEX IF EXISTS public.users_username_lower_key;
+
+ALTER TABLE public.users
+    DROP COLUMN IF EXISTS phone,
+    DROP COLUMN IF EXISTS username;
+
+DROP INDEX IF EXISTS public.users_email_lower_key;
@@ -0,0 +1,36 @@
+-- Emails differing only in case or surrounding spaces belong to the same
+-- mailbox. Such accounts have to be merged by hand before the unique index
+-- below can be created, so list them and abort. Converting internationalized
+-- domains to IDNA ASCII is not possible here, cmd/migrator does it after the
+-- migrations.
+DO
+$$
+    DECLARE
+        collisions TEXT;
+    BEGIN
+        SELECT string_agg(format('%s (user ids %s)', normalized, ids), '; ')
+        INTO collisions
+        FROM (SELECT lower(btrim(email))                   AS normalized,
+                     string_agg(id::TEXT, ', ' ORDER BY id) AS ids
+              FROM public.users
+              GROUP BY 1
+              HAVING count(*) > 1) AS duplicates;
+
+        IF collisions IS NOT NULL THEN
+            RAISE EXCEPTION 'users with case-insensitively equal emails must be merged first: %', collisions;
+        END IF;
+    END
+$$;
+
+UPDATE public.users
+SET email = lower(btrim(email))
+WHERE email <> lower(btrim(email));
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_lower_key ON public.users (lower(email));
+
+ALTER TABLE public.users
+    ADD COLUMN username TEXT,
+    ADD COLUMN phone    TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_username_lower_key ON public.users (lower(username)) WHERE username IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS users_phone_key ON public.users (phone) WHERE phone IS NOT NULL;
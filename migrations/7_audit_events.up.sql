@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS public.audit_events
+(
+    id          BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMP NOT NULL,
+    action      TEXT      NOT NULL,
+    actor_id    INTEGER,
+    subject_id  INTEGER,
+    app_id      INTEGER,
+    ip          TEXT      NOT NULL DEFAULT '',
+    user_agent  TEXT      NOT NULL DEFAULT '',
+    outcome     TEXT      NOT NULL,
+    reason      TEXT      NOT NULL DEFAULT '',
+    prev_hash   BYTEA,
+    hash        BYTEA     NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_subject_id_idx ON public.audit_events (subject_id);
+CREATE INDEX IF NOT EXISTS audit_events_occurred_at_idx ON public.audit_events (occurred_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS public.known_devices;
+DROP TABLE IF EXISTS public.login_attempts;
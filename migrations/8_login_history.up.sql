@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS public.login_attempts
+(
+    id          BIGSERIAL PRIMARY KEY,
+    user_id     INTEGER REFERENCES public.users (id) ON DELETE CASCADE,
+    app_id      INTEGER,
+    login       TEXT      NOT NULL,
+    ip          TEXT      NOT NULL DEFAULT '',
+    user_agent  TEXT      NOT NULL DEFAULT '',
+    device_id   TEXT      NOT NULL DEFAULT '',
+    success     BOOLEAN   NOT NULL,
+    reason      TEXT      NOT NULL DEFAULT '',
+    occurred_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS login_attempts_user_id_idx ON public.login_attempts (user_id, id);
+
+CREATE TABLE IF NOT EXISTS public.known_devices
+(
+    user_id       INTEGER   NOT NULL REFERENCES public.users (id) ON DELETE CASCADE,
+    device_id     TEXT      NOT NULL,
+    ip            TEXT      NOT NULL DEFAULT '',
+    user_agent    TEXT      NOT NULL DEFAULT '',
+    first_seen_at TIMESTAMP NOT NULL,
+    last_seen_at  TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, device_id)
+);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS public.sessions
+(
+    id           TEXT PRIMARY KEY,
+    user_id      INTEGER   NOT NULL REFERENCES public.users (id) ON DELETE CASCADE,
+    app_id       INTEGER   NOT NULL,
+    device_id    TEXT      NOT NULL DEFAULT '',
+    ip           TEXT      NOT NULL DEFAULT '',
+    user_agent   TEXT      NOT NULL DEFAULT '',
+    created_at   TIMESTAMP NOT NULL,
+    last_seen_at TIMESTAMP NOT NULL,
+    expires_at   TIMESTAMP NOT NULL,
+    revoked_at   TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON public.sessions (user_id);
+CREATE INDEX IF NOT EXISTS sessions_expires_at_idx ON public.sessions (expires_at);
@@ -0,0 +1,44 @@
+package tests
+
+import (
+	"context"
+	"sso/tests/suite"
+	"sync/atomic"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	ssov1 "github.com/makar182/protos/gen/sso"
+	"github.com/stretchr/testify/require"
+)
+
+const benchUsers = 32
+
+// BenchmarkLogin_Concurrent logs in from parallel clients. Every login
+// appends to the audit chain under one advisory lock, comparing ns/op with
+// -cpu 1 and -cpu 8 shows what waiting for it costs:
+//
+//	go test ./tests -run '^$' -bench Login_Concurrent -cpu 1,8
+func BenchmarkLogin_Concurrent(b *testing.B) {
+	ctx := context.Background()
+	_, client := suite.NewAuthClient(b)
+
+	// distinct users, so the logins only share the audit lock
+	users := make([]*ssov1.LoginRequest, benchUsers)
+	for i := range users {
+		users[i] = &ssov1.LoginRequest{Email: gofakeit.Email(), Password: randomPassword(), AppId: appId}
+		_, err := client.Register(ctx, &ssov1.RegisterRequest{Email: users[i].Email, Password: users[i].Password})
+		require.NoError(b, err)
+	}
+
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		user := users[next.Add(1)%benchUsers]
+		for pb.Next() {
+			if _, err := client.Login(ctx, user); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
@@ -6,8 +6,11 @@ import (
 	ssov1 "github.com/makar182/protos/gen/sso"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"sso/internal/services/auth"
 	"sso/tests/suite"
+	"strings"
 	"testing"
 	"time"
 )
@@ -85,6 +88,12 @@ func TestRegister_FailCases(t *testing.T) {
 			password:    "",
 			expectedErr: "email and password must be provided",
 		},
+		{
+			name:        "Invalid email",
+			email:       "not-an-email",
+			password:    randomPassword(),
+			expectedErr: "email is invalid",
+		},
 	}
 
 	for _, tt := range tests {
@@ -135,7 +144,7 @@ func TestLogin_FailCases(t *testing.T) {
 			email:       gofakeit.Email(),
 			password:    randomPassword(),
 			appId:       appId,
-			expectedErr: "failed to login: " + auth.ErrInternalServerError.Error(),
+			expectedErr: "failed to login: " + auth.ErrInvalidCredentials.Error(),
 		},
 	}
 
@@ -182,5 +191,35 @@ func TestRegister_Duplication(t *testing.T) {
 		Password: password,
 	})
 
-	assert.ErrorContains(t, err, "failed to register: "+auth.ErrInternalServerError.Error())
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	assert.ErrorContains(t, err, "failed to register: "+auth.ErrUserAlreadyExists.Error())
+}
+
+func TestRegister_EmailIsCaseInsensitive(t *testing.T) {
+	ctx, st := suite.NewSuite(t)
+
+	email := gofakeit.Email()
+	password := randomPassword()
+
+	regResp, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: password,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, regResp.GetUserId())
+
+	// The same mailbox spelled differently is the same account
+	_, err = st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    "  " + strings.ToUpper(email),
+		Password: password,
+	})
+	assert.Error(t, err)
+
+	loginResp, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    strings.ToUpper(email),
+		Password: password,
+		AppId:    appId,
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, loginResp.GetToken())
 }
@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"net/http"
+	"sso/tests/suite"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	ssov1 "github.com/makar182/protos/gen/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tokenRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password,omitempty"`
+}
+
+type tokenResponse struct {
+	Token string `json:"token"`
+}
+
+type validateTokenResponse struct {
+	UserId    int64     `json:"userId"`
+	Email     string    `json:"email"`
+	AppId     int64     `json:"appId"`
+	SessionId string    `json:"sessionId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func TestTokens_ValidateRefreshStepUp(t *testing.T) {
+	ctx, st := suite.NewSuite(t)
+
+	email := gofakeit.Email()
+	password := randomPassword()
+
+	regResp, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	var login tokenResponse
+	code := st.CallGateway(ctx, http.MethodPost, "/v1/auth/login", map[string]any{"email": email, "password": password, "appId": appId}, &login)
+	require.Equal(t, http.StatusOK, code)
+	require.NotEmpty(t, login.Token)
+
+	var claims validateTokenResponse
+	code = st.CallGateway(ctx, http.MethodPost, "/v1/auth/validate", tokenRequest{Token: login.Token}, &claims)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, regResp.GetUserId(), claims.UserId)
+	assert.Equal(t, email, claims.Email)
+	assert.Equal(t, int64(appId), claims.AppId)
+	assert.NotEmpty(t, claims.SessionId)
+	assert.True(t, claims.ExpiresAt.After(time.Now()))
+
+	var refreshed tokenResponse
+	code = st.CallGateway(ctx, http.MethodPost, "/v1/auth/refresh", tokenRequest{Token: login.Token}, &refreshed)
+	require.Equal(t, http.StatusOK, code)
+	require.NotEmpty(t, refreshed.Token)
+
+	var refreshedClaims validateTokenResponse
+	code = st.CallGateway(ctx, http.MethodPost, "/v1/auth/validate", tokenRequest{Token: refreshed.Token}, &refreshedClaims)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, claims.SessionId, refreshedClaims.SessionId, "a refreshed token keeps the session")
+
+	code = st.CallGateway(ctx, http.MethodPost, "/v1/auth/step-up", tokenRequest{Token: refreshed.Token, Password: "wrong-" + password}, nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	var steppedUp tokenResponse
+	code = st.CallGateway(ctx, http.MethodPost, "/v1/auth/step-up", tokenRequest{Token: refreshed.Token, Password: password}, &steppedUp)
+	require.Equal(t, http.StatusOK, code)
+	require.NotEmpty(t, steppedUp.Token)
+
+	_, err = st.AuthClient.Logout(ctx, &ssov1.LogoutRequest{Token: steppedUp.Token})
+	require.NoError(t, err)
+
+	code = st.CallGateway(ctx, http.MethodPost, "/v1/auth/validate", tokenRequest{Token: login.Token}, nil)
+	assert.Equal(t, http.StatusUnauthorized, code, "tokens of a revoked session are rejected")
+	code = st.CallGateway(ctx, http.MethodPost, "/v1/auth/refresh", tokenRequest{Token: steppedUp.Token}, nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestTokens_InvalidToken(t *testing.T) {
+	ctx, st := suite.NewSuite(t)
+
+	code := st.CallGateway(ctx, http.MethodPost, "/v1/auth/validate", tokenRequest{Token: "not-a-token"}, nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code = st.CallGateway(ctx, http.MethodPost, "/v1/auth/refresh", tokenRequest{}, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
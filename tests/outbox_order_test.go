@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"sso/tests/suite"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOutbox_InterleavedTransactions checks that an event inserted later
+// can not commit before an earlier one with a smaller seq, which would let a
+// watcher move its cursor past the earlier event.
+func TestOutbox_InterleavedTransactions(t *testing.T) {
+	ctx, st := suite.NewSuite(t)
+
+	db, err := sql.Open("pgx", st.Cfg.StoragePath)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	eventType := "test.interleaved." + gofakeit.UUID()
+	t.Cleanup(func() {
+		_, _ = db.ExecContext(context.Background(), "DELETE FROM outbox_events WHERE type = $1", eventType)
+	})
+
+	// never due, so the relay leaves the events alone
+	insert := func(ctx context.Context, tx *sql.Tx) (int64, error) {
+		var seq int64
+		err := tx.QueryRowContext(ctx,
+			"INSERT INTO outbox_events(type, payload, occurred_at, next_attempt_at) VALUES ($1, '{}', now(), 'infinity') RETURNING seq",
+			eventType,
+		).Scan(&seq)
+		return seq, err
+	}
+	visible := func() []int64 {
+		rows, err := db.QueryContext(ctx, "SELECT seq FROM outbox_events WHERE type = $1 ORDER BY seq", eventType)
+		require.NoError(t, err)
+		defer rows.Close()
+		var seqs []int64
+		for rows.Next() {
+			var seq int64
+			require.NoError(t, rows.Scan(&seq))
+			seqs = append(seqs, seq)
+		}
+		require.NoError(t, rows.Err())
+		return seqs
+	}
+
+	first, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer first.Rollback()
+	second, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer second.Rollback()
+
+	firstSeq, err := insert(ctx, first)
+	require.NoError(t, err)
+
+	type result struct {
+		seq int64
+		err error
+	}
+	inserted := make(chan result, 1)
+	go func() {
+		seq, err := insert(ctx, second)
+		inserted <- result{seq: seq, err: err}
+	}()
+
+	// the second insert waits for the first transaction to end
+	select {
+	case <-inserted:
+		t.Fatal("second insert did not wait for the first transaction")
+	case <-time.After(300 * time.Millisecond):
+	}
+	assert.Empty(t, visible())
+
+	require.NoError(t, first.Commit())
+	res := <-inserted
+	require.NoError(t, res.err)
+	assert.Greater(t, res.seq, firstSeq)
+	assert.Equal(t, []int64{firstSeq}, visible())
+
+	require.NoError(t, second.Commit())
+	assert.Equal(t, []int64{firstSeq, res.seq}, visible())
+}
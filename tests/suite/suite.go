@@ -1,11 +1,18 @@
 package suite
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	ssov1 "github.com/makar182/protos/gen/sso"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"net"
+	"net/http"
+	"os"
 	"sso/internal/config"
 	"strconv"
 	"testing"
@@ -17,13 +24,15 @@ type Suite struct {
 	*testing.T
 	Cfg        *config.Config
 	AuthClient ssov1.AuthClient
+	// GatewayURL is the base URL of the HTTP gateway.
+	GatewayURL string
 }
 
 func NewSuite(t *testing.T) (context.Context, *Suite) {
 	t.Helper()
 	t.Parallel()
 
-	cfg := config.MustLoadByPath("../config/local_tests.yaml")
+	cfg, client := NewAuthClient(t)
 
 	ctx, cancelCtx := context.WithTimeout(context.Background(), cfg.GRPC.Timeout)
 
@@ -32,18 +41,78 @@ func NewSuite(t *testing.T) (context.Context, *Suite) {
 			t.Helper()
 			cancelCtx()
 		})
-	cc, err := grpc.DialContext(context.Background(), grpcAddress(cfg), grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		t.Fatalf("failed to connect to gRPC server: %v", err)
-	}
 
 	return ctx, &Suite{
 		T:          t,
 		Cfg:        cfg,
-		AuthClient: ssov1.NewAuthClient(cc),
+		AuthClient: client,
+		GatewayURL: "http://" + cfg.Gateway.Address,
 	}
 }
 
+// NewAuthClient connects to the service under test without a Suite, e.g. for
+// benchmarks.
+func NewAuthClient(t testing.TB) (*config.Config, ssov1.AuthClient) {
+	t.Helper()
+
+	cfg := config.MustLoadByPath("../config/local_tests.yaml")
+
+	cc, err := grpc.DialContext(context.Background(), grpcAddress(cfg), grpc.WithTransportCredentials(transportCredentials(t, cfg)))
+	if err != nil {
+		t.Fatalf("failed to connect to gRPC server: %v", err)
+	}
+	t.Cleanup(func() { _ = cc.Close() })
+
+	return cfg, ssov1.NewAuthClient(cc)
+}
+
+// transportCredentials dials with TLS when the server has a certificate,
+// trusting that certificate, e.g. a self-signed one in the test environment.
+func transportCredentials(t testing.TB, cfg *config.Config) credentials.TransportCredentials {
+	if cfg.GRPC.TLS.CertFile == "" {
+		return insecure.NewCredentials()
+	}
+
+	pem, err := os.ReadFile(cfg.GRPC.TLS.CertFile)
+	if err != nil {
+		t.Fatalf("failed to read server certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		t.Fatalf("no certificates in %s", cfg.GRPC.TLS.CertFile)
+	}
+	return credentials.NewTLS(&tls.Config{RootCAs: roots, ServerName: grpcHost, MinVersion: tls.VersionTLS12})
+}
+
 func grpcAddress(cfg *config.Config) string {
 	return net.JoinHostPort(grpcHost, strconv.Itoa(cfg.GRPC.Port))
 }
+
+// CallGateway sends req as JSON to the gateway and decodes the response into
+// resp when the status is 200 OK. It returns the HTTP status code.
+func (s *Suite) CallGateway(ctx context.Context, method, path string, req, resp any) int {
+	s.Helper()
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		s.Fatalf("failed to encode gateway request: %v", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, s.GatewayURL+path, bytes.NewReader(body))
+	if err != nil {
+		s.Fatalf("failed to create gateway request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		s.Fatalf("failed to call gateway: %v", err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode == http.StatusOK && resp != nil {
+		if err := json.NewDecoder(httpResp.Body).Decode(resp); err != nil {
+			s.Fatalf("failed to decode response of %s %s: %v", method, path, err)
+		}
+	}
+	return httpResp.StatusCode
+}